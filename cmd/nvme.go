@@ -15,10 +15,17 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"runtime"
+	"strconv"
 	"unsafe"
 
 	"github.com/dswarbrick/go-nvme/nvme"
@@ -69,12 +76,55 @@ func checkCaps() {
 }
 
 func main() {
-	fmt.Println("Go nvme Reference Implementation")
-	fmt.Printf("Built with %s on %s (%s)\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
-
 	device := flag.String("device", "", "NVMe device from which to read SMART attributes, e.g. /dev/nvme0")
+	selfTest := flag.String("t", "", "Start a device self-test (short, extended, vendor), or abort a running self-test (abort)")
+	tempUnit := flag.String("temp-unit", "celsius", "Temperature unit (celsius, fahrenheit, kelvin)")
+	profile := flag.String("profile", "", "Collect data according to a profile (minimal, standard, deep)")
+	scrub := flag.Bool("scrub", false, "Scrub the device's namespace, checking that all blocks are readable")
+	scrubRate := flag.Uint64("scrub-rate", 0, "Maximum scrub rate in MB/s (0 for unlimited)")
+	checkpoint := flag.String("checkpoint", "", "File in which to save and from which to resume scrub progress")
+	bundle := flag.String("bundle", "", "File in which to save the data collected with -profile as a support bundle")
+	analyze := flag.String("analyze", "", "Print a previously saved support bundle or raw binary file, without accessing a device")
+	analyzeType := flag.String("analyze-type", "bundle", "Type of the -analyze file (bundle, id-ctrl, id-ns, smart, error, telemetry)")
+	analyzeFormat := flag.String("analyze-format", "text", "Output format of -analyze (text, json)")
+	effects := flag.Bool("effects", false, "Print the commands supported by the controller and their effects")
+	showRegs := flag.Bool("show-regs", false, "Print the controller registers (requires root, PCIe controllers only)")
+	telemetry := flag.String("telemetry", "", "File in which to save newly captured host-initiated telemetry data")
+	telemetryArea := flag.Int("telemetry-area", 3, "Last telemetry data area (1-4) to save with -telemetry")
+	vendorLog := flag.String("vendor-log", "", "Print a vendor specific log page (use \"list\" to show those available)")
+	vendorCmd := flag.String("vendor-cmd", "", "Run a vendor specific command with the remaining arguments (use \"list\" to show those available)")
+	rawBinary := flag.String("raw-binary", "", "File in which to save the raw data of -raw-log or -raw-identify")
+	rawLog := flag.String("raw-log", "", "Log page identifier to save with -raw-binary, e.g. 0x02")
+	rawIdentify := flag.String("raw-identify", "", "Identify CNS value to save with -raw-binary, e.g. 0x01")
+	rawLen := flag.Int("raw-len", 0, "Length in bytes of the log page saved with -raw-log (0 for its default length)")
+	parse := flag.String("parse", "", "Print a raw binary file previously saved from a device, without accessing a device")
+	parseType := flag.String("parse-type", "smart", "Type of the -parse file (id-ctrl, id-ns, smart, error, telemetry)")
 	flag.Parse()
 
+	// Keep JSON reports free of the banner, so that they can be piped into other tools.
+	if *analyzeFormat != "json" {
+		fmt.Println("Go nvme Reference Implementation")
+		fmt.Printf("Built with %s on %s (%s)\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	}
+
+	// Offline reports are printed in the selected unit too
+	unit, err := nvme.ParseTemperatureUnit(*tempUnit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	nvme.SetTemperatureUnit(unit)
+
+	if *analyze != "" {
+		runAnalyze(*analyze, *analyzeType, *analyzeFormat)
+		return
+	}
+
+	if *parse != "" {
+		runAnalyze(*parse, *parseType, "text")
+		return
+	}
+
 	checkCaps()
 
 	if *device == "" {
@@ -89,7 +139,364 @@ func main() {
 	}
 	defer d.Close()
 
+	if *selfTest != "" {
+		runSelfTest(d, *selfTest)
+		return
+	}
+
+	if *effects {
+		l, err := d.GetCommandEffects()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read commands supported and effects log:", err)
+			os.Exit(1)
+		}
+
+		l.Print(os.Stdout)
+		return
+	}
+
+	if *showRegs {
+		regs, err := d.GetRegisters()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read controller registers:", err)
+			os.Exit(1)
+		}
+
+		regs.Print(os.Stdout)
+		return
+	}
+
+	if *telemetry != "" {
+		runTelemetry(d, *telemetry, *telemetryArea)
+		return
+	}
+
+	if *rawBinary != "" {
+		runDump(d, *rawBinary, *rawLog, *rawIdentify, *rawLen)
+		return
+	}
+
+	if *vendorLog != "" {
+		runVendorLog(d, *vendorLog)
+		return
+	}
+
+	if *vendorCmd != "" {
+		runVendorCommand(d, *vendorCmd, flag.Args())
+		return
+	}
+
+	if *profile != "" {
+		runCollect(d, *profile, *bundle)
+		return
+	}
+
+	// Target the device's own namespace if a namespace device was specified
+	nsid, err := d.NamespaceID()
+	if err != nil {
+		nsid = 1
+	}
+
+	if *scrub {
+		runScrub(d, nsid, *scrubRate*1000000, *checkpoint)
+		return
+	}
+
 	d.IdentifyController(os.Stdout)
-	d.IdentifyNamespace(os.Stdout, 1)
+	if err := d.IdentifyNamespace(os.Stdout, nsid); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot identify namespace:", err)
+	}
+
 	d.PrintSMART(os.Stdout)
 }
+
+// runDump saves the raw data of a log page or identify data structure to a file.
+func runDump(d *nvme.NVMeDevice, file, logID, cns string, length int) {
+	if (logID == "") == (cns == "") {
+		fmt.Fprintln(os.Stderr, "Exactly one of -raw-log or -raw-identify must be specified")
+		os.Exit(1)
+	}
+
+	id, err := strconv.ParseUint(logID+cns, 0, 8)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid log page identifier or CNS value:", err)
+		os.Exit(1)
+	}
+
+	nsid, err := d.NamespaceID()
+	if err != nil {
+		nsid = 0xffffffff
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot create raw binary file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if logID != "" {
+		err = d.DumpLogPage(f, uint8(id), 0xffffffff, length)
+	} else {
+		err = d.DumpIdentify(f, uint8(id), nsid)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot read raw data:", err)
+		os.Exit(1)
+	}
+}
+
+// runVendorLog prints a vendor specific log page provided by the device's vendor plugins, or lists
+// the log pages available if name is "list".
+func runVendorLog(d *nvme.NVMeDevice, name string) {
+	if name == "list" {
+		plugins, err := d.VendorPlugins()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot identify controller:", err)
+			os.Exit(1)
+		}
+
+		for _, p := range plugins {
+			for _, l := range p.Logs {
+				fmt.Printf("%-10s %-12s %s\n", p.Name, l.Name, l.Usage)
+			}
+		}
+
+		return
+	}
+
+	if err := d.PrintVendorLog(os.Stdout, name); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot read vendor log page:", err)
+		os.Exit(1)
+	}
+}
+
+// runVendorCommand runs a vendor specific command provided by the device's vendor plugins, or
+// lists the commands available if name is "list".
+func runVendorCommand(d *nvme.NVMeDevice, name string, args []string) {
+	if name == "list" {
+		plugins, err := d.VendorPlugins()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot identify controller:", err)
+			os.Exit(1)
+		}
+
+		for _, p := range plugins {
+			for _, c := range p.Commands {
+				fmt.Printf("%-10s %-12s %s\n", p.Name, c.Name, c.Usage)
+			}
+		}
+
+		return
+	}
+
+	if err := d.RunVendorCommand(os.Stdout, name, args); err != nil {
+		fmt.Fprintln(os.Stderr, "Vendor command failed:", err)
+		os.Exit(1)
+	}
+}
+
+// runCollect gathers and prints the data specified by a collection profile, and saves it as a
+// support bundle if a bundle file is specified.
+func runCollect(d *nvme.NVMeDevice, name, bundle string) {
+	p, err := nvme.LookupProfile(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	c, err := d.Collect(p)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Collection failed:", err)
+		os.Exit(1)
+	}
+
+	c.Print(os.Stdout)
+
+	if bundle == "" {
+		return
+	}
+
+	f, err := os.Create(bundle)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot create support bundle:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if err := c.SaveBundle(f); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot save support bundle:", err)
+		os.Exit(1)
+	}
+}
+
+// runAnalyze prints a support bundle saved by runCollect, or a raw binary file, e.g. as saved with
+// -raw-binary, in the specified format. The file may have been provided by a customer.
+func runAnalyze(file, typ, format string) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot read file:", err)
+		os.Exit(1)
+	}
+
+	var p interface{ Print(io.Writer) }
+
+	switch typ {
+	case "bundle":
+		p, err = nvme.LoadBundle(bytes.NewReader(buf))
+	case "id-ctrl":
+		var c nvme.NVMeController
+		c, err = nvme.ParseIdentifyController(buf)
+		p = &c
+	case "id-ns":
+		var ns nvme.NVMeNamespace
+		ns, err = nvme.ParseIdentifyNamespace(buf, 0)
+		p = &ns
+	case "smart":
+		p, err = nvme.ParseSMARTLog(buf)
+	case "error":
+		p, err = nvme.ParseErrorLog(buf)
+	case "telemetry":
+		p, err = nvme.ParseTelemetryHeader(buf)
+	default:
+		err = fmt.Errorf("unknown file type %q", typ)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch format {
+	case "text":
+		p.Print(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(p); err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot encode report:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", format)
+		os.Exit(1)
+	}
+}
+
+// runTelemetry captures host-initiated telemetry data and saves it to a file for vendor analysis.
+func runTelemetry(d *nvme.NVMeDevice, file string, area int) {
+	f, err := os.Create(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot create telemetry file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	hdr, err := d.CaptureHostTelemetry(f, area)
+	if err != nil {
+		os.Remove(file)
+		fmt.Fprintln(os.Stderr, "Telemetry capture failed:", err)
+		os.Exit(1)
+	}
+
+	hdr.Print(os.Stdout)
+}
+
+// scrubCheckpoint is the content of a scrub checkpoint file.
+type scrubCheckpoint struct {
+	NSID      uint32          `json:"nsid"`
+	Next      uint64          `json:"next"`
+	BadRanges []nvme.LBARange `json:"bad_ranges,omitempty"`
+}
+
+// runScrub scrubs a namespace, resuming from and saving progress to the checkpoint file, if any.
+// The scrub can be interrupted with SIGINT, and later resumed.
+func runScrub(d *nvme.NVMeDevice, nsid uint32, rate uint64, checkpoint string) {
+	opts := nvme.ScrubOptions{RateLimit: rate}
+
+	if checkpoint != "" {
+		var cp scrubCheckpoint
+
+		if buf, err := os.ReadFile(checkpoint); err == nil {
+			if err := json.Unmarshal(buf, &cp); err != nil {
+				fmt.Fprintln(os.Stderr, "Invalid checkpoint file:", err)
+				os.Exit(1)
+			}
+
+			if cp.NSID == nsid {
+				opts.Start = cp.Next
+				opts.BadRanges = cp.BadRanges
+				fmt.Printf("Resuming scrub of namespace %d at LBA %d\n", nsid, cp.Next)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(os.Stderr, "Cannot read checkpoint file:", err)
+			os.Exit(1)
+		}
+
+		opts.Checkpoint = func(r *nvme.ScrubReport) {
+			cp := scrubCheckpoint{NSID: nsid, Next: r.Next, BadRanges: r.BadRanges}
+			if err := saveCheckpoint(checkpoint, &cp); err != nil {
+				fmt.Fprintln(os.Stderr, "Cannot save checkpoint file:", err)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	r, err := d.Scrub(ctx, nsid, opts)
+	if r != nil {
+		r.Print(os.Stdout)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Scrub stopped:", err)
+		os.Exit(1)
+	}
+}
+
+// saveCheckpoint writes a scrub checkpoint to a temporary file, which then replaces the checkpoint
+// file, so that an interrupted write cannot leave a truncated checkpoint behind.
+func saveCheckpoint(name string, cp *scrubCheckpoint) error {
+	buf, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// runSelfTest starts or aborts a device self-test, and prints the self-test log.
+func runSelfTest(d *nvme.NVMeDevice, test string) {
+	var err error
+
+	switch test {
+	case "short":
+		err = d.StartSelfTest(0xffffffff, nvme.SelfTestShort)
+	case "extended", "long":
+		err = d.StartSelfTest(0xffffffff, nvme.SelfTestExtended)
+	case "vendor":
+		err = d.StartSelfTest(0xffffffff, nvme.SelfTestVendor)
+	case "abort":
+		err = d.AbortSelfTest()
+	default:
+		err = fmt.Errorf("unknown self-test %q", test)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Self-test failed:", err)
+		os.Exit(1)
+	}
+
+	if l, err := d.GetSelfTestLog(); err == nil {
+		l.Print(os.Stdout)
+	}
+}
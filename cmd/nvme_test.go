@@ -0,0 +1,61 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dswarbrick/go-nvme/nvme"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeTemperatureUnit(t *testing.T) {
+	assert := assert.New(t)
+
+	// Composite temperature of 310 K
+	smart := make([]byte, 512)
+	smart[1], smart[2] = 0x36, 0x01
+
+	file := filepath.Join(t.TempDir(), "smart.bin")
+	if !assert.NoError(os.WriteFile(file, smart, 0o600)) {
+		return
+	}
+
+	r, w, err := os.Pipe()
+	if !assert.NoError(err) {
+		return
+	}
+
+	origArgs, origStdout := os.Args, os.Stdout
+	t.Cleanup(func() {
+		os.Args, os.Stdout = origArgs, origStdout
+		nvme.SetTemperatureUnit(nvme.Celsius)
+	})
+
+	os.Args = []string{"nvme", "-temp-unit", "fahrenheit", "-analyze", file, "-analyze-type", "smart"}
+	os.Stdout = w
+
+	main()
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if assert.NoError(err) {
+		assert.Contains(string(out), "99 °F")
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command structgen generates the low-level structs of NVMe data structures, along with decoders
+// for them, from a table of fields. Fields are specified by byte range, in the notation used by
+// the NVM Express specifications, so that tables can be checked against the spec at a glance.
+//
+// The table consists of struct definitions of the form:
+//
+//	struct nvmeExampleLog 512 Example log page
+//	01:00   Flags   u16     Flags
+//	15:08   Count   u64     Count
+//	511:16  Vs      bytes   Vendor Specific
+//	end
+//
+// The header specifies the struct name, its size in bytes, and a description. Each field
+// specifies its byte range (the byte offset alone for single bytes), name, type and an optional
+// comment. Types are u8, u16, u32, u64, bytes (a byte array spanning the range), or an array of
+// these or of a previously defined struct, e.g. [8]u16. Gaps between fields are filled with
+// reserved fields. Overlapping fields, fields whose type does not match their byte range, and
+// fields exceeding the struct size are rejected.
+//
+// The generated decoders read the little-endian wire format of NVMe data structures explicitly, so
+// unlike binary.Read with the native byte order, they are correct on big-endian architectures too.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// field is a field of a struct definition.
+type field struct {
+	start, end int    // Byte range, inclusive
+	name       string // Go field name
+	elem       string // Element type: u8, u16, u32, u64, or a struct name
+	count      int    // Array length, 0 if not an array
+	comment    string
+	reserved   bool // Generated to fill a gap
+}
+
+// structDef is a struct definition of the table.
+type structDef struct {
+	name   string
+	desc   string
+	size   int
+	fields []field
+}
+
+var elemSizes = map[string]int{"u8": 1, "u16": 2, "u32": 4, "u64": 8}
+
+var goTypes = map[string]string{"u8": "uint8", "u16": "uint16", "u32": "uint32", "u64": "uint64"}
+
+func main() {
+	in := flag.String("in", "", "Field table")
+	out := flag.String("out", "", "Generated Go source file")
+	pkg := flag.String("pkg", "nvme", "Package name of the generated source")
+	flag.Parse()
+
+	if err := run(*in, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "structgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, pkg string) error {
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	defs, err := parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	src, err := generate(defs, pkg, filepath.Base(in))
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(out, src, 0644)
+}
+
+// parse reads the struct definitions of a field table.
+func parse(r io.Reader) ([]*structDef, error) {
+	var (
+		defs  []*structDef
+		cur   *structDef
+		sizes = make(map[string]int)
+	)
+
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		tokens := strings.Fields(text)
+
+		switch {
+		case tokens[0] == "struct":
+			if cur != nil {
+				return nil, fmt.Errorf("line %d: struct %s not ended", line, cur.name)
+			}
+
+			if len(tokens) < 3 {
+				return nil, fmt.Errorf("line %d: expected struct name and size", line)
+			}
+
+			size, err := strconv.Atoi(tokens[2])
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("line %d: invalid struct size %q", line, tokens[2])
+			}
+
+			cur = &structDef{name: tokens[1], size: size, desc: strings.Join(tokens[3:], " ")}
+
+		case tokens[0] == "end":
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: end without struct", line)
+			}
+
+			if err := cur.fill(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			defs = append(defs, cur)
+			sizes[cur.name] = cur.size
+			cur = nil
+
+		default:
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: field outside struct", line)
+			}
+
+			if len(tokens) < 3 {
+				return nil, fmt.Errorf("line %d: expected byte range, name and type", line)
+			}
+
+			f, err := parseField(tokens, sizes)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			cur.fields = append(cur.fields, f)
+		}
+	}
+
+	if cur != nil {
+		return nil, fmt.Errorf("struct %s not ended", cur.name)
+	}
+
+	return defs, scanner.Err()
+}
+
+// parseField parses a field line, checking that its type matches its byte range.
+func parseField(tokens []string, sizes map[string]int) (field, error) {
+	f := field{name: tokens[1], comment: strings.Join(tokens[3:], " ")}
+
+	var err error
+
+	if hi, lo, ok := strings.Cut(tokens[0], ":"); ok {
+		f.end, err = strconv.Atoi(hi)
+		if err == nil {
+			f.start, err = strconv.Atoi(lo)
+		}
+	} else {
+		f.start, err = strconv.Atoi(tokens[0])
+		f.end = f.start
+	}
+
+	if err != nil || f.start > f.end {
+		return f, fmt.Errorf("invalid byte range %q", tokens[0])
+	}
+
+	width := f.end - f.start + 1
+	typ := tokens[2]
+
+	if typ == "bytes" {
+		f.elem, f.count = "u8", width
+		return f, nil
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		n, elem, ok := strings.Cut(typ[1:], "]")
+		if !ok {
+			return f, fmt.Errorf("invalid type %q", typ)
+		}
+
+		if f.count, err = strconv.Atoi(n); err != nil || f.count <= 0 {
+			return f, fmt.Errorf("invalid array length in %q", typ)
+		}
+
+		typ = elem
+	}
+
+	size, ok := elemSizes[typ]
+	if !ok {
+		if size, ok = sizes[typ]; !ok {
+			return f, fmt.Errorf("unknown type %q", typ)
+		}
+	}
+
+	f.elem = typ
+
+	n := f.count
+	if n == 0 {
+		n = 1
+	}
+
+	if n*size != width {
+		return f, fmt.Errorf("field %s: type %s is %d bytes, but byte range is %d bytes", f.name,
+			tokens[2], n*size, width)
+	}
+
+	return f, nil
+}
+
+// fill checks that the fields are in order and do not overlap, and inserts reserved fields into
+// the gaps between them.
+func (s *structDef) fill() error {
+	var (
+		fields []field
+		next   int
+	)
+
+	for _, f := range append(s.fields, field{start: s.size, end: s.size}) {
+		if f.start < next {
+			if f.name == "" {
+				return fmt.Errorf("struct %s: fields exceed size %d", s.name, s.size)
+			}
+			return fmt.Errorf("struct %s: field %s overlaps previous field", s.name, f.name)
+		}
+
+		if f.start > next {
+			fields = append(fields, field{start: next, end: f.start - 1,
+				name: fmt.Sprintf("Rsvd%d", next), elem: "u8", count: f.start - next,
+				comment: "...", reserved: true})
+		}
+
+		if f.name != "" {
+			fields = append(fields, f)
+		}
+
+		next = f.end + 1
+	}
+
+	s.fields = fields
+
+	return nil
+}
+
+func (f *field) goType() string {
+	elem, ok := goTypes[f.elem]
+	if !ok {
+		elem = f.elem
+	}
+
+	switch {
+	case f.count == 0:
+		return elem
+	case f.elem == "u8":
+		return fmt.Sprintf("[%d]byte", f.count)
+	}
+
+	return fmt.Sprintf("[%d]%s", f.count, elem)
+}
+
+// decodeElem returns the statement decoding a single element at the specified offset expression.
+func decodeElem(dst, elem, off string) string {
+	switch elem {
+	case "u8":
+		return fmt.Sprintf("%s = buf[%s]", dst, off)
+	case "u16", "u32", "u64":
+		return fmt.Sprintf("%s = binary.LittleEndian.Uint%s(buf[%s:])", dst, elem[1:], off)
+	}
+
+	return fmt.Sprintf("%s.unmarshal(buf[%s:])", dst, off)
+}
+
+// generate returns the formatted Go source of the struct definitions and their decoders.
+func generate(defs []*structDef, pkg, source string) ([]byte, error) {
+	var b bytes.Buffer
+
+	for _, s := range defs {
+		fmt.Fprintf(&b, "\n// %s is the low-level struct of the %s.\n", s.name, s.desc)
+		fmt.Fprintf(&b, "type %s struct {\n", s.name)
+
+		for _, f := range s.fields {
+			fmt.Fprintf(&b, "\t%s %s", f.name, f.goType())
+			if f.comment != "" {
+				fmt.Fprintf(&b, " // %s", f.comment)
+			}
+			fmt.Fprintln(&b)
+		}
+
+		fmt.Fprintf(&b, "} // %d bytes\n\n", s.size)
+
+		fmt.Fprintf(&b, "// unmarshal decodes %s from its little-endian wire format. buf must be at least %d\n",
+			s.name, s.size)
+		fmt.Fprintf(&b, "// bytes long.\n")
+		fmt.Fprintf(&b, "func (s *%s) unmarshal(buf []byte) {\n", s.name)
+		fmt.Fprintf(&b, "\t_ = buf[%d]\n", s.size-1)
+
+		for _, f := range s.fields {
+			dst := "s." + f.name
+
+			switch {
+			case f.reserved:
+				continue
+			case f.count == 0:
+				fmt.Fprintln(&b, "\t"+decodeElem(dst, f.elem, strconv.Itoa(f.start)))
+			case f.elem == "u8":
+				fmt.Fprintf(&b, "\tcopy(%s[:], buf[%d:%d])\n", dst, f.start, f.end+1)
+			default:
+				size := (f.end - f.start + 1) / f.count
+				fmt.Fprintf(&b, "\tfor i := range %s {\n", dst)
+				fmt.Fprintf(&b, "\t\t%s\n", decodeElem(dst+"[i]", f.elem,
+					fmt.Sprintf("%d+%d*i", f.start, size)))
+				fmt.Fprintf(&b, "\t}\n")
+			}
+		}
+
+		fmt.Fprintf(&b, "}\n")
+	}
+
+	var src bytes.Buffer
+
+	fmt.Fprintf(&src, "// Code generated by structgen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&src, "package %s\n", pkg)
+
+	if bytes.Contains(b.Bytes(), []byte("binary.")) {
+		fmt.Fprintf(&src, "\nimport \"encoding/binary\"\n")
+	}
+
+	src.Write(b.Bytes())
+
+	return format.Source(src.Bytes())
+}
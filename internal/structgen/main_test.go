@@ -0,0 +1,91 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	defs, err := parse(strings.NewReader(`
+# Comment
+struct nvmeEntry 4 Entry
+01:00   ID      u16     Identifier
+end
+
+struct nvmeList 16 List
+0       Count   u8
+15:08   Entries [2]nvmeEntry
+end
+`))
+	if !assert.NoError(err) || !assert.Len(defs, 2) {
+		return
+	}
+
+	// Reserved fields are inserted into gaps
+	assert.Equal([]string{"Count", "Rsvd1", "Entries"},
+		[]string{defs[1].fields[0].name, defs[1].fields[1].name, defs[1].fields[2].name})
+	assert.Equal("[7]byte", defs[1].fields[1].goType())
+	assert.Equal("[2]nvmeEntry", defs[1].fields[2].goType())
+
+	src, err := generate(defs, "nvme", "test.def")
+	if assert.NoError(err) {
+		assert.Contains(string(src), "s.ID = binary.LittleEndian.Uint16(buf[0:])")
+		assert.Contains(string(src), "s.Entries[i].unmarshal(buf[8+4*i:])")
+	}
+
+	for _, table := range []string{
+		"struct s 4\n01:00 A u32\nend\n",         // Type does not match range
+		"struct s 4\n01:00 A u16\n0 B u8\nend\n", // Overlap
+		"struct s 4\n04 A u8\nend\n",             // Exceeds size
+		"struct s 4\n0 A nvmeFoo\nend\n",         // Unknown type
+		"struct s 4\n0 A u8\n",                   // Not ended
+	} {
+		_, err := parse(strings.NewReader(table))
+		assert.Error(err, table)
+	}
+}
+
+// TestGenerated checks that the generated nvme structs are up to date with their field table.
+func TestGenerated(t *testing.T) {
+	f, err := os.Open("../../nvme/structs.def")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	defs, err := parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := generate(defs, "nvme", "structs.def")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := os.ReadFile("../../nvme/structs_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(src), string(current), "structs_gen.go is stale, run go generate")
+}
@@ -48,6 +48,12 @@ func _ioc(dir, t, nr, size uintptr) uintptr {
 	return (dir << directionShift) | (t << typeShift) | (nr << numberShift) | (size << sizeShift)
 }
 
+// Io calculates the ioctl command for an ioctl of the specified type and number, which takes no
+// argument
+func Io(t, nr uintptr) uintptr {
+	return _ioc(directionNone, t, nr, 0)
+}
+
 // Ior calculates the ioctl command for a read-ioctl of the specified type, number and size
 func Ior(t, nr, size uintptr) uintptr {
 	return _ioc(directionRead, t, nr, size)
@@ -65,9 +71,16 @@ func Iowr(t, nr, size uintptr) uintptr {
 
 // ioctl executes an ioctl command on the specified file descriptor
 func Ioctl(fd, cmd, ptr uintptr) error {
-	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, cmd, ptr)
+	_, err := IoctlRet(fd, cmd, ptr)
+	return err
+}
+
+// IoctlRet executes an ioctl command on the specified file descriptor, and additionally returns
+// the (non-negative) return value of the syscall, which some drivers use to convey a status
+func IoctlRet(fd, cmd, ptr uintptr) (uintptr, error) {
+	r1, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, cmd, ptr)
 	if errno != 0 {
-		return errno
+		return 0, errno
 	}
-	return nil
+	return r1, nil
 }
@@ -0,0 +1,34 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+// Abort requests the controller to abort the command identified by the submission queue ID (0 for
+// the admin submission queue) and command identifier. Aborting is best effort: the returned bool
+// reports whether the controller aborted the command, which otherwise may still complete normally.
+// Note that the kernel assigns the SQID and CID of passthrough commands, which callers can only
+// learn from sources such as the error log or tracing.
+func (d *NVMeDevice) Abort(sqid, cid uint16) (bool, error) {
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_ABORT,
+		cdw10:  uint32(sqid) | uint32(cid)<<16,
+	}
+
+	if err := d.adminCmd(&cmd, nil); err != nil {
+		return false, err
+	}
+
+	// Bit 0 of completion queue entry dword 0 is cleared if the command was aborted
+	return cmd.result&1 == 0, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// cmicANA is the Asymmetric Namespace Access Reporting support bit of the CMIC field.
+const cmicANA = 1 << 3
+
+// ANAState is the Asymmetric Namespace Access state of an ANA group, i.e. of the paths to its
+// namespaces through a controller.
+type ANAState uint8
+
+const (
+	ANAOptimized      ANAState = 0x1
+	ANANonOptimized   ANAState = 0x2
+	ANAInaccessible   ANAState = 0x3
+	ANAPersistentLoss ANAState = 0x4
+	ANAChange         ANAState = 0xf
+)
+
+func (s ANAState) String() string {
+	switch s {
+	case ANAOptimized:
+		return "optimized"
+	case ANANonOptimized:
+		return "non-optimized"
+	case ANAInaccessible:
+		return "inaccessible"
+	case ANAPersistentLoss:
+		return "persistent loss"
+	case ANAChange:
+		return "change"
+	}
+
+	return fmt.Sprintf("unknown (%#x)", uint8(s))
+}
+
+// ANAGroup is an ANA group descriptor, listing the namespaces of the group.
+type ANAGroup struct {
+	GroupID     uint32
+	ChangeCount uint64
+	State       ANAState
+	NSIDs       []uint32 // Empty if only the groups were requested
+}
+
+// ANALog is the decoded Asymmetric Namespace Access log page (0x0C).
+type ANALog struct {
+	ChangeCount uint64
+	Groups      []ANAGroup
+}
+
+// Print outputs the ANA log in a pretty-print style.
+func (l *ANALog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgANAHeader), l.ChangeCount, len(l.Groups))
+
+	for _, g := range l.Groups {
+		fmt.Fprintf(w, msg(MsgANAGroup), g.GroupID, g.State, g.ChangeCount, g.NSIDs)
+	}
+}
+
+// NamespaceState returns the ANA state of the specified namespace, and whether the namespace was
+// found in any of the groups.
+func (l *ANALog) NamespaceState(nsid uint32) (ANAState, bool) {
+	for _, g := range l.Groups {
+		for _, id := range g.NSIDs {
+			if id == nsid {
+				return g.State, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// GetANALog reads the Asymmetric Namespace Access log page, which reports the ANA state of each
+// ANA group, i.e. whether the namespaces in the group are optimally accessible through this
+// controller. If groupsOnly is true, the namespace lists are omitted.
+func (d *NVMeDevice) GetANALog(groupsOnly bool) (*ANALog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Cmic&cmicANA == 0 {
+		return nil, fmt.Errorf("ANA log: %w", ErrNotSupported)
+	}
+
+	// Size the buffer for the maximum number of groups, and of namespaces if requested
+	length := 16 + 32*int(idCtrlr.Nanagrpid)
+
+	args := logPageArgs{nsid: 0xffffffff}
+	if groupsOnly {
+		args.lsp = 1 // Return Groups Only (RGO)
+	} else {
+		length += 4 * int(idCtrlr.Nn)
+	}
+
+	buf, err := d.readLog(NVME_LOG_ANA, args, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeANALog(buf)
+}
+
+func decodeANALog(buf []byte) (*ANALog, error) {
+	if len(buf) < 16 {
+		return nil, fmt.Errorf("ANA log truncated")
+	}
+
+	var h nvmeANALogHeader
+
+	h.unmarshal(buf)
+
+	l := &ANALog{ChangeCount: h.Chgcnt}
+
+	off := 16
+
+	for i := 0; i < int(h.Ngrps); i++ {
+		if off+32 > len(buf) {
+			return nil, fmt.Errorf("ANA log truncated at group descriptor %d", i)
+		}
+
+		var desc nvmeANAGroupDesc
+
+		desc.unmarshal(buf[off:])
+		off += 32
+
+		if off+4*int(desc.Nnsids) > len(buf) {
+			return nil, fmt.Errorf("ANA log truncated in group %d", desc.Grpid)
+		}
+
+		g := ANAGroup{GroupID: desc.Grpid, ChangeCount: desc.Chgcnt, State: ANAState(desc.State & 0xf)}
+
+		for j := 0; j < int(desc.Nnsids); j++ {
+			g.NSIDs = append(g.NSIDs, binary.LittleEndian.Uint32(buf[off:]))
+			off += 4
+		}
+
+		l.Groups = append(l.Groups, g)
+	}
+
+	return l, nil
+}
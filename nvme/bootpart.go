@@ -0,0 +1,107 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// bootPartitionUnit is the unit of the Boot Partition Size field.
+const bootPartitionUnit = 128 << 10
+
+// BootPartitionInfo describes the boot partitions of a controller.
+type BootPartitionInfo struct {
+	Size   uint64 // Size of each boot partition, in bytes
+	Active uint8  // Identifier of the active boot partition (0 or 1)
+}
+
+// GetBootPartitionInfo reads the header of the Boot Partition log page. A Size of zero indicates
+// that the controller does not support boot partitions.
+func (d *NVMeDevice) GetBootPartitionInfo() (*BootPartitionInfo, error) {
+	return d.bootPartitionInfo(0)
+}
+
+func (d *NVMeDevice) bootPartitionInfo(bpid uint8) (*BootPartitionInfo, error) {
+	buf := make([]byte, 16)
+
+	if err := d.getLog(NVME_LOG_BOOT_PARTITION, logPageArgs{lsp: bpid & 1}, buf); err != nil {
+		return nil, err
+	}
+
+	var hdr nvmeBootPartitionHeader
+
+	hdr.unmarshal(buf)
+
+	return &BootPartitionInfo{
+		Size:   uint64(hdr.Bpinfo&0x7fff) * bootPartitionUnit,
+		Active: uint8(hdr.Bpinfo >> 31),
+	}, nil
+}
+
+// ReadBootPartition writes the image of the specified boot partition to w, returning the number
+// of bytes written. The image is read from the Boot Partition log page in chunks.
+func (d *NVMeDevice) ReadBootPartition(bpid uint8, w io.Writer) (int64, error) {
+	if bpid > 1 {
+		return 0, fmt.Errorf("invalid boot partition identifier %d", bpid)
+	}
+
+	info, err := d.bootPartitionInfo(bpid)
+	if err != nil {
+		return 0, err
+	}
+
+	if info.Size == 0 {
+		return 0, fmt.Errorf("boot partitions: %w", ErrNotSupported)
+	}
+
+	buf := make([]byte, 0x4000)
+
+	var written int64
+
+	// The image follows the 16 byte header
+	for off := uint64(0); off < info.Size; off += uint64(len(buf)) {
+		chunk := buf
+		if info.Size-off < uint64(len(chunk)) {
+			chunk = chunk[:info.Size-off]
+		}
+
+		args := logPageArgs{lsp: bpid, offset: 16 + off}
+
+		if err := d.getLog(NVME_LOG_BOOT_PARTITION, args, chunk); err != nil {
+			return written, fmt.Errorf("boot partition offset %d: %w", off, err)
+		}
+
+		n, err := w.Write(chunk)
+		written += int64(n)
+
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// ReadActiveBootPartition writes the image of the active boot partition to w, returning the
+// number of bytes written.
+func (d *NVMeDevice) ReadActiveBootPartition(w io.Writer) (int64, error) {
+	info, err := d.GetBootPartitionInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	return d.ReadBootPartition(info.Active, w)
+}
@@ -0,0 +1,129 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bundleVersion is the version of the support bundle format written by SaveBundle.
+const bundleVersion = 1
+
+// bundle is the JSON encoding of a Collection. The decoded SMART log is not stored, since it is
+// decoded again from the raw log page when the bundle is loaded.
+type bundle struct {
+	Version    int               `json:"version"`
+	Profile    string            `json:"profile"`
+	Controller NVMeController    `json:"controller"`
+	NSID       uint32            `json:"nsid,omitempty"`
+	NSSize     uint64            `json:"ns_size,omitempty"`
+	NSUse      uint64            `json:"ns_use,omitempty"`
+	LogPages   map[uint8][]byte  `json:"log_pages"`
+	Features   map[uint8]uint32  `json:"features"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+// bundle returns the JSON encoding of the collection.
+func (c *Collection) bundle() bundle {
+	b := bundle{
+		Version:    bundleVersion,
+		Profile:    c.Profile,
+		Controller: c.Controller,
+		NSID:       c.NSID,
+		NSSize:     c.NSSize,
+		NSUse:      c.NSUse,
+		LogPages:   c.LogPages,
+		Features:   c.Features,
+		Errors:     make(map[string]string, len(c.Errors)),
+	}
+
+	for item, err := range c.Errors {
+		b.Errors[item] = err.Error()
+	}
+
+	return b
+}
+
+// SaveBundle writes the collection to w as a support bundle, which can later be analyzed with
+// LoadBundle without access to the device.
+func (c *Collection) SaveBundle(w io.Writer) error {
+	b := c.bundle()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(&b)
+}
+
+// MarshalJSON encodes the collection as a JSON report, i.e. the support bundle content plus the
+// decoded SMART log.
+func (c *Collection) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		bundle
+		SMART *SMARTLog `json:"smart,omitempty"`
+	}{c.bundle(), c.SMART})
+}
+
+// LoadBundle reads a support bundle written by SaveBundle, decoding the raw log pages it contains.
+// Errors which occurred during collection are restored as opaque errors with the same text.
+func LoadBundle(r io.Reader) (*Collection, error) {
+	var b bundle
+
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("invalid support bundle: %w", err)
+	}
+
+	if b.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported support bundle version %d", b.Version)
+	}
+
+	c := &Collection{
+		Profile:    b.Profile,
+		Controller: b.Controller,
+		NSID:       b.NSID,
+		NSSize:     b.NSSize,
+		NSUse:      b.NSUse,
+		LogPages:   b.LogPages,
+		Features:   b.Features,
+		Errors:     make(map[string]error, len(b.Errors)),
+	}
+
+	if c.LogPages == nil {
+		c.LogPages = make(map[uint8][]byte)
+	}
+	if c.Features == nil {
+		c.Features = make(map[uint8]uint32)
+	}
+
+	for item, msg := range b.Errors {
+		c.Errors[item] = errors.New(msg)
+	}
+
+	if buf, ok := c.LogPages[NVME_LOG_SMART]; ok {
+		if len(buf) < 512 {
+			return nil, fmt.Errorf("SMART log page truncated to %d bytes", len(buf))
+		}
+
+		var sl nvmeSMARTLog
+
+		sl.unmarshal(buf)
+		c.SMART = sl.decode()
+	}
+
+	return c, nil
+}
@@ -0,0 +1,381 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Capacity Management operations, cf. NVM Express Base Specification 2.0c, figure 155.
+const (
+	capMgmtSelectConfig      = 0x0
+	capMgmtCreateEnduranceGp = 0x1
+	capMgmtDeleteEnduranceGp = 0x2
+	capMgmtCreateNVMSet      = 0x3
+	capMgmtDeleteNVMSet      = 0x4
+)
+
+// MediaUnitConfig is a media unit of a channel in a capacity configuration.
+type MediaUnitConfig struct {
+	ID         uint16
+	DataLength uint16 // Media Unit Data Length (MUDL)
+}
+
+// ChannelConfig is a channel of an endurance group in a capacity configuration.
+type ChannelConfig struct {
+	ID         uint16
+	MediaUnits []MediaUnitConfig
+}
+
+// EnduranceGroupConfig is an endurance group in a capacity configuration.
+type EnduranceGroupConfig struct {
+	ID                 uint16
+	CapacityAdjustment uint16   // Capacity Adjustment Factor, in MiB
+	TotalCapacity      *big.Int // Total endurance group capacity, in bytes
+	SpareCapacity      *big.Int // Spare endurance group capacity, in bytes
+	EnduranceEstimate  *big.Int // Estimate of the data that may be written, in billions of bytes
+	NVMSets            []uint16
+	Channels           []ChannelConfig
+}
+
+// CapacityConfig is a capacity configuration which may be selected with SelectCapacityConfig.
+type CapacityConfig struct {
+	ID              uint16
+	DomainID        uint16
+	EnduranceGroups []EnduranceGroupConfig
+}
+
+// CapacityConfigList is the decoded Supported Capacity Configuration List log page (0x11).
+type CapacityConfigList []CapacityConfig
+
+// Print outputs the capacity configurations in a pretty-print style.
+func (l CapacityConfigList) Print(w io.Writer) {
+	for _, c := range l {
+		fmt.Fprintf(w, msg(MsgCapConfig), c.ID, c.DomainID, len(c.EnduranceGroups))
+
+		for _, eg := range c.EnduranceGroups {
+			fmt.Fprintf(w, msg(MsgCapEnduranceGroup), eg.ID, formatBigBytes(eg.TotalCapacity),
+				formatBigBytes(eg.SpareCapacity), eg.NVMSets, len(eg.Channels))
+		}
+	}
+}
+
+// GetCapacityConfigs reads the Supported Capacity Configuration List log page of the specified
+// domain.
+func (d *NVMeDevice) GetCapacityConfigs(domain uint16) (CapacityConfigList, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.getLog(NVME_LOG_SUPPORTED_CAP, logPageArgs{lsi: domain}, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeCapacityConfigs(buf)
+}
+
+// Domain describes a domain of the NVM subsystem, i.e. a set of media whose capacity is managed
+// independently, as reported in the Domain List.
+type Domain struct {
+	ID                    uint16
+	TotalCapacity         *big.Int // Bytes
+	UnallocatedCapacity   *big.Int // Bytes
+	MaxEnduranceGroupSize *big.Int // Maximum capacity of an endurance group in the domain, in bytes
+}
+
+// DomainList is the decoded Domain List.
+type DomainList []Domain
+
+// Print outputs the domain list in a pretty-print style.
+func (l DomainList) Print(w io.Writer) {
+	for _, dom := range l {
+		fmt.Fprintf(w, msg(MsgDomain), dom.ID, formatBigBytes(dom.TotalCapacity),
+			formatBigBytes(dom.UnallocatedCapacity), formatBigBytes(dom.MaxEnduranceGroupSize))
+	}
+}
+
+// GetDomains returns the domains of the NVM subsystem.
+func (d *NVMeDevice) GetDomains() (DomainList, error) {
+	if err := d.checkVersion("domain list", Version20); err != nil {
+		return nil, err
+	}
+
+	var domains DomainList
+
+	// Each list contains up to 31 domains, with identifiers greater than or equal to that
+	// specified
+	for start := uint16(0); ; {
+		buf := make([]byte, 4096)
+
+		args := identifyArgs{cns: NVME_IDENTIFY_CNS_DOMAIN_LIST, cnssi: start}
+		if err := d.identifyWith(args, buf); err != nil {
+			return nil, err
+		}
+
+		page := decodeDomainList(buf)
+		domains = append(domains, page...)
+
+		if len(page) < 31 || page[len(page)-1].ID == 0xffff {
+			return domains, nil
+		}
+
+		start = page[len(page)-1].ID + 1
+	}
+}
+
+func decodeDomainList(buf []byte) DomainList {
+	n := int(buf[0])
+	if n > 31 {
+		n = 31
+	}
+
+	l := make(DomainList, n)
+
+	for i := range l {
+		var raw nvmeDomainAttr
+
+		raw.unmarshal(buf[128*(i+1):])
+
+		l[i] = Domain{
+			ID:                    raw.DomainID,
+			TotalCapacity:         le128ToBigInt(raw.TotalCapacity),
+			UnallocatedCapacity:   le128ToBigInt(raw.UnallocCapacity),
+			MaxEnduranceGroupSize: le128ToBigInt(raw.MaxEGCapacity),
+		}
+	}
+
+	return l
+}
+
+// MediaUnitStatus is the status of a media unit from the Media Unit Status log page.
+type MediaUnitStatus struct {
+	ID                 uint16
+	DomainID           uint16
+	EnduranceGroupID   uint16
+	NVMSetID           uint16
+	CapacityAdjustment uint16 // Capacity Adjustment Factor, in MiB
+	AvailSpare         uint8  // Percent
+	PercentUsed        uint8
+	Channels           []uint16 // Channels to which the media unit is attached
+}
+
+// MediaUnitStatusLog is the decoded Media Unit Status log page (0x10) of a domain.
+type MediaUnitStatusLog struct {
+	Channels       uint16 // Number of channels in the domain
+	SelectedConfig uint16 // Selected capacity configuration, 0 if none
+	MediaUnits     []MediaUnitStatus
+}
+
+// Print outputs the media unit status in a pretty-print style.
+func (l *MediaUnitStatusLog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgMediaUnitHeader), l.SelectedConfig, l.Channels, len(l.MediaUnits))
+
+	for _, mu := range l.MediaUnits {
+		fmt.Fprintf(w, msg(MsgMediaUnit), mu.ID, mu.EnduranceGroupID, mu.NVMSetID,
+			mu.CapacityAdjustment, mu.AvailSpare, mu.PercentUsed, mu.Channels)
+	}
+}
+
+// GetMediaUnitStatus reads the Media Unit Status log page of the specified domain.
+func (d *NVMeDevice) GetMediaUnitStatus(domain uint16) (*MediaUnitStatusLog, error) {
+	args := logPageArgs{lsi: domain}
+	hdr := make([]byte, 16)
+
+	if err := d.getLog(NVME_LOG_MEDIA_UNIT_STATUS, args, hdr); err != nil {
+		return nil, err
+	}
+
+	// Size the buffer for each media unit being attached to every channel
+	nmu, cchans := int(binary.LittleEndian.Uint16(hdr)), int(binary.LittleEndian.Uint16(hdr[2:]))
+
+	buf, err := d.readLog(NVME_LOG_MEDIA_UNIT_STATUS, args, 16+nmu*(16+2*cchans))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMediaUnitStatus(buf)
+}
+
+// decodeMediaUnitStatus decodes the Media Unit Status log page. Each media unit status descriptor
+// is followed by the identifiers of the channels to which the media unit is attached.
+func decodeMediaUnitStatus(buf []byte) (*MediaUnitStatusLog, error) {
+	p := &capDecoder{buf: buf}
+
+	l := &MediaUnitStatusLog{MediaUnits: make([]MediaUnitStatus, p.u16())}
+	l.Channels, l.SelectedConfig = p.u16(), p.u16()
+	p.skip(10)
+
+	for i := range l.MediaUnits {
+		start := p.off
+
+		mu := &l.MediaUnits[i]
+		mu.ID, mu.DomainID, mu.EnduranceGroupID, mu.NVMSetID = p.u16(), p.u16(), p.u16(), p.u16()
+		mu.CapacityAdjustment = p.u16()
+
+		b := p.next(4)
+		mu.AvailSpare, mu.PercentUsed = b[0], b[1]
+
+		// Channel Identifiers Offset (CIO) is relative to the start of the descriptor
+		if mucs, cio := int(b[2]), int(b[3]); mucs > 0 {
+			if n := start + cio - p.off; n > 0 {
+				p.skip(n)
+			}
+
+			mu.Channels = make([]uint16, mucs)
+			for j := range mu.Channels {
+				mu.Channels[j] = p.u16()
+			}
+		}
+
+		if p.err != nil {
+			return nil, p.err
+		}
+	}
+
+	return l, nil
+}
+
+// SelectCapacityConfig selects a capacity configuration reported by GetCapacityConfigs, which
+// creates its endurance groups and NVM sets. Any existing configuration is replaced.
+func (d *NVMeDevice) SelectCapacityConfig(id uint16) error {
+	_, err := d.capacityMgmt(capMgmtSelectConfig, id, 0)
+	return err
+}
+
+// CreateEnduranceGroup creates an endurance group of the specified capacity, which is a multiple
+// of the capacity adjustment factor, returning the identifier of the new endurance group.
+func (d *NVMeDevice) CreateEnduranceGroup(capacity uint64) (uint16, error) {
+	return d.capacityMgmt(capMgmtCreateEnduranceGp, 0, capacity)
+}
+
+// DeleteEnduranceGroup deletes an endurance group, including all its NVM sets and namespaces.
+func (d *NVMeDevice) DeleteEnduranceGroup(endgid uint16) error {
+	_, err := d.capacityMgmt(capMgmtDeleteEnduranceGp, endgid, 0)
+	return err
+}
+
+// CreateNVMSet creates an NVM set of the specified capacity in an endurance group, returning the
+// identifier of the new NVM set.
+func (d *NVMeDevice) CreateNVMSet(endgid uint16, capacity uint64) (uint16, error) {
+	return d.capacityMgmt(capMgmtCreateNVMSet, endgid, capacity)
+}
+
+// DeleteNVMSet deletes an NVM set, including all its namespaces.
+func (d *NVMeDevice) DeleteNVMSet(setid uint16) error {
+	_, err := d.capacityMgmt(capMgmtDeleteNVMSet, setid, 0)
+	return err
+}
+
+// capacityMgmt issues a Capacity Management command, returning the identifier of the created
+// element, if any.
+func (d *NVMeDevice) capacityMgmt(op uint8, element uint16, capacity uint64) (uint16, error) {
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_CAPACITY_MGMT,
+		cdw10:  uint32(op&0xf) | uint32(element)<<16,
+		cdw11:  uint32(capacity),
+		cdw12:  uint32(capacity >> 32),
+	}
+
+	err := d.adminCmd(&cmd, nil)
+	return uint16(cmd.result), err
+}
+
+// decodeCapacityConfigs decodes the variable length descriptors of the Supported Capacity
+// Configuration List log page.
+func decodeCapacityConfigs(buf []byte) (CapacityConfigList, error) {
+	p := &capDecoder{buf: buf, off: 16}
+
+	l := make(CapacityConfigList, buf[0])
+
+	for i := range l {
+		c := &l[i]
+		c.ID, c.DomainID = p.u16(), p.u16()
+		c.EnduranceGroups = make([]EnduranceGroupConfig, p.u16())
+		p.skip(26)
+
+		for j := range c.EnduranceGroups {
+			eg := &c.EnduranceGroups[j]
+			eg.ID, eg.CapacityAdjustment = p.u16(), p.u16()
+			p.skip(12)
+			eg.TotalCapacity, eg.SpareCapacity, eg.EnduranceEstimate = p.u128(), p.u128(), p.u128()
+			p.skip(16)
+
+			eg.NVMSets = make([]uint16, p.u16())
+			for k := range eg.NVMSets {
+				eg.NVMSets[k] = p.u16()
+			}
+
+			eg.Channels = make([]ChannelConfig, p.u16())
+			for k := range eg.Channels {
+				ch := &eg.Channels[k]
+				ch.ID = p.u16()
+				ch.MediaUnits = make([]MediaUnitConfig, p.u16())
+
+				for m := range ch.MediaUnits {
+					mu := &ch.MediaUnits[m]
+					mu.ID = p.u16()
+					p.skip(4)
+					mu.DataLength = p.u16()
+				}
+			}
+		}
+
+		if p.err != nil {
+			return nil, p.err
+		}
+	}
+
+	return l, nil
+}
+
+// capDecoder reads little-endian fields sequentially from a capacity management log page,
+// recording an error instead of reading past its end.
+type capDecoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (p *capDecoder) next(n int) []byte {
+	if p.err == nil && p.off+n > len(p.buf) {
+		p.err = fmt.Errorf("capacity management log page truncated at offset %d", p.off)
+	}
+
+	if p.err != nil {
+		return make([]byte, n)
+	}
+
+	b := p.buf[p.off : p.off+n]
+	p.off += n
+
+	return b
+}
+
+func (p *capDecoder) skip(n int) {
+	p.next(n)
+}
+
+func (p *capDecoder) u16() uint16 {
+	return binary.LittleEndian.Uint16(p.next(2))
+}
+
+func (p *capDecoder) u128() *big.Int {
+	var b [16]byte
+
+	copy(b[:], p.next(16))
+
+	return le128ToBigInt(b)
+}
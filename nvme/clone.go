@@ -0,0 +1,249 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// oncsCopy is the Copy command support bit of the ONCS field.
+const oncsCopy = 1 << 8
+
+// copyFormat2h is the Copy source range descriptor format which specifies the source namespace of
+// each range, and cdfsCopyFormat2h its support bit of the CDFS field.
+const (
+	copyFormat2h     = 0x2
+	cdfsCopyFormat2h = 1 << copyFormat2h
+)
+
+// CloneOptions controls the behaviour of CloneNamespace.
+type CloneOptions struct {
+	ChunkLen int                      // Bytes per read / write, 0 for the default (limited by MDTS)
+	Verify   bool                     // Read back and compare each chunk after writing it
+	Force    bool                     // Skip the check that the destination is not in use
+	Progress func(done, total uint64) // Called after each chunk, with counts in logical blocks
+}
+
+// CloneNamespace copies the contents of namespace srcNsid of src to namespace dstNsid of dst,
+// which may be on the same or a different controller. Both namespaces must use the same LBA data
+// size without metadata, and the destination must be at least as large as the source.
+//
+// If both namespaces are accessed through the same device and the controller supports the Copy
+// command with source range descriptor format 2h, which specifies the source namespace, the
+// contents are copied by the controller. Otherwise they are copied with chunked Read and Write
+// commands. Either way, the devices must permit I/O commands (see ioCmd).
+func CloneNamespace(src *NVMeDevice, srcNsid uint32, dst *NVMeDevice, dstNsid uint32,
+	opts CloneOptions) error {
+
+	srcNs, err := src.identifyNamespace(srcNsid)
+	if err != nil {
+		return err
+	}
+
+	dstNs, err := dst.identifyNamespace(dstNsid)
+	if err != nil {
+		return err
+	}
+
+	_, srcLbaf := srcNs.currentLBAF()
+	_, dstLbaf := dstNs.currentLBAF()
+
+	if srcLbaf.Ds < 9 {
+		return fmt.Errorf("namespace %d: invalid LBA data size", srcNsid)
+	} else if srcLbaf.Ds != dstLbaf.Ds {
+		return fmt.Errorf("LBA data size mismatch: %d != %d", 1<<srcLbaf.Ds, 1<<dstLbaf.Ds)
+	} else if srcLbaf.Ms != 0 || dstLbaf.Ms != 0 {
+		return fmt.Errorf("namespaces with metadata are not supported")
+	} else if dstNs.Nsze < srcNs.Nsze {
+		return fmt.Errorf("destination namespace too small: %d < %d blocks", dstNs.Nsze, srcNs.Nsze)
+	}
+
+	if !opts.Force {
+		if err := dst.CheckNotInUse(dstNsid); err != nil {
+			return err
+		}
+	}
+
+	if src == dst {
+		idCtrlr, err := src.controller()
+		if err != nil {
+			return err
+		}
+
+		if idCtrlr.Oncs&oncsCopy != 0 && idCtrlr.Cdfs&cdfsCopyFormat2h != 0 {
+			return copyNamespace(src, srcNsid, srcNs.Nsze, dstNsid, dstNs, 1<<srcLbaf.Ds, opts)
+		}
+	}
+
+	chunkLen := opts.ChunkLen
+	if chunkLen == 0 {
+		chunkLen = defaultXferLen
+
+		for _, d := range []*NVMeDevice{src, dst} {
+			if chunkLen, err = d.maxXferLen(chunkLen); err != nil {
+				return err
+			}
+		}
+	}
+
+	lbaSize := 1 << srcLbaf.Ds
+
+	chunkBlocks := uint64(chunkLen / lbaSize)
+	if chunkBlocks == 0 {
+		return fmt.Errorf("chunk length %d smaller than LBA size %d", chunkLen, lbaSize)
+	}
+
+	buf := make([]byte, chunkBlocks*uint64(lbaSize))
+	verifyBuf := make([]byte, len(buf))
+
+	for slba := uint64(0); slba < srcNs.Nsze; slba += chunkBlocks {
+		nlb := chunkBlocks
+		if slba+nlb > srcNs.Nsze {
+			nlb = srcNs.Nsze - slba
+		}
+
+		data := buf[:nlb*uint64(lbaSize)]
+
+		if err := src.rwBlocks(NVME_CMD_READ, srcNsid, slba, data, lbaSize); err != nil {
+			return fmt.Errorf("read LBA %d: %w", slba, err)
+		}
+
+		if err := dst.rwBlocks(NVME_CMD_WRITE, dstNsid, slba, data, lbaSize); err != nil {
+			return fmt.Errorf("write LBA %d: %w", slba, err)
+		}
+
+		if opts.Verify {
+			check := verifyBuf[:len(data)]
+
+			if err := dst.rwBlocks(NVME_CMD_READ, dstNsid, slba, check, lbaSize); err != nil {
+				return fmt.Errorf("verify LBA %d: %w", slba, err)
+			}
+
+			if !bytes.Equal(data, check) {
+				return fmt.Errorf("verify LBA %d: data mismatch", slba)
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(slba+nlb, srcNs.Nsze)
+		}
+	}
+
+	return nil
+}
+
+// copyNamespace clones the first nsze logical blocks of namespace srcNsid to the same LBAs of
+// namespace dstNsid, using Copy commands with a single source range each, which is limited by the
+// destination namespace's maximum single source range length and maximum copy length.
+func copyNamespace(d *NVMeDevice, srcNsid uint32, nsze uint64, dstNsid uint32,
+	dstNs *nvmeIdentNamespace, lbaSize int, opts CloneOptions) error {
+
+	// The Number of Logical Blocks of a source range is a 16-bit 0's based value
+	chunkBlocks := uint64(0x10000)
+
+	for _, limit := range []uint64{uint64(dstNs.Mssrl), uint64(dstNs.Mcl)} {
+		if limit > 0 && limit < chunkBlocks {
+			chunkBlocks = limit
+		}
+	}
+
+	if opts.ChunkLen > 0 && uint64(opts.ChunkLen/lbaSize) < chunkBlocks {
+		if chunkBlocks = uint64(opts.ChunkLen / lbaSize); chunkBlocks == 0 {
+			return fmt.Errorf("chunk length %d smaller than LBA size %d", opts.ChunkLen, lbaSize)
+		}
+	}
+
+	var srcBuf, dstBuf []byte
+
+	if opts.Verify {
+		srcBuf = make([]byte, chunkBlocks*uint64(lbaSize))
+		dstBuf = make([]byte, len(srcBuf))
+	}
+
+	for slba := uint64(0); slba < nsze; slba += chunkBlocks {
+		nlb := chunkBlocks
+		if slba+nlb > nsze {
+			nlb = nsze - slba
+		}
+
+		if err := d.copyBlocks(srcNsid, dstNsid, slba, nlb); err != nil {
+			return fmt.Errorf("copy LBA %d: %w", slba, err)
+		}
+
+		if opts.Verify {
+			n := nlb * uint64(lbaSize)
+
+			if err := d.rwBlocks(NVME_CMD_READ, srcNsid, slba, srcBuf[:n], lbaSize); err != nil {
+				return fmt.Errorf("verify LBA %d: %w", slba, err)
+			}
+
+			if err := d.rwBlocks(NVME_CMD_READ, dstNsid, slba, dstBuf[:n], lbaSize); err != nil {
+				return fmt.Errorf("verify LBA %d: %w", slba, err)
+			}
+
+			if !bytes.Equal(srcBuf[:n], dstBuf[:n]) {
+				return fmt.Errorf("verify LBA %d: data mismatch", slba)
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(slba+nlb, nsze)
+		}
+	}
+
+	return nil
+}
+
+// copyBlocks issues a Copy command to namespace dstNsid, copying nlb logical blocks starting at
+// slba of namespace srcNsid to the same LBAs, with a single format 2h source range descriptor.
+func (d *NVMeDevice) copyBlocks(srcNsid, dstNsid uint32, slba, nlb uint64) error {
+	// cf. NVM Express NVM Command Set Specification 1.0c, Copy - Source Range Entries Descriptor
+	// Format 2h
+	buf := make([]byte, 32)
+	binary.LittleEndian.PutUint32(buf[0:], srcNsid)
+	binary.LittleEndian.PutUint64(buf[8:], slba)
+	binary.LittleEndian.PutUint16(buf[16:], uint16(nlb-1)) // 0's based
+
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_CMD_COPY,
+		nsid:     dstNsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    uint32(slba), // Starting Destination LBA
+		cdw11:    uint32(slba >> 32),
+		cdw12:    copyFormat2h << 8, // One source range (0's based), Descriptor Format 2h
+	}
+
+	return d.ioCmd(&cmd, buf)
+}
+
+// rwBlocks issues a Read or Write command for the logical blocks starting at slba, with the
+// number of blocks determined by the length of buf.
+func (d *NVMeDevice) rwBlocks(opcode uint8, nsid uint32, slba uint64, buf []byte, lbaSize int) error {
+	cmd := nvmePassthruCommand{
+		opcode:   opcode,
+		nsid:     nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    uint32(slba),
+		cdw11:    uint32(slba >> 32),
+		cdw12:    uint32(len(buf)/lbaSize) - 1, // Number of Logical Blocks (0's based)
+	}
+
+	return d.ioCmd(&cmd, buf)
+}
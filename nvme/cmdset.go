@@ -0,0 +1,97 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CommandSetVector is an I/O Command Set combination, with bit n set if the command set with CSI
+// n is part of the combination.
+type CommandSetVector uint64
+
+// Supports reports whether the command set with the specified CSI is part of the combination.
+func (v CommandSetVector) Supports(csi uint8) bool {
+	return csi < 64 && v&(1<<csi) != 0
+}
+
+func (v CommandSetVector) String() string {
+	names := map[uint8]string{NVME_CSI_NVM: "NVM", NVME_CSI_KV: "KV", NVME_CSI_ZNS: "ZNS"}
+
+	var sets []string
+
+	for csi := uint8(0); csi < 64; csi++ {
+		if !v.Supports(csi) {
+			continue
+		}
+
+		if name, ok := names[csi]; ok {
+			sets = append(sets, name)
+		} else {
+			sets = append(sets, fmt.Sprintf("CSI %#x", csi))
+		}
+	}
+
+	if len(sets) == 0 {
+		return "none"
+	}
+
+	return strings.Join(sets, "|")
+}
+
+// CommandSetCombinations lists the I/O Command Set combinations supported by a controller. The
+// index of a combination is the value used to select it with the I/O Command Set Profile feature.
+type CommandSetCombinations []CommandSetVector
+
+// Print outputs the command set combinations in a pretty-print style.
+func (c CommandSetCombinations) Print(w io.Writer) {
+	for i, v := range c {
+		if v != 0 {
+			fmt.Fprintf(w, msg(MsgCmdSetCombination), i, v)
+		}
+	}
+}
+
+// GetCommandSetCombinations returns the I/O Command Set combinations supported by the controller
+// with the specified controller identifier. Trailing unsupported (zero) combinations are omitted.
+func (d *NVMeDevice) GetCommandSetCombinations(cntid uint16) (CommandSetCombinations, error) {
+	if err := d.checkVersion("I/O command set data structure", Version14); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_CMD_SET, 0, 0, cntid, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeIOCommandSets
+
+	raw.unmarshal(buf)
+
+	n := len(raw.Vectors)
+	for n > 0 && raw.Vectors[n-1] == 0 {
+		n--
+	}
+
+	c := make(CommandSetCombinations, n)
+	for i := range c {
+		c[i] = CommandSetVector(raw.Vectors[i])
+	}
+
+	return c, nil
+}
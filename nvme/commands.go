@@ -16,8 +16,136 @@ package nvme
 
 const (
 	// cf. NVM Express Base Specification 2.0c , section 5: Admin Command Set
-	NVME_ADMIN_GET_LOG_PAGE uint8 = 0x02
-	NVME_ADMIN_IDENTIFY     uint8 = 0x06
+	NVME_ADMIN_GET_LOG_PAGE   uint8 = 0x02
+	NVME_ADMIN_IDENTIFY       uint8 = 0x06
+	NVME_ADMIN_ABORT          uint8 = 0x08
+	NVME_ADMIN_SET_FEATURES   uint8 = 0x09
+	NVME_ADMIN_GET_FEATURES   uint8 = 0x0a
+	NVME_ADMIN_NS_MGMT        uint8 = 0x0d
+	NVME_ADMIN_FW_COMMIT      uint8 = 0x10
+	NVME_ADMIN_FW_DOWNLOAD    uint8 = 0x11
+	NVME_ADMIN_SELF_TEST      uint8 = 0x14
+	NVME_ADMIN_NS_ATTACH      uint8 = 0x15
+	NVME_ADMIN_DIRECTIVE_SEND uint8 = 0x19
+	NVME_ADMIN_DIRECTIVE_RECV uint8 = 0x1a
+	NVME_ADMIN_VIRT_MGMT      uint8 = 0x1c
+	NVME_ADMIN_MI_SEND        uint8 = 0x1d
+	NVME_ADMIN_MI_RECV        uint8 = 0x1e
+	NVME_ADMIN_CAPACITY_MGMT  uint8 = 0x20
+	NVME_ADMIN_LOCKDOWN       uint8 = 0x24
+	NVME_ADMIN_SECURITY_SEND  uint8 = 0x81
+	NVME_ADMIN_SECURITY_RECV  uint8 = 0x82
+	NVME_ADMIN_GET_LBA_STATUS uint8 = 0x86
+)
+
+const (
+	// cf. NVM Express NVM Command Set Specification 1.0c, figure 18: Opcodes for NVM Commands
+	NVME_CMD_FLUSH         uint8 = 0x00
+	NVME_CMD_WRITE         uint8 = 0x01
+	NVME_CMD_READ          uint8 = 0x02
+	NVME_CMD_WRITE_UNCOR   uint8 = 0x04
+	NVME_CMD_COMPARE       uint8 = 0x05
+	NVME_CMD_WRITE_ZEROES  uint8 = 0x08
+	NVME_CMD_DSM           uint8 = 0x09
+	NVME_CMD_VERIFY        uint8 = 0x0c
+	NVME_CMD_RESV_REGISTER uint8 = 0x0d
+	NVME_CMD_RESV_REPORT   uint8 = 0x0e
+	NVME_CMD_RESV_ACQUIRE  uint8 = 0x11
+	NVME_CMD_RESV_RELEASE  uint8 = 0x15
+	NVME_CMD_COPY          uint8 = 0x19
+)
+
+const (
+	// cf. NVM Express Zoned Namespace Command Set Specification 1.1b, section 3: I/O Commands
+	NVME_CMD_ZONE_MGMT_RECV uint8 = 0x7a
+)
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 273: CNS Values
+	NVME_IDENTIFY_CNS_NS                uint8 = 0x00
+	NVME_IDENTIFY_CNS_CTRL              uint8 = 0x01
+	NVME_IDENTIFY_CNS_NS_ACTIVE_LIST    uint8 = 0x02
+	NVME_IDENTIFY_CNS_NS_DESC_LIST      uint8 = 0x03
+	NVME_IDENTIFY_CNS_NVMSET_LIST       uint8 = 0x04
+	NVME_IDENTIFY_CNS_CSI_NS            uint8 = 0x05
+	NVME_IDENTIFY_CNS_CSI_CTRL          uint8 = 0x06
+	NVME_IDENTIFY_CNS_ALLOCATED_NS_LIST uint8 = 0x10
+	NVME_IDENTIFY_CNS_ALLOCATED_NS      uint8 = 0x11
+	NVME_IDENTIFY_CNS_NS_CTRL_LIST      uint8 = 0x12
+	NVME_IDENTIFY_CNS_CTRL_LIST         uint8 = 0x13
+	NVME_IDENTIFY_CNS_PRIMARY_CTRL_CAP  uint8 = 0x14
+	NVME_IDENTIFY_CNS_SECONDARY_CTRL    uint8 = 0x15
+	NVME_IDENTIFY_CNS_NS_GRANULARITY    uint8 = 0x16
+	NVME_IDENTIFY_CNS_UUID_LIST         uint8 = 0x17
+	NVME_IDENTIFY_CNS_DOMAIN_LIST       uint8 = 0x18
+	NVME_IDENTIFY_CNS_ENDGRP_LIST       uint8 = 0x19
+	NVME_IDENTIFY_CNS_CMD_SET           uint8 = 0x1c
+)
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 286: Command Set Identifiers
+	NVME_CSI_NVM uint8 = 0x0
+	NVME_CSI_KV  uint8 = 0x1
+	NVME_CSI_ZNS uint8 = 0x2
+)
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 202: Get Log Page - Log Page Identifiers
+	NVME_LOG_SUPPORTED_PAGES   uint8 = 0x00
+	NVME_LOG_ERROR             uint8 = 0x01
+	NVME_LOG_SMART             uint8 = 0x02
+	NVME_LOG_FW_SLOT           uint8 = 0x03
+	NVME_LOG_CHANGED_NS        uint8 = 0x04
+	NVME_LOG_CMD_EFFECTS       uint8 = 0x05
+	NVME_LOG_DEVICE_SELF_TEST  uint8 = 0x06
+	NVME_LOG_TELEMETRY_HOST    uint8 = 0x07
+	NVME_LOG_TELEMETRY_CTRL    uint8 = 0x08
+	NVME_LOG_ENDURANCE_GROUP   uint8 = 0x09
+	NVME_LOG_PRED_LAT          uint8 = 0x0a
+	NVME_LOG_PRED_LAT_AGG      uint8 = 0x0b
+	NVME_LOG_ANA               uint8 = 0x0c
+	NVME_LOG_PERSISTENT_EVENT  uint8 = 0x0d
+	NVME_LOG_LBA_STATUS        uint8 = 0x0e
+	NVME_LOG_ENDURANCE_EVENTS  uint8 = 0x0f
+	NVME_LOG_MEDIA_UNIT_STATUS uint8 = 0x10
+	NVME_LOG_SUPPORTED_CAP     uint8 = 0x11
+	NVME_LOG_FID_EFFECTS       uint8 = 0x12
+	NVME_LOG_LOCKDOWN          uint8 = 0x14
+	NVME_LOG_BOOT_PARTITION    uint8 = 0x15
+	NVME_LOG_ROTATIONAL_MEDIA  uint8 = 0x16
+	NVME_LOG_FDP_CONFIGS       uint8 = 0x20
+	NVME_LOG_FDP_RUH_USAGE     uint8 = 0x21
+	NVME_LOG_FDP_STATS         uint8 = 0x22
+	NVME_LOG_FDP_EVENTS        uint8 = 0x23
+	NVME_LOG_DISCOVERY         uint8 = 0x70
+	NVME_LOG_RESV_NOTIFY       uint8 = 0x80
+	NVME_LOG_SANITIZE          uint8 = 0x81
+)
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 317: Feature Identifiers
+	NVME_FEAT_ARBITRATION      uint8 = 0x01
+	NVME_FEAT_POWER_MGMT       uint8 = 0x02
+	NVME_FEAT_TEMP_THRESH      uint8 = 0x04
+	NVME_FEAT_ERR_RECOVERY     uint8 = 0x05
+	NVME_FEAT_VOLATILE_WC      uint8 = 0x06
+	NVME_FEAT_NUM_QUEUES       uint8 = 0x07
+	NVME_FEAT_IRQ_COALESCE     uint8 = 0x08
+	NVME_FEAT_WRITE_ATOMIC     uint8 = 0x0a
+	NVME_FEAT_ASYNC_EVENT      uint8 = 0x0b
+	NVME_FEAT_PLM_CONFIG       uint8 = 0x13
+	NVME_FEAT_PLM_WINDOW       uint8 = 0x14
+	NVME_FEAT_HOST_BEHAVIOR    uint8 = 0x16
+	NVME_FEAT_SANITIZE_CONFIG  uint8 = 0x17
+	NVME_FEAT_NS_WRITE_PROTECT uint8 = 0x84
+)
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 187: Get Features - Select
+	NVME_FEAT_SEL_CURRENT   uint8 = 0x0
+	NVME_FEAT_SEL_DEFAULT   uint8 = 0x1
+	NVME_FEAT_SEL_SAVED     uint8 = 0x2
+	NVME_FEAT_SEL_SUPPORTED uint8 = 0x3
 )
 
 // Defined in <linux/nvme_ioctl.h> (first 64 bytes refer to NVM Express Base Specification 2.0c,
@@ -0,0 +1,1503 @@
+// Copyright 2017-22 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type capturedCmd struct {
+	req  uintptr
+	cmd  nvmePassthruCommand
+	data []byte // Buffer referenced by the command's address
+}
+
+// mockSubmit replaces the ioctl submission function with fn for the duration of a test.
+func mockSubmit(t *testing.T, fn func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error)) {
+	orig := submitCmd
+	submitCmd = fn
+	t.Cleanup(func() { submitCmd = orig })
+}
+
+// captureCmds replaces the ioctl submission function for the duration of a test, recording each
+// command instead of passing it to the kernel. Identify Controller commands return idCtrlr.
+func captureCmds(t *testing.T, idCtrlr *nvmeIdentController) *[]capturedCmd {
+	var cmds []capturedCmd
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		cmds = append(cmds, capturedCmd{req, *cmd, data})
+
+		if cmd.opcode == NVME_ADMIN_IDENTIFY && cmd.cdw10 == 1 && idCtrlr != nil {
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, idCtrlr)
+			copy(data, buf.Bytes())
+		}
+
+		return 0, nil
+	})
+
+	return &cmds
+}
+
+// Command encoding vectors. The expected values correspond to the passthru commands that the
+// equivalent nvme-cli invocation encodes, as derived from the nvme-cli sources and the NVMe
+// specifications. Commands which nvme-cli has no dedicated command for, such as Abort, are named
+// by the equivalent admin-passthru invocation.
+var cmdVectors = []struct {
+	name  string // nvme-cli equivalent
+	ident nvmeIdentController
+	io    bool // I/O rather than admin command
+	fn    func(d *NVMeDevice) error
+	want  nvmePassthruCommand
+}{
+	{
+		name: "nvme id-ctrl",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.IdentifyController(io.Discard)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, nsid: 0, data_len: 4096, cdw10: 0x1},
+	},
+	{
+		name: "nvme id-ns -n 1",
+		fn:   func(d *NVMeDevice) error { return d.IdentifyNamespace(io.Discard, 1) },
+		want: nvmePassthruCommand{opcode: 0x06, nsid: 1, data_len: 4096, cdw10: 0x0},
+	},
+	{
+		name: "nvme smart-log",
+		fn:   func(d *NVMeDevice) error { return d.PrintSMART(io.Discard) },
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f0002},
+	},
+	{
+		name: "nvme get-feature -f 0x84 -n 1",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.getFeature(NVME_FEAT_NS_WRITE_PROTECT, 1, NVME_FEAT_SEL_CURRENT, 0, nil)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x0a, nsid: 1, cdw10: 0x84},
+	},
+	{
+		name: "nvme get-feature -f 0x84 -n 1 -s 3",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.getFeature(NVME_FEAT_NS_WRITE_PROTECT, 1, NVME_FEAT_SEL_SUPPORTED, 0, nil)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x0a, nsid: 1, cdw10: 0x384},
+	},
+	{
+		name: "nvme set-feature -f 0x84 -n 1 -v 1",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.setFeature(NVME_FEAT_NS_WRITE_PROTECT, 1, false, 1, 0, nil)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x09, nsid: 1, cdw10: 0x84, cdw11: 0x1},
+	},
+	{
+		name: "nvme get-feature -f 0x16 -l 512",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetHostBehavior()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x0a, data_len: 512, cdw10: 0x16},
+	},
+	{
+		name: "nvme set-feature -f 0x16 -l 512 -s",
+		fn:   func(d *NVMeDevice) error { return d.SetHostBehavior(HostBehavior{AdvancedCommandRetry: true}, true) },
+		want: nvmePassthruCommand{opcode: 0x09, data_len: 512, cdw10: 0x80000016},
+	},
+	{
+		name: "nvme set-feature -f 1 -v 0x03020103",
+		fn: func(d *NVMeDevice) error {
+			return d.SetArbitration(Arbitration{Burst: 3, LowPriorityWeight: 1,
+				MediumPriorityWeight: 2, HighPriorityWeight: 3}, false)
+		},
+		want: nvmePassthruCommand{opcode: 0x09, cdw10: 0x01, cdw11: 0x03020103},
+	},
+	{
+		name: "nvme set-feature -f 8 -v 0x0a07",
+		fn: func(d *NVMeDevice) error {
+			return d.SetInterruptCoalescing(InterruptCoalescing{Threshold: 7, Time: 10}, false)
+		},
+		want: nvmePassthruCommand{opcode: 0x09, cdw10: 0x08, cdw11: 0x0a07},
+	},
+	{
+		name: "nvme fid-support-effects-log",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetFeatureEffects()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 1024, cdw10: 0x00ff0012},
+	},
+	{
+		name: "nvme fw-commit -s 2 -a 1",
+		fn:   func(d *NVMeDevice) error { return d.FirmwareCommit(2, FirmwareCommitReplaceActivate, 0) },
+		want: nvmePassthruCommand{opcode: 0x10, cdw10: 0x0a},
+	},
+	{
+		name: "nvme fw-commit -a 7 -b 1",
+		fn:   func(d *NVMeDevice) error { return d.FirmwareCommit(0, FirmwareCommitActivateBootPartition, 1) },
+		want: nvmePassthruCommand{opcode: 0x10, cdw10: 0x80000038},
+	},
+	{
+		name: "nvme fw-download -f fw.bin --xfer 4096 (final chunk)",
+		fn:   func(d *NVMeDevice) error { return d.FirmwareDownload(make([]byte, 6144)) },
+		want: nvmePassthruCommand{opcode: 0x11, data_len: 2048, cdw10: 0x1ff, cdw11: 0x400},
+	},
+	{
+		name:  "nvme fw-download -f fw.bin --xfer 8192 (final chunk, limited by MDTS)",
+		fn:    func(d *NVMeDevice) error { return d.FirmwareDownload(make([]byte, 12288)) },
+		ident: nvmeIdentController{Mdts: 1, Fwug: 4},
+		want:  nvmePassthruCommand{opcode: 0x11, data_len: 4096, cdw10: 0x3ff, cdw11: 0x800},
+	},
+	{
+		name: "nvme fw-log",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetFirmwareSlotInfo()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f0003},
+	},
+	{
+		name: "nvme self-test-log",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetSelfTestLog()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 564, cdw10: 0x008c0006},
+	},
+	{
+		name: "nvme device-self-test -s 0xf",
+		fn:   func(d *NVMeDevice) error { return d.AbortSelfTest() },
+		want: nvmePassthruCommand{opcode: 0x14, nsid: 0xffffffff, cdw10: 0xf},
+	},
+	{
+		name:  "nvme create-ns -s 0x100000 -c 0x100000 -f 1 -m 1",
+		ident: nvmeIdentController{Oacs: oacsNsMgmt},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.CreateNamespace(NamespaceSpec{Size: 0x100000, FormattedLBASize: 1, Shared: true})
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x0d, data_len: 4096, cdw10: 0x0},
+	},
+	{
+		name:  "nvme delete-ns -n 2",
+		ident: nvmeIdentController{Oacs: oacsNsMgmt},
+		fn:    func(d *NVMeDevice) error { return d.DeleteNamespace(2, true) },
+		want:  nvmePassthruCommand{opcode: 0x0d, nsid: 2, cdw10: 0x1},
+	},
+	{
+		name:  "nvme attach-ns -n 2 -c 1,2",
+		ident: nvmeIdentController{Oacs: oacsNsMgmt},
+		fn:    func(d *NVMeDevice) error { return d.AttachNamespace(2, []uint16{2, 1}) },
+		want:  nvmePassthruCommand{opcode: 0x15, nsid: 2, data_len: 4096, cdw10: 0x0},
+	},
+	{
+		name:  "nvme detach-ns -n 2 -c 1",
+		ident: nvmeIdentController{Oacs: oacsNsMgmt},
+		fn:    func(d *NVMeDevice) error { return d.DetachNamespace(2, []uint16{1}, true) },
+		want:  nvmePassthruCommand{opcode: 0x15, nsid: 2, data_len: 4096, cdw10: 0x1},
+	},
+	{
+		name: "nvme admin-passthru --opcode=0x08 --cdw10=0x12340001",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.Abort(1, 0x1234)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x08, cdw10: 0x12340001},
+	},
+	{
+		name:  "nvme security-recv -p 0x1 -s 0x1 -t 2048",
+		ident: nvmeIdentController{Oacs: oacsSecurity},
+		fn: func(d *NVMeDevice) error {
+			return d.SecurityReceive(0, SecurityProtocolTCG1, 0x0001, 0, make([]byte, 2048))
+		},
+		want: nvmePassthruCommand{opcode: 0x82, data_len: 2048, cdw10: 0x01000100, cdw11: 2048},
+	},
+	{
+		name:  "nvme security-send -p 0xea -s 0x0 -N 0x1",
+		ident: nvmeIdentController{Oacs: oacsSecurity},
+		fn: func(d *NVMeDevice) error {
+			return d.SecuritySend(0, SecurityProtocolRPMB, 0, 1, make([]byte, 512))
+		},
+		want: nvmePassthruCommand{opcode: 0x81, data_len: 512, cdw10: 0xea000001, cdw11: 512},
+	},
+	{
+		name: "nvme resv-register -n 1 -k 0x1234 -r 0",
+		io:   true,
+		fn:   func(d *NVMeDevice) error { return d.RegisterReservationKey(1, 0x1234) },
+		want: nvmePassthruCommand{opcode: 0x0d, nsid: 1, data_len: 16, cdw10: 0x0},
+	},
+	{
+		name: "nvme resv-acquire -n 1 -c 0x1234 -p 0x5678 -t 1 -a 2",
+		io:   true,
+		fn: func(d *NVMeDevice) error {
+			return d.PreemptReservation(1, 0x1234, 0x5678, ResvWriteExclusive, true)
+		},
+		want: nvmePassthruCommand{opcode: 0x11, nsid: 1, data_len: 16, cdw10: 0x102},
+	},
+	{
+		name: "nvme resv-release -n 1 -c 0x1234 -t 1",
+		io:   true,
+		fn:   func(d *NVMeDevice) error { return d.ReleaseReservation(1, 0x1234, ResvWriteExclusive) },
+		want: nvmePassthruCommand{opcode: 0x15, nsid: 1, data_len: 8, cdw10: 0x100},
+	},
+	{
+		name: "nvme resv-report -n 1 -e",
+		io:   true,
+		fn: func(d *NVMeDevice) error {
+			_, err := d.ReservationReport(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x0e, nsid: 1, data_len: 4096, cdw10: 0x3ff, cdw11: 0x1},
+	},
+	{
+		name:  "nvme dir-send -n 1 -D 0 -O 1 -T 1 -e 1",
+		ident: nvmeIdentController{Oacs: oacsDirectives},
+		fn:    func(d *NVMeDevice) error { return d.EnableStreams(1, true) },
+		want:  nvmePassthruCommand{opcode: 0x19, nsid: 1, cdw11: 0x1, cdw12: 0x101},
+	},
+	{
+		name:  "nvme dir-receive -n 1 -D 1 -O 3 -r 4",
+		ident: nvmeIdentController{Oacs: oacsDirectives},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.AllocateStreams(1, 4)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x1a, nsid: 1, cdw11: 0x103, cdw12: 0x4},
+	},
+	{
+		name:  "nvme dir-receive -n 1 -D 1 -O 1 -l 32",
+		ident: nvmeIdentController{Oacs: oacsDirectives},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetStreamParams(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x1a, nsid: 1, data_len: 32, cdw10: 0x7, cdw11: 0x101},
+	},
+	{
+		name: "nvme list-secondary --cntid=1",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetSecondaryControllers(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, data_len: 4096, cdw10: 0x10015},
+	},
+	{
+		name:  "nvme virt-mgmt -c 2 -r 0 -a 8 -n 4",
+		ident: nvmeIdentController{Oacs: oacsVirtMgmt},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.AssignSecondaryResources(2, VirtQueueResource, 4)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x1c, cdw10: 0x20008, cdw11: 0x4},
+	},
+	{
+		name:  "nvme lockdown --ofi=0x10 --ifc=0 --prhbt=1 --scp=0",
+		ident: nvmeIdentController{Oacs: oacsLockdown},
+		fn: func(d *NVMeDevice) error {
+			return d.Lockdown(LockdownAdminOpcode, NVME_ADMIN_FW_COMMIT, LockdownAdminQueue, true)
+		},
+		want: nvmePassthruCommand{opcode: 0x24, cdw10: 0x1010},
+	},
+	{
+		name: "nvme get-log --log-id=0x14 --lsp=0x12 --log-len=512",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetLockdownLog(LockdownProhibited, LockdownFeatureID)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f1214},
+	},
+	{
+		name: "nvme capacity-mgmt --operation=0 --element-id=3",
+		fn: func(d *NVMeDevice) error {
+			return d.SelectCapacityConfig(3)
+		},
+		want: nvmePassthruCommand{opcode: 0x20, cdw10: 0x30000},
+	},
+	{
+		name: "nvme capacity-mgmt --operation=3 --element-id=1 --cap-lower=0x100 --cap-upper=0x1",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.CreateNVMSet(1, 0x100000100)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x20, cdw10: 0x10003, cdw11: 0x100, cdw12: 0x1},
+	},
+	{
+		name: "nvme supported-cap-config-log --domain-id=2",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetCapacityConfigs(2)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, data_len: 4096, cdw10: 0x03ff0011, cdw11: 0x20000},
+	},
+	{
+		name:  "nvme get-lba-status --namespace-id=1 --start-lba=0x100000000 --range-len=64 --action=0x11",
+		ident: nvmeIdentController{Oacs: oacsGetLBAStatus},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetLBAStatus(1, 0x100000000, 64, LBAStatusTracked)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x86, nsid: 1, data_len: 4096, cdw11: 0x1, cdw12: 0x3ff,
+			cdw13: 0x11000040},
+	},
+	{
+		name:  "nvme admin-passthru --opcode=0x1e --cdw10=0x1 --cdw12=0x80000000 --data-len=8 -r",
+		ident: nvmeIdentController{Oacs: oacsMI},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.SubsystemHealthPoll(true)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x1e, data_len: 8, cdw10: 0x1, cdw12: 0x80000000},
+	},
+	{
+		name:  "nvme id-iocs --controller-id=0",
+		ident: nvmeIdentController{Ver: uint32(Version20)},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetCommandSetCombinations(0)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, data_len: 4096, cdw10: 0x1c},
+	},
+	{
+		name: "nvme admin-passthru --opcode=0xc2 --namespace-id=1 --cdw10=0x10 --cdw15=0xff --data-len=512 -r --timeout=5000",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.SubmitAdmin(&PassthruCommand{Opcode: 0xc2, NSID: 1, CDW10: 0x10,
+				CDW15: 0xff, Data: make([]byte, 512), Timeout: 5 * time.Second})
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0xc2, nsid: 1, data_len: 512, cdw10: 0x10, cdw15: 0xff,
+			timeout_ms: 5000},
+	},
+	{
+		name: "nvme io-passthru --opcode=0x02 --namespace-id=1 --cdw10=0x800 --cdw12=7 --data-len=4096 -r",
+		io:   true,
+		fn: func(d *NVMeDevice) error {
+			_, err := d.SubmitIO(&PassthruCommand{Opcode: NVME_CMD_READ, NSID: 1, CDW10: 0x800,
+				CDW12: 7, Data: make([]byte, 4096)})
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 1, data_len: 4096, cdw10: 0x800, cdw12: 7},
+	},
+	{
+		name:  "nvme error-log --log-entries=4",
+		ident: nvmeIdentController{Elpe: 3},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetErrorLog()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 256, cdw10: 0x003f0001},
+	},
+	{
+		name:  "nvme smart-log --namespace-id=2",
+		ident: nvmeIdentController{Lpa: lpaSMARTPerNS},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetNamespaceSMARTLog(2)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 2, data_len: 512, cdw10: 0x007f0002},
+	},
+	{
+		name: "nvme effects-log",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetCommandEffects()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 4096, cdw10: 0x03ff0005},
+	},
+	{
+		name:  "nvme predictable-lat-log 1",
+		ident: nvmeIdentController{Ctratt: ctrattPLM},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetPredictableLatency(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f000a,
+			cdw11: 0x10000},
+	},
+	{
+		name:  "nvme pred-lat-event-agg-log",
+		ident: nvmeIdentController{Ctratt: ctrattPLM},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetPredictableLatencyEvents()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 8, cdw10: 0x0001000b},
+	},
+	{
+		name:  "nvme set-feature -f 0x13 -v 1 --cdw12=1 -l 512",
+		ident: nvmeIdentController{Ctratt: ctrattPLM},
+		fn:    func(d *NVMeDevice) error { return d.SetPLMConfig(1, PLMConfig{Enabled: true}, false) },
+		want:  nvmePassthruCommand{opcode: 0x09, data_len: 512, cdw10: 0x13, cdw11: 1, cdw12: 1},
+	},
+	{
+		name: "nvme set-feature -f 0x14 -v 1 --cdw12=2",
+		fn:   func(d *NVMeDevice) error { return d.SetPLMWindow(1, PLMWindowNonDeterministic, false) },
+		want: nvmePassthruCommand{opcode: 0x09, cdw10: 0x14, cdw11: 1, cdw12: 2},
+	},
+	{
+		name:  "nvme ana-log --groups-only",
+		ident: nvmeIdentController{Cmic: cmicANA, Nanagrpid: 2, Nn: 32},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetANALog(true)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 80, cdw10: 0x0013010c},
+	},
+	{
+		name:  "nvme endurance-log --group-id=2",
+		ident: nvmeIdentController{Ctratt: ctrattEnduranceGroups},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetEnduranceGroupInfo(2)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, data_len: 512, cdw10: 0x007f0009, cdw11: 0x20000},
+	},
+	{
+		name:  "nvme endurance-event-agg-log",
+		ident: nvmeIdentController{Ctratt: ctrattEnduranceGroups},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetEnduranceGroupEvents()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 8, cdw10: 0x0001000f},
+	},
+	{
+		name:  "nvme fdp stats --endgrp-id=1",
+		ident: nvmeIdentController{Ctratt: ctrattFDP},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetFDPStats(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, data_len: 64, cdw10: 0x000f0022, cdw11: 0x10000},
+	},
+	{
+		name:  "nvme fdp events --endgrp-id=1 --host-events",
+		ident: nvmeIdentController{Ctratt: ctrattFDP},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetFDPEvents(1, true)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, data_len: 64, cdw10: 0x000f0123, cdw11: 0x10000},
+	},
+	{
+		name:  "nvme fdp events --endgrp-id=1",
+		ident: nvmeIdentController{Ctratt: ctrattFDP},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetFDPEvents(1, false)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, data_len: 64, cdw10: 0x000f0023, cdw11: 0x10000},
+	},
+	{
+		name: "nvme rotational-media-info-log --endg-id=1",
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetRotationalMedia(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, data_len: 512, cdw10: 0x007f0016, cdw11: 0x10000},
+	},
+	{
+		name: "nvme ocp set-latency-monitor-feature",
+		fn:   func(d *NVMeDevice) error { return d.SetLatencyMonitor(LatencyMonitorConfig{Enable: true}) },
+		want: nvmePassthruCommand{opcode: 0x09, data_len: 4096, cdw10: 0xc5},
+	},
+	{
+		name: "nvme get-log --log-id=0x02 --log-len=512 --rae --raw-binary",
+		fn:   func(d *NVMeDevice) error { return d.DumpLogPage(io.Discard, 0x02, 0xffffffff, 0) },
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f8002},
+	},
+	{
+		name: "nvme id-ns -n 1 --raw-binary",
+		fn:   func(d *NVMeDevice) error { return d.DumpIdentify(io.Discard, 0x00, 1) },
+		want: nvmePassthruCommand{opcode: 0x06, nsid: 1, data_len: 4096, cdw10: 0x0},
+	},
+	{
+		name:  "nvme id-nvmset",
+		ident: nvmeIdentController{Ctratt: ctrattNVMSets},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetNVMSets()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, data_len: 4096, cdw10: 0x4},
+	},
+	{
+		name:  "nvme list-ctrl -n 1",
+		ident: nvmeIdentController{Oacs: oacsNsMgmt},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.ListAttachedControllers(1)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, nsid: 1, data_len: 4096, cdw10: 0x12},
+	},
+	{
+		name:  "nvme get-log --log-id=0xc0 --log-len=512 --uuid-index=2",
+		ident: nvmeIdentController{VendorID: vidMicron},
+		fn: func(d *NVMeDevice) error {
+			d.SetUUIDIndex(2)
+			_, err := d.GetMicronSMARTLog()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f00c0,
+			cdw14: 2},
+	},
+	{
+		name: "nvme get-log --log-id=0x02 --log-len=512",
+		fn: func(d *NVMeDevice) error {
+			d.SetUUIDIndex(2)
+			_, err := d.GetSMARTLog()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x02, nsid: 0xffffffff, data_len: 512, cdw10: 0x007f0002},
+	},
+	{
+		name:  "nvme zns id-ctrl",
+		ident: nvmeIdentController{Ver: uint32(Version20)},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.IdentifyZNSController()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, data_len: 4096, cdw10: 0x6, cdw11: 0x02000000},
+	},
+	{
+		name:  "nvme id-ns -n 2 --force",
+		ident: nvmeIdentController{Oacs: oacsNsMgmt},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.IdentifyAllocatedNamespace(2)
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, nsid: 2, data_len: 4096, cdw10: 0x11},
+	},
+	{
+		name:  "nvme endurance-group-list -i 1",
+		ident: nvmeIdentController{Ctratt: ctrattEnduranceGroups, Ver: uint32(Version14)},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.ListEnduranceGroups()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, data_len: 4096, cdw10: 0x19, cdw11: 1},
+	},
+	{
+		name:  "nvme id-ns-granularity",
+		ident: nvmeIdentController{Ctratt: ctrattNsGranularity},
+		fn: func(d *NVMeDevice) error {
+			_, err := d.GetNamespaceGranularity()
+			return err
+		},
+		want: nvmePassthruCommand{opcode: 0x06, data_len: 4096, cdw10: 0x16},
+	},
+	{
+		name: "nvme admin-passthru --opcode=0x06 --namespace-id=1 --cdw10=0x00020005 --cdw11=0x02000000",
+		fn: func(d *NVMeDevice) error {
+			return d.Identify(NVME_IDENTIFY_CNS_CSI_NS, NVME_CSI_ZNS, 1, 2, make([]byte, 8192))
+		},
+		want: nvmePassthruCommand{opcode: 0x06, nsid: 1, data_len: 4096, cdw10: 0x20005,
+			cdw11: 0x2000000},
+	},
+}
+
+func TestCommandEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	// ioctl(fd, NVME_IOCTL_ADMIN_CMD or 0xc0484e41, ...)
+	assert.Equal(uintptr(0xc0484e41), NVME_IOCTL_ADMIN_CMD)
+
+	for _, v := range cmdVectors {
+		cmds := captureCmds(t, &v.ident)
+
+		assert.NoError(v.fn(NewNVMeDevice("/dev/null")), v.name)
+
+		// Some commands are preceded by an identify, so only the last command is checked
+		if assert.NotEmpty(*cmds, v.name) {
+			c := (*cmds)[len(*cmds)-1]
+
+			// Buffer addresses vary between runs
+			if v.want.data_len > 0 {
+				assert.NotZero(c.cmd.addr, v.name)
+			}
+			c.cmd.addr = 0
+
+			if v.io {
+				assert.Equal(NVME_IOCTL_IO_CMD, c.req, v.name)
+			} else {
+				assert.Equal(NVME_IOCTL_ADMIN_CMD, c.req, v.name)
+			}
+			assert.Equal(v.want, c.cmd, v.name)
+		}
+	}
+}
+
+func TestCloneNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	// Namespace 1 is the source and namespace 2 the destination, both with 512-byte LBAs
+	const lbaSize = 512
+
+	media := map[uint32][]byte{1: make([]byte, 10*lbaSize), 2: make([]byte, 12*lbaSize)}
+	for i := range media[1] {
+		media[1][i] = byte(i * 7)
+	}
+
+	var copies []uint64
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch cmd.opcode {
+		case NVME_ADMIN_IDENTIFY:
+			if cmd.cdw10 == uint32(NVME_IDENTIFY_CNS_CTRL) {
+				// Only the second device's controller supports cross-namespace Copy
+				if fd == 2 {
+					binary.LittleEndian.PutUint16(data[520:], oncsCopy)
+					binary.LittleEndian.PutUint16(data[534:], cdfsCopyFormat2h)
+				}
+				break
+			}
+
+			ns := nvmeIdentNamespace{Nsze: uint64(len(media[cmd.nsid]) / lbaSize), Mssrl: 3}
+			ns.Lbaf[0].Ds = 9
+
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, &ns)
+			copy(data, buf.Bytes())
+		case NVME_CMD_READ, NVME_CMD_WRITE:
+			assert.Equal(NVME_IOCTL_IO_CMD, req)
+
+			off := (uint64(cmd.cdw11)<<32 | uint64(cmd.cdw10)) * lbaSize
+			n := uint64(cmd.cdw12+1) * lbaSize
+			assert.Equal(uint64(cmd.data_len), n)
+
+			if cmd.opcode == NVME_CMD_READ {
+				copy(data, media[cmd.nsid][off:off+n])
+			} else {
+				copy(media[cmd.nsid][off:off+n], data)
+			}
+		case NVME_CMD_COPY:
+			assert.Equal(uint32(copyFormat2h<<8), cmd.cdw12)
+
+			snsid := binary.LittleEndian.Uint32(data[0:])
+			slba := binary.LittleEndian.Uint64(data[8:])
+			nlb := uint64(binary.LittleEndian.Uint16(data[16:])) + 1
+			dlba := uint64(cmd.cdw11)<<32 | uint64(cmd.cdw10)
+
+			copy(media[cmd.nsid][dlba*lbaSize:], media[snsid][slba*lbaSize:(slba+nlb)*lbaSize])
+			copies = append(copies, slba)
+		}
+
+		return 0, nil
+	})
+
+	var progress []uint64
+
+	d := NewNVMeDevice("/dev/null")
+	opts := CloneOptions{
+		ChunkLen: 4 * lbaSize,
+		Verify:   true,
+		Force:    true,
+		Progress: func(done, total uint64) {
+			assert.Equal(uint64(10), total)
+			progress = append(progress, done)
+		},
+	}
+
+	assert.NoError(CloneNamespace(d, 1, d, 2, opts))
+	assert.Equal(media[1], media[2][:len(media[1])])
+	assert.Equal([]uint64{4, 8, 10}, progress)
+
+	// Destination smaller than source
+	assert.Error(CloneNamespace(d, 2, d, 1, opts))
+	assert.Empty(copies)
+
+	// Copy commands are used within a controller supporting them, limited by MSSRL
+	media[2] = make([]byte, 12*lbaSize)
+	progress = nil
+
+	d = NewNVMeDevice("/dev/null")
+	d.fd = 2
+
+	assert.NoError(CloneNamespace(d, 1, d, 2, opts))
+	assert.Equal(media[1], media[2][:len(media[1])])
+	assert.Equal([]uint64{0, 3, 6, 9}, copies)
+	assert.Equal([]uint64{3, 6, 9, 10}, progress)
+}
+
+func TestCollect(t *testing.T) {
+	assert := assert.New(t)
+
+	cmds := captureCmds(t, &nvmeIdentController{VendorID: 0x144d})
+
+	_, err := LookupProfile("bogus")
+	assert.Error(err)
+
+	p, err := LookupProfile("Standard")
+	if !assert.NoError(err) {
+		return
+	}
+
+	c, err := NewNVMeDevice("/dev/null").Collect(p)
+	if assert.NoError(err) {
+		assert.Equal(uint16(0x144d), c.Controller.VendorID)
+		assert.Equal(uint32(1), c.NSID)
+		assert.Len(c.LogPages, len(p.LogPages))
+		assert.Len(c.Features, len(p.Features))
+		assert.NotNil(c.SMART)
+		assert.Empty(c.Errors)
+	}
+
+	// Identify controller and namespace, then one command per log page and feature
+	assert.Len(*cmds, 2+len(p.LogPages)+len(p.Features))
+
+	for _, c := range *cmds {
+		if c.cmd.opcode == NVME_ADMIN_GET_LOG_PAGE {
+			assert.NotZero(c.cmd.cdw10&(1<<15), "RAE not set")
+		}
+	}
+}
+
+func TestScrub(t *testing.T) {
+	assert := assert.New(t)
+
+	bad := map[uint64]bool{300: true, 301: true, 302: true, 700: true}
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch {
+		case cmd.opcode == NVME_ADMIN_IDENTIFY && cmd.cdw10 == 1:
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, &nvmeIdentController{
+				Oacs: oacsGetLBAStatus,
+				Oncs: oncsVerify,
+			})
+			copy(data, buf.Bytes())
+		case cmd.opcode == NVME_ADMIN_GET_LBA_STATUS:
+			// Only LBAs 300-301 are tracked as potentially unrecoverable
+			if cmd.cdw10 == 300 {
+				binary.LittleEndian.PutUint32(data[0:], 1)
+				binary.LittleEndian.PutUint64(data[8:], 300)
+				binary.LittleEndian.PutUint32(data[16:], 2)
+			}
+		case cmd.opcode == NVME_ADMIN_IDENTIFY:
+			ns := nvmeIdentNamespace{Nsze: 1000}
+			ns.Lbaf[0].Ds = 9
+
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, &ns)
+			copy(data, buf.Bytes())
+		case cmd.opcode == NVME_CMD_VERIFY:
+			slba := uint64(cmd.cdw11)<<32 | uint64(cmd.cdw10)
+			for lba := slba; lba <= slba+uint64(cmd.cdw12); lba++ {
+				if bad[lba] {
+					return 0x281, nil // Unrecovered Read Error
+				}
+			}
+		}
+
+		return 0, nil
+	})
+
+	var checkpoints []uint64
+
+	d := NewNVMeDevice("/dev/null")
+	r, err := d.Scrub(context.Background(), 1, ScrubOptions{
+		Start:      256,
+		BadRanges:  []LBARange{{10, 1}},
+		Checkpoint: func(r *ScrubReport) { checkpoints = append(checkpoints, r.Next) },
+	})
+
+	if assert.NoError(err) {
+		assert.True(r.Verify)
+		assert.Equal(uint64(1000), r.Next)
+		assert.Equal(uint64(744), r.Scrubbed)
+		assert.Equal([]LBARange{{10, 1}, {300, 3}, {700, 1}}, r.BadRanges)
+		assert.Equal([]uint64{512, 768, 1000}, checkpoints)
+		assert.True(r.LBAStatusChecked)
+		assert.Equal([]LBARange{{10, 1}, {302, 1}, {700, 1}}, r.Untracked)
+	}
+
+	// A cancelled scrub returns its progress
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r, err = d.Scrub(ctx, 1, ScrubOptions{})
+	assert.ErrorIs(err, context.Canceled)
+	assert.Equal(uint64(0), r.Next)
+}
+
+func TestHandleStats(t *testing.T) {
+	assert := assert.New(t)
+
+	captureCmds(t, nil)
+
+	d := NewNVMeDevice("/dev/null")
+	d.IdentifyNamespace(io.Discard, 1)
+	d.rwBlocks(NVME_CMD_READ, 1, 0, make([]byte, 4096), 512)
+	d.rwBlocks(NVME_CMD_WRITE, 1, 0, make([]byte, 1024), 512)
+	d.scrubRange(1, 0, 8, nil, true)
+
+	assert.Equal(HandleStats{
+		Admin:   CommandStats{Commands: 2, Bytes: 8192}, // Controller version and namespace
+		IORead:  CommandStats{Commands: 1, Bytes: 4096},
+		IOWrite: CommandStats{Commands: 1, Bytes: 1024},
+		IOOther: CommandStats{Commands: 1},
+	}, d.Stats())
+
+	d.ResetStats()
+	assert.Equal(HandleStats{}, d.Stats())
+}
+
+func TestReadBootPartition(t *testing.T) {
+	assert := assert.New(t)
+
+	// Boot partition 1 is active, and each partition is 128 KiB
+	image := make([]byte, 16+bootPartitionUnit)
+	image[0] = NVME_LOG_BOOT_PARTITION
+	binary.LittleEndian.PutUint32(image[4:], 1<<31|1)
+
+	for i := 16; i < len(image); i++ {
+		image[i] = byte(i * 7)
+	}
+
+	var bpids []uint32
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		if cmd.opcode == NVME_ADMIN_GET_LOG_PAGE && uint8(cmd.cdw10) == NVME_LOG_BOOT_PARTITION {
+			bpids = append(bpids, cmd.cdw10>>8&0x7f)
+
+			off := uint64(cmd.cdw13)<<32 | uint64(cmd.cdw12)
+			copy(data, image[off:])
+		}
+
+		return 0, nil
+	})
+
+	var w bytes.Buffer
+
+	n, err := NewNVMeDevice("/dev/null").ReadActiveBootPartition(&w)
+	if assert.NoError(err) {
+		assert.Equal(int64(bootPartitionUnit), n)
+		assert.Equal(image[16:], w.Bytes())
+	}
+
+	// Header of partition 0, then header and 8 chunks of the active partition 1
+	assert.Equal([]uint32{0, 1, 1, 1, 1, 1, 1, 1, 1, 1}, bpids)
+}
+
+func TestSubmitAdmin(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		cmd.result = 0x1234
+		return uintptr(NVME_SC_INVALID_FIELD), nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	// Both the status and dword 0 are returned
+	res, err := d.SubmitAdmin(&PassthruCommand{Opcode: 0xc0})
+	assert.Equal(uint32(0x1234), res)
+	assert.Equal(NVMeStatus(NVME_SC_INVALID_FIELD), err)
+
+	// Abort transfers no data, but vendor specific opcodes need not follow the opcode bits
+	_, err = d.SubmitAdmin(&PassthruCommand{Opcode: NVME_ADMIN_ABORT, Data: make([]byte, 4)})
+	assert.ErrorContains(err, "does not transfer data")
+	_, err = d.SubmitAdmin(&PassthruCommand{Opcode: 0xc0, Data: make([]byte, 4)})
+	assert.Equal(NVMeStatus(NVME_SC_INVALID_FIELD), err)
+
+	_, err = d.SubmitAdmin(&PassthruCommand{Opcode: 0xc2, Timeout: -time.Second})
+	assert.Error(err)
+}
+
+func TestSubmitIO(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		cmd.result = 0x5678
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	res, err := d.SubmitIO(&PassthruCommand{Opcode: NVME_CMD_FLUSH, NSID: 1})
+	assert.NoError(err)
+	assert.Equal(uint32(0x5678), res)
+
+	// Flush transfers no data
+	_, err = d.SubmitIO(&PassthruCommand{Opcode: NVME_CMD_FLUSH, NSID: 1, Data: make([]byte, 4)})
+	assert.Error(err)
+	_, err = d.SubmitIO(&PassthruCommand{Opcode: 0x80, NSID: 1, Data: make([]byte, 4)})
+	assert.NoError(err)
+}
+
+func TestCaptureHostTelemetry(t *testing.T) {
+	assert := assert.New(t)
+
+	// Data areas 1 to 3 end at blocks 8, 40 and 40, i.e. data area 3 is empty
+	log := make([]byte, 41*telemetryBlockSize)
+	log[0] = NVME_LOG_TELEMETRY_HOST
+	log[8], log[10], log[12] = 8, 40, 40
+	log[381] = 3
+
+	for i := telemetryBlockSize; i < len(log); i++ {
+		log[i] = byte(i / telemetryBlockSize)
+	}
+
+	var lsps []uint32
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch cmd.opcode {
+		case NVME_ADMIN_IDENTIFY:
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, &nvmeIdentController{Lpa: lpaTelemetry})
+			copy(data, buf.Bytes())
+		case NVME_ADMIN_GET_LOG_PAGE:
+			lsps = append(lsps, cmd.cdw10>>8&0x7f)
+
+			off := uint64(cmd.cdw13)<<32 | uint64(cmd.cdw12)
+			copy(data, log[off:])
+		}
+
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	var w bytes.Buffer
+
+	hdr, err := d.CaptureHostTelemetry(&w, 3)
+	if assert.NoError(err) {
+		assert.Equal(uint8(3), hdr.HostGeneration)
+		assert.Equal(int64(9*telemetryBlockSize), hdr.Size(1))
+		assert.Equal(int64(len(log)), hdr.Size(4))
+		assert.Equal(log, w.Bytes())
+	}
+
+	// Only the header is read with the create bit set
+	assert.Equal([]uint32{1, 0, 0, 0}, lsps)
+
+	_, err = d.CaptureHostTelemetry(&w, 4)
+	assert.ErrorIs(err, ErrNotSupported)
+}
+
+func TestSaveControllerTelemetry(t *testing.T) {
+	assert := assert.New(t)
+
+	log := make([]byte, 3*telemetryBlockSize)
+	log[0] = NVME_LOG_TELEMETRY_CTRL
+	log[8], log[10], log[12] = 1, 2, 2
+	log[382], log[383] = 1, 7
+
+	var raes []bool
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch cmd.opcode {
+		case NVME_ADMIN_IDENTIFY:
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, &nvmeIdentController{Lpa: lpaTelemetry})
+			copy(data, buf.Bytes())
+		case NVME_ADMIN_GET_LOG_PAGE:
+			raes = append(raes, cmd.cdw10&(1<<15) != 0)
+
+			off := uint64(cmd.cdw13)<<32 | uint64(cmd.cdw12)
+			copy(data, log[off:])
+
+			// Simulate the controller replacing its data after the first read
+			log[383] = 8
+		}
+
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	hdr, err := d.GetControllerTelemetryStatus()
+	if assert.NoError(err) {
+		assert.True(hdr.ControllerAvailable)
+		assert.Equal(uint8(7), hdr.ControllerGeneration)
+	}
+
+	var w bytes.Buffer
+
+	hdr, err = d.SaveControllerTelemetry(&w, 2)
+	if assert.NoError(err) {
+		assert.Equal(uint8(8), hdr.ControllerGeneration)
+		assert.Equal(log, w.Bytes())
+	}
+
+	assert.Equal([]bool{true, true, true, true}, raes)
+
+	// Generation number changes between the first and last reads of the header
+	log[383] = 7
+	w.Reset()
+	_, err = d.SaveControllerTelemetry(&w, 2)
+	assert.ErrorContains(err, "generation changed")
+	assert.Zero(w.Len())
+}
+
+func TestGetPersistentEventLog(t *testing.T) {
+	assert := assert.New(t)
+
+	log := make([]byte, pelHeaderLength, 0x5000)
+	log[0] = NVME_LOG_PERSISTENT_EVENT
+	log[4] = 3                                                  // Total number of events
+	binary.LittleEndian.PutUint16(log[18:], pelHeaderLength-17) // Log header length
+	binary.LittleEndian.PutUint64(log[20:], 1.6e12)             // Timestamp
+	copy(log[56:], "S123                ")                      // Serial number
+	binary.LittleEndian.PutUint16(log[372:], 4)                 // Generation number
+
+	event := func(etype uint8, vsi, data []byte) {
+		hdr := make([]byte, pelEventHdrLength)
+		hdr[0], hdr[2] = etype, pelEventHdrLength-3
+		binary.LittleEndian.PutUint64(hdr[6:], 1.6e12+1000)
+		binary.LittleEndian.PutUint16(hdr[20:], uint16(len(vsi)))
+		binary.LittleEndian.PutUint16(hdr[22:], uint16(len(vsi)+len(data)))
+
+		log = append(append(append(log, hdr...), vsi...), data...)
+	}
+
+	event(PELFirmwareCommit, nil, []byte("1.0     2.0     \x01\x02\x00\x00"))
+	event(PELVendorSpecific, []byte{0xaa, 0xbb}, make([]byte, 0x4000))
+	event(PELThermalExcursion, nil, []byte{3, 1, 0, 0})
+	binary.LittleEndian.PutUint64(log[8:], uint64(len(log))) // Total log length
+
+	var lsps []uint32
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch cmd.opcode {
+		case NVME_ADMIN_IDENTIFY:
+			buf := new(bytes.Buffer)
+			binary.Write(buf, binary.LittleEndian, &nvmeIdentController{Lpa: lpaPersistentEvent})
+			copy(data, buf.Bytes())
+		case NVME_ADMIN_GET_LOG_PAGE:
+			lsps = append(lsps, cmd.cdw10>>8&0x7f)
+
+			off := uint64(cmd.cdw13)<<32 | uint64(cmd.cdw12)
+			copy(data, log[off:])
+		}
+
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	l, err := d.GetPersistentEventLog()
+	if assert.NoError(err) {
+		assert.Equal(uint16(4), l.Generation)
+		assert.Equal("S123", l.SerialNumber)
+		assert.Equal(int64(1.6e12), l.Timestamp.UnixMilli())
+
+		if assert.Len(l.Events, 3) {
+			assert.Equal(&PELFirmwareCommitEvent{OldRevision: "1.0", NewRevision: "2.0",
+				Action: FirmwareCommitReplaceActivate, Slot: 2}, l.Events[0].Detail)
+			assert.Equal([]byte{0xaa, 0xbb}, l.Events[1].VendorInfo)
+			assert.Len(l.Events[1].Data, 0x4000)
+			assert.Nil(l.Events[1].Detail)
+			assert.Equal(&PELThermalExcursionEvent{OverTemperature: 3, Threshold: 1}, l.Events[2].Detail)
+		}
+	}
+
+	// Establish context, read the log in two chunks, then release context
+	assert.Equal([]uint32{pelEstablishCtx, pelReadLog, pelReadLog, pelReleaseCtx}, lsps)
+}
+
+func TestGetSupportedLogPages(t *testing.T) {
+	assert := assert.New(t)
+
+	log := make([]byte, 1024)
+	binary.LittleEndian.PutUint32(log[4*NVME_LOG_SUPPORTED_PAGES:], logSupported)
+	binary.LittleEndian.PutUint32(log[4*NVME_LOG_SMART:], logSupported|logIndexOffset)
+	binary.LittleEndian.PutUint32(log[4*int(NVME_LOG_SANITIZE):], logSupported|0x30000)
+
+	var logIDs []uint8
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		if cmd.opcode == NVME_ADMIN_GET_LOG_PAGE {
+			logIDs = append(logIDs, uint8(cmd.cdw10))
+
+			if uint8(cmd.cdw10) == NVME_LOG_SUPPORTED_PAGES {
+				copy(data, log)
+			}
+		}
+
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	l, err := d.GetSupportedLogPages()
+	if assert.NoError(err) {
+		assert.True(l.Supported(NVME_LOG_SMART))
+		assert.True(l.IndexOffset(NVME_LOG_SMART))
+		assert.False(l.Supported(NVME_LOG_ERROR))
+		assert.Equal(uint16(3), l.SpecificParameter(NVME_LOG_SANITIZE))
+	}
+
+	// Unsupported log pages are no longer requested from the controller
+	_, err = d.GetErrorLog()
+	assert.ErrorIs(err, ErrNotSupported)
+
+	_, err = d.GetSMARTLog()
+	assert.NoError(err)
+
+	// Vendor specific log pages are not recorded, since they may depend on the UUID index
+	_, known := d.Support().LogPage(0xca)
+	assert.False(known)
+
+	assert.Equal([]uint8{NVME_LOG_SUPPORTED_PAGES, NVME_LOG_SMART}, logIDs)
+}
+
+func TestGetDiscoveryLog(t *testing.T) {
+	assert := assert.New(t)
+
+	log := make([]byte, 3*1024)
+	log[0], log[8] = 1, 2 // Generation 1, 2 records
+
+	entry := log[1024:]
+	entry[0], entry[1], entry[2] = TransportTCP, AddrFamilyIPv4, SubsystemNVMe
+	copy(entry[32:], "4420")
+	copy(entry[256:], "nqn.2014-08.org.example:subsys1")
+	copy(entry[512:], "192.0.2.1")
+
+	entry = log[2048:]
+	entry[0], entry[1], entry[2] = TransportRDMA, AddrFamilyIPv6, SubsystemCurrentDiscovery
+
+	reads := 0
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		off := uint64(cmd.cdw13)<<32 | uint64(cmd.cdw12)
+		copy(data, log[off:])
+
+		// Simulate a change of the discovery log after the header is first read
+		if reads++; reads == 1 {
+			log[0] = 2
+		}
+
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	l, err := d.GetDiscoveryLog()
+	if assert.NoError(err) {
+		assert.Equal(uint64(2), l.Generation)
+
+		if assert.Len(l.Entries, 2) {
+			assert.Equal("tcp", l.Entries[0].Transport())
+			assert.Equal("ipv4", l.Entries[0].Family())
+			assert.Equal("4420", l.Entries[0].TransportSvcID)
+			assert.Equal("192.0.2.1", l.Entries[0].TransportAddr)
+			assert.Equal("nqn.2014-08.org.example:subsys1", l.Entries[0].SubsystemNQN)
+			assert.Equal("current discovery subsystem", l.Entries[1].Subsystem())
+		}
+	}
+
+	// Header, then the whole log page twice
+	assert.Equal(3, reads)
+}
+
+func TestGetReservationNotifications(t *testing.T) {
+	assert := assert.New(t)
+
+	// Two pending notifications, the first of which reports one more available
+	pending := [][]byte{
+		{5, 0, 0, 0, 0, 0, 0, 0, byte(ResvNotifyReservationPreempted), 1, 0, 0, 1, 0, 0, 0},
+		{6, 0, 0, 0, 0, 0, 0, 0, byte(ResvNotifyRegistrationPreempted), 0, 0, 0, 2, 0, 0, 0},
+	}
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		if len(pending) > 0 {
+			copy(data, pending[0])
+			pending = pending[1:]
+		}
+
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	l, err := d.GetReservationNotifications()
+	if assert.NoError(err) {
+		assert.Equal([]ReservationNotification{
+			{Count: 5, Type: ResvNotifyReservationPreempted, Available: 1, NSID: 1},
+			{Count: 6, Type: ResvNotifyRegistrationPreempted, NSID: 2},
+		}, l)
+	}
+
+	// No notification pending
+	l, err = d.GetReservationNotifications()
+	assert.NoError(err)
+	assert.Empty(l)
+}
+
+func TestVendorLogGating(t *testing.T) {
+	assert := assert.New(t)
+
+	captureCmds(t, &nvmeIdentController{VendorID: vidIntel})
+	d := NewNVMeDevice("/dev/null")
+
+	_, err := d.GetMicronSMARTLog()
+	assert.ErrorIs(err, ErrNotSupported)
+	_, err = d.GetWDCSMARTLog()
+	assert.ErrorIs(err, ErrNotSupported)
+
+	cmds := captureCmds(t, &nvmeIdentController{VendorID: vidMicron})
+	d = NewNVMeDevice("/dev/null")
+
+	_, err = d.GetMicronSMARTLog()
+	assert.NoError(err)
+
+	last := (*cmds)[len(*cmds)-1].cmd
+	assert.Equal(NVME_ADMIN_GET_LOG_PAGE, last.opcode)
+	assert.Equal(uint32(NVME_LOG_OCP_SMART), last.cdw10&0xff)
+}
+
+func TestVendorPlugins(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { RegisterVendorPlugin(VendorPlugin{Name: "intel"}) })
+
+	RegisterVendorPlugin(VendorPlugin{
+		Name: "test",
+		OUIs: []uint32{0x123456},
+		Logs: []VendorLog{{
+			Name: "dummy",
+			Print: func(d *NVMeDevice, w io.Writer) error {
+				_, err := io.WriteString(w, "dummy")
+				return err
+			},
+		}},
+		Commands: []VendorCommand{{
+			Name: "echo",
+			Run: func(d *NVMeDevice, args []string, w io.Writer) error {
+				_, err := io.WriteString(w, strings.Join(args, " "))
+				return err
+			},
+		}},
+	})
+	t.Cleanup(func() {
+		vendorMu.Lock()
+		delete(vendorPlugins, "test")
+		vendorMu.Unlock()
+	})
+
+	plugins := LookupVendorPlugins(vidSolidigm, 0x123456)
+	if assert.Len(plugins, 2) {
+		assert.Equal("intel", plugins[0].Name)
+		assert.Equal("test", plugins[1].Name)
+	}
+
+	assert.Empty(LookupVendorPlugins(0xffff, 0))
+
+	captureCmds(t, &nvmeIdentController{VendorID: vidIntel, IEEE: [3]byte{0x56, 0x34, 0x12}})
+	d := NewNVMeDevice("/dev/null")
+
+	var b strings.Builder
+
+	assert.NoError(d.PrintVendorLog(&b, "dummy"))
+	assert.Equal("dummy", b.String())
+	assert.ErrorIs(d.PrintVendorLog(&b, "bogus"), ErrNotSupported)
+
+	b.Reset()
+	assert.NoError(d.RunVendorCommand(&b, "echo", []string{"a", "b"}))
+	assert.Equal("a b", b.String())
+	assert.ErrorIs(d.RunVendorCommand(&b, "bogus", nil), ErrNotSupported)
+}
+
+func TestListNamespaces(t *testing.T) {
+	assert := assert.New(t)
+
+	var starts []uint32
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		if cmd.opcode == NVME_ADMIN_IDENTIFY && cmd.cdw10 == uint32(NVME_IDENTIFY_CNS_NS_ACTIVE_LIST) {
+			starts = append(starts, cmd.nsid)
+
+			// 1500 active namespaces
+			buf := data
+			for i, nsid := 0, cmd.nsid+1; i < nsListLen && nsid <= 1500; i, nsid = i+1, nsid+1 {
+				binary.LittleEndian.PutUint32(buf[4*i:], nsid)
+			}
+		}
+
+		return 0, nil
+	})
+
+	nsids, err := NewNVMeDevice("/dev/null").ListNamespaces()
+	if assert.NoError(err) {
+		assert.Len(nsids, 1500)
+		assert.Equal(uint32(1), nsids[0])
+		assert.Equal(uint32(1500), nsids[1499])
+	}
+
+	assert.Equal([]uint32{0, 1024}, starts)
+}
+
+func TestVersionGating(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("1.4.0", Version14.String())
+	assert.Equal("2.0.1", Version(0x00020001).String())
+	assert.Equal("pre-1.2", Version(0).String())
+
+	cmds := captureCmds(t, &nvmeIdentController{Ver: uint32(Version12)})
+	d := NewNVMeDevice("/dev/null")
+
+	v, err := d.Version()
+	if assert.NoError(err) {
+		assert.Equal(Version12, v)
+	}
+
+	_, err = d.GetNamespaceDescriptors(1)
+	assert.ErrorIs(err, ErrNotSupported)
+	assert.EqualError(err, "namespace identification descriptor list requires NVMe 1.3, "+
+		"controller implements 1.2.0: not supported by controller")
+
+	_, err = d.GetDomains()
+	assert.ErrorIs(err, ErrNotSupported)
+
+	// Only Identify Controller commands may have been issued
+	for _, c := range *cmds {
+		assert.Equal(uint32(NVME_IDENTIFY_CNS_CTRL), c.cmd.cdw10)
+	}
+}
+
+func TestUUIDIndexSupport(t *testing.T) {
+	assert := assert.New(t)
+
+	var sent []uint32
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		sent = append(sent, cmd.cdw14)
+
+		// The vendor log page is only implemented for UUID index 2
+		if cmd.cdw14 != 2 {
+			return uintptr(NVME_SC_INVALID_LOG_PAGE), nil
+		}
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	assert.Error(d.DumpLogPage(io.Discard, 0xca, 0xffffffff, 512))
+	assert.ErrorIs(d.DumpLogPage(io.Discard, 0xca, 0xffffffff, 512), ErrNotSupported)
+
+	// Unsupported with no UUID index must not imply unsupported with another index
+	assert.NoError(d.SetUUIDIndex(2))
+	assert.NoError(d.DumpLogPage(io.Discard, 0xca, 0xffffffff, 512))
+	assert.Equal([]uint32{0, 2}, sent)
+
+	supported, known := d.Support().LogPageUUID(0xca, 2)
+	assert.True(known)
+	assert.True(supported)
+}
+
+func TestIdentifyZNSController(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch cmd.cdw10 {
+		case uint32(NVME_IDENTIFY_CNS_CTRL):
+			binary.LittleEndian.PutUint32(data[80:], uint32(Version20))
+		case uint32(NVME_IDENTIFY_CNS_CSI_CTRL):
+			data[0] = 2 // ZASL
+		}
+		return 0, nil
+	})
+
+	// ZASL is in units of the minimum memory page size
+	d := NewNVMeDevice("/dev/null")
+	assert.NoError(d.SetMinPageSize(16384))
+
+	c, err := d.IdentifyZNSController()
+	if assert.NoError(err) {
+		assert.Equal(uint32(64<<10), c.ZoneAppendSizeLimit)
+	}
+}
+
+func TestKVNamespaceRouting(t *testing.T) {
+	assert := assert.New(t)
+
+	descErr := false
+
+	mockSubmit(t, func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+		switch uint8(cmd.cdw10) {
+		case NVME_IDENTIFY_CNS_CTRL:
+			binary.LittleEndian.PutUint32(data[80:], uint32(Version20))
+		case NVME_IDENTIFY_CNS_NS_DESC_LIST:
+			if descErr {
+				return uintptr(NVME_SC_INVALID_FIELD), nil
+			}
+			copy(data, []byte{nidtCSI, 1, 0, 0, NVME_CSI_KV})
+		case NVME_IDENTIFY_CNS_CSI_NS:
+			binary.LittleEndian.PutUint64(data[0:], 1<<30) // NSZE
+		case NVME_IDENTIFY_CNS_NS:
+			t.Error("NVM identify namespace issued for key value namespace")
+		}
+		return 0, nil
+	})
+
+	d := NewNVMeDevice("/dev/null")
+
+	var out bytes.Buffer
+
+	assert.NoError(d.IdentifyNamespace(&out, 1))
+	assert.Contains(out.String(), "KV namespace size 1073741824 bytes")
+
+	_, err := d.identifyNamespace(1)
+	assert.ErrorIs(err, ErrNotSupported)
+
+	// A failing descriptor list is reported rather than assuming the NVM command set
+	descErr = true
+
+	_, err = d.IdentifyKVNamespace(1)
+	assert.ErrorIs(err, NVMeStatus(NVME_SC_INVALID_FIELD))
+	assert.Error(d.IdentifyNamespace(io.Discard, 1))
+}
+
+func TestControllerCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cmds := captureCmds(t, &nvmeIdentController{Ver: uint32(Version20),
+		Ctratt: ctrattEnduranceGroups})
+
+	identifies := func() (n int) {
+		for _, c := range *cmds {
+			if c.cmd.opcode == NVME_ADMIN_IDENTIFY && c.cmd.cdw10 == uint32(NVME_IDENTIFY_CNS_CTRL) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The capability and version checks share a single Identify Controller command
+	d := NewNVMeDevice("/dev/null")
+
+	_, err := d.ListEnduranceGroups()
+	assert.NoError(err)
+	_, err = d.ListEnduranceGroups()
+	assert.NoError(err)
+	assert.Equal(1, identifies())
+
+	// Firmware activation discards the cached data
+	assert.NoError(d.FirmwareCommit(0, FirmwareCommitActivateBootPartition, 0))
+
+	v, err := d.Version()
+	assert.NoError(err)
+	assert.Equal(Version20, v)
+	assert.Equal(2, identifies())
+}
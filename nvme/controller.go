@@ -15,6 +15,7 @@
 package nvme
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 )
@@ -25,72 +26,256 @@ type NVMeController struct {
 	ModelNumber     string
 	SerialNumber    string
 	FirmwareVersion string
-	OUI             uint32 // IEEE OUI identifier
-	MaxDataXferSize uint
+	Version         Version // NVMe version, or zero prior to NVMe 1.2
+	OUI             uint32  // IEEE OUI identifier
+	MaxDataXferSize uint    // Maximum data transfer size in bytes, or zero if unlimited
+
+	// Raw capability fields, preferably queried via the Supports* methods.
+	OACS    uint16 // Optional Admin Command Support
+	ONCS    uint16 // Optional NVM Command Support
+	LPA     uint8  // Log Page Attributes
+	FRMW    uint8  // Firmware Updates
+	SANICAP uint32 // Sanitize Capabilities
+	CTRATT  uint32 // Controller Attributes
+
+	SubsystemNQN   string         // NVM Subsystem NVMe Qualified Name
+	ControllerID   uint16         // Controller ID, unique within the subsystem
+	CMIC           uint8          // Controller Multi-Path I/O and Namespace Sharing Capabilities
+	ControllerType ControllerType // I/O, discovery or administrative controller
+	FGUID          string         // FRU Globally Unique Identifier, if reported
+	PowerStates    []PowerState   // Power state descriptors of the supported power states
+}
+
+// OUIVendor returns the name of the vendor to which the controller's IEEE OUI identifier is
+// assigned, or an empty string if it is not known.
+func (c *NVMeController) OUIVendor() string {
+	return OUIVendor(c.OUI)
+}
+
+// ControllerType is the type of an NVMe controller (CNTRLTYPE), cf. NVM Express Base Specification
+// 2.0c, figure 275.
+type ControllerType uint8
+
+const (
+	ControllerTypeUnreported ControllerType = 0x0
+	ControllerTypeIO         ControllerType = 0x1
+	ControllerTypeDiscovery  ControllerType = 0x2
+	ControllerTypeAdmin      ControllerType = 0x3
+)
+
+func (t ControllerType) String() string {
+	switch t {
+	case ControllerTypeUnreported:
+		return "not reported"
+	case ControllerTypeIO:
+		return "I/O"
+	case ControllerTypeDiscovery:
+		return "discovery"
+	case ControllerTypeAdmin:
+		return "administrative"
+	}
+
+	return fmt.Sprintf("unknown (%#x)", uint8(t))
+}
+
+// Controller Multi-Path I/O and Namespace Sharing Capabilities (CMIC) bits.
+const (
+	cmicMultiPort  = 1 << 0
+	cmicMultiCtrlr = 1 << 1
+	cmicSRIOV      = 1 << 2
+)
+
+// IsDiscovery reports whether the controller is an NVMe over Fabrics discovery controller.
+func (c *NVMeController) IsDiscovery() bool { return c.ControllerType == ControllerTypeDiscovery }
+
+// MultiPort reports whether the NVM subsystem may contain more than one port.
+func (c *NVMeController) MultiPort() bool { return c.CMIC&cmicMultiPort != 0 }
+
+// MultiController reports whether the NVM subsystem may contain more than one controller.
+func (c *NVMeController) MultiController() bool { return c.CMIC&cmicMultiCtrlr != 0 }
+
+// SRIOV reports whether the controller is associated with an SR-IOV virtual function.
+func (c *NVMeController) SRIOV() bool { return c.CMIC&cmicSRIOV != 0 }
+
+// SupportsANA reports whether the controller supports Asymmetric Namespace Access reporting.
+func (c *NVMeController) SupportsANA() bool { return c.CMIC&cmicANA != 0 }
+
+// Optional Admin Command Support (OACS) bits not used elsewhere in this package.
+const (
+	oacsFormat   = 1 << 1
+	oacsFirmware = 1 << 2
+)
+
+// Optional NVM Command Support (ONCS) bits not used elsewhere in this package.
+const (
+	oncsCompare      = 1 << 0
+	oncsWriteUncorr  = 1 << 1
+	oncsDSM          = 1 << 2
+	oncsWriteZeroes  = 1 << 3
+	oncsReservations = 1 << 5
+)
+
+// SupportsSecurity reports whether the controller supports Security Send / Receive.
+func (c *NVMeController) SupportsSecurity() bool { return c.OACS&oacsSecurity != 0 }
+
+// SupportsFormat reports whether the controller supports the Format NVM command.
+func (c *NVMeController) SupportsFormat() bool { return c.OACS&oacsFormat != 0 }
+
+// SupportsFirmwareUpdate reports whether the controller supports Firmware Commit and Firmware
+// Image Download.
+func (c *NVMeController) SupportsFirmwareUpdate() bool { return c.OACS&oacsFirmware != 0 }
+
+// SupportsFirmwareActivateNoReset reports whether firmware can be activated without a reset.
+func (c *NVMeController) SupportsFirmwareActivateNoReset() bool {
+	return c.FRMW&frmwActivateNoReset != 0
+}
+
+// FirmwareSlots returns the number of firmware slots supported by the controller.
+func (c *NVMeController) FirmwareSlots() int {
+	return int(c.FRMW>>frmwSlotsShift) & frmwSlotsMask
 }
 
+// SupportsNamespaceManagement reports whether the controller supports Namespace Management and
+// Namespace Attachment.
+func (c *NVMeController) SupportsNamespaceManagement() bool { return c.OACS&oacsNsMgmt != 0 }
+
+// SupportsSelfTest reports whether the controller supports Device Self-test.
+func (c *NVMeController) SupportsSelfTest() bool { return c.OACS&oacsSelfTest != 0 }
+
+// SupportsDirectives reports whether the controller supports Directive Send / Receive.
+func (c *NVMeController) SupportsDirectives() bool { return c.OACS&oacsDirectives != 0 }
+
+// SupportsMI reports whether the controller supports NVMe-MI Send / Receive.
+func (c *NVMeController) SupportsMI() bool { return c.OACS&oacsMI != 0 }
+
+// SupportsVirtualization reports whether the controller supports Virtualization Management.
+func (c *NVMeController) SupportsVirtualization() bool { return c.OACS&oacsVirtMgmt != 0 }
+
+// SupportsLBAStatus reports whether the controller supports Get LBA Status.
+func (c *NVMeController) SupportsLBAStatus() bool { return c.OACS&oacsGetLBAStatus != 0 }
+
+// SupportsLockdown reports whether the controller supports the Command and Feature Lockdown
+// capability.
+func (c *NVMeController) SupportsLockdown() bool { return c.OACS&oacsLockdown != 0 }
+
+// SupportsSanitize reports whether the controller supports at least one sanitize action.
+func (c *NVMeController) SupportsSanitize() bool {
+	return c.SANICAP&(sanicapCryptoErase|sanicapBlockErase|sanicapOverwrite) != 0
+}
+
+// SupportsCryptoErase reports whether the controller supports the Crypto Erase sanitize action.
+func (c *NVMeController) SupportsCryptoErase() bool { return c.SANICAP&sanicapCryptoErase != 0 }
+
+// SupportsBlockErase reports whether the controller supports the Block Erase sanitize action.
+func (c *NVMeController) SupportsBlockErase() bool { return c.SANICAP&sanicapBlockErase != 0 }
+
+// SupportsOverwrite reports whether the controller supports the Overwrite sanitize action.
+func (c *NVMeController) SupportsOverwrite() bool { return c.SANICAP&sanicapOverwrite != 0 }
+
+// SupportsSMARTPerNamespace reports whether the SMART / Health log page is available on a
+// per-namespace basis.
+func (c *NVMeController) SupportsSMARTPerNamespace() bool { return c.LPA&lpaSMARTPerNS != 0 }
+
+// SupportsTelemetry reports whether the controller supports the Telemetry log pages.
+func (c *NVMeController) SupportsTelemetry() bool { return c.LPA&lpaTelemetry != 0 }
+
+// SupportsPersistentEventLog reports whether the controller supports the Persistent Event log
+// page.
+func (c *NVMeController) SupportsPersistentEventLog() bool { return c.LPA&lpaPersistentEvent != 0 }
+
+// SupportsCompare reports whether the controller supports the Compare command.
+func (c *NVMeController) SupportsCompare() bool { return c.ONCS&oncsCompare != 0 }
+
+// SupportsWriteUncorrectable reports whether the controller supports Write Uncorrectable.
+func (c *NVMeController) SupportsWriteUncorrectable() bool { return c.ONCS&oncsWriteUncorr != 0 }
+
+// SupportsDSM reports whether the controller supports Dataset Management (i.e. deallocate / TRIM).
+func (c *NVMeController) SupportsDSM() bool { return c.ONCS&oncsDSM != 0 }
+
+// SupportsWriteZeroes reports whether the controller supports Write Zeroes.
+func (c *NVMeController) SupportsWriteZeroes() bool { return c.ONCS&oncsWriteZeroes != 0 }
+
+// SupportsReservations reports whether the controller supports reservations.
+func (c *NVMeController) SupportsReservations() bool { return c.ONCS&oncsReservations != 0 }
+
+// SupportsVerify reports whether the controller supports the Verify command.
+func (c *NVMeController) SupportsVerify() bool { return c.ONCS&oncsVerify != 0 }
+
+// SupportsCopy reports whether the controller supports the Copy command.
+func (c *NVMeController) SupportsCopy() bool { return c.ONCS&oncsCopy != 0 }
+
+// SupportsEnduranceGroups reports whether the controller supports Endurance Groups.
+func (c *NVMeController) SupportsEnduranceGroups() bool {
+	return c.CTRATT&ctrattEnduranceGroups != 0
+}
+
+// SupportsNVMSets reports whether the controller supports NVM Sets.
+func (c *NVMeController) SupportsNVMSets() bool { return c.CTRATT&ctrattNVMSets != 0 }
+
+// SupportsFDP reports whether the controller supports Flexible Data Placement.
+func (c *NVMeController) SupportsFDP() bool { return c.CTRATT&ctrattFDP != 0 }
+
 // Print outputs the attributes of an NVMe controller in a pretty-print style.
 func (c *NVMeController) Print(w io.Writer) {
-	fmt.Fprintf(w, "Vendor ID          : %#04x\n", c.VendorID)
-	fmt.Fprintf(w, "Model number       : %s\n", c.ModelNumber)
-	fmt.Fprintf(w, "Serial number      : %s\n", c.SerialNumber)
-	fmt.Fprintf(w, "Firmware version   : %s\n", c.FirmwareVersion)
-	fmt.Fprintf(w, "IEEE OUI identifier: %#06x\n", c.OUI)
-	fmt.Fprintf(w, "Max. data xfer size: %d pages\n", c.MaxDataXferSize)
+	fmt.Fprintf(w, msg(MsgCtrlVendorID), c.VendorID)
+	fmt.Fprintf(w, msg(MsgCtrlModelNumber), c.ModelNumber)
+	fmt.Fprintf(w, msg(MsgCtrlSerialNumber), c.SerialNumber)
+	fmt.Fprintf(w, msg(MsgCtrlFirmwareVersion), c.FirmwareVersion)
+	fmt.Fprintf(w, msg(MsgCtrlVersion), c.Version)
+	fmt.Fprintf(w, msg(MsgCtrlOUI), c.OUI)
+
+	if name := c.OUIVendor(); name != "" {
+		fmt.Fprintf(w, msg(MsgCtrlOUIVendor), name)
+	}
+
+	fmt.Fprintf(w, msg(MsgCtrlSubsystemNQN), c.SubsystemNQN)
+	fmt.Fprintf(w, msg(MsgCtrlID), c.ControllerID)
+	fmt.Fprintf(w, msg(MsgCtrlType), c.ControllerType)
+
+	if c.FGUID != "" {
+		fmt.Fprintf(w, msg(MsgCtrlFGUID), c.FGUID)
+	}
+
+	if c.MaxDataXferSize > 0 {
+		fmt.Fprintf(w, msg(MsgCtrlMaxDataXferSize), c.MaxDataXferSize)
+	} else {
+		fmt.Fprint(w, msg(MsgCtrlMaxDataXferNoLimit))
+	}
 }
 
-// nvmeIdentController is the low-level struct to decode the response of an NVME_ADMIN_IDENTIFY
-// controller ioctl.
-type nvmeIdentController struct {
-	VendorID     uint16                  // PCI Vendor ID
-	Ssvid        uint16                  // PCI Subsystem Vendor ID
-	SerialNumber [20]byte                // Serial Number
-	ModelNumber  [40]byte                // Model Number
-	Firmware     [8]byte                 // Firmware Revision
-	Rab          uint8                   // Recommended Arbitration Burst
-	IEEE         [3]byte                 // IEEE OUI Identifier
-	Cmic         uint8                   // Controller Multi-Path I/O and Namespace Sharing Capabilities
-	Mdts         uint8                   // Maximum Data Transfer Size
-	Cntlid       uint16                  // Controller ID
-	Ver          uint32                  // Version
-	Rtd3r        uint32                  // RTD3 Resume Latency
-	Rtd3e        uint32                  // RTD3 Entry Latency
-	Oaes         uint32                  // Optional Asynchronous Events Supported
-	Rsvd96       [160]byte               // ...
-	Oacs         uint16                  // Optional Admin Command Support
-	Acl          uint8                   // Abort Command Limit
-	Aerl         uint8                   // Asynchronous Event Request Limit
-	Frmw         uint8                   // Firmware Updates
-	Lpa          uint8                   // Log Page Attributes
-	Elpe         uint8                   // Error Log Page Entries
-	Npss         uint8                   // Number of Power States Support
-	Avscc        uint8                   // Admin Vendor Specific Command Configuration
-	Apsta        uint8                   // Autonomous Power State Transition Attributes
-	Wctemp       uint16                  // Warning Composite Temperature Threshold
-	Cctemp       uint16                  // Critical Composite Temperature Threshold
-	Mtfa         uint16                  // Maximum Time for Firmware Activation
-	Hmpre        uint32                  // Host Memory Buffer Preferred Size
-	Hmmin        uint32                  // Host Memory Buffer Minimum Size
-	Tnvmcap      [16]byte                // Total NVM Capacity
-	Unvmcap      [16]byte                // Unallocated NVM Capacity
-	Rpmbs        uint32                  // Replay Protected Memory Block Support
-	Rsvd316      [196]byte               // ...
-	Sqes         uint8                   // Submission Queue Entry Size
-	Cqes         uint8                   // Completion Queue Entry Size
-	Rsvd514      [2]byte                 // (defined in NVMe 1.3 spec)
-	Nn           uint32                  // Number of Namespaces
-	Oncs         uint16                  // Optional NVM Command Support
-	Fuses        uint16                  // Fused Operation Support
-	Fna          uint8                   // Format NVM Attributes
-	Vwc          uint8                   // Volatile Write Cache
-	Awun         uint16                  // Atomic Write Unit Normal
-	Awupf        uint16                  // Atomic Write Unit Power Fail
-	Nvscc        uint8                   // NVM Vendor Specific Command Configuration
-	Rsvd531      uint8                   // ...
-	Acwu         uint16                  // Atomic Compare & Write Unit
-	Rsvd534      [2]byte                 // ...
-	Sgls         uint32                  // SGL Support
-	Rsvd540      [1508]byte              // ...
-	Psd          [32]nvmeIdentPowerState // Power State Descriptors
-	Vs           [1024]byte              // Vendor Specific
-} // 4096 bytes
+// decode converts the raw identify controller data into an NVMeController. The maximum data
+// transfer size assumes a minimum memory page size of 4 KiB.
+func (c *nvmeIdentController) decode() NVMeController {
+	ctrl := NVMeController{
+		VendorID:        c.VendorID,
+		ModelNumber:     string(c.ModelNumber[:]),
+		SerialNumber:    string(bytes.TrimSpace(c.SerialNumber[:])),
+		FirmwareVersion: string(c.Firmware[:]),
+		Version:         Version(c.Ver),
+		MaxDataXferSize: mdtsBytes(c.Mdts, defaultPageSize),
+		// Convert IEEE OUI ID from big-endian
+		OUI:     uint32(c.IEEE[0]) | uint32(c.IEEE[1])<<8 | uint32(c.IEEE[2])<<16,
+		OACS:    c.Oacs,
+		ONCS:    c.Oncs,
+		LPA:     c.Lpa,
+		FRMW:    c.Frmw,
+		SANICAP: c.Sanicap,
+		CTRATT:  c.Ctratt,
+
+		SubsystemNQN:   string(bytes.TrimRight(c.Subnqn[:], "\x00")),
+		ControllerID:   c.Cntlid,
+		CMIC:           c.Cmic,
+		ControllerType: ControllerType(c.Cntrltype),
+	}
+
+	if c.Fguid != [16]byte{} {
+		ctrl.FGUID = formatUUID(c.Fguid[:])
+	}
+
+	// NPSS is zero-based
+	for i := 0; i <= int(c.Npss) && i < len(c.Psd); i++ {
+		ctrl.PowerStates = append(ctrl.PowerStates, c.Psd[i].decode())
+	}
+
+	return ctrl
+}
@@ -0,0 +1,211 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// oacsDirectives is the Directives support bit of the OACS field.
+const oacsDirectives = 1 << 5
+
+// Directive types, cf. NVM Express Base Specification 2.0c, figure 427.
+const (
+	DirectiveIdentify = 0x00
+	DirectiveStreams  = 0x01
+)
+
+// Directive operations.
+const (
+	dirIdentifyRecvParams = 0x01 // Identify: Return Parameters
+	dirIdentifySendEnable = 0x01 // Identify: Enable Directive
+
+	dirStreamsRecvParams     = 0x01 // Streams: Return Parameters
+	dirStreamsRecvStatus     = 0x02 // Streams: Get Status
+	dirStreamsRecvAllocate   = 0x03 // Streams: Allocate Resources
+	dirStreamsSendReleaseID  = 0x01 // Streams: Release Identifier
+	dirStreamsSendReleaseRes = 0x02 // Streams: Release Resources
+)
+
+// DirectiveStatus describes a directive type's support, as reported by the Identify directive.
+type DirectiveStatus struct {
+	Supported  bool
+	Enabled    bool
+	Persistent bool // Enablement persists across controller level resets
+}
+
+// GetDirectives returns the status of each directive type supported for the specified namespace.
+func (d *NVMeDevice) GetDirectives(nsid uint32) (map[uint8]DirectiveStatus, error) {
+	buf := make([]byte, 4096)
+
+	if _, err := d.directiveRecv(nsid, DirectiveIdentify, dirIdentifyRecvParams, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	// Supported, enabled and persistent directives are bitmaps at offsets 0, 32 and 64
+	bit := func(off, dtype int) bool {
+		return buf[off+dtype/8]&(1<<(dtype%8)) != 0
+	}
+
+	dirs := make(map[uint8]DirectiveStatus)
+
+	for dtype := 0; dtype < 256; dtype++ {
+		if bit(0, dtype) {
+			dirs[uint8(dtype)] = DirectiveStatus{
+				Supported:  true,
+				Enabled:    bit(32, dtype),
+				Persistent: bit(64, dtype),
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+// EnableStreams enables or disables the Streams directive for the specified namespace.
+func (d *NVMeDevice) EnableStreams(nsid uint32, enable bool) error {
+	cdw12 := uint32(DirectiveStreams) << 8
+	if enable {
+		cdw12 |= 1
+	}
+
+	_, err := d.directiveSend(nsid, DirectiveIdentify, dirIdentifySendEnable, 0, cdw12, nil)
+	return err
+}
+
+// StreamParams is the decoded Streams directive Return Parameters data structure.
+type StreamParams struct {
+	MaxStreams      uint16 // Maximum Streams Limit
+	SubsysAvailable uint16 // NVM subsystem streams available
+	SubsysOpen      uint16 // NVM subsystem streams open
+	WriteSize       uint32 // Stream Write Size, in logical blocks
+	Granularity     uint16 // Stream Granularity Size, in units of the stream write size
+	NSAllocated     uint16 // Namespace streams allocated
+	NSOpen          uint16 // Namespace streams open
+}
+
+// Print outputs the stream parameters in a pretty-print style.
+func (p *StreamParams) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgStreamsMax), p.MaxStreams)
+	fmt.Fprintf(w, msg(MsgStreamsSubsys), p.SubsysAvailable, p.SubsysOpen)
+	fmt.Fprintf(w, msg(MsgStreamsNamespace), p.NSAllocated, p.NSOpen)
+	fmt.Fprintf(w, msg(MsgStreamsWriteSize), p.WriteSize, p.Granularity)
+}
+
+// GetStreamParams returns the Streams directive parameters of the specified namespace.
+func (d *NVMeDevice) GetStreamParams(nsid uint32) (*StreamParams, error) {
+	buf := make([]byte, 32)
+
+	if _, err := d.directiveRecv(nsid, DirectiveStreams, dirStreamsRecvParams, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	return &StreamParams{
+		MaxStreams:      binary.LittleEndian.Uint16(buf[0:]),
+		SubsysAvailable: binary.LittleEndian.Uint16(buf[2:]),
+		SubsysOpen:      binary.LittleEndian.Uint16(buf[4:]),
+		WriteSize:       binary.LittleEndian.Uint32(buf[16:]),
+		Granularity:     binary.LittleEndian.Uint16(buf[20:]),
+		NSAllocated:     binary.LittleEndian.Uint16(buf[22:]),
+		NSOpen:          binary.LittleEndian.Uint16(buf[24:]),
+	}, nil
+}
+
+// GetStreamStatus returns the identifiers of the open streams of the specified namespace. At most
+// 2047 identifiers are returned.
+func (d *NVMeDevice) GetStreamStatus(nsid uint32) ([]uint16, error) {
+	buf := make([]byte, 4096)
+
+	if _, err := d.directiveRecv(nsid, DirectiveStreams, dirStreamsRecvStatus, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	n := int(binary.LittleEndian.Uint16(buf[0:]))
+	if limit := len(buf)/2 - 1; n > limit {
+		n = limit
+	}
+
+	ids := make([]uint16, n)
+	for i := range ids {
+		ids[i] = binary.LittleEndian.Uint16(buf[2+i*2:])
+	}
+
+	return ids, nil
+}
+
+// AllocateStreams requests the allocation of stream resources for the exclusive use of the
+// specified namespace, returning the number of streams allocated.
+func (d *NVMeDevice) AllocateStreams(nsid uint32, n uint16) (uint16, error) {
+	res, err := d.directiveRecv(nsid, DirectiveStreams, dirStreamsRecvAllocate, 0, uint32(n), nil)
+	return uint16(res), err
+}
+
+// ReleaseStream releases the specified stream identifier.
+func (d *NVMeDevice) ReleaseStream(nsid uint32, id uint16) error {
+	_, err := d.directiveSend(nsid, DirectiveStreams, dirStreamsSendReleaseID, id, 0, nil)
+	return err
+}
+
+// ReleaseStreamResources releases all stream resources allocated to the specified namespace.
+func (d *NVMeDevice) ReleaseStreamResources(nsid uint32) error {
+	_, err := d.directiveSend(nsid, DirectiveStreams, dirStreamsSendReleaseRes, 0, 0, nil)
+	return err
+}
+
+// directiveRecv issues a Directive Receive command, returning the command-specific result.
+func (d *NVMeDevice) directiveRecv(nsid uint32, dtype, doper uint8, dspec uint16, cdw12 uint32,
+	buf []byte) (uint32, error) {
+
+	return d.directive(NVME_ADMIN_DIRECTIVE_RECV, nsid, dtype, doper, dspec, cdw12, buf)
+}
+
+// directiveSend issues a Directive Send command, returning the command-specific result.
+func (d *NVMeDevice) directiveSend(nsid uint32, dtype, doper uint8, dspec uint16, cdw12 uint32,
+	buf []byte) (uint32, error) {
+
+	return d.directive(NVME_ADMIN_DIRECTIVE_SEND, nsid, dtype, doper, dspec, cdw12, buf)
+}
+
+func (d *NVMeDevice) directive(opcode uint8, nsid uint32, dtype, doper uint8, dspec uint16,
+	cdw12 uint32, buf []byte) (uint32, error) {
+
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return 0, err
+	}
+
+	if idCtrlr.Oacs&oacsDirectives == 0 {
+		return 0, fmt.Errorf("directives: %w", ErrNotSupported)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: opcode,
+		nsid:   nsid,
+		cdw11:  uint32(doper) | uint32(dtype)<<8 | uint32(dspec)<<16,
+		cdw12:  cdw12,
+	}
+
+	if len(buf) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&buf[0])))
+		cmd.data_len = uint32(len(buf))
+		cmd.cdw10 = uint32(len(buf)/4) - 1 // Number of Dwords (0's based)
+	}
+
+	err = d.adminCmd(&cmd, buf)
+	return cmd.result, err
+}
@@ -0,0 +1,202 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// discoveryRetries is the number of times the discovery log is re-read if its generation counter
+// changes while it is being read.
+const discoveryRetries = 10
+
+// Transport Type (TRTYPE) values.
+const (
+	TransportRDMA     uint8 = 1
+	TransportFC       uint8 = 2
+	TransportTCP      uint8 = 3
+	TransportLoopback uint8 = 254
+)
+
+// Address Family (ADRFAM) values.
+const (
+	AddrFamilyIPv4      uint8 = 1
+	AddrFamilyIPv6      uint8 = 2
+	AddrFamilyIB        uint8 = 3
+	AddrFamilyFC        uint8 = 4
+	AddrFamilyIntraHost uint8 = 254
+)
+
+// Subsystem Type (SUBTYPE) values.
+const (
+	SubsystemReferral         uint8 = 1 // Referral to another discovery service
+	SubsystemNVMe             uint8 = 2
+	SubsystemCurrentDiscovery uint8 = 3 // The discovery controller reporting the log
+)
+
+var transportNames = map[uint8]string{
+	TransportRDMA:     "rdma",
+	TransportFC:       "fc",
+	TransportTCP:      "tcp",
+	TransportLoopback: "loop",
+}
+
+var addrFamilyNames = map[uint8]string{
+	AddrFamilyIPv4:      "ipv4",
+	AddrFamilyIPv6:      "ipv6",
+	AddrFamilyIB:        "ib",
+	AddrFamilyFC:        "fc",
+	AddrFamilyIntraHost: "intra-host",
+}
+
+var subsystemTypeNames = map[uint8]string{
+	SubsystemReferral:         "referral",
+	SubsystemNVMe:             "nvme subsystem",
+	SubsystemCurrentDiscovery: "current discovery subsystem",
+}
+
+// DiscoveryEntry is a record of the Discovery log page, describing a subsystem port which the
+// host may connect to.
+type DiscoveryEntry struct {
+	TransportType    uint8
+	AddrFamily       uint8
+	SubsystemType    uint8
+	TransportReq     uint8 // Transport Requirements (TREQ)
+	PortID           uint16
+	ControllerID     uint16 // 0xffff for the dynamic controller model
+	AdminQueueSize   uint16 // Maximum admin submission queue size
+	Flags            uint16 // Entry Flags (EFLAGS)
+	TransportSvcID   string // Transport service identifier, e.g. a TCP port number
+	SubsystemNQN     string
+	TransportAddr    string
+	TransportSubtype [256]byte // Transport Specific Address Subtype (TSAS)
+}
+
+// Transport returns the name of the transport type, as used by nvme-cli.
+func (e *DiscoveryEntry) Transport() string {
+	return enumName(transportNames, e.TransportType)
+}
+
+// Family returns the name of the address family, as used by nvme-cli.
+func (e *DiscoveryEntry) Family() string {
+	return enumName(addrFamilyNames, e.AddrFamily)
+}
+
+// Subsystem returns a description of the subsystem type.
+func (e *DiscoveryEntry) Subsystem() string {
+	return enumName(subsystemTypeNames, e.SubsystemType)
+}
+
+func enumName(names map[uint8]string, v uint8) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%d)", v)
+}
+
+// DiscoveryLog is the decoded Discovery log page (0x70) of a fabrics discovery controller.
+type DiscoveryLog struct {
+	Generation uint64
+	Entries    []DiscoveryEntry
+}
+
+// Print outputs the discovery log in a pretty-print style.
+func (l *DiscoveryLog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgDiscoveryHeader), l.Generation, len(l.Entries))
+
+	for _, e := range l.Entries {
+		fmt.Fprintf(w, msg(MsgDiscoveryEntry), e.Transport(), e.Family(), e.TransportAddr,
+			e.TransportSvcID, e.SubsystemNQN, e.PortID, e.Subsystem())
+	}
+}
+
+// GetDiscoveryLog reads the Discovery log page of a fabrics discovery controller, e.g. a
+// /dev/nvmeX device connected to a discovery subsystem. If the discovery log changes while it is
+// being read, as indicated by its generation counter, it is read again.
+func (d *NVMeDevice) GetDiscoveryLog() (*DiscoveryLog, error) {
+	hdr := make([]byte, 1024)
+
+	if err := d.getLog(NVME_LOG_DISCOVERY, logPageArgs{}, hdr); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < discoveryRetries; i++ {
+		var h nvmeDiscoveryLogHeader
+
+		h.unmarshal(hdr)
+
+		if h.Numrec > 0xffff {
+			return nil, fmt.Errorf("invalid number of discovery log records %d", h.Numrec)
+		}
+
+		buf, err := d.readLog(NVME_LOG_DISCOVERY, logPageArgs{}, 1024*(1+int(h.Numrec)))
+		if err != nil {
+			return nil, err
+		}
+
+		// The generation counter of the header read together with the records must match the
+		// one read previously, otherwise the records may be inconsistent
+		if bytes.Equal(buf[:8], hdr[:8]) {
+			return decodeDiscoveryLog(buf)
+		}
+
+		copy(hdr, buf[:1024])
+	}
+
+	return nil, fmt.Errorf("discovery log changed during %d consecutive reads", discoveryRetries)
+}
+
+func decodeDiscoveryLog(buf []byte) (*DiscoveryLog, error) {
+	var h nvmeDiscoveryLogHeader
+
+	h.unmarshal(buf)
+
+	if 1024*(1+h.Numrec) > uint64(len(buf)) {
+		return nil, fmt.Errorf("discovery log truncated")
+	}
+
+	l := &DiscoveryLog{Generation: h.Genctr, Entries: make([]DiscoveryEntry, h.Numrec)}
+
+	for i := range l.Entries {
+		var raw nvmeDiscoveryLogEntry
+
+		raw.unmarshal(buf[1024*(i+1):])
+
+		l.Entries[i] = DiscoveryEntry{
+			TransportType:    raw.Trtype,
+			AddrFamily:       raw.Adrfam,
+			SubsystemType:    raw.Subtype,
+			TransportReq:     raw.Treq,
+			PortID:           raw.Portid,
+			ControllerID:     raw.Cntlid,
+			AdminQueueSize:   raw.Asqsz,
+			Flags:            raw.Eflags,
+			TransportSvcID:   discoveryString(raw.Trsvcid[:]),
+			SubsystemNQN:     discoveryString(raw.Subnqn[:]),
+			TransportAddr:    discoveryString(raw.Traddr[:]),
+			TransportSubtype: raw.Tsas,
+		}
+	}
+
+	return l, nil
+}
+
+// discoveryString converts a space or NUL padded ASCII field of a discovery log entry.
+func discoveryString(b []byte) string {
+	return string(bytes.TrimRight(b, " \x00"))
+}
@@ -0,0 +1,53 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"io"
+)
+
+// DumpLogPage writes the raw, undecoded content of a log page to w, e.g. to preserve the exact
+// data returned by the device for a vendor escalation. If length is zero, the length in which the
+// log page is collected by Collect is used, or 4096 bytes for log pages unknown to this package.
+// The Retain Asynchronous Event bit is set, so that dumping a log page does not clear any event
+// associated with it.
+func (d *NVMeDevice) DumpLogPage(w io.Writer, logID uint8, nsid uint32, length int) error {
+	if length == 0 {
+		if length = logPageLen[logID]; length == 0 {
+			length = 4096
+		}
+	}
+
+	buf, err := d.readLog(logID, logPageArgs{nsid: nsid, rae: true, uuidIndex: d.vendorUUIDIndex(logID)}, length)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// DumpIdentify writes the raw, undecoded 4096-byte identify data structure of the specified CNS
+// value to w. nsid is ignored by the controller for CNS values which are not namespace specific.
+func (d *NVMeDevice) DumpIdentify(w io.Writer, cns uint8, nsid uint32) error {
+	buf := make([]byte, 4096)
+
+	if err := d.identify(cns, NVME_CSI_NVM, nsid, 0, buf); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,312 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Feature Identifier Supported and Effects data structure bits, cf. NVM Express Base
+// Specification 2.0c, figure 233.
+const (
+	fidEffectSupported = 1 << 0  // FSUPP
+	fidEffectUDCC      = 1 << 1  // User Data Content Change
+	fidEffectNCC       = 1 << 2  // Namespace Capability Change
+	fidEffectNIC       = 1 << 3  // Namespace Inventory Change
+	fidEffectCCC       = 1 << 4  // Controller Capability Change
+	fidEffectUSS       = 1 << 19 // UUID Selection Supported
+)
+
+// FeatureScope is a bitmask of the scopes to which a feature applies.
+type FeatureScope uint16
+
+const (
+	FeatureScopeNamespace      FeatureScope = 1 << 0
+	FeatureScopeController     FeatureScope = 1 << 1
+	FeatureScopeNVMSet         FeatureScope = 1 << 2
+	FeatureScopeEnduranceGroup FeatureScope = 1 << 3
+	FeatureScopeDomain         FeatureScope = 1 << 4
+	FeatureScopeNVMSubsystem   FeatureScope = 1 << 5
+)
+
+func (s FeatureScope) String() string {
+	names := []string{"namespace", "controller", "NVM set", "endurance group", "domain",
+		"NVM subsystem"}
+
+	var scopes []string
+
+	for i, name := range names {
+		if s&(1<<i) != 0 {
+			scopes = append(scopes, name)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return "none"
+	}
+
+	return strings.Join(scopes, "|")
+}
+
+// FeatureEffects describes a supported feature, as reported by the Feature Identifiers Supported
+// and Effects log page (0x12).
+type FeatureEffects struct {
+	FID                      uint8
+	UserDataChange           bool // May change user data content
+	NamespaceCapChange       bool // May change namespace capabilities
+	NamespaceInventoryChange bool // May change the namespace inventory
+	ControllerCapChange      bool // May change controller capabilities
+	UUIDSelection            bool // Supports selection of a UUID by Set / Get Features
+	Scope                    FeatureScope
+}
+
+func (e FeatureEffects) effectsString() string {
+	return effectsString("UDCC", e.UserDataChange, e.NamespaceCapChange,
+		e.NamespaceInventoryChange, e.ControllerCapChange, e.UUIDSelection)
+}
+
+// effectsString returns the abbreviations of the effects of a command or feature, joined by "|",
+// or "none". The abbreviation of a user data change differs between commands and features.
+func effectsString(udc string, userData, nsCap, nsInventory, ctrlCap, uuid bool) string {
+	var effects []string
+
+	for _, e := range []struct {
+		set  bool
+		name string
+	}{{userData, udc}, {nsCap, "NCC"}, {nsInventory, "NIC"}, {ctrlCap, "CCC"}, {uuid, "USS"}} {
+		if e.set {
+			effects = append(effects, e.name)
+		}
+	}
+
+	if len(effects) == 0 {
+		return "none"
+	}
+
+	return strings.Join(effects, "|")
+}
+
+// FeatureEffectsLog is the list of supported features from log page 0x12.
+type FeatureEffectsLog []FeatureEffects
+
+// Print outputs the supported features and their effects in a pretty-print style.
+func (l FeatureEffectsLog) Print(w io.Writer) {
+	for _, e := range l {
+		fmt.Fprintf(w, msg(MsgFeatureEffects), e.FID, e.Scope, e.effectsString())
+	}
+}
+
+// GetFeatureEffects reads the Feature Identifiers Supported and Effects log page, returning an
+// entry for each feature supported by the controller.
+func (d *NVMeDevice) GetFeatureEffects() (FeatureEffectsLog, error) {
+	effects, err := d.readFeatureEffects()
+	if err != nil {
+		return nil, err
+	}
+
+	var l FeatureEffectsLog
+
+	for fid, e := range effects {
+		if e&fidEffectSupported == 0 {
+			continue
+		}
+
+		l = append(l, FeatureEffects{
+			FID:                      uint8(fid),
+			UserDataChange:           e&fidEffectUDCC != 0,
+			NamespaceCapChange:       e&fidEffectNCC != 0,
+			NamespaceInventoryChange: e&fidEffectNIC != 0,
+			ControllerCapChange:      e&fidEffectCCC != 0,
+			UUIDSelection:            e&fidEffectUSS != 0,
+			Scope:                    FeatureScope(e >> 20),
+		})
+	}
+
+	return l, nil
+}
+
+func (d *NVMeDevice) readFeatureEffects() ([256]uint32, error) {
+	var effects [256]uint32
+
+	buf := make([]byte, 1024)
+
+	if err := d.getLogPage(NVME_LOG_FID_EFFECTS, 0xffffffff, false, buf); err != nil {
+		return effects, err
+	}
+
+	binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &effects)
+
+	return effects, nil
+}
+
+// FeatureCapabilities describes the capabilities of a feature, as reported by a Get Features
+// command with the "supported capabilities" select value.
+type FeatureCapabilities struct {
+	Saveable          bool
+	NamespaceSpecific bool
+	Changeable        bool
+}
+
+// GetFeatureCapabilities returns whether the specified feature is saveable, namespace specific
+// and changeable.
+func (d *NVMeDevice) GetFeatureCapabilities(fid uint8) (FeatureCapabilities, error) {
+	val, err := d.getFeature(fid, 0, NVME_FEAT_SEL_SUPPORTED, 0, nil)
+	if err != nil {
+		return FeatureCapabilities{}, err
+	}
+
+	return FeatureCapabilities{
+		Saveable:          val&(1<<0) != 0,
+		NamespaceSpecific: val&(1<<1) != 0,
+		Changeable:        val&(1<<2) != 0,
+	}, nil
+}
+
+// Commands Supported and Effects data structure bits, cf. NVM Express Base Specification 2.0c,
+// figure 211.
+const (
+	cmdEffectSupported = 1 << 0  // CSUPP
+	cmdEffectLBCC      = 1 << 1  // Logical Block Content Change
+	cmdEffectNCC       = 1 << 2  // Namespace Capability Change
+	cmdEffectNIC       = 1 << 3  // Namespace Inventory Change
+	cmdEffectCCC       = 1 << 4  // Controller Capability Change
+	cmdEffectUSS       = 1 << 19 // UUID Selection Supported
+)
+
+// CommandSubmission is the Command Submission and Execution (CSE) restriction of a command.
+type CommandSubmission uint8
+
+const (
+	SubmissionUnrestricted CommandSubmission = 0x0 // No restriction
+	SubmissionNamespace    CommandSubmission = 0x1 // No other command for the same namespace may be outstanding
+	SubmissionController   CommandSubmission = 0x2 // No other command for any namespace may be outstanding
+)
+
+func (s CommandSubmission) String() string {
+	switch s {
+	case SubmissionUnrestricted:
+		return "unrestricted"
+	case SubmissionNamespace:
+		return "exclusive per namespace"
+	case SubmissionController:
+		return "exclusive per controller"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(s))
+}
+
+// CommandEffects describes a supported command, as reported by the Commands Supported and Effects
+// log page (0x05).
+type CommandEffects struct {
+	Opcode                   uint8
+	UserDataChange           bool // May change logical block content
+	NamespaceCapChange       bool // May change namespace capabilities
+	NamespaceInventoryChange bool // May change the namespace inventory
+	ControllerCapChange      bool // May change controller capabilities
+	UUIDSelection            bool // Supports selection of a UUID
+	Submission               CommandSubmission
+}
+
+func (e CommandEffects) effectsString() string {
+	return effectsString("LBCC", e.UserDataChange, e.NamespaceCapChange,
+		e.NamespaceInventoryChange, e.ControllerCapChange, e.UUIDSelection)
+}
+
+// CommandEffectsLog lists the admin and I/O commands supported by the controller, from log page
+// 0x05.
+type CommandEffectsLog struct {
+	Admin []CommandEffects
+	IO    []CommandEffects // Commands of the controller's selected I/O command set
+}
+
+// AdminCommand returns the effects of an admin command, and whether it is supported.
+func (l *CommandEffectsLog) AdminCommand(opcode uint8) (CommandEffects, bool) {
+	return findCommand(l.Admin, opcode)
+}
+
+// IOCommand returns the effects of an I/O command, and whether it is supported.
+func (l *CommandEffectsLog) IOCommand(opcode uint8) (CommandEffects, bool) {
+	return findCommand(l.IO, opcode)
+}
+
+func findCommand(cmds []CommandEffects, opcode uint8) (CommandEffects, bool) {
+	for _, e := range cmds {
+		if e.Opcode == opcode {
+			return e, true
+		}
+	}
+
+	return CommandEffects{}, false
+}
+
+// Print outputs the supported commands and their effects in a pretty-print style.
+func (l *CommandEffectsLog) Print(w io.Writer) {
+	for _, e := range l.Admin {
+		fmt.Fprintf(w, msg(MsgCmdEffects), "Admin", e.Opcode, e.Submission, e.effectsString())
+	}
+
+	for _, e := range l.IO {
+		fmt.Fprintf(w, msg(MsgCmdEffects), "I/O", e.Opcode, e.Submission, e.effectsString())
+	}
+}
+
+// GetCommandEffects reads the Commands Supported and Effects log page, e.g. to check that a
+// command is supported, or whether it may change user data, before issuing it.
+func (d *NVMeDevice) GetCommandEffects() (*CommandEffectsLog, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.getLogPage(NVME_LOG_CMD_EFFECTS, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeCommandEffects(buf), nil
+}
+
+func decodeCommandEffects(buf []byte) *CommandEffectsLog {
+	var raw nvmeCmdEffectsLog
+
+	raw.unmarshal(buf)
+
+	return &CommandEffectsLog{
+		Admin: supportedCommands(raw.Acs[:]),
+		IO:    supportedCommands(raw.Iocs[:]),
+	}
+}
+
+func supportedCommands(entries []uint32) []CommandEffects {
+	var cmds []CommandEffects
+
+	for opcode, e := range entries {
+		if e&cmdEffectSupported == 0 {
+			continue
+		}
+
+		cmds = append(cmds, CommandEffects{
+			Opcode:                   uint8(opcode),
+			UserDataChange:           e&cmdEffectLBCC != 0,
+			NamespaceCapChange:       e&cmdEffectNCC != 0,
+			NamespaceInventoryChange: e&cmdEffectNIC != 0,
+			ControllerCapChange:      e&cmdEffectCCC != 0,
+			UUIDSelection:            e&cmdEffectUSS != 0,
+			Submission:               CommandSubmission(e >> 16 & 0x7),
+		})
+	}
+
+	return cmds
+}
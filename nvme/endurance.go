@@ -0,0 +1,194 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ctrattEnduranceGroups is the Endurance Groups support bit of the CTRATT field.
+const ctrattEnduranceGroups = 1 << 4
+
+// EnduranceGroupInfo is the decoded Endurance Group Information log page (0x09).
+type EnduranceGroupInfo struct {
+	EnduranceGroupID  uint16
+	CritWarning       uint8 // See CritWarn*, although only spare, reliability and read-only apply
+	AvailSpare        uint8 // Percent
+	SpareThresh       uint8 // Percent
+	PercentUsed       uint8
+	DomainID          uint16
+	EnduranceEstimate *big.Int // Estimated data units that may be written over the lifetime
+	DataUnitsRead     *big.Int // In units of 1000 512-byte blocks
+	DataUnitsWritten  *big.Int // In units of 1000 512-byte blocks
+	MediaUnitsWritten *big.Int // In units of 1000 512-byte blocks
+	HostReads         *big.Int
+	HostWrites        *big.Int
+	MediaErrors       *big.Int
+	NumErrLogEntries  *big.Int
+	TotalCapacity     *big.Int // Bytes
+	UnallocCapacity   *big.Int // Bytes
+}
+
+// Print outputs the endurance group information in a pretty-print style.
+func (e *EnduranceGroupInfo) Print(w io.Writer) {
+	unit := big.NewInt(512 * 1000)
+
+	fmt.Fprintf(w, msg(MsgEnduranceHeader), e.EnduranceGroupID, e.CritWarning, e.AvailSpare,
+		e.SpareThresh, e.PercentUsed)
+	fmt.Fprintf(w, msg(MsgEnduranceUsage), formatBigBytes(e.TotalCapacity),
+		formatBigBytes(e.UnallocCapacity),
+		formatBigBytes(new(big.Int).Mul(e.DataUnitsRead, unit)),
+		formatBigBytes(new(big.Int).Mul(e.DataUnitsWritten, unit)),
+		formatBigBytes(new(big.Int).Mul(e.MediaUnitsWritten, unit)), e.MediaErrors)
+}
+
+// checkEnduranceGroups returns ErrNotSupported if the controller does not support endurance
+// groups.
+func (d *NVMeDevice) checkEnduranceGroups() error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Ctratt&ctrattEnduranceGroups == 0 {
+		return fmt.Errorf("endurance groups: %w", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// GetEnduranceGroupInfo reads the Endurance Group Information log page of the specified endurance
+// group.
+func (d *NVMeDevice) GetEnduranceGroupInfo(endgid uint16) (*EnduranceGroupInfo, error) {
+	if err := d.checkEnduranceGroups(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 512)
+
+	if err := d.getLog(NVME_LOG_ENDURANCE_GROUP, logPageArgs{lsi: endgid}, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeEnduranceGroupLog
+
+	raw.unmarshal(buf)
+
+	return &EnduranceGroupInfo{
+		EnduranceGroupID:  endgid,
+		CritWarning:       raw.CritWarning,
+		AvailSpare:        raw.AvailSpare,
+		SpareThresh:       raw.SpareThresh,
+		PercentUsed:       raw.PercentUsed,
+		DomainID:          raw.DomainID,
+		EnduranceEstimate: le128ToBigInt(raw.EnduranceEst),
+		DataUnitsRead:     le128ToBigInt(raw.DataUnitsRead),
+		DataUnitsWritten:  le128ToBigInt(raw.DataUnitsWritten),
+		MediaUnitsWritten: le128ToBigInt(raw.MediaUnitsWritten),
+		HostReads:         le128ToBigInt(raw.HostReads),
+		HostWrites:        le128ToBigInt(raw.HostWrites),
+		MediaErrors:       le128ToBigInt(raw.MediaErrors),
+		NumErrLogEntries:  le128ToBigInt(raw.NumErrLogEntries),
+		TotalCapacity:     le128ToBigInt(raw.TotalCapacity),
+		UnallocCapacity:   le128ToBigInt(raw.UnallocCapacity),
+	}, nil
+}
+
+// GetEnduranceGroupEvents reads the Endurance Group Event Aggregate log page (0x0F), returning
+// the identifiers of the endurance groups which have events to report, i.e. whose Endurance Group
+// Information log page should be read. Reading the log page clears it, and releases the
+// associated asynchronous event.
+func (d *NVMeDevice) GetEnduranceGroupEvents() ([]uint16, error) {
+	if err := d.checkEnduranceGroups(); err != nil {
+		return nil, err
+	}
+
+	return d.getAggregateLog(NVME_LOG_ENDURANCE_EVENTS)
+}
+
+// ListEnduranceGroups returns the identifiers of the endurance groups in the NVM subsystem, in
+// ascending order.
+func (d *NVMeDevice) ListEnduranceGroups() ([]uint16, error) {
+	if err := d.checkEnduranceGroups(); err != nil {
+		return nil, err
+	}
+
+	if err := d.checkVersion("endurance group list", Version14); err != nil {
+		return nil, err
+	}
+
+	var ids []uint16
+
+	// Each list contains up to 2047 identifiers greater than or equal to that specified
+	for start := uint16(1); ; {
+		buf := make([]byte, 4096)
+
+		args := identifyArgs{cns: NVME_IDENTIFY_CNS_ENDGRP_LIST, cnssi: start}
+		if err := d.identifyWith(args, buf); err != nil {
+			return nil, err
+		}
+
+		page := decodeControllerList(buf)
+		ids = append(ids, page...)
+
+		if len(page) < maxControllerListLen || page[len(page)-1] == 0xffff {
+			return ids, nil
+		}
+
+		start = page[len(page)-1] + 1
+	}
+}
+
+// RotationalMedia is the decoded Rotational Media Information log page (0x16) of an endurance
+// group consisting of rotational media.
+type RotationalMedia struct {
+	EnduranceGroupID uint16
+	Actuators        uint16
+	SpinupCount      uint32 // Lifetime number of spin-ups
+	SpinupFailures   uint32 // Lifetime number of spin-ups which failed to reach the nominal speed
+	LoadCount        uint32 // Lifetime number of head loads
+	LoadFailures     uint32 // Lifetime number of failed head loads
+}
+
+// Print outputs the rotational media information in a pretty-print style.
+func (r *RotationalMedia) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgRotationalMedia), r.EnduranceGroupID, r.Actuators, r.SpinupCount,
+		r.SpinupFailures, r.LoadCount, r.LoadFailures)
+}
+
+// GetRotationalMedia reads the Rotational Media Information log page of the specified endurance
+// group.
+func (d *NVMeDevice) GetRotationalMedia(endgid uint16) (*RotationalMedia, error) {
+	buf := make([]byte, 512)
+
+	if err := d.getLog(NVME_LOG_ROTATIONAL_MEDIA, logPageArgs{lsi: endgid}, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeRotationalMediaLog
+
+	raw.unmarshal(buf)
+
+	return &RotationalMedia{
+		EnduranceGroupID: raw.Endgid,
+		Actuators:        raw.Numa,
+		SpinupCount:      raw.Spinc,
+		SpinupFailures:   raw.Fspinc,
+		LoadCount:        raw.Ldc,
+		LoadFailures:     raw.Fldc,
+	}, nil
+}
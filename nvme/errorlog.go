@@ -0,0 +1,107 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// ErrorLogEntry is an entry of the Error Information log page (0x01).
+type ErrorLogEntry struct {
+	ErrorCount uint64 // Unique, incrementing identifier of the error; zero if the entry is unused
+	SQID       uint16 // Submission queue of the failed command
+	CID        uint16 // Command identifier of the failed command
+	Status     NVMeStatus
+	ParamValid bool  // ParamByte and ParamBit are valid
+	ParamByte  uint8 // Byte of the command in which the error occurred
+	ParamBit   uint8 // Bit of the byte in which the error occurred
+	LBA        uint64
+	NSID       uint32
+	VendorLog  uint8  // Log page with vendor specific information about the error, 0 if none
+	CmdInfo    uint64 // Command specific information
+}
+
+// ErrorLog is the decoded Error Information log page, newest entry first.
+type ErrorLog []ErrorLogEntry
+
+// NonEmpty returns only the entries which describe an error.
+func (l ErrorLog) NonEmpty() ErrorLog {
+	var entries ErrorLog
+
+	for _, e := range l {
+		if e.ErrorCount != 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// Print outputs the error log in a pretty-print style.
+func (l ErrorLog) Print(w io.Writer) {
+	for _, e := range l {
+		fmt.Fprintf(w, msg(MsgErrorLogEntry), e.ErrorCount, e.SQID, e.CID, e.Status)
+
+		if e.ParamValid {
+			fmt.Fprintf(w, msg(MsgErrorLogParam), e.ParamByte, e.ParamBit)
+		}
+
+		fmt.Fprintf(w, msg(MsgErrorLogLBA), e.LBA, e.NSID)
+	}
+}
+
+// GetErrorLog reads and decodes all entries of the Error Information log page, as many as the
+// controller's Error Log Page Entries (ELPE) field indicates.
+func (d *NVMeDevice) GetErrorLog() (ErrorLog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	// ELPE is 0's based
+	buf, err := d.readLog(NVME_LOG_ERROR, logPageArgs{nsid: 0xffffffff}, 64*(int(idCtrlr.Elpe)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeErrorLog(buf), nil
+}
+
+func decodeErrorLog(buf []byte) ErrorLog {
+	l := make(ErrorLog, len(buf)/64)
+
+	for i := range l {
+		var raw nvmeErrorLogEntry
+
+		raw.unmarshal(buf[64*i:])
+
+		l[i] = ErrorLogEntry{
+			ErrorCount: raw.ErrorCount,
+			SQID:       raw.Sqid,
+			CID:        raw.Cmdid,
+			Status:     NVMeStatus(raw.StatusField >> 1), // Bit 0 is the phase tag
+			ParamValid: raw.ParmErrLoc != 0xffff,
+			ParamByte:  uint8(raw.ParmErrLoc),
+			ParamBit:   uint8(raw.ParmErrLoc>>8) & 0x7,
+			LBA:        raw.Lba,
+			NSID:       raw.Nsid,
+			VendorLog:  raw.Vsia,
+			CmdInfo:    raw.Csi,
+		}
+	}
+
+	return l
+}
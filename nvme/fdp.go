@@ -0,0 +1,371 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// ctrattFDP is the Flexible Data Placement support bit of the CTRATT field.
+const ctrattFDP = 1 << 19
+
+// fdpaValid is the FDP Configuration Valid bit of the FDP Attributes field.
+const fdpaValid = 1 << 7
+
+// ReclaimUnitHandleType is the type of a reclaim unit handle.
+type ReclaimUnitHandleType uint8
+
+const (
+	RUHInitiallyIsolated    ReclaimUnitHandleType = 1
+	RUHPersistentlyIsolated ReclaimUnitHandleType = 2
+)
+
+func (t ReclaimUnitHandleType) String() string {
+	switch t {
+	case RUHInitiallyIsolated:
+		return "initially isolated"
+	case RUHPersistentlyIsolated:
+		return "persistently isolated"
+	}
+
+	return fmt.Sprintf("reserved (%d)", uint8(t))
+}
+
+// FDPConfig is a Flexible Data Placement configuration of an endurance group.
+type FDPConfig struct {
+	Valid            bool
+	Attributes       uint8 // FDP Attributes (FDPA)
+	ReclaimGroups    uint32
+	MaxPlacementIDs  uint16 // Maximum placement identifiers per namespace
+	Namespaces       uint32 // Maximum namespaces which may use FDP
+	ReclaimUnitSize  uint64 // Nominal reclaim unit size, in bytes
+	ReclaimUnitLimit time.Duration
+	Handles          []ReclaimUnitHandleType
+	VendorSpecific   []byte
+}
+
+// FDPConfigList is the decoded FDP Configurations log page (0x20).
+type FDPConfigList []FDPConfig
+
+// Print outputs the FDP configurations in a pretty-print style.
+func (l FDPConfigList) Print(w io.Writer) {
+	for i, c := range l {
+		fmt.Fprintf(w, msg(MsgFDPConfig), i, c.Valid, c.ReclaimGroups, len(c.Handles),
+			formatBigBytes(new(big.Int).SetUint64(c.ReclaimUnitSize)), c.MaxPlacementIDs)
+
+		for j, h := range c.Handles {
+			fmt.Fprintf(w, msg(MsgFDPHandle), j, h)
+		}
+	}
+}
+
+// ReclaimUnitHandleUsage is the usage of a reclaim unit handle.
+type ReclaimUnitHandleUsage uint8
+
+const (
+	RUHUnused              ReclaimUnitHandleUsage = 0
+	RUHHostSpecified       ReclaimUnitHandleUsage = 1 // In use by placement identifiers of namespaces
+	RUHControllerSpecified ReclaimUnitHandleUsage = 2 // In use only by the controller
+)
+
+func (u ReclaimUnitHandleUsage) String() string {
+	switch u {
+	case RUHUnused:
+		return "unused"
+	case RUHHostSpecified:
+		return "host specified"
+	case RUHControllerSpecified:
+		return "controller specified"
+	}
+
+	return fmt.Sprintf("reserved (%d)", uint8(u))
+}
+
+// FDPStats is the decoded FDP Statistics log page (0x22), from which the write amplification of
+// an endurance group may be calculated.
+type FDPStats struct {
+	HostBytesWritten  *big.Int // Host bytes with metadata written
+	MediaBytesWritten *big.Int // Media bytes with metadata written
+	MediaBytesErased  *big.Int
+}
+
+// Print outputs the FDP statistics in a pretty-print style.
+func (s *FDPStats) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgFDPStats), formatBigBytes(s.HostBytesWritten),
+		formatBigBytes(s.MediaBytesWritten), formatBigBytes(s.MediaBytesErased))
+}
+
+// FDPEventType is the type of an FDP event.
+type FDPEventType uint8
+
+const (
+	FDPEventRUNotFull            FDPEventType = 0x00 // Reclaim unit not written to capacity
+	FDPEventRUTimeLimitExceeded  FDPEventType = 0x01
+	FDPEventResetModifiedRUH     FDPEventType = 0x02 // Controller level reset modified reclaim unit handles
+	FDPEventInvalidPlacementID   FDPEventType = 0x03
+	FDPEventMediaReallocated     FDPEventType = 0x80
+	FDPEventImplicitlyModifiedRU FDPEventType = 0x81 // Implicitly modified reclaim unit handle
+)
+
+var fdpEventNames = map[FDPEventType]string{
+	FDPEventRUNotFull:            "reclaim unit not written to capacity",
+	FDPEventRUTimeLimitExceeded:  "reclaim unit time limit exceeded",
+	FDPEventResetModifiedRUH:     "controller level reset modified reclaim unit handles",
+	FDPEventInvalidPlacementID:   "invalid placement identifier",
+	FDPEventMediaReallocated:     "media reallocated",
+	FDPEventImplicitlyModifiedRU: "implicitly modified reclaim unit handle",
+}
+
+func (t FDPEventType) String() string {
+	if name, ok := fdpEventNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("reserved (%#02x)", uint8(t))
+}
+
+// FDP Event Flags bits, indicating which fields of an FDP event are valid.
+const (
+	fdpEventPIDValid      = 1 << 0
+	fdpEventNSIDValid     = 1 << 1
+	fdpEventLocationValid = 1 << 2
+)
+
+// FDPEvent is an event from the FDP Events log page (0x23). Fields which are not applicable to
+// the event type are zero.
+type FDPEvent struct {
+	Type         FDPEventType
+	Timestamp    time.Time
+	NSID         uint32
+	PlacementID  uint16
+	ReclaimGroup uint16
+	Handle       uint8
+	TypeSpecific [16]byte
+}
+
+// Print outputs the FDP event in a pretty-print style.
+func (e *FDPEvent) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgFDPEvent), e.Timestamp.Format(time.RFC3339), e.Type, e.NSID,
+		e.PlacementID, e.ReclaimGroup, e.Handle)
+}
+
+// checkFDP returns ErrNotSupported if the controller does not support flexible data placement.
+func (d *NVMeDevice) checkFDP() error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Ctratt&ctrattFDP == 0 {
+		return fmt.Errorf("flexible data placement: %w", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// GetFDPConfigs reads the FDP Configurations log page of the specified endurance group.
+func (d *NVMeDevice) GetFDPConfigs(endgid uint16) (FDPConfigList, error) {
+	if err := d.checkFDP(); err != nil {
+		return nil, err
+	}
+
+	args := logPageArgs{lsi: endgid}
+	hdr := make([]byte, 16)
+
+	if err := d.getLog(NVME_LOG_FDP_CONFIGS, args, hdr); err != nil {
+		return nil, err
+	}
+
+	var h nvmeFDPConfigHeader
+
+	h.unmarshal(hdr)
+
+	if h.Sze < 16 || h.Sze > 1<<20 {
+		return nil, fmt.Errorf("invalid FDP configurations log size %d", h.Sze)
+	}
+
+	buf, err := d.readLog(NVME_LOG_FDP_CONFIGS, args, int(h.Sze))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeFDPConfigs(buf)
+}
+
+func decodeFDPConfigs(buf []byte) (FDPConfigList, error) {
+	var h nvmeFDPConfigHeader
+
+	h.unmarshal(buf)
+
+	l := make(FDPConfigList, int(h.Numfdpc)+1)
+	off := 16
+
+	for i := range l {
+		if off+64 > len(buf) {
+			return nil, fmt.Errorf("FDP configurations log truncated at descriptor %d", i)
+		}
+
+		var desc nvmeFDPConfigDesc
+
+		desc.unmarshal(buf[off:])
+
+		end := off + int(desc.Dsze)
+		if end > len(buf) || int(desc.Dsze) < 64+4*int(desc.Nruh)+int(desc.Vss) {
+			return nil, fmt.Errorf("invalid FDP configuration descriptor %d", i)
+		}
+
+		c := &l[i]
+		c.Valid = desc.Fdpa&fdpaValid != 0
+		c.Attributes = desc.Fdpa
+		c.ReclaimGroups = desc.Nrg
+		c.MaxPlacementIDs = desc.Maxpids + 1
+		c.Namespaces = desc.Nnss
+		c.ReclaimUnitSize = desc.Runs
+		c.ReclaimUnitLimit = time.Duration(desc.Erutl) * time.Second
+		c.Handles = make([]ReclaimUnitHandleType, desc.Nruh)
+
+		for j := range c.Handles {
+			c.Handles[j] = ReclaimUnitHandleType(buf[off+64+4*j])
+		}
+
+		if desc.Vss > 0 {
+			vs := off + 64 + 4*int(desc.Nruh)
+			c.VendorSpecific = buf[vs : vs+int(desc.Vss)]
+		}
+
+		off = end
+	}
+
+	return l, nil
+}
+
+// GetReclaimUnitHandleUsage reads the Reclaim Unit Handle Usage log page (0x21) of the specified
+// endurance group, returning the usage of each reclaim unit handle.
+func (d *NVMeDevice) GetReclaimUnitHandleUsage(endgid uint16) ([]ReclaimUnitHandleUsage, error) {
+	if err := d.checkFDP(); err != nil {
+		return nil, err
+	}
+
+	args := logPageArgs{lsi: endgid}
+	hdr := make([]byte, 8)
+
+	if err := d.getLog(NVME_LOG_FDP_RUH_USAGE, args, hdr); err != nil {
+		return nil, err
+	}
+
+	buf, err := d.readLog(NVME_LOG_FDP_RUH_USAGE, args, 8+8*int(binary.LittleEndian.Uint16(hdr)))
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]ReclaimUnitHandleUsage, binary.LittleEndian.Uint16(buf))
+	for i := range usage {
+		usage[i] = ReclaimUnitHandleUsage(buf[8+8*i])
+	}
+
+	return usage, nil
+}
+
+// GetFDPStats reads the FDP Statistics log page of the specified endurance group.
+func (d *NVMeDevice) GetFDPStats(endgid uint16) (*FDPStats, error) {
+	if err := d.checkFDP(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 64)
+
+	if err := d.getLog(NVME_LOG_FDP_STATS, logPageArgs{lsi: endgid}, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeFDPStats
+
+	raw.unmarshal(buf)
+
+	return &FDPStats{
+		HostBytesWritten:  le128ToBigInt(raw.Hbmw),
+		MediaBytesWritten: le128ToBigInt(raw.Mbmw),
+		MediaBytesErased:  le128ToBigInt(raw.Mbe),
+	}, nil
+}
+
+// GetFDPEvents reads the FDP Events log page of the specified endurance group. If host is true,
+// the host events are returned, otherwise the controller events.
+func (d *NVMeDevice) GetFDPEvents(endgid uint16, host bool) ([]FDPEvent, error) {
+	if err := d.checkFDP(); err != nil {
+		return nil, err
+	}
+
+	args := logPageArgs{lsi: endgid}
+	if host {
+		args.lsp = 1 // Host events
+	}
+
+	hdr := make([]byte, 64)
+
+	if err := d.getLog(NVME_LOG_FDP_EVENTS, args, hdr); err != nil {
+		return nil, err
+	}
+
+	n := binary.LittleEndian.Uint32(hdr)
+	if n > 0xffff {
+		return nil, fmt.Errorf("invalid number of FDP events %d", n)
+	}
+
+	buf, err := d.readLog(NVME_LOG_FDP_EVENTS, args, 64+64*int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeFDPEvents(buf), nil
+}
+
+func decodeFDPEvents(buf []byte) []FDPEvent {
+	n := int(binary.LittleEndian.Uint32(buf))
+
+	var events []FDPEvent
+
+	for i := 0; i < n && 64*(i+2) <= len(buf); i++ {
+		var raw nvmeFDPEvent
+
+		raw.unmarshal(buf[64*(i+1):])
+
+		e := FDPEvent{
+			Type:         FDPEventType(raw.Type),
+			Timestamp:    decodeTimestamp(raw.Ts),
+			TypeSpecific: raw.Tsed,
+		}
+
+		if raw.Flags&fdpEventPIDValid != 0 {
+			e.PlacementID = raw.Pid
+		}
+
+		if raw.Flags&fdpEventNSIDValid != 0 {
+			e.NSID = raw.Nsid
+		}
+
+		if raw.Flags&fdpEventLocationValid != 0 {
+			e.ReclaimGroup, e.Handle = raw.Rgid, raw.Ruhid
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
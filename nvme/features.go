@@ -0,0 +1,227 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// Arbitration is the Arbitration feature (FID 0x01), which controls command arbitration between
+// submission queues. Weights are 0's based values, as encoded on the wire.
+type Arbitration struct {
+	Burst                uint8 // Arbitration Burst, as a power of two (7 = no limit)
+	LowPriorityWeight    uint8
+	MediumPriorityWeight uint8
+	HighPriorityWeight   uint8
+}
+
+// Print outputs the arbitration settings in a pretty-print style.
+func (a Arbitration) Print(w io.Writer) {
+	if a.Burst == 7 {
+		fmt.Fprint(w, msg(MsgArbBurstNoLimit))
+	} else {
+		fmt.Fprintf(w, msg(MsgArbBurst), 1<<a.Burst)
+	}
+
+	fmt.Fprintf(w, msg(MsgArbWeights), uint(a.HighPriorityWeight)+1,
+		uint(a.MediumPriorityWeight)+1, uint(a.LowPriorityWeight)+1)
+}
+
+// GetArbitration returns the current value of the Arbitration feature.
+func (d *NVMeDevice) GetArbitration() (Arbitration, error) {
+	val, err := d.getFeature(NVME_FEAT_ARBITRATION, 0, NVME_FEAT_SEL_CURRENT, 0, nil)
+	if err != nil {
+		return Arbitration{}, err
+	}
+
+	return Arbitration{
+		Burst:                uint8(val & 0x7),
+		LowPriorityWeight:    uint8(val >> 8),
+		MediumPriorityWeight: uint8(val >> 16),
+		HighPriorityWeight:   uint8(val >> 24),
+	}, nil
+}
+
+// SetArbitration sets the Arbitration feature. If save is true, the setting persists across power
+// cycles and resets.
+func (d *NVMeDevice) SetArbitration(a Arbitration, save bool) error {
+	cdw11 := uint32(a.Burst&0x7) | uint32(a.LowPriorityWeight)<<8 |
+		uint32(a.MediumPriorityWeight)<<16 | uint32(a.HighPriorityWeight)<<24
+
+	_, err := d.setFeature(NVME_FEAT_ARBITRATION, 0, save, cdw11, 0, nil)
+	return err
+}
+
+// InterruptCoalescing is the Interrupt Coalescing feature (FID 0x08), which controls the
+// aggregation of completion queue interrupts.
+type InterruptCoalescing struct {
+	Threshold uint8 // Aggregation Threshold, 0's based number of completion queue entries
+	Time      uint8 // Aggregation Time, in 100 microsecond increments
+}
+
+// Print outputs the interrupt coalescing settings in a pretty-print style.
+func (c InterruptCoalescing) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgIRQCoalThreshold), uint(c.Threshold)+1)
+	fmt.Fprintf(w, msg(MsgIRQCoalTime), uint(c.Time)*100)
+}
+
+// GetInterruptCoalescing returns the current value of the Interrupt Coalescing feature.
+func (d *NVMeDevice) GetInterruptCoalescing() (InterruptCoalescing, error) {
+	val, err := d.getFeature(NVME_FEAT_IRQ_COALESCE, 0, NVME_FEAT_SEL_CURRENT, 0, nil)
+	if err != nil {
+		return InterruptCoalescing{}, err
+	}
+
+	return InterruptCoalescing{Threshold: uint8(val), Time: uint8(val >> 8)}, nil
+}
+
+// SetInterruptCoalescing sets the Interrupt Coalescing feature. If save is true, the setting
+// persists across power cycles and resets.
+func (d *NVMeDevice) SetInterruptCoalescing(c InterruptCoalescing, save bool) error {
+	_, err := d.setFeature(NVME_FEAT_IRQ_COALESCE, 0, save, uint32(c.Threshold)|uint32(c.Time)<<8, 0, nil)
+	return err
+}
+
+// HostBehavior is the Host Behavior Support feature (FID 0x16), through which the host indicates
+// support for optional behaviors that the controller may then rely on.
+type HostBehavior struct {
+	AdvancedCommandRetry   bool   // Advanced Command Retry Enable (ACRE)
+	ExtendedTelemetryArea4 bool   // Extended Telemetry Data Area 4 Supported (ETDAS)
+	LBAFormatExtension     bool   // LBA Format Extension Enable (LBAFEE)
+	CopyDescriptorFormats  uint16 // Copy Descriptor Formats Enable (CDFE), bit n enables format n
+}
+
+// GetHostBehavior returns the current value of the Host Behavior Support feature.
+func (d *NVMeDevice) GetHostBehavior() (HostBehavior, error) {
+	buf := make([]byte, 512)
+
+	if _, err := d.getFeature(NVME_FEAT_HOST_BEHAVIOR, 0, NVME_FEAT_SEL_CURRENT, 0, buf); err != nil {
+		return HostBehavior{}, err
+	}
+
+	var hb nvmeHostBehavior
+
+	binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &hb)
+
+	return HostBehavior{
+		AdvancedCommandRetry:   hb.Acre&1 != 0,
+		ExtendedTelemetryArea4: hb.Etdas&1 != 0,
+		LBAFormatExtension:     hb.Lbafee&1 != 0,
+		CopyDescriptorFormats:  hb.Cdfe,
+	}, nil
+}
+
+// SetHostBehavior sets the Host Behavior Support feature. If save is true, the setting persists
+// across power cycles and resets.
+func (d *NVMeDevice) SetHostBehavior(hb HostBehavior, save bool) error {
+	raw := nvmeHostBehavior{
+		Acre:   boolToUint8(hb.AdvancedCommandRetry),
+		Etdas:  boolToUint8(hb.ExtendedTelemetryArea4),
+		Lbafee: boolToUint8(hb.LBAFormatExtension),
+		Cdfe:   hb.CopyDescriptorFormats,
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &raw)
+
+	_, err := d.setFeature(NVME_FEAT_HOST_BEHAVIOR, 0, save, 0, 0, buf.Bytes())
+	return err
+}
+
+// getFeature issues a Get Features command for the specified feature identifier, returning the
+// feature value from completion queue entry dword 0. buf may be nil for features which do not
+// transfer a data structure.
+func (d *NVMeDevice) getFeature(fid uint8, nsid uint32, sel uint8, cdw11 uint32, buf []byte) (uint32, error) {
+	uuidIndex := d.vendorUUIDIndex(fid)
+
+	if supported, known := d.support.FeatureUUID(fid, uuidIndex); known && !supported {
+		return 0, fmt.Errorf("feature %#02x: %w", fid, ErrNotSupported)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_GET_FEATURES,
+		nsid:   nsid,
+		cdw10:  uint32(fid) | uint32(sel&0x7)<<8,
+		cdw11:  cdw11,
+		cdw14:  uint32(uuidIndex),
+	}
+
+	if len(buf) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&buf[0])))
+		cmd.data_len = uint32(len(buf))
+	}
+
+	err := d.adminCmd(&cmd, buf)
+
+	// Only a failure to read the current value is a reliable indication that the feature is
+	// unsupported, since other select values may themselves be unsupported by older controllers.
+	if err == nil || sel == NVME_FEAT_SEL_CURRENT {
+		d.support.recordFeature(fid, uuidIndex, err)
+	}
+
+	return cmd.result, err
+}
+
+// setFeature issues a Set Features command for the specified feature identifier. If save is
+// true, the controller is asked to persist the value across power cycles and resets. The
+// command-specific result from completion queue entry dword 0 is returned.
+func (d *NVMeDevice) setFeature(fid uint8, nsid uint32, save bool, cdw11, cdw12 uint32, buf []byte) (uint32, error) {
+	uuidIndex := d.vendorUUIDIndex(fid)
+
+	if supported, known := d.support.FeatureUUID(fid, uuidIndex); known && !supported {
+		return 0, fmt.Errorf("feature %#02x: %w", fid, ErrNotSupported)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_SET_FEATURES,
+		nsid:   nsid,
+		cdw10:  uint32(fid),
+		cdw11:  cdw11,
+		cdw12:  cdw12,
+		cdw14:  uint32(uuidIndex),
+	}
+
+	if save {
+		cmd.cdw10 |= 1 << 31
+	}
+
+	if len(buf) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&buf[0])))
+		cmd.data_len = uint32(len(buf))
+	}
+
+	err := d.adminCmd(&cmd, buf)
+
+	// A failed Set Features may simply be due to an invalid value, so only success is recorded.
+	if err == nil {
+		d.support.recordFeature(fid, uuidIndex, nil)
+	}
+
+	return cmd.result, err
+}
+
+// nvmeHostBehavior is the low-level struct of the Host Behavior Support data structure.
+type nvmeHostBehavior struct {
+	Acre   uint8 // Advanced Command Retry Enable
+	Etdas  uint8 // Extended Telemetry Data Area 4 Supported
+	Lbafee uint8 // LBA Format Extension Enable
+	Rsvd3  uint8
+	Cdfe   uint16 // Copy Descriptor Formats Enable
+	Rsvd6  [506]byte
+} // 512 bytes
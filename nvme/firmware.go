@@ -0,0 +1,223 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// FirmwareCommitAction is the Commit Action (CA) field of a Firmware Commit command.
+type FirmwareCommitAction uint8
+
+const (
+	// Replace the image in the specified slot, without activating it
+	FirmwareCommitReplace FirmwareCommitAction = 0x0
+	// Replace the image in the specified slot, and activate it at the next reset
+	FirmwareCommitReplaceActivate FirmwareCommitAction = 0x1
+	// Activate the existing image in the specified slot at the next reset
+	FirmwareCommitActivate FirmwareCommitAction = 0x2
+	// Replace the image in the specified slot, and activate it immediately without reset
+	FirmwareCommitReplaceActivateNow FirmwareCommitAction = 0x3
+	// Replace the specified boot partition with the downloaded image
+	FirmwareCommitReplaceBootPartition FirmwareCommitAction = 0x6
+	// Mark the specified boot partition as active
+	FirmwareCommitActivateBootPartition FirmwareCommitAction = 0x7
+)
+
+// Firmware Updates (FRMW) bits of the identify controller data structure.
+const (
+	frmwSlot1ReadOnly   = 1 << 0
+	frmwActivateNoReset = 1 << 4
+	frmwSlotsShift      = 1
+	frmwSlotsMask       = 0x7
+)
+
+// defaultFirmwareXferLen is the firmware download chunk size used when the controller does not
+// report a Firmware Update Granularity, as per nvme-cli.
+const defaultFirmwareXferLen = 4096
+
+// FirmwareDownload transfers a firmware image to the controller, in chunks of the controller's
+// Firmware Update Granularity (FWUG). The image is not applied until it is committed with
+// FirmwareCommit.
+func (d *NVMeDevice) FirmwareDownload(image []byte) error {
+	if len(image) == 0 || len(image)%4 != 0 {
+		return fmt.Errorf("firmware image size must be a non-zero multiple of 4 bytes")
+	}
+
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	// FWUG is in 4 KiB units; 0 indicates no information, 0xff indicates no restriction
+	xferLen := defaultFirmwareXferLen
+	if idCtrlr.Fwug > 0 && idCtrlr.Fwug < 0xff {
+		xferLen = int(idCtrlr.Fwug) * 4096
+	}
+
+	// Neither may a transfer exceed MDTS
+	if xferLen, err = d.maxXferLen(xferLen); err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(image); offset += xferLen {
+		chunk := image[offset:]
+		if len(chunk) > xferLen {
+			chunk = chunk[:xferLen]
+		}
+
+		cmd := nvmePassthruCommand{
+			opcode:   NVME_ADMIN_FW_DOWNLOAD,
+			addr:     uint64(uintptr(unsafe.Pointer(&chunk[0]))),
+			data_len: uint32(len(chunk)),
+			cdw10:    uint32(len(chunk)/4) - 1, // Number of dwords, 0's based
+			cdw11:    uint32(offset / 4),       // Offset in dwords
+		}
+
+		if err := d.adminCmd(&cmd, chunk); err != nil {
+			return fmt.Errorf("firmware download failed at offset %#x: %w", offset, err)
+		}
+	}
+
+	return nil
+}
+
+// FirmwareCommit commits a previously downloaded firmware image to the specified slot (or 0 to let
+// the controller choose), or activates an existing image. bootPartitionID is only used by the boot
+// partition commit actions. If the commit succeeds but a reset is required to activate the image,
+// an NVMeStatus error is returned for which ResetRequired() is true.
+func (d *NVMeDevice) FirmwareCommit(slot uint8, action FirmwareCommitAction, bootPartitionID uint8) error {
+	if slot > 7 {
+		return fmt.Errorf("invalid firmware slot %d", slot)
+	}
+
+	if bootPartitionID > 1 {
+		return fmt.Errorf("invalid boot partition ID %d", bootPartitionID)
+	}
+
+	switch action {
+	case FirmwareCommitReplace, FirmwareCommitReplaceActivate, FirmwareCommitActivate,
+		FirmwareCommitReplaceActivateNow:
+		if err := d.checkFirmwareSlot(slot, action); err != nil {
+			return err
+		}
+	case FirmwareCommitReplaceBootPartition, FirmwareCommitActivateBootPartition:
+	default:
+		return fmt.Errorf("invalid firmware commit action %d", action)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_FW_COMMIT,
+		cdw10:  uint32(slot) | uint32(action)<<3 | uint32(bootPartitionID)<<31,
+	}
+
+	// Activated firmware may report different identify controller data
+	d.resetController()
+
+	return d.adminCmd(&cmd, nil)
+}
+
+// UpdateFirmware downloads a firmware image and commits it to the specified slot.
+func (d *NVMeDevice) UpdateFirmware(image []byte, slot uint8, action FirmwareCommitAction) error {
+	if err := d.FirmwareDownload(image); err != nil {
+		return err
+	}
+
+	return d.FirmwareCommit(slot, action, 0)
+}
+
+// checkFirmwareSlot consults the controller's FRMW field to validate a commit to the specified
+// slot.
+func (d *NVMeDevice) checkFirmwareSlot(slot uint8, action FirmwareCommitAction) error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	// A value of zero is not valid, so treat it as "unknown" rather than refusing every slot
+	nslots := (idCtrlr.Frmw >> frmwSlotsShift) & frmwSlotsMask
+	if nslots > 0 && slot > nslots {
+		return fmt.Errorf("firmware slot %d exceeds number of slots (%d)", slot, nslots)
+	}
+
+	replaces := action != FirmwareCommitActivate
+	if replaces && slot == 1 && idCtrlr.Frmw&frmwSlot1ReadOnly != 0 {
+		return fmt.Errorf("firmware slot 1 is read-only")
+	}
+
+	if action == FirmwareCommitReplaceActivateNow && idCtrlr.Frmw&frmwActivateNoReset == 0 {
+		return fmt.Errorf("firmware activation without reset: %w", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// FirmwareSlotInfo is the decoded Firmware Slot Information log page (0x03).
+type FirmwareSlotInfo struct {
+	ActiveSlot    uint8     // Slot from which the running firmware was loaded
+	NextResetSlot uint8     // Slot to be activated at the next reset, 0 if none
+	Revisions     [7]string // Firmware revision per slot (slots 1-7), empty if the slot is unused
+}
+
+// Print outputs the firmware slot information in a pretty-print style.
+func (fs *FirmwareSlotInfo) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgFwActiveSlot), fs.ActiveSlot)
+
+	if fs.NextResetSlot != 0 {
+		fmt.Fprintf(w, msg(MsgFwNextSlot), fs.NextResetSlot)
+	}
+
+	for i, rev := range fs.Revisions {
+		if rev != "" {
+			fmt.Fprintf(w, msg(MsgFwSlot), i+1, rev)
+		}
+	}
+}
+
+// GetFirmwareSlotInfo reads and decodes the Firmware Slot Information log page.
+func (d *NVMeDevice) GetFirmwareSlotInfo() (*FirmwareSlotInfo, error) {
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_FW_SLOT, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	var fl nvmeFwSlotLog
+
+	binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &fl)
+
+	fs := &FirmwareSlotInfo{
+		ActiveSlot:    fl.Afi & 0x7,
+		NextResetSlot: (fl.Afi >> 4) & 0x7,
+	}
+
+	for i, frs := range fl.Frs {
+		fs.Revisions[i] = string(bytes.TrimRight(frs[:], " \x00"))
+	}
+
+	return fs, nil
+}
+
+// nvmeFwSlotLog is the low-level struct of the Firmware Slot Information log page.
+type nvmeFwSlotLog struct {
+	Afi    uint8      // Active Firmware Info
+	Rsvd1  [7]byte    // ...
+	Frs    [7][8]byte // Firmware Revision for Slot 1-7
+	Rsvd64 [448]byte  // ...
+} // 512 bytes
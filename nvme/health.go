@@ -0,0 +1,90 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+)
+
+// HealthStatus is an overall health classification, ordered from best to worst.
+type HealthStatus int
+
+const (
+	HealthOK HealthStatus = iota
+	HealthUnknown
+	HealthWarning
+	HealthCritical
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthOK:
+		return msg(MsgHealthOK)
+	case HealthWarning:
+		return msg(MsgHealthWarning)
+	case HealthCritical:
+		return msg(MsgHealthCritical)
+	}
+
+	return msg(MsgHealthUnknown)
+}
+
+// Health is a health classification together with the reasons which led to it.
+type Health struct {
+	Status  HealthStatus
+	Reasons []string
+}
+
+func (h *Health) add(status HealthStatus, reason string) {
+	if status > h.Status {
+		h.Status = status
+	}
+
+	h.Reasons = append(h.Reasons, reason)
+}
+
+// Health classifies the SMART log. Any critical warning other than a temperature excursion is
+// critical. A temperature excursion, exceeding the rated endurance, or recording any media and
+// data integrity errors is a warning.
+func (sl *SMARTLog) Health() Health {
+	var h Health
+
+	if sl.CritWarning&CritWarnSpare != 0 {
+		h.add(HealthCritical, fmt.Sprintf(msg(MsgHealthSpare), sl.AvailSpare, sl.SpareThresh))
+	}
+	if sl.CritWarning&CritWarnReliability != 0 {
+		h.add(HealthCritical, msg(MsgHealthReliability))
+	}
+	if sl.CritWarning&CritWarnReadOnly != 0 {
+		h.add(HealthCritical, msg(MsgHealthReadOnly))
+	}
+	if sl.CritWarning&CritWarnVolatileBackup != 0 {
+		h.add(HealthCritical, msg(MsgHealthBackup))
+	}
+	if sl.CritWarning&CritWarnPMRReadOnly != 0 {
+		h.add(HealthCritical, msg(MsgHealthPMRReadOnly))
+	}
+	if sl.CritWarning&CritWarnTemperature != 0 {
+		h.add(HealthWarning, msg(MsgHealthTemperature))
+	}
+	if sl.PercentUsed >= 100 {
+		h.add(HealthWarning, fmt.Sprintf(msg(MsgHealthWornOut), sl.PercentUsed))
+	}
+	if sl.MediaErrors != nil && sl.MediaErrors.Sign() > 0 {
+		h.add(HealthWarning, fmt.Sprintf(msg(MsgHealthMediaErrors), sl.MediaErrors))
+	}
+
+	return h
+}
@@ -0,0 +1,262 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	sysfsNVMeDir = "/sys/class/nvme"
+	procMounts   = "/proc/mounts"
+	procSwaps    = "/proc/swaps"
+
+	nvmeNsRe   = regexp.MustCompile(`^nvme\d+(c\d+)?n\d+$`)
+	nvmePathRe = regexp.MustCompile(`c\d+n`)
+)
+
+// ErrInUse is returned (wrapped) when a destructive operation is refused because the affected
+// namespace is in use.
+var ErrInUse = errors.New("device is in use")
+
+// Mount describes a mounted filesystem.
+type Mount struct {
+	Device     string
+	MountPoint string
+	FSType     string
+}
+
+// ImpactReport lists everything that would be affected by a destructive operation (e.g., format,
+// sanitize or namespace deletion) on one or more namespaces.
+type ImpactReport struct {
+	Namespaces []string // Namespace block devices, e.g. nvme0n1
+	Partitions []string
+	Holders    []string // Stacked block devices, e.g. dm-0 or md127
+	Mounts     []Mount
+	Swaps      []string
+}
+
+// InUse returns true if any affected device is mounted, used as swap, or held by another block
+// device.
+func (r *ImpactReport) InUse() bool {
+	return len(r.Mounts) > 0 || len(r.Swaps) > 0 || len(r.Holders) > 0
+}
+
+// Print outputs the impact report in a pretty-print style.
+func (r *ImpactReport) Print(w io.Writer) {
+	for _, ns := range r.Namespaces {
+		fmt.Fprintf(w, msg(MsgImpactNamespace), ns)
+	}
+	for _, p := range r.Partitions {
+		fmt.Fprintf(w, msg(MsgImpactPartition), p)
+	}
+	for _, h := range r.Holders {
+		fmt.Fprintf(w, msg(MsgImpactHolder), h)
+	}
+	for _, m := range r.Mounts {
+		fmt.Fprintf(w, msg(MsgImpactMount), m.Device, m.MountPoint, m.FSType)
+	}
+	for _, s := range r.Swaps {
+		fmt.Fprintf(w, msg(MsgImpactSwap), s)
+	}
+
+	if r.InUse() {
+		fmt.Fprint(w, msg(MsgImpactInUse))
+	} else {
+		fmt.Fprint(w, msg(MsgImpactNotInUse))
+	}
+}
+
+// ImpactReport determines which block devices, holders, mounts and swap areas would be affected
+// by a destructive operation on the specified namespace. An nsid of 0xffffffff selects all
+// namespaces of the controller.
+func (d *NVMeDevice) ImpactReport(nsid uint32) (*ImpactReport, error) {
+	r := new(ImpactReport)
+
+	namespaces, err := namespaceBlockDevs(filepath.Base(d.Name), nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	// affected contains every block device name which could be the source of a mount or swap
+	affected := make(map[string]bool)
+
+	for _, ns := range namespaces {
+		r.Namespaces = append(r.Namespaces, ns)
+		affected[ns] = true
+
+		entries, err := os.ReadDir(filepath.Join(sysfsBlockDir, ns))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if _, err := os.Stat(filepath.Join(sysfsBlockDir, ns, e.Name(), "partition")); err == nil {
+				r.Partitions = append(r.Partitions, e.Name())
+				affected[e.Name()] = true
+			}
+		}
+	}
+
+	// Walk holders recursively, e.g. a dm-crypt target on top of an md array on a partition
+	var walk func(name string)
+	walk = func(name string) {
+		holders, _ := os.ReadDir(filepath.Join(sysfsBlockDir, name, "holders"))
+		for _, h := range holders {
+			if !affected[h.Name()] {
+				affected[h.Name()] = true
+				r.Holders = append(r.Holders, h.Name())
+				walk(h.Name())
+			}
+		}
+	}
+
+	for _, name := range append(append([]string{}, r.Namespaces...), r.Partitions...) {
+		walk(name)
+	}
+
+	if r.Mounts, err = affectedMounts(affected); err != nil {
+		return nil, err
+	}
+
+	if r.Swaps, err = affectedSwaps(affected); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CheckNotInUse returns an ErrInUse error if a destructive operation on the specified namespace
+// would affect any device that is in use. Destructive operations in this package perform this
+// check unless explicitly forced.
+func (d *NVMeDevice) CheckNotInUse(nsid uint32) error {
+	r, err := d.ImpactReport(nsid)
+	if err != nil {
+		return fmt.Errorf("cannot determine whether namespace is in use: %w", err)
+	}
+
+	if r.InUse() {
+		return fmt.Errorf("namespace %#x: %w", nsid, ErrInUse)
+	}
+
+	return nil
+}
+
+// namespaceBlockDevs returns the block device names of the specified namespace(s) of a controller.
+// If the device name is itself a namespace block device, it is returned as-is.
+func namespaceBlockDevs(ctrlr string, nsid uint32) ([]string, error) {
+	if nvmeNsRe.MatchString(ctrlr) {
+		return []string{ctrlr}, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(sysfsNVMeDir, ctrlr))
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces []string
+
+	for _, e := range entries {
+		if !nvmeNsRe.MatchString(e.Name()) {
+			continue
+		}
+
+		if nsid != 0xffffffff {
+			buf, err := os.ReadFile(filepath.Join(sysfsNVMeDir, ctrlr, e.Name(), "nsid"))
+			if err != nil {
+				return nil, err
+			}
+
+			if id, _ := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 32); uint32(id) != nsid {
+				continue
+			}
+		}
+
+		// Hidden per-path devices (nvmeXcYnZ) are represented by their multipath head (nvmeXnZ)
+		name := nvmePathRe.ReplaceAllString(e.Name(), "n")
+		namespaces = append(namespaces, name)
+	}
+
+	return namespaces, nil
+}
+
+func affectedMounts(affected map[string]bool) ([]Mount, error) {
+	f, err := os.Open(procMounts)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []Mount
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+
+		if affected[resolveDevName(fields[0])] {
+			mounts = append(mounts, Mount{Device: fields[0], MountPoint: fields[1], FSType: fields[2]})
+		}
+	}
+
+	return mounts, scanner.Err()
+}
+
+func affectedSwaps(affected map[string]bool) ([]string, error) {
+	f, err := os.Open(procSwaps)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var swaps []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[1] != "partition" {
+			continue
+		}
+
+		if affected[resolveDevName(fields[0])] {
+			swaps = append(swaps, fields[0])
+		}
+	}
+
+	return swaps, scanner.Err()
+}
+
+// resolveDevName returns the kernel name of a device node path, following symlinks such as
+// /dev/mapper/* and /dev/disk/by-*/*.
+func resolveDevName(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	return filepath.Base(path)
+}
@@ -0,0 +1,167 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	vidIntel    = 0x8086 // PCI vendor ID of Intel
+	vidSolidigm = 0x025e // PCI vendor ID of Solidigm, formerly Intel's NAND SSD business
+
+	// NVME_LOG_INTEL_SMART_ADD is the vendor specific Intel / Solidigm Additional SMART
+	// Attributes log page.
+	NVME_LOG_INTEL_SMART_ADD uint8 = 0xca
+)
+
+// Attribute keys of the Intel Additional SMART Attributes log page.
+const (
+	intelAttrProgramFail      = 0xab
+	intelAttrEraseFail        = 0xac
+	intelAttrWearLeveling     = 0xad
+	intelAttrE2EErrors        = 0xb8
+	intelAttrCRCErrors        = 0xc7
+	intelAttrMediaWear        = 0xe2
+	intelAttrHostReads        = 0xe3
+	intelAttrWorkloadTimer    = 0xe4
+	intelAttrThermalThrottle  = 0xea
+	intelAttrRetryBufOverflow = 0xf0
+	intelAttrPLLLockLoss      = 0xf3
+	intelAttrNANDWrites       = 0xf4
+	intelAttrHostWrites       = 0xf5
+)
+
+// IntelSMARTValue is an attribute of the Intel Additional SMART Attributes log page.
+type IntelSMARTValue struct {
+	Normalized uint8  // Normalized value, 100 is best
+	Raw        uint64 // Raw (current) value
+}
+
+// IntelSMARTLog is the decoded Intel / Solidigm Additional SMART Attributes log page (0xCA).
+type IntelSMARTLog struct {
+	ProgramFailCount IntelSMARTValue
+	EraseFailCount   IntelSMARTValue
+
+	// Wear leveling count, i.e. the minimum, maximum and average erase cycles of NAND blocks
+	WearLeveling    uint8 // Normalized value
+	WearLevelingMin uint16
+	WearLevelingMax uint16
+	WearLevelingAvg uint16
+
+	E2EErrorCount IntelSMARTValue // End-to-end error detection count
+	CRCErrorCount IntelSMARTValue // PCIe CRC error count
+
+	// Timed workload statistics, measured since the workload timer was last reset
+	TimedMediaWear IntelSMARTValue // Media wear, in units of 1/1024 percent
+	TimedHostReads IntelSMARTValue // Percentage of host I/O which are reads
+	TimedWorkload  IntelSMARTValue // Workload timer, in minutes
+
+	ThermalThrottlePercent uint8  // Percentage of time throttled
+	ThermalThrottleCount   uint32 // Number of throttling events
+
+	RetryBufferOverflow IntelSMARTValue
+	PLLLockLoss         IntelSMARTValue
+	NANDBytesWritten    IntelSMARTValue // In units of 32 MiB
+	HostBytesWritten    IntelSMARTValue // In units of 32 MiB
+}
+
+// Print outputs the Intel additional SMART log in a pretty-print style.
+func (l *IntelSMARTLog) Print(w io.Writer) {
+	fmt.Fprint(w, msg(MsgIntelSMARTHeader))
+	fmt.Fprintf(w, msg(MsgIntelSMARTFailures), l.ProgramFailCount.Raw, l.EraseFailCount.Raw,
+		l.E2EErrorCount.Raw, l.CRCErrorCount.Raw, l.RetryBufferOverflow.Raw, l.PLLLockLoss.Raw)
+	fmt.Fprintf(w, msg(MsgIntelSMARTWear), l.WearLeveling, l.WearLevelingMin, l.WearLevelingMax,
+		l.WearLevelingAvg)
+	fmt.Fprintf(w, msg(MsgIntelSMARTWorkload), float64(l.TimedMediaWear.Raw)/1024,
+		l.TimedHostReads.Raw, l.TimedWorkload.Raw)
+	fmt.Fprintf(w, msg(MsgIntelSMARTThrottle), l.ThermalThrottlePercent, l.ThermalThrottleCount)
+	fmt.Fprintf(w, msg(MsgIntelSMARTWrites), l.NANDBytesWritten.Raw*32, l.HostBytesWritten.Raw*32)
+}
+
+// isIntel reports whether the PCI vendor ID is that of Intel or Solidigm.
+func isIntel(vid uint16) bool {
+	return vid == vidIntel || vid == vidSolidigm
+}
+
+// GetIntelSMARTLog reads the Intel / Solidigm Additional SMART Attributes log page. Since the log
+// identifier is vendor specific, ErrNotSupported is returned for devices of other vendors.
+func (d *NVMeDevice) GetIntelSMARTLog() (*IntelSMARTLog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isIntel(idCtrlr.VendorID) {
+		return nil, fmt.Errorf("intel additional SMART log on vendor %#04x: %w", idCtrlr.VendorID,
+			ErrNotSupported)
+	}
+
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_INTEL_SMART_ADD, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeIntelSMARTLog(buf), nil
+}
+
+// decodeIntelSMARTLog decodes the 12-byte attribute entries of the log page. Entries are matched
+// by key rather than position, since the set of attributes varies between drive generations.
+func decodeIntelSMARTLog(buf []byte) *IntelSMARTLog {
+	l := &IntelSMARTLog{}
+
+	for off := 0; off+12 <= len(buf); off += 12 {
+		e := buf[off : off+12]
+		v := IntelSMARTValue{Normalized: e[3], Raw: leUint(e[5:11])}
+
+		switch e[0] {
+		case intelAttrProgramFail:
+			l.ProgramFailCount = v
+		case intelAttrEraseFail:
+			l.EraseFailCount = v
+		case intelAttrWearLeveling:
+			l.WearLeveling = v.Normalized
+			l.WearLevelingMin = binary.LittleEndian.Uint16(e[5:])
+			l.WearLevelingMax = binary.LittleEndian.Uint16(e[7:])
+			l.WearLevelingAvg = binary.LittleEndian.Uint16(e[9:])
+		case intelAttrE2EErrors:
+			l.E2EErrorCount = v
+		case intelAttrCRCErrors:
+			l.CRCErrorCount = v
+		case intelAttrMediaWear:
+			l.TimedMediaWear = v
+		case intelAttrHostReads:
+			l.TimedHostReads = v
+		case intelAttrWorkloadTimer:
+			l.TimedWorkload = v
+		case intelAttrThermalThrottle:
+			l.ThermalThrottlePercent = e[5]
+			l.ThermalThrottleCount = binary.LittleEndian.Uint32(e[6:])
+		case intelAttrRetryBufOverflow:
+			l.RetryBufferOverflow = v
+		case intelAttrPLLLockLoss:
+			l.PLLLockLoss = v
+		case intelAttrNANDWrites:
+			l.NANDBytesWritten = v
+		case intelAttrHostWrites:
+			l.HostBytesWritten = v
+		}
+	}
+
+	return l
+}
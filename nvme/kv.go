@@ -0,0 +1,117 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// KVFormat describes a key value format supported by a Key Value namespace.
+type KVFormat struct {
+	MaxKeyLen   uint16 // Bytes
+	MaxValueLen uint32 // Bytes
+	MaxKeys     uint32 // 0 if not reported
+}
+
+// KVNamespace holds the Key Value command set specific attributes of a namespace.
+type KVNamespace struct {
+	Size               uint64 // Bytes
+	Utilization        uint64 // Bytes
+	OptimalValueGran   uint32 // Optimal value granularity, in bytes
+	Formats            []KVFormat
+	WriteProtected     bool
+	NVMSetID           uint16
+	EnduranceGroupID   uint16
+	ANAGroupID         uint32
+	FormatProgress     uint8 // Percentage remaining of a format in progress, if supported
+	FormatProgressSupp bool  // FormatProgress is supported
+}
+
+// Print outputs the KV namespace attributes in a pretty-print style.
+func (n *KVNamespace) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgKVNamespace), n.Size, n.Utilization, n.OptimalValueGran)
+
+	for i, f := range n.Formats {
+		fmt.Fprintf(w, msg(MsgKVFormat), i, f.MaxKeyLen, f.MaxValueLen, f.MaxKeys)
+	}
+}
+
+// IdentifyKVNamespace returns the Key Value command set specific attributes of the specified
+// namespace. If the namespace reports a command set other than Key Value in its Namespace
+// Identification Descriptor list, ErrNotSupported is returned, so that the NVM command set
+// identify data of a namespace is not misinterpreted.
+func (d *NVMeDevice) IdentifyKVNamespace(nsid uint32) (*KVNamespace, error) {
+	if err := d.checkVersion("key value namespace identify", Version14); err != nil {
+		return nil, err
+	}
+
+	csi, err := d.namespaceCSI(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	if csi != NVME_CSI_KV {
+		return nil, fmt.Errorf("namespace %d has command set %#x, not key value: %w", nsid, csi,
+			ErrNotSupported)
+	}
+
+	return d.identifyKVNamespace(nsid)
+}
+
+// identifyKVNamespace issues a Key Value command set Identify Namespace command, without checking
+// the command set of the namespace.
+func (d *NVMeDevice) identifyKVNamespace(nsid uint32) (*KVNamespace, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_CSI_NS, NVME_CSI_KV, nsid, 0, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeKVNamespace(buf), nil
+}
+
+func decodeKVNamespace(buf []byte) *KVNamespace {
+	var raw nvmeKVIdentNamespace
+
+	raw.unmarshal(buf)
+
+	n := &KVNamespace{
+		Size:               raw.Nsze,
+		Utilization:        raw.Nuse,
+		OptimalValueGran:   raw.Novg,
+		WriteProtected:     raw.Nsattr&1 != 0,
+		NVMSetID:           raw.Nvmsetid,
+		EnduranceGroupID:   raw.Endgid,
+		ANAGroupID:         raw.Anagrpid,
+		FormatProgress:     raw.Fpi & 0x7f,
+		FormatProgressSupp: raw.Fpi&0x80 != 0,
+	}
+
+	// NKVF is 0's based
+	for i := 0; i <= int(raw.Nkvf) && i < 16; i++ {
+		var f nvmeKVFormat
+
+		f.unmarshal(raw.Kvf[16*i:])
+
+		n.Formats = append(n.Formats, KVFormat{
+			MaxKeyLen:   f.Kml,
+			MaxValueLen: f.Vml,
+			MaxKeys:     f.Mnk,
+		})
+	}
+
+	return n
+}
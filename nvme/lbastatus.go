@@ -0,0 +1,193 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// oacsGetLBAStatus is the Get LBA Status support bit of the OACS field.
+const oacsGetLBAStatus = 1 << 9
+
+// LBAStatusAction is the Action Type (ATYPE) of a Get LBA Status command.
+type LBAStatusAction uint8
+
+const (
+	// LBAStatusScan requests the controller to scan the range for potentially unrecoverable
+	// logical blocks, including those not yet tracked.
+	LBAStatusScan LBAStatusAction = 0x10
+	// LBAStatusTracked returns only the potentially unrecoverable logical blocks already tracked
+	// by the controller.
+	LBAStatusTracked LBAStatusAction = 0x11
+)
+
+// LBAStatus is the result of a Get LBA Status command.
+type LBAStatus struct {
+	// Completion condition (CMPC): 1 if the command completed after examining the requested
+	// number of logical blocks, 2 if all logical blocks of the namespace were examined.
+	Condition uint8
+	Ranges    []LBARange // Potentially unrecoverable ranges
+}
+
+// GetLBAStatus returns the ranges of potentially unrecoverable logical blocks, examining up to
+// count logical blocks starting at slba. A count of 0 requests the controller to examine as many
+// logical blocks as it can.
+func (d *NVMeDevice) GetLBAStatus(nsid uint32, slba uint64, count uint16, atype LBAStatusAction) (*LBAStatus, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Oacs&oacsGetLBAStatus == 0 {
+		return nil, fmt.Errorf("get LBA status: %w", ErrNotSupported)
+	}
+
+	buf := make([]byte, 4096)
+
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_ADMIN_GET_LBA_STATUS,
+		nsid:     nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    uint32(slba),
+		cdw11:    uint32(slba >> 32),
+		cdw12:    uint32(len(buf)/4) - 1, // Maximum Number of Dwords (0's based)
+		cdw13:    uint32(count) | uint32(atype)<<24,
+	}
+
+	if err := d.adminCmd(&cmd, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeLBAStatus(buf), nil
+}
+
+func decodeLBAStatus(buf []byte) *LBAStatus {
+	var hdr nvmeLBAStatusHeader
+
+	hdr.unmarshal(buf)
+
+	s := &LBAStatus{Condition: hdr.Cmpc}
+
+	for i := 0; i < int(hdr.Nlsd) && 8+16*(i+1) <= len(buf); i++ {
+		var desc nvmeLBAStatusDesc
+
+		desc.unmarshal(buf[8+16*i:])
+		s.Ranges = append(s.Ranges, LBARange{Start: desc.Dslba, Count: uint64(desc.Nlb)})
+	}
+
+	return s
+}
+
+// LBAStatusNamespace lists the tracked potentially unrecoverable ranges of a namespace.
+type LBAStatusNamespace struct {
+	NSID uint32
+	// Recommended action type: the ATYPE of the Get LBA Status command recommended to query the
+	// ranges, i.e. LBAStatusScan or LBAStatusTracked.
+	RecommendedAction LBAStatusAction
+	Ranges            []LBARange
+}
+
+// LBAStatusLog is the decoded LBA Status Information log page (0x0E).
+type LBAStatusLog struct {
+	Generation             uint16 // Incremented when the log page contents change
+	EstimatedUnrecoverable uint32 // Estimated number of unrecoverable logical blocks
+	Namespaces             []LBAStatusNamespace
+}
+
+// Print outputs the LBA status log in a pretty-print style.
+func (l *LBAStatusLog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgLBAStatusHeader), l.Generation, l.EstimatedUnrecoverable)
+
+	for _, ns := range l.Namespaces {
+		fmt.Fprintf(w, msg(MsgLBAStatusNamespace), ns.NSID, ns.RecommendedAction, len(ns.Ranges))
+
+		for _, r := range ns.Ranges {
+			fmt.Fprintf(w, msg(MsgLBAStatusRange), r.Start, r.Count)
+		}
+	}
+}
+
+// GetLBAStatusLog reads the LBA Status Information log page, which lists the ranges of logical
+// blocks that the controller tracks as potentially unrecoverable, e.g. after media errors.
+func (d *NVMeDevice) GetLBAStatusLog() (*LBAStatusLog, error) {
+	hdr := make([]byte, 16)
+
+	args := logPageArgs{rae: true}
+
+	if err := d.getLog(NVME_LOG_LBA_STATUS, args, hdr); err != nil {
+		return nil, err
+	}
+
+	var h nvmeLBAStatusLogHeader
+
+	h.unmarshal(hdr)
+
+	if h.Lslplen < 16 {
+		return decodeLBAStatusLog(hdr)
+	}
+
+	// Read the whole log page, releasing the asynchronous event
+	args.rae = false
+
+	buf, err := d.readLog(NVME_LOG_LBA_STATUS, args, int(h.Lslplen))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeLBAStatusLog(buf)
+}
+
+func decodeLBAStatusLog(buf []byte) (*LBAStatusLog, error) {
+	var h nvmeLBAStatusLogHeader
+
+	h.unmarshal(buf)
+
+	l := &LBAStatusLog{Generation: h.Lsgc, EstimatedUnrecoverable: h.Estulb}
+
+	off := 16
+
+	for i := 0; i < int(h.Nlslne); i++ {
+		if off+16 > len(buf) {
+			return nil, fmt.Errorf("LBA status log truncated at namespace element %d", i)
+		}
+
+		var e nvmeLBAStatusNSElement
+
+		e.unmarshal(buf[off:])
+		off += 16
+
+		ns := LBAStatusNamespace{NSID: e.Neid, RecommendedAction: LBAStatusAction(e.Ratype)}
+
+		for j := 0; j < int(e.Nlrd); j++ {
+			if off+16 > len(buf) {
+				return nil, fmt.Errorf("LBA status log truncated in namespace %d", e.Neid)
+			}
+
+			var rd nvmeLBARangeDesc
+
+			rd.unmarshal(buf[off:])
+			off += 16
+
+			ns.Ranges = append(ns.Ranges, LBARange{Start: rd.Rslba, Count: uint64(rd.Rnlb)})
+		}
+
+		l.Namespaces = append(l.Namespaces, ns)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// oacsLockdown is the Command and Feature Lockdown support bit of the OACS field.
+const oacsLockdown = 1 << 10
+
+// LockdownScope is the scope (SCP) of a Lockdown command, i.e. the kind of identifier which is
+// prohibited or allowed.
+type LockdownScope uint8
+
+const (
+	LockdownAdminOpcode LockdownScope = 0x0 // Admin command opcode
+	LockdownFeatureID   LockdownScope = 0x2 // Set Features feature identifier
+	LockdownMIOpcode    LockdownScope = 0x3 // Management Interface command opcode
+	LockdownPCIeOpcode  LockdownScope = 0x4 // PCIe command opcode
+)
+
+func (s LockdownScope) String() string {
+	switch s {
+	case LockdownAdminOpcode:
+		return "admin command opcode"
+	case LockdownFeatureID:
+		return "Set Features FID"
+	case LockdownMIOpcode:
+		return "MI command opcode"
+	case LockdownPCIeOpcode:
+		return "PCIe command opcode"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(s))
+}
+
+// LockdownInterface is the interface (IFC) to which a Lockdown command applies.
+type LockdownInterface uint8
+
+const (
+	LockdownAdminQueue      LockdownInterface = 0x0 // Admin submission queue only
+	LockdownAdminQueueAndMI LockdownInterface = 0x1 // Admin submission queue and out-of-band management interface
+	LockdownMIOnly          LockdownInterface = 0x2 // Out-of-band management interface only
+)
+
+// LockdownContent selects the contents of the identifier list of the Command and Feature Lockdown
+// log page.
+type LockdownContent uint8
+
+const (
+	LockdownSupported  LockdownContent = 0x0 // Identifiers which may be prohibited
+	LockdownProhibited LockdownContent = 0x1 // Identifiers which are currently prohibited
+	LockdownAllowed    LockdownContent = 0x2 // Identifiers which may be prohibited, but currently are not
+)
+
+func (c LockdownContent) String() string {
+	switch c {
+	case LockdownSupported:
+		return "supported"
+	case LockdownProhibited:
+		return "prohibited"
+	case LockdownAllowed:
+		return "allowed"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(c))
+}
+
+// Lockdown prohibits (or allows) the execution of the specified command opcode, or the setting
+// of the specified feature, via the selected interface. Prohibitions persist until the next power
+// cycle.
+func (d *NVMeDevice) Lockdown(scope LockdownScope, id uint8, ifc LockdownInterface, prohibit bool) error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Oacs&oacsLockdown == 0 {
+		return fmt.Errorf("command and feature lockdown: %w", ErrNotSupported)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_LOCKDOWN,
+		cdw10:  uint32(scope&0xf) | uint32(ifc&0x3)<<5 | uint32(id)<<8,
+	}
+
+	if prohibit {
+		cmd.cdw10 |= 1 << 4
+	}
+
+	return d.adminCmd(&cmd, nil)
+}
+
+// LockdownLog is the decoded Command and Feature Lockdown log page (0x14).
+type LockdownLog struct {
+	Scope   LockdownScope
+	Content LockdownContent
+	IDs     []uint8 // Command opcodes or feature identifiers
+}
+
+// Print outputs the lockdown log in a pretty-print style.
+func (l *LockdownLog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgLockdownHeader), l.Content, l.Scope, len(l.IDs))
+
+	for _, id := range l.IDs {
+		fmt.Fprintf(w, msg(MsgLockdownID), id)
+	}
+}
+
+// GetLockdownLog reads the Command and Feature Lockdown log page, listing the identifiers of the
+// specified scope which are supported, prohibited or allowed.
+func (d *NVMeDevice) GetLockdownLog(content LockdownContent, scope LockdownScope) (*LockdownLog, error) {
+	buf := make([]byte, 512)
+
+	args := logPageArgs{nsid: 0xffffffff, lsp: uint8(content&0x3)<<4 | uint8(scope&0xf)}
+
+	if err := d.getLog(NVME_LOG_LOCKDOWN, args, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeLockdownLog(buf), nil
+}
+
+func decodeLockdownLog(buf []byte) *LockdownLog {
+	var raw nvmeLockdownLog
+
+	raw.unmarshal(buf)
+
+	l := &LockdownLog{
+		Scope:   LockdownScope(raw.Cfila & 0xf),
+		Content: LockdownContent(raw.Cfila >> 4 & 0x3),
+		IDs:     make([]uint8, raw.Length),
+	}
+
+	copy(l.IDs, raw.Cfil[:])
+
+	return l
+}
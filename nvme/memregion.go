@@ -0,0 +1,162 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// CAP register bits indicating controller memory buffer and persistent memory region support.
+const (
+	capPMRS = 1 << 56
+	capCMBS = 1 << 57
+)
+
+// CMBInfo describes the controller memory buffer, decoded from the CMBLOC and CMBSZ registers.
+type CMBInfo struct {
+	Supported        bool
+	BAR              uint8  // Base Indicator Register
+	Offset           uint64 // Offset within the BAR, in bytes
+	Size             uint64 // Size, in bytes
+	SubmissionQueues bool   // Supports I/O submission queues
+	CompletionQueues bool   // Supports I/O completion queues
+	PRPSGLLists      bool   // Supports PRP and SGL lists
+	ReadData         bool   // Supports data for read commands
+	WriteData        bool   // Supports data for write commands
+}
+
+// PMRInfo describes the persistent memory region, decoded from the PMR registers.
+type PMRInfo struct {
+	Supported        bool
+	BAR              uint8  // Base Indicator Register
+	Size             uint64 // Size of the BAR, in bytes
+	ReadData         bool   // Supports data for read commands
+	WriteData        bool   // Supports data for write commands
+	Enabled          bool
+	Ready            bool
+	Timeout          time.Duration // Worst-case time for the PMR to become ready
+	ElasticityBuffer uint64        // Elasticity buffer size, in bytes
+	SustainedWrite   uint64        // Sustained write throughput, in bytes per second
+}
+
+// MemoryRegions describes the controller's host-accessible memory regions.
+type MemoryRegions struct {
+	CMB CMBInfo
+	PMR PMRInfo
+}
+
+// Print outputs the memory regions in a pretty-print style.
+func (m *MemoryRegions) Print(w io.Writer) {
+	if m.CMB.Supported && m.CMB.Size > 0 {
+		fmt.Fprintf(w, msg(MsgCMBRegion), m.CMB.Size, m.CMB.BAR, m.CMB.Offset)
+		fmt.Fprintf(w, msg(MsgCMBUse), m.CMB.SubmissionQueues, m.CMB.CompletionQueues,
+			m.CMB.PRPSGLLists, m.CMB.ReadData, m.CMB.WriteData)
+	} else {
+		fmt.Fprint(w, msg(MsgCMBNone))
+	}
+
+	if m.PMR.Supported {
+		fmt.Fprintf(w, msg(MsgPMRRegion), m.PMR.Size, m.PMR.BAR, m.PMR.Enabled, m.PMR.Ready)
+		fmt.Fprintf(w, msg(MsgPMRUse), m.PMR.ReadData, m.PMR.WriteData, m.PMR.Timeout)
+		fmt.Fprintf(w, msg(MsgPMRBuffer), m.PMR.ElasticityBuffer, m.PMR.SustainedWrite)
+	} else {
+		fmt.Fprint(w, msg(MsgPMRNone))
+	}
+}
+
+// MemoryRegions reports whether the controller exposes a controller memory buffer (CMB) or
+// persistent memory region (PMR), and their sizes and attributes. The controller registers are
+// read via sysfs, which requires root privileges.
+func (d *NVMeDevice) MemoryRegions() (*MemoryRegions, error) {
+	regs, err := d.readRegisters()
+	if err != nil {
+		return nil, err
+	}
+
+	m := decodeMemoryRegions(regs)
+
+	if m.PMR.Supported {
+		if m.PMR.Size, err = d.barSize(m.PMR.BAR); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// decodeMemoryRegions decodes the CMB and PMR registers, with the exception of the PMR size,
+// which is the size of the PMR's BAR.
+func decodeMemoryRegions(regs []byte) *MemoryRegions {
+	var m MemoryRegions
+
+	caps := reg64(regs, regCAP)
+
+	if caps&capCMBS != 0 {
+		loc, sz := reg32(regs, regCMBLOC), reg32(regs, regCMBSZ)
+
+		// Size Units: 4 KiB * 16^SZU
+		unit := uint64(4096) << (4 * ((sz >> 8) & 0xf))
+
+		m.CMB = CMBInfo{
+			Supported:        true,
+			BAR:              uint8(loc & 0x7),
+			Offset:           uint64(loc>>12) * unit,
+			Size:             uint64(sz>>12) * unit,
+			SubmissionQueues: sz&(1<<0) != 0,
+			CompletionQueues: sz&(1<<1) != 0,
+			PRPSGLLists:      sz&(1<<2) != 0,
+			ReadData:         sz&(1<<3) != 0,
+			WriteData:        sz&(1<<4) != 0,
+		}
+	}
+
+	if caps&capPMRS != 0 {
+		pmrcap, ebs := reg32(regs, regPMRCAP), reg32(regs, regPMREBS)
+
+		// PMRTO is in units of 500 ms (PMRTU 0) or minutes (PMRTU 1)
+		timeout := time.Duration((pmrcap>>16)&0xff) * 500 * time.Millisecond
+		if (pmrcap>>8)&0x3 == 1 {
+			timeout = time.Duration((pmrcap>>16)&0xff) * time.Minute
+		}
+
+		m.PMR = PMRInfo{
+			Supported:        true,
+			BAR:              uint8((pmrcap >> 5) & 0x7),
+			ReadData:         pmrcap&(1<<3) != 0,
+			WriteData:        pmrcap&(1<<4) != 0,
+			Enabled:          reg32(regs, regPMRCTL)&1 != 0,
+			Ready:            reg32(regs, regPMRSTS)&(1<<8) == 0,
+			Timeout:          timeout,
+			ElasticityBuffer: uint64(ebs>>8) << pmrSizeShift(ebs&0xf),
+		}
+
+		swtp := reg32(regs, regPMRSWTP)
+		m.PMR.SustainedWrite = uint64(swtp>>8) << pmrSizeShift(swtp&0xf)
+	}
+
+	return &m
+}
+
+// pmrSizeShift returns the shift corresponding to a PMR size or throughput unit, i.e. bytes,
+// KiB, MiB or GiB.
+func pmrSizeShift(unit uint32) uint {
+	if unit > 3 {
+		return 0
+	}
+
+	return uint(unit) * 10
+}
@@ -0,0 +1,620 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"sync"
+)
+
+// MessageID identifies a human-readable report string. Each message is a format string, and any
+// override must accept the same arguments, in the same order, as the built-in message.
+type MessageID string
+
+// Report strings used by the printers in this package.
+const (
+	MsgCtrlVendorID           MessageID = "ctrl.vendor_id"
+	MsgCtrlModelNumber        MessageID = "ctrl.model_number"
+	MsgCtrlSerialNumber       MessageID = "ctrl.serial_number"
+	MsgCtrlFirmwareVersion    MessageID = "ctrl.firmware_version"
+	MsgCtrlVersion            MessageID = "ctrl.version"
+	MsgCtrlOUI                MessageID = "ctrl.oui"
+	MsgCtrlOUIVendor          MessageID = "ctrl.oui_vendor"
+	MsgCtrlMaxDataXferSize    MessageID = "ctrl.max_data_xfer_size"
+	MsgCtrlMaxDataXferNoLimit MessageID = "ctrl.max_data_xfer_no_limit"
+	MsgCtrlSubsystemNQN       MessageID = "ctrl.subsystem_nqn"
+	MsgCtrlID                 MessageID = "ctrl.id"
+	MsgCtrlType               MessageID = "ctrl.type"
+	MsgCtrlFGUID              MessageID = "ctrl.fguid"
+
+	MsgNsSize           MessageID = "ns.size"
+	MsgNsUtilisation    MessageID = "ns.utilisation"
+	MsgNsWriteProtected MessageID = "ns.write_protected"
+
+	MsgSMARTHeader           MessageID = "smart.header"
+	MsgSMARTCritWarning      MessageID = "smart.critical_warning"
+	MsgSMARTTemperature      MessageID = "smart.temperature"
+	MsgSMARTTempSensor       MessageID = "smart.temperature_sensor"
+	MsgSMARTAvailSpare       MessageID = "smart.avail_spare"
+	MsgSMARTSpareThresh      MessageID = "smart.avail_spare_threshold"
+	MsgSMARTPercentUsed      MessageID = "smart.percentage_used"
+	MsgSMARTDataUnitsRead    MessageID = "smart.data_units_read"
+	MsgSMARTDataUnitsWritten MessageID = "smart.data_units_written"
+	MsgSMARTHostReads        MessageID = "smart.host_read_commands"
+	MsgSMARTHostWrites       MessageID = "smart.host_write_commands"
+	MsgSMARTCtrlBusyTime     MessageID = "smart.controller_busy_time"
+	MsgSMARTPowerCycles      MessageID = "smart.power_cycles"
+	MsgSMARTPowerOnHours     MessageID = "smart.power_on_hours"
+	MsgSMARTUnsafeShutdowns  MessageID = "smart.unsafe_shutdowns"
+	MsgSMARTMediaErrors      MessageID = "smart.media_errors"
+	MsgSMARTNumErrLogEntries MessageID = "smart.error_log_entries"
+
+	MsgArbBurst         MessageID = "arb.burst"
+	MsgArbBurstNoLimit  MessageID = "arb.burst_no_limit"
+	MsgArbWeights       MessageID = "arb.weights"
+	MsgIRQCoalThreshold MessageID = "irq_coalesce.threshold"
+	MsgIRQCoalTime      MessageID = "irq_coalesce.time"
+
+	MsgFeatureEffects MessageID = "feature_effects.entry"
+	MsgCmdEffects     MessageID = "command_effects.entry"
+
+	MsgHealthOK          MessageID = "health.ok"
+	MsgHealthUnknown     MessageID = "health.unknown"
+	MsgHealthWarning     MessageID = "health.warning"
+	MsgHealthCritical    MessageID = "health.critical"
+	MsgHealthSpare       MessageID = "health.reason.spare"
+	MsgHealthTemperature MessageID = "health.reason.temperature"
+	MsgHealthReliability MessageID = "health.reason.reliability"
+	MsgHealthReadOnly    MessageID = "health.reason.read_only"
+	MsgHealthBackup      MessageID = "health.reason.volatile_backup"
+	MsgHealthPMRReadOnly MessageID = "health.reason.pmr_read_only"
+	MsgHealthWornOut     MessageID = "health.reason.worn_out"
+	MsgHealthMediaErrors MessageID = "health.reason.media_errors"
+	MsgHealthNoSMART     MessageID = "health.reason.no_smart"
+	MsgPoolStatus        MessageID = "pool.status"
+	MsgPoolWeakest       MessageID = "pool.weakest"
+	MsgPoolMember        MessageID = "pool.member"
+	MsgPoolReason        MessageID = "pool.reason"
+
+	MsgImpactNamespace MessageID = "impact.namespace"
+	MsgImpactPartition MessageID = "impact.partition"
+	MsgImpactHolder    MessageID = "impact.holder"
+	MsgImpactMount     MessageID = "impact.mount"
+	MsgImpactSwap      MessageID = "impact.swap"
+	MsgImpactInUse     MessageID = "impact.in_use"
+	MsgImpactNotInUse  MessageID = "impact.not_in_use"
+
+	MsgUtilInterval    MessageID = "util.interval"
+	MsgUtilBusy        MessageID = "util.busy"
+	MsgUtilIOPS        MessageID = "util.iops"
+	MsgUtilThroughput  MessageID = "util.throughput"
+	MsgUtilCmdsPerBusy MessageID = "util.cmds_per_busy"
+
+	MsgFwActiveSlot MessageID = "fw_slot.active"
+	MsgFwNextSlot   MessageID = "fw_slot.next_reset"
+	MsgFwSlot       MessageID = "fw_slot.revision"
+
+	MsgSelfTestCurrent    MessageID = "self_test.current"
+	MsgSelfTestIdle       MessageID = "self_test.idle"
+	MsgSelfTestHeader     MessageID = "self_test.header"
+	MsgSelfTestResult     MessageID = "self_test.result"
+	MsgSelfTestFailingLBA MessageID = "self_test.failing_lba"
+
+	MsgThermalWarning  MessageID = "thermal.warning"
+	MsgThermalCritical MessageID = "thermal.critical"
+
+	MsgZoneCount       MessageID = "zns.zones"
+	MsgZoneState       MessageID = "zns.state"
+	MsgZoneActive      MessageID = "zns.active"
+	MsgZoneOpen        MessageID = "zns.open"
+	MsgZoneNoLimit     MessageID = "zns.no_limit"
+	MsgZoneUtilisation MessageID = "zns.utilisation"
+
+	MsgZNSZoneSize        MessageID = "zns.ns.zone_size"
+	MsgZNSLimits          MessageID = "zns.ns.limits"
+	MsgZNSCharacteristics MessageID = "zns.ns.characteristics"
+	MsgZNSZRWA            MessageID = "zns.ns.zrwa"
+
+	MsgKVNamespace MessageID = "kv.namespace"
+	MsgKVFormat    MessageID = "kv.format"
+
+	MsgWipeDevice       MessageID = "wipe.device"
+	MsgWipeMethod       MessageID = "wipe.method"
+	MsgWipePasses       MessageID = "wipe.passes"
+	MsgWipeGlobalErased MessageID = "wipe.global_erased"
+	MsgWipeStarted      MessageID = "wipe.started"
+	MsgWipeIssued       MessageID = "wipe.issued"
+	MsgWipeSignature    MessageID = "wipe.signature"
+
+	MsgOpalSSC              MessageID = "opal.ssc"
+	MsgOpalLockingSupported MessageID = "opal.locking_supported"
+	MsgOpalLockingEnabled   MessageID = "opal.locking_enabled"
+	MsgOpalLocked           MessageID = "opal.locked"
+	MsgOpalMediaEncryption  MessageID = "opal.media_encryption"
+	MsgOpalMBRShadowing     MessageID = "opal.mbr_shadowing"
+	MsgOpalMBREnabled       MessageID = "opal.mbr_enabled"
+	MsgOpalMBRDone          MessageID = "opal.mbr_done"
+
+	MsgCMBRegion MessageID = "cmb.region"
+	MsgCMBUse    MessageID = "cmb.use"
+	MsgCMBNone   MessageID = "cmb.none"
+	MsgPMRRegion MessageID = "pmr.region"
+	MsgPMRUse    MessageID = "pmr.use"
+	MsgPMRBuffer MessageID = "pmr.buffer"
+	MsgPMRNone   MessageID = "pmr.none"
+
+	MsgCollectProfile MessageID = "collect.profile"
+	MsgCollectLogPage MessageID = "collect.log_page"
+	MsgCollectFeature MessageID = "collect.feature"
+	MsgCollectError   MessageID = "collect.error"
+
+	MsgResvGeneration MessageID = "resv.generation"
+	MsgResvType       MessageID = "resv.type"
+	MsgResvPTPL       MessageID = "resv.ptpl"
+	MsgResvRegistrant MessageID = "resv.registrant"
+
+	MsgRecAction  MessageID = "rec.action"
+	MsgRecFinding MessageID = "rec.finding"
+
+	MsgScrubProgress  MessageID = "scrub.progress"
+	MsgScrubBadRange  MessageID = "scrub.bad_range"
+	MsgScrubUntracked MessageID = "scrub.untracked"
+
+	MsgStreamsMax       MessageID = "streams.max"
+	MsgStreamsSubsys    MessageID = "streams.subsys"
+	MsgStreamsNamespace MessageID = "streams.namespace"
+	MsgStreamsWriteSize MessageID = "streams.write_size"
+
+	MsgVirtPrimary   MessageID = "virt.primary"
+	MsgVirtResources MessageID = "virt.resources"
+	MsgVirtSecondary MessageID = "virt.secondary"
+
+	MsgLockdownHeader MessageID = "lockdown.header"
+	MsgLockdownID     MessageID = "lockdown.id"
+
+	MsgCapConfig         MessageID = "capacity.config"
+	MsgCapEnduranceGroup MessageID = "capacity.endurance_group"
+
+	MsgLBAStatusHeader    MessageID = "lba_status.header"
+	MsgLBAStatusNamespace MessageID = "lba_status.namespace"
+	MsgLBAStatusRange     MessageID = "lba_status.range"
+
+	MsgMIHealthStatus MessageID = "mi.health_status"
+	MsgMIHealthCCS    MessageID = "mi.health_ccs"
+
+	MsgCmdSetCombination MessageID = "cmdset.combination"
+
+	MsgErrorLogEntry MessageID = "error_log.entry"
+	MsgErrorLogParam MessageID = "error_log.param"
+	MsgErrorLogLBA   MessageID = "error_log.lba"
+
+	MsgNsDescEUI64 MessageID = "ns_desc.eui64"
+	MsgNsDescNGUID MessageID = "ns_desc.nguid"
+	MsgNsDescUUID  MessageID = "ns_desc.uuid"
+	MsgNsDescCSI   MessageID = "ns_desc.csi"
+
+	MsgNVMSet MessageID = "nvm_set"
+
+	MsgUUIDEntry MessageID = "uuid.entry"
+
+	MsgDomain MessageID = "domain"
+
+	MsgChangedNsList     MessageID = "changed_ns.list"
+	MsgChangedNsOverflow MessageID = "changed_ns.overflow"
+
+	MsgTelemetryHeader MessageID = "telemetry.header"
+	MsgTelemetryArea   MessageID = "telemetry.area"
+
+	MsgPLMHeader   MessageID = "plm.header"
+	MsgPLMTypical  MessageID = "plm.typical"
+	MsgPLMEstimate MessageID = "plm.estimate"
+
+	MsgANAHeader MessageID = "ana.header"
+	MsgANAGroup  MessageID = "ana.group"
+
+	MsgPELHeader MessageID = "pel.header"
+	MsgPELEvent  MessageID = "pel.event"
+
+	MsgEnduranceHeader MessageID = "endurance.header"
+	MsgEnduranceUsage  MessageID = "endurance.usage"
+
+	MsgMediaUnitHeader MessageID = "capacity.media_unit_header"
+	MsgMediaUnit       MessageID = "capacity.media_unit"
+
+	MsgSupportedLogPage MessageID = "support.supported_log_page"
+
+	MsgSanitizeStatus    MessageID = "sanitize.status"
+	MsgSanitizeEstimates MessageID = "sanitize.estimates"
+
+	MsgDiscoveryHeader MessageID = "discovery.header"
+	MsgDiscoveryEntry  MessageID = "discovery.entry"
+
+	MsgResvNotification MessageID = "reservation.notification"
+
+	MsgFDPConfig MessageID = "fdp.config"
+	MsgFDPHandle MessageID = "fdp.handle"
+	MsgFDPStats  MessageID = "fdp.stats"
+	MsgFDPEvent  MessageID = "fdp.event"
+
+	MsgRotationalMedia MessageID = "rotational.media"
+
+	MsgOCPSMARTHeader    MessageID = "ocp.smart.header"
+	MsgOCPSMARTMedia     MessageID = "ocp.smart.media"
+	MsgOCPSMARTBadBlocks MessageID = "ocp.smart.bad_blocks"
+	MsgOCPSMARTErrors    MessageID = "ocp.smart.errors"
+	MsgOCPSMARTWear      MessageID = "ocp.smart.wear"
+	MsgOCPSMARTEvents    MessageID = "ocp.smart.events"
+
+	MsgLatencyMonitorHeader MessageID = "ocp.latency.header"
+	MsgLatencyMonitorBucket MessageID = "ocp.latency.bucket"
+	MsgLatencyMonitorDebug  MessageID = "ocp.latency.debug"
+
+	MsgOCPDeviceCaps MessageID = "ocp.device_caps"
+
+	MsgIntelSMARTHeader   MessageID = "intel.smart.header"
+	MsgIntelSMARTFailures MessageID = "intel.smart.failures"
+	MsgIntelSMARTWear     MessageID = "intel.smart.wear"
+	MsgIntelSMARTWorkload MessageID = "intel.smart.workload"
+	MsgIntelSMARTThrottle MessageID = "intel.smart.throttle"
+	MsgIntelSMARTWrites   MessageID = "intel.smart.writes"
+
+	MsgWDCSMARTHeader   MessageID = "wdc.smart.header"
+	MsgWDCSMARTMedia    MessageID = "wdc.smart.media"
+	MsgWDCSMARTErrors   MessageID = "wdc.smart.errors"
+	MsgWDCSMARTFailures MessageID = "wdc.smart.failures"
+	MsgWDCSMARTWear     MessageID = "wdc.smart.wear"
+	MsgWDCSMARTEvents   MessageID = "wdc.smart.events"
+
+	MsgPowerState         MessageID = "ctrl.power_state"
+	MsgPowerStatePerf     MessageID = "ctrl.power_state_perf"
+	MsgPowerStateWorkload MessageID = "ctrl.power_state_workload"
+
+	MsgRegCAP         MessageID = "reg.cap"
+	MsgRegQueues      MessageID = "reg.queues"
+	MsgRegTimeout     MessageID = "reg.timeout"
+	MsgRegCommandSets MessageID = "reg.command_sets"
+	MsgRegPageSizes   MessageID = "reg.page_sizes"
+	MsgRegVersion     MessageID = "reg.version"
+	MsgRegCC          MessageID = "reg.cc"
+	MsgRegConfig      MessageID = "reg.config"
+	MsgRegCSTS        MessageID = "reg.csts"
+	MsgRegStatus      MessageID = "reg.status"
+
+	MsgSupportLogPage MessageID = "support.log_page"
+	MsgSupportFeature MessageID = "support.feature"
+
+	MsgSupportLogPageUUID MessageID = "support.log_page_uuid"
+	MsgSupportFeatureUUID MessageID = "support.feature_uuid"
+	MsgSupported          MessageID = "support.supported"
+	MsgNotSupported       MessageID = "support.not_supported"
+)
+
+// defaultMessages is the built-in (English) message catalog.
+var defaultMessages = map[MessageID]string{
+	MsgCtrlVendorID:           "Vendor ID          : %#04x\n",
+	MsgCtrlModelNumber:        "Model number       : %s\n",
+	MsgCtrlSerialNumber:       "Serial number      : %s\n",
+	MsgCtrlFirmwareVersion:    "Firmware version   : %s\n",
+	MsgCtrlVersion:            "NVMe version       : %s\n",
+	MsgCtrlOUI:                "IEEE OUI identifier: %#06x\n",
+	MsgCtrlOUIVendor:          "IEEE OUI vendor    : %s\n",
+	MsgCtrlMaxDataXferSize:    "Max. data xfer size: %d bytes\n",
+	MsgCtrlMaxDataXferNoLimit: "Max. data xfer size: no limit\n",
+	MsgCtrlSubsystemNQN:       "Subsystem NQN      : %s\n",
+	MsgCtrlID:                 "Controller ID      : %d\n",
+	MsgCtrlType:               "Controller type    : %s\n",
+	MsgCtrlFGUID:              "FRU GUID           : %s\n",
+
+	MsgNsSize:           "Namespace %d size: %d sectors\n",
+	MsgNsUtilisation:    "Namespace %d utilisation: %d sectors\n",
+	MsgNsWriteProtected: "Namespace %d write protected: %t\n",
+
+	MsgSMARTHeader:           "\nSMART data follows:\n",
+	MsgSMARTCritWarning:      "Critical warning: %#02x\n",
+	MsgSMARTTemperature:      "Temperature: %s\n",
+	MsgSMARTTempSensor:       "Temperature sensor %d: %s\n",
+	MsgSMARTAvailSpare:       "Avail. spare: %d%%\n",
+	MsgSMARTSpareThresh:      "Avail. spare threshold: %d%%\n",
+	MsgSMARTPercentUsed:      "Percentage used: %d%%\n",
+	MsgSMARTDataUnitsRead:    "Data units read: %d [%s]\n",
+	MsgSMARTDataUnitsWritten: "Data units written: %d [%s]\n",
+	MsgSMARTHostReads:        "Host read commands: %d\n",
+	MsgSMARTHostWrites:       "Host write commands: %d\n",
+	MsgSMARTCtrlBusyTime:     "Controller busy time: %d\n",
+	MsgSMARTPowerCycles:      "Power cycles: %d\n",
+	MsgSMARTPowerOnHours:     "Power on hours: %d\n",
+	MsgSMARTUnsafeShutdowns:  "Unsafe shutdowns: %d\n",
+	MsgSMARTMediaErrors:      "Media & data integrity errors: %d\n",
+	MsgSMARTNumErrLogEntries: "Error information log entries: %d\n",
+
+	MsgArbBurst:         "Arbitration burst  : %d commands\n",
+	MsgArbBurstNoLimit:  "Arbitration burst  : no limit\n",
+	MsgArbWeights:       "Priority weights   : high=%d, medium=%d, low=%d\n",
+	MsgIRQCoalThreshold: "Aggregation thresh.: %d completions\n",
+	MsgIRQCoalTime:      "Aggregation time   : %d µs\n",
+
+	MsgFeatureEffects: "FID %#02x: scope=%s, effects=%s\n",
+	MsgCmdEffects:     "%s opcode %#02x: submission=%s, effects=%s\n",
+
+	MsgHealthOK:          "OK",
+	MsgHealthUnknown:     "UNKNOWN",
+	MsgHealthWarning:     "WARNING",
+	MsgHealthCritical:    "CRITICAL",
+	MsgHealthSpare:       "available spare %d%% is below threshold %d%%",
+	MsgHealthTemperature: "temperature is outside of thresholds",
+	MsgHealthReliability: "NVM subsystem reliability is degraded",
+	MsgHealthReadOnly:    "media has been placed in read-only mode",
+	MsgHealthBackup:      "volatile memory backup device has failed",
+	MsgHealthPMRReadOnly: "persistent memory region has been placed in read-only mode",
+	MsgHealthWornOut:     "percentage used %d%% exceeds rated endurance",
+	MsgHealthMediaErrors: "%d media and data integrity errors",
+	MsgHealthNoSMART:     "SMART log unavailable: %v",
+	MsgPoolStatus:        "Pool health       : %s\n",
+	MsgPoolWeakest:       "Weakest member    : %s\n",
+	MsgPoolMember:        "  %-16s %s\n",
+	MsgPoolReason:        "    - %s\n",
+
+	MsgImpactNamespace: "Namespace block device: %s\n",
+	MsgImpactPartition: "Partition             : %s\n",
+	MsgImpactHolder:    "Holder                : %s\n",
+	MsgImpactMount:     "Mounted filesystem    : %s on %s (%s)\n",
+	MsgImpactSwap:      "Active swap           : %s\n",
+	MsgImpactInUse:     "Affected devices are IN USE\n",
+	MsgImpactNotInUse:  "Affected devices are not in use\n",
+
+	MsgUtilInterval:    "Sample interval    : %s\n",
+	MsgUtilBusy:        "Controller busy    : %.1f%%\n",
+	MsgUtilIOPS:        "Host commands/s    : %.1f read, %.1f write\n",
+	MsgUtilThroughput:  "Throughput         : %s/s read, %s/s write\n",
+	MsgUtilCmdsPerBusy: "Commands/busy sec. : %.1f\n",
+
+	MsgFwActiveSlot: "Active firmware slot      : %d\n",
+	MsgFwNextSlot:   "Slot to activate at reset: %d\n",
+	MsgFwSlot:       "Firmware slot %d revision : %s\n",
+
+	MsgSelfTestCurrent:    "Current self-test  : %s, %d%% complete\n",
+	MsgSelfTestIdle:       "Current self-test  : none\n",
+	MsgSelfTestHeader:     "Num  Test       Status                                     Segment  Power on hours\n",
+	MsgSelfTestResult:     "#%-3d %-10s %-42s %-8d %d\n",
+	MsgSelfTestFailingLBA: "     failing LBA %d in namespace %d, status %#03x\n",
+
+	MsgThermalWarning:  "composite temperature exceeded warning threshold for %d minutes",
+	MsgThermalCritical: "composite temperature exceeded critical threshold for %d minutes",
+
+	MsgZoneCount:       "Zones              : %d\n",
+	MsgZoneState:       "  %-17s: %d\n",
+	MsgZoneActive:      "Active zones       : %d of %s\n",
+	MsgZoneOpen:        "Open zones         : %d of %s\n",
+	MsgZoneNoLimit:     "unlimited",
+	MsgZoneUtilisation: "Zone capacity used : %d of %d blocks (%.1f%%)\n",
+
+	MsgZNSZoneSize:        "Zone size          : %d blocks, descriptor extension %d bytes\n",
+	MsgZNSLimits:          "Zone limits        : %s active, %s open\n",
+	MsgZNSCharacteristics: "Zone attributes    : variable capacity %t, active excursions %t, read across boundaries %t\n",
+	MsgZNSZRWA:            "ZRWA               : %d resources, size %d blocks, flush granularity %d blocks, explicit flush %t\n",
+
+	MsgKVNamespace: "KV namespace size %d bytes, utilization %d bytes, optimal value granularity %d bytes\n",
+	MsgKVFormat:    "KV format %d: max key length %d, max value length %d, max keys %d\n",
+
+	MsgWipeDevice:       "Device             : %s\n",
+	MsgWipeMethod:       "Sanitize method    : %s\n",
+	MsgWipePasses:       "Overwrite passes   : %d\n",
+	MsgWipeGlobalErased: "Global data erased : %t\n",
+	MsgWipeStarted:      "Sanitize started   : %s\n",
+	MsgWipeIssued:       "Certificate issued : %s\n",
+	MsgWipeSignature:    "HMAC-SHA256        : %x\n",
+
+	MsgOpalSSC:              "Security subsystem : %s (base ComID %#04x, %d ComIDs)\n",
+	MsgOpalLockingSupported: "Locking supported  : %t\n",
+	MsgOpalLockingEnabled:   "Locking enabled    : %t\n",
+	MsgOpalLocked:           "Locked             : %t\n",
+	MsgOpalMediaEncryption:  "Media encryption   : %t\n",
+	MsgOpalMBRShadowing:     "MBR shadowing      : %t\n",
+	MsgOpalMBREnabled:       "MBR enabled        : %t\n",
+	MsgOpalMBRDone:          "MBR done           : %t\n",
+
+	MsgCMBRegion: "Controller memory buffer      : %d bytes in BAR%d at offset %#x\n",
+	MsgCMBUse:    "  SQs: %t, CQs: %t, PRP/SGL lists: %t, read data: %t, write data: %t\n",
+	MsgCMBNone:   "Controller memory buffer      : not supported\n",
+	MsgPMRRegion: "Persistent memory region      : %d bytes in BAR%d, enabled: %t, ready: %t\n",
+	MsgPMRUse:    "  read data: %t, write data: %t, ready timeout: %s\n",
+	MsgPMRBuffer: "  elasticity buffer: %d bytes, sustained write: %d bytes/s\n",
+	MsgPMRNone:   "Persistent memory region      : not supported\n",
+
+	MsgCollectProfile: "Collection profile : %s\n",
+	MsgCollectLogPage: "Log page %#04x      : %d bytes\n",
+	MsgCollectFeature: "Feature %#04x       : %#08x\n",
+	MsgCollectError:   "Not collected      : %s: %v\n",
+
+	MsgResvGeneration: "Generation         : %d\n",
+	MsgResvType:       "Reservation type   : %s\n",
+	MsgResvPTPL:       "Persist through power loss: %t\n",
+	MsgResvRegistrant: "Controller %#04x  host ID %x  key %#016x  holder: %t\n",
+
+	MsgRecAction:  "Recommended action : %s\n",
+	MsgRecFinding: "  [%s] %s: %s\n",
+
+	MsgScrubProgress:  "Namespace %d scrubbed: %d of %d blocks (%s)\n",
+	MsgScrubBadRange:  "  unreadable LBAs %d-%d (%d blocks)\n",
+	MsgScrubUntracked: "  LBAs %d-%d (%d blocks) not reported by Get LBA Status\n",
+
+	MsgStreamsMax:       "Max. streams       : %d\n",
+	MsgStreamsSubsys:    "Subsystem streams  : %d available, %d open\n",
+	MsgStreamsNamespace: "Namespace streams  : %d allocated, %d open\n",
+	MsgStreamsWriteSize: "Stream write size  : %d blocks, granularity %d\n",
+
+	MsgVirtPrimary:   "Primary controller : %#04x, port %#04x\n",
+	MsgVirtResources: "  %s resources: %d flexible (%d assigned, %d primary), %d private, max %d per secondary, granularity %d\n",
+	MsgVirtSecondary: "Secondary controller %#04x: VF %d, online: %t, VQ %d, VI %d\n",
+
+	MsgLockdownHeader: "Lockdown (%s %s identifiers): %d\n",
+	MsgLockdownID:     "  %#02x\n",
+
+	MsgCapConfig:         "Capacity configuration %d (domain %d): %d endurance groups\n",
+	MsgCapEnduranceGroup: "  Endurance group %d: capacity %s, spare %s, NVM sets %v, %d channels\n",
+
+	MsgLBAStatusHeader:    "LBA status generation %d, estimated unrecoverable blocks: %d\n",
+	MsgLBAStatusNamespace: "  Namespace %d (recommended action %#02x): %d ranges\n",
+	MsgLBAStatusRange:     "    LBA %d, %d blocks\n",
+
+	MsgMIHealthStatus: "Drive functional: %t, reset not required: %t, port 0 link: %t, port 1 link: %t\n",
+	MsgMIHealthCCS:    "Composite controller status: %#04x\n",
+
+	MsgCmdSetCombination: "I/O command set combination %d: %s\n",
+
+	MsgErrorLogEntry: "Error %d: SQID %d, CID %#04x: %s\n",
+	MsgErrorLogParam: "  Parameter error location: byte %d, bit %d\n",
+	MsgErrorLogLBA:   "  LBA %d, namespace %d\n",
+
+	MsgNsDescEUI64: "EUI-64 : %s\n",
+	MsgNsDescNGUID: "NGUID  : %s\n",
+	MsgNsDescUUID:  "UUID   : %s\n",
+	MsgNsDescCSI:   "CSI    : %#x\n",
+
+	MsgNVMSet: "NVM set %d (endurance group %d): %s total, %s unallocated, optimal write size %d bytes, random read %d us\n",
+
+	MsgUUIDEntry: "UUID index %d: %s (%s)\n",
+
+	MsgDomain: "Domain %d: %s total, %s unallocated, max endurance group %s\n",
+
+	MsgChangedNsList:     "Changed namespaces (%d): %v\n",
+	MsgChangedNsOverflow: "More than 1024 namespaces changed\n",
+
+	MsgTelemetryHeader: "Telemetry log %#02x: OUI %#06x, host generation %d, controller data available: %t, controller generation %d\n",
+	MsgTelemetryArea:   "  Data area %d: last block %d, %d bytes\n",
+
+	MsgPLMHeader:   "NVM Set %d: window %s, events %#04x\n",
+	MsgPLMTypical:  "  DTWIN reads typical %d, writes typical %d, time max %d ms, NDWIN time min high %d ms, low %d ms\n",
+	MsgPLMEstimate: "  DTWIN reads estimate %d, writes estimate %d, time estimate %d ms\n",
+
+	MsgANAHeader: "ANA log change count %d, %d groups\n",
+	MsgANAGroup:  "  ANA group %d: %s, change count %d, namespaces %v\n",
+
+	MsgPELHeader: "Persistent event log generation %d (%s, %s): %d events\n",
+	MsgPELEvent:  "  %s controller %d: %s, %d bytes\n",
+
+	MsgEnduranceHeader: "Endurance group %d: critical warning %#02x, spare %d%% (threshold %d%%), %d%% used\n",
+	MsgEnduranceUsage:  "  Capacity %s (%s unallocated), data units read %s, written %s, media units written %s, media errors %s\n",
+
+	MsgMediaUnitHeader: "Selected capacity configuration %d, %d channels, %d media units\n",
+	MsgMediaUnit:       "  Media unit %d: endurance group %d, NVM set %d, capacity adjustment %d MiB, spare %d%%, %d%% used, channels %v\n",
+
+	MsgSupportedLogPage: "Log page %#02x: index offset %t, specific parameter %#04x\n",
+
+	MsgSanitizeStatus:    "Sanitize status: %s, %.1f%% complete, global data erased: %t, overwrite passes: %d\n",
+	MsgSanitizeEstimates: "Estimated time: overwrite %s, block erase %s, crypto erase %s\n",
+
+	MsgDiscoveryHeader: "Discovery log generation %d, %d records\n",
+	MsgDiscoveryEntry:  "  %s %s %s:%s (%s), port %d, %s\n",
+
+	MsgResvNotification: "Reservation notification %d: namespace %d, %s\n",
+
+	MsgFDPConfig: "FDP configuration %d: valid %t, %d reclaim groups, %d reclaim unit handles, reclaim unit size %s, %d placement identifiers\n",
+	MsgFDPHandle: "  Reclaim unit handle %d: %s\n",
+	MsgFDPStats:  "Host bytes written %s, media bytes written %s, media bytes erased %s\n",
+	MsgFDPEvent:  "%s: %s, namespace %d, placement identifier %d, reclaim group %d, handle %d\n",
+
+	MsgRotationalMedia: "Endurance group %d: %d actuators, %d spin-ups (%d failed), %d loads (%d failed)\n",
+
+	MsgOCPSMARTHeader:    "\nOCP SMART / Health Information Extended:\n",
+	MsgOCPSMARTMedia:     "Physical media written : %s, read: %s\n",
+	MsgOCPSMARTBadBlocks: "Bad blocks             : user %d (%d%% spare), system %d (%d%% spare)\n",
+	MsgOCPSMARTErrors:    "Errors                 : XOR recovery %d, uncorrectable read %d, soft ECC %d, end-to-end detected %d / corrected %d, PCIe correctable %d\n",
+	MsgOCPSMARTWear:      "Wear                   : system data %d%% used, erase count min %d / max %d, %d%% free blocks, capacitor health %d%%\n",
+	MsgOCPSMARTEvents:    "Events                 : incomplete shutdowns %d, thermal throttling %d (status %d), PLP starts %s, PCIe link retraining %d\n",
+
+	MsgLatencyMonitorHeader: "Latency monitor status %#02x: bucket timer %d / %d, thresholds %v\n",
+	MsgLatencyMonitorBucket: "  %s bucket %d: read %d, write %d, deallocate %d, max latency %v ms\n",
+	MsgLatencyMonitorDebug:  "  Debug log triggered by %d ms latency at %s, source %#04x\n",
+
+	MsgOCPDeviceCaps: "OCP device capabilities: %d PCIe ports, OOB management %#04x, write zeroes %#04x, sanitize %#04x, DSM %#04x, write uncorrectable %#04x, fused %#04x, min power state %d\n",
+
+	MsgIntelSMARTHeader:   "\nIntel Additional SMART Attributes:\n",
+	MsgIntelSMARTFailures: "Failures         : program %d, erase %d, end-to-end %d, CRC %d, retry buffer overflow %d, PLL lock loss %d\n",
+	MsgIntelSMARTWear:     "Wear leveling    : %d%%, erase cycles min %d / max %d / avg %d\n",
+	MsgIntelSMARTWorkload: "Timed workload   : media wear %.3f%%, host reads %d%%, timer %d min\n",
+	MsgIntelSMARTThrottle: "Thermal throttle : %d%%, %d events\n",
+	MsgIntelSMARTWrites:   "Bytes written    : NAND %d MiB, host %d MiB\n",
+
+	MsgWDCSMARTHeader:   "\nWDC Extended SMART Log:\n",
+	MsgWDCSMARTMedia:    "NAND media : written %s, read %s, %d bad blocks\n",
+	MsgWDCSMARTErrors:   "Errors     : uncorrectable read %d, soft ECC %d, end-to-end detected %d / corrected %d, PCIe correctable %d\n",
+	MsgWDCSMARTFailures: "Failures   : program %d, user data erase %d, system area erase %d\n",
+	MsgWDCSMARTWear:     "Wear       : system data %d%% used, erase count min %d / max %d, refresh count %d, %d%% free blocks\n",
+	MsgWDCSMARTEvents:   "Events     : incomplete shutdowns %d, thermal throttling %d (status %d)\n",
+
+	MsgPowerState:         "ps %4d : mp:%s %s enlat:%d exlat:%d rrt:%d rrl:%d\n",
+	MsgPowerStatePerf:     "          rwt:%d rwl:%d idle_power:%s active_power:%s\n",
+	MsgPowerStateWorkload: "          active_power_workload:%s\n",
+
+	MsgRegCAP:         "cap     : %#x\n",
+	MsgRegQueues:      "  Max. queue entries: %d, contiguous queues required: %t, doorbell stride: %d bytes\n",
+	MsgRegTimeout:     "  Timeout: %s\n",
+	MsgRegCommandSets: "  Command sets supported: %#x, arbitration: %#x, subsystem reset: %t, boot partitions: %t\n",
+	MsgRegPageSizes:   "  Memory page size: min. %d bytes, max. %d bytes\n",
+	MsgRegVersion:     "version : %#x (%s)\n",
+	MsgRegCC:          "cc      : %#x\n",
+	MsgRegConfig:      "  Enabled: %t, page size: %d bytes, I/O SQ/CQ entry size: %d/%d bytes, shutdown notification: %d\n",
+	MsgRegCSTS:        "csts    : %#x\n",
+	MsgRegStatus:      "  Ready: %t, fatal status: %t, shutdown status: %d, subsystem reset occurred: %t, processing paused: %t\n",
+
+	MsgSupportLogPage: "Log page %#02x: %s\n",
+	MsgSupportFeature: "Feature %#02x: %s\n",
+
+	MsgSupportLogPageUUID: "Log page %#02x (UUID index %d): %s\n",
+	MsgSupportFeatureUUID: "Feature %#02x (UUID index %d): %s\n",
+	MsgSupported:          "supported",
+	MsgNotSupported:       "not supported",
+}
+
+var (
+	msgMu       sync.RWMutex
+	msgOverride func(id MessageID) (string, bool)
+)
+
+// SetMessageOverride installs a hook which is consulted for every report string before the
+// built-in catalog, allowing report output to be localized or rebranded. If the hook returns false,
+// the built-in message is used. Passing nil removes the hook.
+func SetMessageOverride(fn func(id MessageID) (string, bool)) {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+
+	msgOverride = fn
+}
+
+// DefaultMessages returns a copy of the built-in message catalog, e.g. as a template for
+// translation.
+func DefaultMessages() map[MessageID]string {
+	m := make(map[MessageID]string, len(defaultMessages))
+	for k, v := range defaultMessages {
+		m[k] = v
+	}
+
+	return m
+}
+
+// msg returns the report string for the specified message ID.
+func msg(id MessageID) string {
+	msgMu.RLock()
+	fn := msgOverride
+	msgMu.RUnlock()
+
+	if fn != nil {
+		if s, ok := fn(id); ok {
+			return s
+		}
+	}
+
+	return defaultMessages[id]
+}
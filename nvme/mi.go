@@ -0,0 +1,146 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// oacsMI is the NVMe-MI Send and NVMe-MI Receive support bit of the OACS field.
+const oacsMI = 1 << 6
+
+// NVMe-MI command opcodes, cf. NVM Express Management Interface Specification 1.2c, figure 68.
+const (
+	MIReadDataStructure    uint8 = 0x00
+	MISubsystemHealthPoll  uint8 = 0x01
+	MIControllerHealthPoll uint8 = 0x02
+	MIConfigurationSet     uint8 = 0x03
+	MIConfigurationGet     uint8 = 0x04
+	MIVPDRead              uint8 = 0x05
+	MIVPDWrite             uint8 = 0x06
+	MIReset                uint8 = 0x07
+)
+
+// MISend tunnels an NVMe-MI command which transfers data to the management endpoint, with the
+// specified NVMe-MI command dwords 0 and 1 (NMD0, NMD1). The NVMe-MI response (NMRESP) is
+// returned.
+func (d *NVMeDevice) MISend(opcode uint8, nmd0, nmd1 uint32, data []byte) (uint32, error) {
+	return d.miCmd(NVME_ADMIN_MI_SEND, opcode, nmd0, nmd1, data)
+}
+
+// MIReceive tunnels an NVMe-MI command which transfers data from the management endpoint into
+// buf, with the specified NVMe-MI command dwords 0 and 1 (NMD0, NMD1). The NVMe-MI response
+// (NMRESP) is returned.
+func (d *NVMeDevice) MIReceive(opcode uint8, nmd0, nmd1 uint32, buf []byte) (uint32, error) {
+	return d.miCmd(NVME_ADMIN_MI_RECV, opcode, nmd0, nmd1, buf)
+}
+
+func (d *NVMeDevice) miCmd(adminOpcode, opcode uint8, nmd0, nmd1 uint32, buf []byte) (uint32, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return 0, err
+	}
+
+	if idCtrlr.Oacs&oacsMI == 0 {
+		return 0, fmt.Errorf("NVMe-MI send / receive: %w", ErrNotSupported)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: adminOpcode,
+		cdw10:  uint32(opcode),
+		cdw11:  nmd0,
+		cdw12:  nmd1,
+	}
+
+	if len(buf) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&buf[0])))
+		cmd.data_len = uint32(len(buf))
+	}
+
+	err = d.adminCmd(&cmd, buf)
+	return cmd.result, err
+}
+
+// SubsystemHealth is the decoded NVM Subsystem Health data structure, returned by the NVM
+// Subsystem Health Status Poll command.
+type SubsystemHealth struct {
+	DriveFunctional  bool
+	ResetNotRequired bool
+	Port0LinkActive  bool
+	Port1LinkActive  bool
+	// Critical warnings, with the same bit assignments as the SMART log's CritWarning
+	CritWarning       uint8
+	Temperature       uint16 // Composite temperature, in Kelvin, zero if not available
+	PercentUsed       uint8  // Percentage drive life used
+	ControllerChanges uint16 // Composite Controller Status (CCS) bits
+}
+
+// Print outputs the NVM subsystem health in a pretty-print style.
+func (h *SubsystemHealth) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgMIHealthStatus), h.DriveFunctional, h.ResetNotRequired,
+		h.Port0LinkActive, h.Port1LinkActive)
+	fmt.Fprintf(w, msg(MsgSMARTCritWarning), h.CritWarning)
+
+	if h.Temperature != 0 {
+		fmt.Fprintf(w, msg(MsgSMARTTemperature), FormatTemperature(h.Temperature))
+	}
+
+	fmt.Fprintf(w, msg(MsgSMARTPercentUsed), h.PercentUsed)
+	fmt.Fprintf(w, msg(MsgMIHealthCCS), h.ControllerChanges)
+}
+
+// SubsystemHealthPoll issues an NVM Subsystem Health Status Poll via NVMe-MI Receive. If clear is
+// true, the Composite Controller Status bits are cleared after being returned.
+func (d *NVMeDevice) SubsystemHealthPoll(clear bool) (*SubsystemHealth, error) {
+	buf := make([]byte, 8)
+
+	var nmd1 uint32
+	if clear {
+		nmd1 = 1 << 31 // Clear Status (CS)
+	}
+
+	if _, err := d.MIReceive(MISubsystemHealthPoll, 0, nmd1, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeSubsystemHealth(buf), nil
+}
+
+func decodeSubsystemHealth(buf []byte) *SubsystemHealth {
+	var raw nvmeSubsystemHealth
+
+	raw.unmarshal(buf)
+
+	h := &SubsystemHealth{
+		DriveFunctional:  raw.Nss&(1<<5) != 0,
+		ResetNotRequired: raw.Nss&(1<<4) != 0,
+		Port0LinkActive:  raw.Nss&(1<<3) != 0,
+		Port1LinkActive:  raw.Nss&(1<<2) != 0,
+		// SMART warning bits are cleared to 0 to indicate a warning
+		CritWarning:       ^raw.Sw & 0x3f,
+		PercentUsed:       raw.Pdlu,
+		ControllerChanges: raw.Ccs,
+	}
+
+	// Temperature in degrees Celsius, as a two's complement value; 0x80 to 0xc3 are reserved or
+	// indicate no data or a sensor failure
+	if c := int8(raw.Ctemp); raw.Ctemp < 0x80 || raw.Ctemp > 0xc3 {
+		h.Temperature = uint16(273 + int(c))
+	}
+
+	return h
+}
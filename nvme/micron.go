@@ -0,0 +1,50 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+)
+
+// vidMicron is the PCI vendor ID of Micron.
+const vidMicron = 0x1344
+
+// GetMicronSMARTLog reads the Micron extended SMART log page. Micron datacenter SSDs such as the
+// 7400 and 7450 report their NAND-level statistics in log page 0xC0 using the layout of the OCP
+// SMART / Health Information Extended log, but firmware predating OCP 2.0 conformance does not
+// populate the log page GUID. The GUID is therefore not checked, and the VID is checked instead.
+func (d *NVMeDevice) GetMicronSMARTLog() (*OCPSMARTLog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.VendorID != vidMicron {
+		return nil, fmt.Errorf("micron extended SMART log on vendor %#04x: %w", idCtrlr.VendorID,
+			ErrNotSupported)
+	}
+
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_OCP_SMART, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeOCPSMARTLog
+
+	raw.unmarshal(buf)
+
+	return raw.decode(), nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ChangedNamespaceList is the decoded Changed Namespace List log page (0x04).
+type ChangedNamespaceList struct {
+	NSIDs []uint32 // Namespaces whose identify data changed, or which were attached or detached
+	// More than 1024 namespaces changed, so NSIDs is empty and all namespaces should be
+	// re-identified
+	Overflow bool
+}
+
+// Print outputs the changed namespace list in a pretty-print style.
+func (l *ChangedNamespaceList) Print(w io.Writer) {
+	if l.Overflow {
+		fmt.Fprint(w, msg(MsgChangedNsOverflow))
+		return
+	}
+
+	fmt.Fprintf(w, msg(MsgChangedNsList), len(l.NSIDs), l.NSIDs)
+}
+
+// GetChangedNamespaces reads the Changed Namespace List log page, i.e. the namespaces which have
+// changed since the log page was last read. Reading the log page clears it, and re-arms the
+// Namespace Attribute Notices asynchronous event, so a monitoring loop may call this after each
+// event, and re-identify (or Rescan) the namespaces returned.
+func (d *NVMeDevice) GetChangedNamespaces() (*ChangedNamespaceList, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.getLogPage(NVME_LOG_CHANGED_NS, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeChangedNamespaces(buf), nil
+}
+
+func decodeChangedNamespaces(buf []byte) *ChangedNamespaceList {
+	l := &ChangedNamespaceList{}
+
+	if binary.LittleEndian.Uint32(buf) == 0xffffffff {
+		l.Overflow = true
+		return l
+	}
+
+	// The list is zero terminated, unless it contains 1024 entries
+	l.NSIDs = decodeNSIDList(buf)
+
+	return l
+}
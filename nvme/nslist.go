@@ -0,0 +1,211 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// nsListLen is the maximum number of NSIDs returned by a single namespace list identify command.
+const nsListLen = 1024
+
+// ListNamespaces returns the NSIDs of all active namespaces, i.e. those attached to the
+// controller, in ascending order.
+func (d *NVMeDevice) ListNamespaces() ([]uint32, error) {
+	return d.listNamespaces(NVME_IDENTIFY_CNS_NS_ACTIVE_LIST)
+}
+
+// ListAllocatedNamespaces returns the NSIDs of all namespaces allocated in the NVM subsystem,
+// whether or not they are attached to any controller, in ascending order. The controller must
+// support namespace management.
+func (d *NVMeDevice) ListAllocatedNamespaces() ([]uint32, error) {
+	if err := d.checkNsMgmt(); err != nil {
+		return nil, err
+	}
+
+	return d.listNamespaces(NVME_IDENTIFY_CNS_ALLOCATED_NS_LIST)
+}
+
+// IdentifyAllocatedNamespace returns the attributes of an allocated namespace, whether or not it
+// is attached to this controller, e.g. to inspect a namespace created with CreateNamespace before
+// attaching it. The controller must support namespace management.
+func (d *NVMeDevice) IdentifyAllocatedNamespace(nsid uint32) (NVMeNamespace, error) {
+	if err := d.checkNsMgmt(); err != nil {
+		return NVMeNamespace{}, err
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_ALLOCATED_NS, NVME_CSI_NVM, nsid, 0, buf); err != nil {
+		return NVMeNamespace{}, err
+	}
+
+	var ns nvmeIdentNamespace
+
+	ns.unmarshal(buf)
+
+	return ns.decode(nsid), nil
+}
+
+// listNamespaces issues namespace list identify commands of the specified CNS value. Each command
+// returns up to 1024 NSIDs greater than the NSID specified, so a full list is followed by another
+// command starting at its last NSID.
+func (d *NVMeDevice) listNamespaces(cns uint8) ([]uint32, error) {
+	var nsids []uint32
+
+	for start := uint32(0); ; {
+		buf := make([]byte, 4*nsListLen)
+
+		if err := d.identify(cns, NVME_CSI_NVM, start, 0, buf); err != nil {
+			return nil, err
+		}
+
+		page := decodeNSIDList(buf)
+		nsids = append(nsids, page...)
+
+		// The maximum NSID is 0xfffffffe, so a list ending with it cannot be continued
+		if len(page) < nsListLen || page[len(page)-1] >= 0xfffffffe {
+			return nsids, nil
+		}
+
+		start = page[len(page)-1]
+	}
+}
+
+// decodeNSIDList decodes a zero terminated list of NSIDs.
+func decodeNSIDList(buf []byte) []uint32 {
+	var nsids []uint32
+
+	for i := 0; i+4 <= len(buf); i += 4 {
+		nsid := binary.LittleEndian.Uint32(buf[i:])
+		if nsid == 0 {
+			break
+		}
+
+		nsids = append(nsids, nsid)
+	}
+
+	return nsids
+}
+
+// Namespace Identifier Types of the Namespace Identification Descriptor list.
+const (
+	nidtEUI64 = 0x1
+	nidtNGUID = 0x2
+	nidtUUID  = 0x3
+	nidtCSI   = 0x4
+)
+
+// NamespaceDescriptors are the identifiers of a namespace reported in its Namespace
+// Identification Descriptor list. The identifiers are globally unique and persist across reboots,
+// unlike NSIDs. Identifiers which are not reported are empty.
+type NamespaceDescriptors struct {
+	EUI64  string // IEEE Extended Unique Identifier, as 16 hex digits
+	NGUID  string // Namespace Globally Unique Identifier, as 32 hex digits
+	UUID   string // Namespace UUID, in its canonical form
+	CSI    uint8  // Command Set Identifier
+	HasCSI bool   // CSI was reported
+}
+
+// Print outputs the namespace identifiers in a pretty-print style.
+func (n *NamespaceDescriptors) Print(w io.Writer) {
+	if n.EUI64 != "" {
+		fmt.Fprintf(w, msg(MsgNsDescEUI64), n.EUI64)
+	}
+	if n.NGUID != "" {
+		fmt.Fprintf(w, msg(MsgNsDescNGUID), n.NGUID)
+	}
+	if n.UUID != "" {
+		fmt.Fprintf(w, msg(MsgNsDescUUID), n.UUID)
+	}
+	if n.HasCSI {
+		fmt.Fprintf(w, msg(MsgNsDescCSI), n.CSI)
+	}
+}
+
+// GetNamespaceDescriptors reads the Namespace Identification Descriptor list of a namespace.
+func (d *NVMeDevice) GetNamespaceDescriptors(nsid uint32) (*NamespaceDescriptors, error) {
+	if err := d.checkVersion("namespace identification descriptor list", Version13); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_NS_DESC_LIST, NVME_CSI_NVM, nsid, 0, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeNamespaceDescriptors(buf)
+}
+
+// namespaceCSI returns the command set of a namespace, as reported by its Namespace Identification
+// Descriptor list. Namespaces of controllers predating the descriptor list, and namespaces which
+// do not report a command set, use the NVM command set.
+func (d *NVMeDevice) namespaceCSI(nsid uint32) (uint8, error) {
+	v, err := d.Version()
+	if err != nil {
+		return 0, err
+	}
+
+	if v < Version13 {
+		return NVME_CSI_NVM, nil
+	}
+
+	desc, err := d.GetNamespaceDescriptors(nsid)
+	if err != nil {
+		return 0, err
+	}
+
+	if !desc.HasCSI {
+		return NVME_CSI_NVM, nil
+	}
+
+	return desc.CSI, nil
+}
+
+func decodeNamespaceDescriptors(buf []byte) (*NamespaceDescriptors, error) {
+	n := &NamespaceDescriptors{}
+
+	// Each descriptor consists of a 4-byte header (type, length) followed by the identifier. The
+	// list is terminated by a descriptor of type zero.
+	for off := 0; off+4 <= len(buf) && buf[off] != 0; {
+		typ, length := buf[off], int(buf[off+1])
+
+		if off+4+length > len(buf) {
+			return nil, fmt.Errorf("namespace descriptor at offset %d exceeds list", off)
+		}
+
+		nid := buf[off+4 : off+4+length]
+
+		switch {
+		case typ == nidtEUI64 && length == 8:
+			n.EUI64 = fmt.Sprintf("%x", nid)
+		case typ == nidtNGUID && length == 16:
+			n.NGUID = fmt.Sprintf("%x", nid)
+		case typ == nidtUUID && length == 16:
+			n.UUID = formatUUID(nid)
+		case typ == nidtCSI && length == 1:
+			n.CSI, n.HasCSI = nid[0], true
+		case typ <= nidtCSI:
+			return nil, fmt.Errorf("invalid length %d of namespace descriptor type %d", length, typ)
+		}
+
+		off += 4 + length
+	}
+
+	return n, nil
+}
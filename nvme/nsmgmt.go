@@ -0,0 +1,403 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"unsafe"
+)
+
+// oacsNsMgmt is the Namespace Management support bit of the OACS field.
+const oacsNsMgmt = 1 << 3
+
+// ctrattNsGranularity is the Namespace Granularity support bit of the CTRATT field.
+const ctrattNsGranularity = 1 << 7
+
+// Namespace Management Select (SEL) values.
+const (
+	nsMgmtCreate = 0x0
+	nsMgmtDelete = 0x1
+)
+
+// Namespace Attachment Select (SEL) values.
+const (
+	nsAttach = 0x0
+	nsDetach = 0x1
+)
+
+// maxControllerListLen is the maximum number of identifiers in a controller list.
+const maxControllerListLen = 2047
+
+// NamespaceSpec describes a namespace to be created with CreateNamespace. Sizes are in logical
+// blocks of the selected LBA format.
+type NamespaceSpec struct {
+	Size             uint64 // Namespace Size (NSZE)
+	Capacity         uint64 // Namespace Capacity (NCAP), defaults to Size if zero
+	FormattedLBASize uint8  // Formatted LBA Size (FLBAS), i.e. index of the LBA format
+	DataProtection   uint8  // End-to-end Data Protection Type Settings (DPS)
+	Shared           bool   // May be attached to multiple controllers (NMIC bit 0)
+	ANAGroupID       uint32 // ANA Group Identifier, 0 to let the controller choose
+	NVMSetID         uint16 // NVM Set Identifier, 0 to let the controller choose
+	EnduranceGroupID uint16 // Endurance Group Identifier, 0 to let the controller choose
+	CSI              uint8  // Command Set Identifier, 0 for the NVM command set
+}
+
+// build returns the host software specified fields of the namespace management data structure.
+func (s *NamespaceSpec) build() ([]byte, error) {
+	if s.Size == 0 {
+		return nil, fmt.Errorf("namespace size must not be zero")
+	}
+
+	ncap := s.Capacity
+	if ncap == 0 {
+		ncap = s.Size
+	}
+
+	if ncap > s.Size {
+		return nil, fmt.Errorf("namespace capacity %d exceeds size %d", ncap, s.Size)
+	}
+
+	ns := nvmeIdentNamespace{
+		Nsze:     s.Size,
+		Ncap:     ncap,
+		Flbas:    s.FormattedLBASize,
+		Dps:      s.DataProtection,
+		Nmic:     boolToUint8(s.Shared),
+		Anagrpid: s.ANAGroupID,
+		Nvmsetid: s.NVMSetID,
+		Endgid:   s.EnduranceGroupID,
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &ns)
+
+	return buf.Bytes(), nil
+}
+
+// CreateNamespace creates a namespace as described by spec, returning the new namespace ID. The
+// namespace is not attached to any controller.
+func (d *NVMeDevice) CreateNamespace(spec NamespaceSpec) (uint32, error) {
+	buf, err := spec.build()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := d.checkNsMgmt(); err != nil {
+		return 0, err
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_ADMIN_NS_MGMT,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    nsMgmtCreate,
+		cdw11:    uint32(spec.CSI) << 24,
+	}
+
+	if err := d.adminCmd(&cmd, buf); err != nil {
+		return 0, err
+	}
+
+	return cmd.result, nil
+}
+
+// DeleteNamespace deletes the specified namespace (or all namespaces, if nsid is 0xffffffff). The
+// deletion is refused if the namespace is in use, unless force is true.
+func (d *NVMeDevice) DeleteNamespace(nsid uint32, force bool) error {
+	if nsid == 0 {
+		return fmt.Errorf("invalid namespace ID %#x", nsid)
+	}
+
+	if err := d.checkNsMgmt(); err != nil {
+		return err
+	}
+
+	if !force {
+		if err := d.CheckNotInUse(nsid); err != nil {
+			return err
+		}
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_NS_MGMT,
+		nsid:   nsid,
+		cdw10:  nsMgmtDelete,
+	}
+
+	return d.adminCmd(&cmd, nil)
+}
+
+// checkNsMgmt consults the controller's OACS field to determine whether namespace management is
+// supported.
+func (d *NVMeDevice) checkNsMgmt() error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Oacs&oacsNsMgmt == 0 {
+		return fmt.Errorf("namespace management: %w", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// AttachNamespace attaches the specified namespace to the controllers identified by ctrlIDs (as
+// reported by the CNTLID field of each controller's identify data). After attaching, a namespace
+// rescan of each controller may be required for the namespace to appear.
+func (d *NVMeDevice) AttachNamespace(nsid uint32, ctrlIDs []uint16) error {
+	return d.nsAttachment(nsid, nsAttach, ctrlIDs)
+}
+
+// DetachNamespace detaches the specified namespace from the controllers identified by ctrlIDs.
+// Detaching is refused if the namespace is in use on this host, unless force is true.
+func (d *NVMeDevice) DetachNamespace(nsid uint32, ctrlIDs []uint16, force bool) error {
+	if !force {
+		if err := d.CheckNotInUse(nsid); err != nil {
+			return err
+		}
+	}
+
+	return d.nsAttachment(nsid, nsDetach, ctrlIDs)
+}
+
+func (d *NVMeDevice) nsAttachment(nsid uint32, sel uint32, ctrlIDs []uint16) error {
+	if nsid == 0 || nsid == 0xffffffff {
+		return fmt.Errorf("invalid namespace ID %#x", nsid)
+	}
+
+	buf, err := buildControllerList(ctrlIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := d.checkNsMgmt(); err != nil {
+		return err
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_ADMIN_NS_ATTACH,
+		nsid:     nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    sel,
+	}
+
+	return d.adminCmd(&cmd, buf)
+}
+
+// buildControllerList returns a controller list data structure containing the specified
+// controller identifiers, in ascending order as required by the specification.
+func buildControllerList(ctrlIDs []uint16) ([]byte, error) {
+	if len(ctrlIDs) == 0 || len(ctrlIDs) > maxControllerListLen {
+		return nil, fmt.Errorf("controller list must contain 1 to %d identifiers", maxControllerListLen)
+	}
+
+	ids := append([]uint16{}, ctrlIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	buf := make([]byte, 4096)
+	binary.LittleEndian.PutUint16(buf, uint16(len(ids)))
+
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			return nil, fmt.Errorf("duplicate controller identifier %#x", id)
+		}
+		binary.LittleEndian.PutUint16(buf[2+i*2:], id)
+	}
+
+	return buf, nil
+}
+
+// ListAttachedControllers returns the identifiers of the controllers to which the specified
+// namespace is attached, in ascending order. The controller must support namespace management.
+func (d *NVMeDevice) ListAttachedControllers(nsid uint32) ([]uint16, error) {
+	return d.listControllers(NVME_IDENTIFY_CNS_NS_CTRL_LIST, nsid)
+}
+
+// ListControllers returns the identifiers of all controllers in the NVM subsystem, in ascending
+// order, e.g. to attach a namespace to every controller. The controller must support namespace
+// management.
+func (d *NVMeDevice) ListControllers() ([]uint16, error) {
+	return d.listControllers(NVME_IDENTIFY_CNS_CTRL_LIST, 0)
+}
+
+// listControllers issues controller list identify commands of the specified CNS value. Each command
+// returns up to 2047 controller identifiers greater than or equal to the CNTID specified, so a full
+// list is followed by another command starting after its last identifier.
+func (d *NVMeDevice) listControllers(cns uint8, nsid uint32) ([]uint16, error) {
+	if err := d.checkNsMgmt(); err != nil {
+		return nil, err
+	}
+
+	var ids []uint16
+
+	for start := uint16(0); ; {
+		buf := make([]byte, 4096)
+
+		if err := d.identify(cns, NVME_CSI_NVM, nsid, start, buf); err != nil {
+			return nil, err
+		}
+
+		page := decodeControllerList(buf)
+		ids = append(ids, page...)
+
+		if len(page) < maxControllerListLen || page[len(page)-1] == 0xffff {
+			return ids, nil
+		}
+
+		start = page[len(page)-1] + 1
+	}
+}
+
+// decodeControllerList decodes a controller list, consisting of the number of identifiers followed
+// by the identifiers. The Endurance Group List has the same format.
+func decodeControllerList(buf []byte) []uint16 {
+	n := int(binary.LittleEndian.Uint16(buf))
+	if n > maxControllerListLen {
+		n = maxControllerListLen
+	}
+
+	ids := make([]uint16, n)
+	for i := range ids {
+		ids[i] = binary.LittleEndian.Uint16(buf[2+2*i:])
+	}
+
+	return ids
+}
+
+// NamespaceGranularity is a namespace granularity descriptor. Granularities are in bytes, and
+// zero if not reported.
+type NamespaceGranularity struct {
+	Size     uint64 // Namespace Size Granularity
+	Capacity uint64 // Namespace Capacity Granularity
+}
+
+// NamespaceGranularityList is the controller's preferred granularity of namespace sizes and
+// capacities.
+type NamespaceGranularityList struct {
+	// If true, each descriptor applies to the LBA format of the same index. Otherwise, the first
+	// descriptor applies to all LBA formats.
+	PerLBAFormat bool
+	Descriptors  []NamespaceGranularity
+}
+
+// ForLBAFormat returns the granularity descriptor applicable to the specified LBA format.
+func (l *NamespaceGranularityList) ForLBAFormat(lbaf uint8) (NamespaceGranularity, bool) {
+	if !l.PerLBAFormat {
+		lbaf = 0
+	}
+
+	if int(lbaf) >= len(l.Descriptors) {
+		return NamespaceGranularity{}, false
+	}
+
+	return l.Descriptors[lbaf], true
+}
+
+// GetNamespaceGranularity returns the controller's Namespace Granularity List.
+func (d *NVMeDevice) GetNamespaceGranularity() (*NamespaceGranularityList, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Ctratt&ctrattNsGranularity == 0 {
+		return nil, fmt.Errorf("namespace granularity: %w", ErrNotSupported)
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_NS_GRANULARITY, NVME_CSI_NVM, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeNamespaceGranularityList(buf), nil
+}
+
+func decodeNamespaceGranularityList(buf []byte) *NamespaceGranularityList {
+	// Up to 16 descriptors follow a 32-byte header. The number of descriptors is zero-based.
+	l := NamespaceGranularityList{PerLBAFormat: binary.LittleEndian.Uint32(buf)&1 != 0}
+
+	for i := 0; i <= int(buf[4]) && i < 16; i++ {
+		var raw nvmeNsGranularityDesc
+		raw.unmarshal(buf[32+16*i:])
+
+		l.Descriptors = append(l.Descriptors, NamespaceGranularity{
+			Size:     raw.SizeGranularity,
+			Capacity: raw.CapGranularity,
+		})
+	}
+
+	return &l
+}
+
+// RoundNamespaceSpec rounds up the size and capacity of spec to the controller's namespace
+// granularity for the LBA format of spec, if the controller reports one.
+func (d *NVMeDevice) RoundNamespaceSpec(spec *NamespaceSpec) error {
+	l, err := d.GetNamespaceGranularity()
+	if errors.Is(err, ErrNotSupported) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The LBA formats common to all namespaces are reported for the broadcast NSID
+	idNs, err := d.identifyNamespace(0xffffffff)
+	if err != nil {
+		return err
+	}
+
+	lbaf := spec.FormattedLBASize & 0xf
+	l.round(spec, lbaf, 1<<idNs.Lbaf[lbaf].Ds)
+
+	return nil
+}
+
+// round rounds up the size and capacity of spec, in logical blocks of lbaSize bytes, to the
+// granularity applicable to lbaf.
+func (l *NamespaceGranularityList) round(spec *NamespaceSpec, lbaf uint8, lbaSize uint64) {
+	g, ok := l.ForLBAFormat(lbaf)
+	if !ok {
+		return
+	}
+
+	if spec.Capacity == 0 {
+		spec.Capacity = spec.Size
+	}
+
+	spec.Size = roundUpBlocks(spec.Size, g.Size, lbaSize)
+	spec.Capacity = roundUpBlocks(spec.Capacity, g.Capacity, lbaSize)
+
+	// Rounding must not leave the capacity larger than the size
+	if spec.Capacity > spec.Size {
+		spec.Capacity = spec.Size
+	}
+}
+
+// roundUpBlocks rounds up a number of logical blocks to a granularity in bytes.
+func roundUpBlocks(blocks, granularity, lbaSize uint64) uint64 {
+	if granularity == 0 || lbaSize == 0 {
+		return blocks
+	}
+
+	g := (granularity + lbaSize - 1) / lbaSize
+
+	return (blocks + g - 1) / g * g
+}
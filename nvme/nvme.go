@@ -15,11 +15,10 @@
 package nvme
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"math/big"
+	"runtime"
 	"unsafe"
 
 	"github.com/dswarbrick/go-nvme/ioctl"
@@ -29,16 +28,41 @@ import (
 
 var (
 	// Defined in <linux/nvme_ioctl.h>
-	NVME_IOCTL_ADMIN_CMD = ioctl.Iowr('N', 0x41, unsafe.Sizeof(nvmePassthruCommand{}))
+	NVME_IOCTL_ID           = ioctl.Io('N', 0x40)
+	NVME_IOCTL_ADMIN_CMD    = ioctl.Iowr('N', 0x41, unsafe.Sizeof(nvmePassthruCommand{}))
+	NVME_IOCTL_IO_CMD       = ioctl.Iowr('N', 0x43, unsafe.Sizeof(nvmePassthruCommand{}))
+	NVME_IOCTL_RESET        = ioctl.Io('N', 0x44)
+	NVME_IOCTL_SUBSYS_RESET = ioctl.Io('N', 0x45)
+	NVME_IOCTL_RESCAN       = ioctl.Io('N', 0x46)
 )
 
+// submitCmd passes a command to the kernel via the specified ioctl, returning the ioctl's
+// non-negative return value. data is the buffer referenced by the command's address, which is
+// kept alive until the ioctl returns. It is a variable so that tests can capture encoded commands
+// and fill in the data returned by them.
+var submitCmd = func(fd int, req uintptr, cmd *nvmePassthruCommand, data []byte) (uintptr, error) {
+	status, err := ioctl.IoctlRet(uintptr(fd), req, uintptr(unsafe.Pointer(cmd)))
+	runtime.KeepAlive(data)
+
+	return status, err
+}
+
 type NVMeDevice struct {
-	Name string
-	fd   int
+	Name    string
+	fd      int
+	support *SupportMatrix
+	stats   handleStats
+
+	uuidIndex uint8 // UUID index of vendor specific log pages and features, see SetUUIDIndex
+	pageSize  int   // Minimum memory page size in bytes, see SetMinPageSize
+
+	idCtrlr      *nvmeIdentController // Cached identify controller data, see controller
+	version      Version              // Cached controller version, see Version
+	versionKnown bool
 }
 
 func NewNVMeDevice(name string) *NVMeDevice {
-	return &NVMeDevice{name, -1}
+	return &NVMeDevice{Name: name, fd: -1, support: newSupportMatrix()}
 }
 
 func (d *NVMeDevice) Open() (err error) {
@@ -50,7 +74,78 @@ func (d *NVMeDevice) Close() error {
 	return unix.Close(d.fd)
 }
 
+// NamespaceID returns the namespace ID of a namespace block device (e.g. /dev/nvme0n1) or
+// namespace generic character device (e.g. /dev/ng0n1). The kernel rejects the request with ENOTTY
+// for controller character devices.
+func (d *NVMeDevice) NamespaceID() (uint32, error) {
+	nsid, err := ioctl.IoctlRet(uintptr(d.fd), NVME_IOCTL_ID, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(nsid), nil
+}
+
+// Reset requests a controller reset. The kernel re-initializes the controller, which is typically
+// required for firmware committed with FirmwareCommitReplace or FirmwareCommitActivate to become
+// active. The device must be a controller character device (e.g. /dev/nvme0).
+func (d *NVMeDevice) Reset() error {
+	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_RESET, 0)
+}
+
+// SubsystemReset requests an NVM subsystem reset, which resets all controllers in the subsystem.
+// The controller must support NVM subsystem resets (CAP.NSSRS), otherwise the kernel returns
+// ENOTTY.
+func (d *NVMeDevice) SubsystemReset() error {
+	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_SUBSYS_RESET, 0)
+}
+
+// Rescan requests the kernel to rescan the controller's namespaces, e.g. so that block devices of
+// namespaces created or attached with CreateNamespace and AttachNamespace appear.
+func (d *NVMeDevice) Rescan() error {
+	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_RESCAN, 0)
+}
+
 func (d *NVMeDevice) IdentifyController(w io.Writer) (NVMeController, error) {
+	idCtrlr, err := d.identifyController(w)
+	if err != nil {
+		return NVMeController{}, err
+	}
+
+	controller := idCtrlr.decode()
+	controller.MaxDataXferSize = mdtsBytes(idCtrlr.Mdts, d.minPageSize())
+
+	fmt.Fprintln(w)
+	controller.Print(w)
+
+	fmt.Fprintln(w)
+	controller.PrintPowerStates(w)
+
+	return controller, nil
+}
+
+// controller returns the identify controller data, issuing an Identify Controller command only
+// the first time. The cached data is used for capability checks, which would otherwise cost an
+// additional command each; it is refreshed by IdentifyController and discarded when firmware is
+// activated.
+func (d *NVMeDevice) controller() (*nvmeIdentController, error) {
+	if d.idCtrlr == nil {
+		if _, err := d.identifyController(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.idCtrlr, nil
+}
+
+// resetController discards the cached identify controller data and version.
+func (d *NVMeDevice) resetController() {
+	d.idCtrlr, d.versionKnown = nil, false
+}
+
+// identifyController issues an Identify Controller command and returns the raw identify data,
+// which is also cached for controller. If w is non-nil, a trace of the command is written to it.
+func (d *NVMeDevice) identifyController(w io.Writer) (*nvmeIdentController, error) {
 	var buf [4096]byte
 
 	cmd := nvmePassthruCommand{
@@ -61,40 +156,113 @@ func (d *NVMeDevice) IdentifyController(w io.Writer) (NVMeController, error) {
 		cdw10:    1, // Identify controller
 	}
 
-	if err := ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_ADMIN_CMD, uintptr(unsafe.Pointer(&cmd))); err != nil {
-		return NVMeController{}, err
+	if err := d.adminCmd(&cmd, buf[:]); err != nil {
+		return nil, err
 	}
 
-	fmt.Fprintf(w, "NVMe call: opcode=%#02x, size=%#04x, nsid=%#08x, cdw10=%#08x\n",
-		cmd.opcode, cmd.data_len, cmd.nsid, cmd.cdw10)
+	if w != nil {
+		fmt.Fprintf(w, "NVMe call: opcode=%#02x, size=%#04x, nsid=%#08x, cdw10=%#08x\n",
+			cmd.opcode, cmd.data_len, cmd.nsid, cmd.cdw10)
+	}
 
 	var idCtrlr nvmeIdentController
 
-	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &idCtrlr)
+	idCtrlr.unmarshal(buf[:])
+
+	d.idCtrlr, d.versionKnown = &idCtrlr, false
+
+	return &idCtrlr, nil
+}
+
+// identify issues an Identify command for the specified CNS and CSI values, with the CNS-specific
+// namespace and controller identifiers.
+func (d *NVMeDevice) identify(cns, csi uint8, nsid uint32, cntid uint16, buf []byte) error {
+	return d.identifyWith(identifyArgs{cns: cns, csi: csi, nsid: nsid, cntid: cntid}, buf)
+}
 
-	controller := NVMeController{
-		VendorID:        idCtrlr.VendorID,
-		ModelNumber:     string(idCtrlr.ModelNumber[:]),
-		SerialNumber:    string(bytes.TrimSpace(idCtrlr.SerialNumber[:])),
-		FirmwareVersion: string(idCtrlr.Firmware[:]),
-		MaxDataXferSize: 1 << idCtrlr.Mdts,
-		// Convert IEEE OUI ID from big-endian
-		OUI: uint32(idCtrlr.IEEE[0]) | uint32(idCtrlr.IEEE[1])<<8 | uint32(idCtrlr.IEEE[2])<<16,
+// identifyLen is the length of every Identify data structure.
+const identifyLen = 4096
+
+// Identify issues an Identify command for the specified CNS and CSI values, with the CNS-specific
+// namespace and controller identifiers, and returns the raw identify data in buf. This allows any
+// identify data structure to be retrieved, including those for which there is no typed support
+// in this package. buf must be at least 4096 bytes long.
+func (d *NVMeDevice) Identify(cns, csi uint8, nsid uint32, cntid uint16, buf []byte) error {
+	if len(buf) < identifyLen {
+		return fmt.Errorf("identify buffer of %d bytes is shorter than %d bytes", len(buf),
+			identifyLen)
 	}
 
-	fmt.Fprintln(w)
-	controller.Print(w)
+	return d.identify(cns, csi, nsid, cntid, buf[:identifyLen])
+}
 
-	for _, ps := range idCtrlr.Psd {
-		if ps.MaxPower > 0 {
-			fmt.Fprintf(w, "%+v\n", ps)
-		}
+// identifyArgs holds the fields of an Identify command.
+type identifyArgs struct {
+	cns   uint8  // Controller or Namespace Structure
+	csi   uint8  // Command Set Identifier
+	nsid  uint32 // Namespace Identifier
+	cntid uint16 // Controller Identifier
+	cnssi uint16 // CNS Specific Identifier, e.g. an NVM Set or domain identifier
+}
+
+// identifyWith issues an Identify command with the fields of args.
+func (d *NVMeDevice) identifyWith(args identifyArgs, buf []byte) error {
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_ADMIN_IDENTIFY,
+		nsid:     args.nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    uint32(args.cns) | uint32(args.cntid)<<16,
+		cdw11:    uint32(args.csi)<<24 | uint32(args.cnssi),
 	}
 
-	return controller, nil
+	return d.adminCmd(&cmd, buf)
 }
 
+// identifyNamespace issues an Identify Namespace command and returns the raw identify data. Since
+// the data is that of the NVM command set, ErrNotSupported is returned for key value namespaces.
+func (d *NVMeDevice) identifyNamespace(nsid uint32) (*nvmeIdentNamespace, error) {
+	csi, err := d.namespaceCSI(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	if csi == NVME_CSI_KV {
+		return nil, fmt.Errorf("namespace %d is a key value namespace: %w", nsid, ErrNotSupported)
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_NS, NVME_CSI_NVM, nsid, 0, buf); err != nil {
+		return nil, err
+	}
+
+	var ns nvmeIdentNamespace
+
+	ns.unmarshal(buf)
+
+	return &ns, nil
+}
+
+// IdentifyNamespace outputs the attributes of a namespace. Key value namespaces, whose identify
+// data differs from that of the NVM command set, are reported with their key value attributes.
 func (d *NVMeDevice) IdentifyNamespace(w io.Writer, namespace uint32) error {
+	csi, err := d.namespaceCSI(namespace)
+	if err != nil {
+		return err
+	}
+
+	if csi == NVME_CSI_KV {
+		kv, err := d.identifyKVNamespace(namespace)
+		if err != nil {
+			return err
+		}
+
+		kv.Print(w)
+
+		return nil
+	}
+
 	var buf [4096]byte
 
 	cmd := nvmePassthruCommand{
@@ -105,7 +273,7 @@ func (d *NVMeDevice) IdentifyNamespace(w io.Writer, namespace uint32) error {
 		cdw10:    0,
 	}
 
-	if err := ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_ADMIN_CMD, uintptr(unsafe.Pointer(&cmd))); err != nil {
+	if err := d.adminCmd(&cmd, buf[:]); err != nil {
 		return err
 	}
 
@@ -114,144 +282,180 @@ func (d *NVMeDevice) IdentifyNamespace(w io.Writer, namespace uint32) error {
 
 	var ns nvmeIdentNamespace
 
-	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &ns)
+	ns.unmarshal(buf[:])
 
-	fmt.Fprintf(w, "Namespace %d size: %d sectors\n", namespace, ns.Nsze)
-	fmt.Fprintf(w, "Namespace %d utilisation: %d sectors\n", namespace, ns.Nuse)
+	fmt.Fprintf(w, msg(MsgNsSize), namespace, ns.Nsze)
+	fmt.Fprintf(w, msg(MsgNsUtilisation), namespace, ns.Nuse)
+	fmt.Fprintf(w, msg(MsgNsWriteProtected), namespace, ns.Nsattr&1 != 0)
 
 	return nil
 }
 
-func (d *NVMeDevice) PrintSMART(w io.Writer) error {
-	buf := make([]byte, 512)
+// defaultXferLen is the default length of data transfers which are split into multiple commands.
+const defaultXferLen = 128 << 10
+
+// maxXferLen returns the largest data transfer length, up to limit, permitted by the controller's
+// Maximum Data Transfer Size.
+func (d *NVMeDevice) maxXferLen(limit int) (int, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return 0, err
+	}
+
+	if mdts := int(mdtsBytes(idCtrlr.Mdts, d.minPageSize())); mdts > 0 && mdts < limit {
+		return mdts, nil
+	}
 
-	// Read SMART log
-	if err := d.readLogPage(0x02, &buf); err != nil {
+	return limit, nil
+}
+
+func (d *NVMeDevice) PrintSMART(w io.Writer) error {
+	sl, err := d.GetSMARTLog()
+	if err != nil {
 		return err
 	}
 
-	var sl nvmeSMARTLog
-
-	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &sl)
-
-	unitsRead := le128ToBigInt(sl.DataUnitsRead)
-	unitsWritten := le128ToBigInt(sl.DataUnitsWritten)
-	unit := big.NewInt(512 * 1000)
-
-	fmt.Fprintln(w, "\nSMART data follows:")
-	fmt.Fprintf(w, "Critical warning: %#02x\n", sl.CritWarning)
-	fmt.Fprintf(w, "Temperature: %d° Celsius\n",
-		(uint16(sl.Temperature[0])|uint16(sl.Temperature[1])<<8)-273) // Kelvin to degrees Celsius
-	fmt.Fprintf(w, "Avail. spare: %d%%\n", sl.AvailSpare)
-	fmt.Fprintf(w, "Avail. spare threshold: %d%%\n", sl.SpareThresh)
-	fmt.Fprintf(w, "Percentage used: %d%%\n", sl.PercentUsed)
-	fmt.Fprintf(w, "Data units read: %d [%s]\n",
-		unitsRead, formatBigBytes(new(big.Int).Mul(unitsRead, unit)))
-	fmt.Fprintf(w, "Data units written: %d [%s]\n",
-		unitsWritten, formatBigBytes(new(big.Int).Mul(unitsWritten, unit)))
-	fmt.Fprintf(w, "Host read commands: %d\n", le128ToBigInt(sl.HostReads))
-	fmt.Fprintf(w, "Host write commands: %d\n", le128ToBigInt(sl.HostWrites))
-	fmt.Fprintf(w, "Controller busy time: %d\n", le128ToBigInt(sl.CtrlBusyTime))
-	fmt.Fprintf(w, "Power cycles: %d\n", le128ToBigInt(sl.PowerCycles))
-	fmt.Fprintf(w, "Power on hours: %d\n", le128ToBigInt(sl.PowerOnHours))
-	fmt.Fprintf(w, "Unsafe shutdowns: %d\n", le128ToBigInt(sl.UnsafeShutdowns))
-	fmt.Fprintf(w, "Media & data integrity errors: %d\n", le128ToBigInt(sl.MediaErrors))
-	fmt.Fprintf(w, "Error information log entries: %d\n", le128ToBigInt(sl.NumErrLogEntries))
+	sl.Print(w)
 
 	return nil
 }
 
-func (d *NVMeDevice) readLogPage(logID uint8, buf *[]byte) error {
-	bufLen := len(*buf)
+// getLogPage issues a Get Log Page command for the specified log page and namespace. If rae is
+// true, the controller is asked to retain any asynchronous event associated with the log page.
+// Log pages which are known to be unsupported by the controller are not requested again.
+func (d *NVMeDevice) getLogPage(logID uint8, nsid uint32, rae bool, buf []byte) error {
+	args := logPageArgs{nsid: nsid, rae: rae, uuidIndex: d.vendorUUIDIndex(logID)}
+	return d.getLog(logID, args, buf)
+}
+
+// logPageArgs holds the optional fields of a Get Log Page command.
+type logPageArgs struct {
+	nsid   uint32
+	lsp    uint8  // Log Specific Field
+	lsi    uint16 // Log Specific Identifier
+	rae    bool   // Retain Asynchronous Event
+	offset uint64 // Log Page Offset, in bytes
+
+	uuidIndex uint8 // UUID Index, see vendorUUIDIndex
+}
+
+// getLog issues a Get Log Page command for the specified log page, with the optional fields of
+// args.
+func (d *NVMeDevice) getLog(logID uint8, args logPageArgs, buf []byte) error {
+	bufLen := len(buf)
 
 	if (bufLen < 4) || (bufLen > 0x4000) || (bufLen%4 != 0) {
 		return fmt.Errorf("invalid buffer size")
 	}
 
+	if supported, known := d.support.LogPageUUID(logID, args.uuidIndex); known && !supported {
+		return fmt.Errorf("log page %#02x: %w", logID, ErrNotSupported)
+	}
+
 	cmd := nvmePassthruCommand{
 		opcode:   NVME_ADMIN_GET_LOG_PAGE,
-		nsid:     0xffffffff, // FIXME
-		addr:     uint64(uintptr(unsafe.Pointer(&(*buf)[0]))),
+		nsid:     args.nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
 		data_len: uint32(bufLen),
-		cdw10:    uint32(logID) | (((uint32(bufLen) / 4) - 1) << 16),
+		cdw10:    uint32(logID) | uint32(args.lsp&0x7f)<<8 | (((uint32(bufLen) / 4) - 1) << 16),
+		cdw11:    uint32(args.lsi) << 16,
+		cdw12:    uint32(args.offset),
+		cdw13:    uint32(args.offset >> 32),
+		cdw14:    uint32(args.uuidIndex & 0x7f),
+	}
+
+	if args.rae {
+		cmd.cdw10 |= 1 << 15
+	}
+
+	err := d.adminCmd(&cmd, buf)
+	d.support.recordLogPage(logID, args.uuidIndex, err)
+
+	return err
+}
+
+// readLog reads length bytes of a log page, in chunks of the largest size permitted by getLog.
+func (d *NVMeDevice) readLog(logID uint8, args logPageArgs, length int) ([]byte, error) {
+	buf := make([]byte, (length+3)&^3)
+
+	for off := 0; off < len(buf); off += 0x4000 {
+		end := off + 0x4000
+		if end > len(buf) {
+			end = len(buf)
+		}
+
+		args.offset = uint64(off)
+
+		if err := d.getLog(logID, args, buf[off:end]); err != nil {
+			return nil, err
+		}
 	}
 
-	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_ADMIN_CMD, uintptr(unsafe.Pointer(&cmd)))
+	return buf[:length], nil
 }
 
-type nvmeIdentPowerState struct {
-	MaxPower        uint16 // Centiwatts
-	Rsvd2           uint8
-	Flags           uint8
-	EntryLat        uint32 // Microseconds
-	ExitLat         uint32 // Microseconds
-	ReadTput        uint8
-	ReadLat         uint8
-	WriteTput       uint8
-	WriteLat        uint8
-	IdlePower       uint16
-	IdleScale       uint8
-	Rsvd19          uint8
-	ActivePower     uint16
-	ActiveWorkScale uint8
-	Rsvd23          [9]byte
+// getAggregateLog reads an event aggregate log page, consisting of a 64-bit number of entries,
+// followed by a list of 16-bit identifiers (e.g. of NVM Sets or endurance groups). The number of
+// entries is read first with the Retain Asynchronous Event bit set, and the asynchronous event is
+// released when the whole log page is read.
+func (d *NVMeDevice) getAggregateLog(logID uint8) ([]uint16, error) {
+	hdr := make([]byte, 8)
+
+	args := logPageArgs{nsid: 0xffffffff, rae: true}
+
+	if err := d.getLog(logID, args, hdr); err != nil {
+		return nil, err
+	}
+
+	n := binary.LittleEndian.Uint64(hdr)
+	if n > 0xffff {
+		return nil, fmt.Errorf("log page %#02x: invalid number of entries %d", logID, n)
+	}
+
+	args.rae = false
+
+	buf, err := d.readLog(logID, args, 8+2*int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeIDList(buf), nil
+}
+
+// decodeIDList decodes an event aggregate log page.
+func decodeIDList(buf []byte) []uint16 {
+	n := binary.LittleEndian.Uint64(buf)
+
+	ids := make([]uint16, 0, n)
+
+	for i := 0; i < int(n) && 8+2*(i+1) <= len(buf); i++ {
+		ids = append(ids, binary.LittleEndian.Uint16(buf[8+2*i:]))
+	}
+
+	return ids
 }
 
-type nvmeLBAF struct {
-	Ms uint16
-	Ds uint8
-	Rp uint8
+// adminCmd submits an admin command to the controller. data is the buffer referenced by the
+// command's address, if any. If the controller completes the command with a non-zero status, it
+// is returned as an NVMeStatus error.
+func (d *NVMeDevice) adminCmd(cmd *nvmePassthruCommand, data []byte) error {
+	return d.submit(NVME_IOCTL_ADMIN_CMD, cmd, data)
 }
 
-type nvmeIdentNamespace struct {
-	Nsze    uint64
-	Ncap    uint64
-	Nuse    uint64
-	Nsfeat  uint8
-	Nlbaf   uint8
-	Flbas   uint8
-	Mc      uint8
-	Dpc     uint8
-	Dps     uint8
-	Nmic    uint8
-	Rescap  uint8
-	Fpi     uint8
-	Rsvd33  uint8
-	Nawun   uint16
-	Nawupf  uint16
-	Nacwu   uint16
-	Nabsn   uint16
-	Nabo    uint16
-	Nabspf  uint16
-	Rsvd46  [2]byte
-	Nvmcap  [16]byte
-	Rsvd64  [40]byte
-	Nguid   [16]byte
-	EUI64   [8]byte
-	Lbaf    [16]nvmeLBAF
-	Rsvd192 [192]byte
-	Vs      [3712]byte
-} // 4096 bytes
-
-type nvmeSMARTLog struct {
-	CritWarning      uint8
-	Temperature      [2]uint8
-	AvailSpare       uint8
-	SpareThresh      uint8
-	PercentUsed      uint8
-	Rsvd6            [26]byte
-	DataUnitsRead    [16]byte
-	DataUnitsWritten [16]byte
-	HostReads        [16]byte
-	HostWrites       [16]byte
-	CtrlBusyTime     [16]byte
-	PowerCycles      [16]byte
-	PowerOnHours     [16]byte
-	UnsafeShutdowns  [16]byte
-	MediaErrors      [16]byte
-	NumErrLogEntries [16]byte
-	WarningTempTime  uint32
-	CritCompTime     uint32
-	TempSensor       [8]uint16
-	Rsvd216          [296]byte
-} // 512 bytes
+// ioCmd submits an I/O command. The device should be a namespace block device (e.g. /dev/nvme0n1)
+// or namespace generic character device (e.g. /dev/ng0n1), since the kernel only permits I/O
+// commands on a controller device if it has exactly one namespace.
+func (d *NVMeDevice) ioCmd(cmd *nvmePassthruCommand, data []byte) error {
+	return d.submit(NVME_IOCTL_IO_CMD, cmd, data)
+}
+
+func (d *NVMeDevice) submit(req uintptr, cmd *nvmePassthruCommand, data []byte) error {
+	status, err := submitCmd(d.fd, req, cmd, data)
+	if err == nil && status != 0 {
+		err = NVMeStatus(status)
+	}
+
+	d.stats.record(req, cmd, err)
+
+	return err
+}
@@ -15,20 +15,1353 @@
 package nvme
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
 )
 
 func TestNVMe(t *testing.T) {
 	assert := assert.New(t)
 
+	assert.Equal(uintptr(0x4e40), NVME_IOCTL_ID)
+	assert.Equal(uintptr(0x4e44), NVME_IOCTL_RESET)
+	assert.Equal(uintptr(0x4e45), NVME_IOCTL_SUBSYS_RESET)
+	assert.Equal(uintptr(0x4e46), NVME_IOCTL_RESCAN)
+
 	// Test that various structs are the size they should be
 	assert.Equal(uintptr(72), unsafe.Sizeof(nvmePassthruCommand{}))
 	assert.Equal(uintptr(4096), unsafe.Sizeof(nvmeIdentController{}))
 	assert.Equal(uintptr(4096), unsafe.Sizeof(nvmeIdentNamespace{}))
 	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeSMARTLog{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeHostBehavior{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeFwSlotLog{}))
+	assert.Equal(uintptr(564), unsafe.Sizeof(nvmeSelfTestLog{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeSanitizeLog{}))
+	assert.Equal(uintptr(4096), unsafe.Sizeof(nvmePrimaryCtrlCaps{}))
+	assert.Equal(uintptr(4096), unsafe.Sizeof(nvmeSecondaryCtrlList{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeLockdownLog{}))
+	assert.Equal(uintptr(16), unsafe.Sizeof(nvmeLBAStatusDesc{}))
+	assert.Equal(uintptr(16), unsafe.Sizeof(nvmeLBARangeDesc{}))
+	assert.Equal(uintptr(64), unsafe.Sizeof(nvmeErrorLogEntry{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeTelemetryHeader{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmePredLatencyLog{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmePLMConfig{}))
+	assert.Equal(uintptr(16), unsafe.Sizeof(nvmeANALogHeader{}))
+	assert.Equal(uintptr(32), unsafe.Sizeof(nvmeANAGroupDesc{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeEnduranceGroupLog{}))
+	assert.Equal(uintptr(1024), unsafe.Sizeof(nvmeDiscoveryLogEntry{}))
+	assert.Equal(uintptr(64), unsafe.Sizeof(nvmeResvNotificationLog{}))
+	assert.Equal(uintptr(64), unsafe.Sizeof(nvmeFDPConfigDesc{}))
+	assert.Equal(uintptr(64), unsafe.Sizeof(nvmeFDPStats{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeRotationalMediaLog{}))
+	assert.Equal(uintptr(512), unsafe.Sizeof(nvmeOCPSMARTLog{}))
+	assert.Equal(uintptr(432), unsafe.Sizeof(nvmeOCPTelemetryStrings{}))
+	assert.Equal(uintptr(128), unsafe.Sizeof(nvmeNVMSetAttr{}))
+	assert.Equal(uintptr(16), unsafe.Sizeof(nvmeKVFormat{}))
+	assert.Equal(uintptr(128), unsafe.Sizeof(nvmeDomainAttr{}))
+	assert.Equal(uintptr(16), unsafe.Sizeof(nvmeNsGranularityDesc{}))
+	assert.Equal(uintptr(rpmbFrameLen), uintptr(binary.Size(rpmbFrame{})))
+	assert.Equal(uintptr(64), unsafe.Sizeof(nvmeZoneDescriptor{}))
+	assert.Equal(uintptr(zoneReportLen), unsafe.Sizeof(nvmeZoneReport{}))
+	assert.Equal(uintptr(4096), unsafe.Sizeof(nvmeZNSIdentNamespace{}))
 
 	// More tests to follow...
 }
+
+func TestSupportMatrix(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newSupportMatrix()
+
+	m.recordLogPage(NVME_LOG_SMART, 0, nil)
+	m.recordLogPage(NVME_LOG_CHANGED_NS, 0, NVMeStatus(nvmeStatusDNR|NVME_SC_INVALID_LOG_PAGE))
+	m.recordLogPage(NVME_LOG_CMD_EFFECTS, 0, unix.EPERM)
+	m.recordLogPage(NVME_LOG_PERSISTENT_EVENT, 0, NVMeStatus(NVME_SC_INVALID_FIELD))
+
+	supported, known := m.LogPage(NVME_LOG_SMART)
+	assert.True(known)
+	assert.True(supported)
+
+	supported, known = m.LogPage(NVME_LOG_CHANGED_NS)
+	assert.True(known)
+	assert.False(supported)
+
+	// Errors unrelated to controller support must not be cached
+	_, known = m.LogPage(NVME_LOG_CMD_EFFECTS)
+	assert.False(known)
+
+	// An invalid field may be caused by a rejected argument, rather than lack of support
+	_, known = m.LogPage(NVME_LOG_PERSISTENT_EVENT)
+	assert.False(known)
+}
+
+func TestMessageOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NVMeController{VendorID: 0x144d}
+
+	SetMessageOverride(func(id MessageID) (string, bool) {
+		if id == MsgCtrlVendorID {
+			return "Hersteller-ID: %#04x\n", true
+		}
+		return "", false
+	})
+	defer SetMessageOverride(nil)
+
+	var sb strings.Builder
+	c.Print(&sb)
+
+	assert.Contains(sb.String(), "Hersteller-ID: 0x144d\n")
+	assert.Contains(sb.String(), "Model number       : \n")
+}
+
+func TestRollupHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	healthy := &SMARTLog{AvailSpare: 100, SpareThresh: 10, PercentUsed: 3, MediaErrors: big.NewInt(0)}
+	worn := &SMARTLog{AvailSpare: 40, SpareThresh: 10, PercentUsed: 70, MediaErrors: big.NewInt(0)}
+	failing := &SMARTLog{CritWarning: CritWarnReadOnly, AvailSpare: 100, SpareThresh: 10,
+		MediaErrors: big.NewInt(2)}
+
+	members := []MemberHealth{
+		{Device: "/dev/nvme0", Health: healthy.Health(), SMART: healthy},
+		{Device: "/dev/nvme1", Health: worn.Health(), SMART: worn},
+	}
+
+	// Equal status is decided by remaining spare margin
+	p := rollup(members)
+	assert.Equal(HealthOK, p.Status)
+	assert.Equal("/dev/nvme1", p.Weakest)
+
+	members = append(members, MemberHealth{Device: "/dev/nvme2", Health: failing.Health(), SMART: failing})
+
+	p = rollup(members)
+	assert.Equal(HealthCritical, p.Status)
+	assert.Equal("/dev/nvme2", p.Weakest)
+	assert.Len(p.Rationale, 2)
+}
+
+func TestImpactReport(t *testing.T) {
+	assert := assert.New(t)
+
+	root := t.TempDir()
+	mkfile := func(path, content string) {
+		path = filepath.Join(root, path)
+		assert.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(os.WriteFile(path, []byte(content), 0644))
+	}
+
+	mkfile("class/nvme/nvme0/nvme0n1/nsid", "1\n")
+	mkfile("class/nvme/nvme0/nvme0n2/nsid", "2\n")
+	mkfile("class/block/nvme0n1/nvme0n1p1/partition", "1\n")
+	mkfile("class/block/nvme0n1p1/holders/md0/.keep", "")
+	mkfile("class/block/nvme0n2/.keep", "")
+	mkfile("mounts", "/dev/md0 /data ext4 rw 0 0\n/dev/sda1 / ext4 rw 0 0\n")
+
+	defer func(block, nvme, mounts, swaps string) {
+		sysfsBlockDir, sysfsNVMeDir, procMounts, procSwaps = block, nvme, mounts, swaps
+	}(sysfsBlockDir, sysfsNVMeDir, procMounts, procSwaps)
+
+	sysfsBlockDir = filepath.Join(root, "class/block")
+	sysfsNVMeDir = filepath.Join(root, "class/nvme")
+	procMounts = filepath.Join(root, "mounts")
+	procSwaps = filepath.Join(root, "swaps")
+
+	d := NewNVMeDevice("/dev/nvme0")
+
+	r, err := d.ImpactReport(1)
+	if assert.NoError(err) {
+		assert.Equal([]string{"nvme0n1"}, r.Namespaces)
+		assert.Equal([]string{"nvme0n1p1"}, r.Partitions)
+		assert.Equal([]string{"md0"}, r.Holders)
+		assert.Equal([]Mount{{"/dev/md0", "/data", "ext4"}}, r.Mounts)
+		assert.True(r.InUse())
+	}
+
+	assert.ErrorIs(d.CheckNotInUse(1), ErrInUse)
+	assert.NoError(d.CheckNotInUse(2))
+}
+
+func TestDeriveUtilization(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := &SMARTLog{CtrlBusyTime: big.NewInt(100), HostReads: big.NewInt(1000),
+		HostWrites: big.NewInt(500), DataUnitsRead: big.NewInt(10), DataUnitsWritten: big.NewInt(20)}
+	cur := &SMARTLog{CtrlBusyTime: big.NewInt(105), HostReads: big.NewInt(601000),
+		HostWrites: big.NewInt(300500), DataUnitsRead: big.NewInt(610), DataUnitsWritten: big.NewInt(20)}
+
+	u, err := DeriveUtilization(prev, cur, 10*time.Minute)
+	if assert.NoError(err) {
+		assert.InDelta(0.5, u.BusyRatio, 1e-9)
+		assert.InDelta(1000, u.ReadCmdsPerSec, 1e-9)
+		assert.InDelta(500, u.WriteCmdsPerSec, 1e-9)
+		assert.InDelta(512000, u.ReadBytesPerSec, 1e-9)
+		assert.InDelta(3000, u.CmdsPerBusySec, 1e-9)
+	}
+
+	_, err = DeriveUtilization(cur, prev, time.Minute)
+	assert.Error(err)
+}
+
+func TestTemperatureUnit(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(float64(37), Celsius.FromKelvin(310))
+	assert.Equal(float64(98.6), Fahrenheit.FromKelvin(310))
+	assert.Equal(float64(310), Kelvin.FromKelvin(310))
+
+	defer SetTemperatureUnit(Celsius)
+
+	assert.Equal("37 °C", FormatTemperature(310))
+	SetTemperatureUnit(Kelvin)
+	assert.Equal("310 K", FormatTemperature(310))
+
+	// JSON keeps the raw Kelvin values alongside the converted ones
+	SetTemperatureUnit(Fahrenheit)
+	buf, err := json.Marshal(&SMARTLog{Temperature: 310, TempSensor: [8]uint16{300}})
+	if assert.NoError(err) {
+		assert.Contains(string(buf), `"temperature_kelvin":310`)
+		assert.Contains(string(buf), `"temperature_unit":"fahrenheit"`)
+		assert.Contains(string(buf), `"temperature":98.6`)
+		assert.Contains(string(buf), `"temp_sensor_kelvin":[300,0,0,0,0,0,0,0]`)
+		assert.Contains(string(buf), `"temp_sensor":[80.6,null,null,null,null,null,null,null]`)
+	}
+}
+
+func TestNamespaceSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	buf, err := (&NamespaceSpec{Size: 0x100000, FormattedLBASize: 1, Shared: true, ANAGroupID: 2}).build()
+	if assert.NoError(err) {
+		assert.Len(buf, 4096)
+		assert.Equal(uint64(0x100000), binary.LittleEndian.Uint64(buf[0:8]))  // NSZE
+		assert.Equal(uint64(0x100000), binary.LittleEndian.Uint64(buf[8:16])) // NCAP
+		assert.Equal(uint8(1), buf[26])                                       // FLBAS
+		assert.Equal(uint8(1), buf[30])                                       // NMIC
+		assert.Equal(uint32(2), binary.LittleEndian.Uint32(buf[92:96]))       // ANAGRPID
+	}
+
+	_, err = (&NamespaceSpec{Size: 10, Capacity: 20}).build()
+	assert.Error(err)
+}
+
+func TestSummarizeZones(t *testing.T) {
+	assert := assert.New(t)
+
+	zones := []Zone{
+		{State: ZoneStateFull, Capacity: 100, StartLBA: 0, WritePointer: 0},
+		{State: ZoneStateImplicitlyOpened, Capacity: 100, StartLBA: 128, WritePointer: 178},
+		{State: ZoneStateClosed, Capacity: 100, StartLBA: 256, WritePointer: 266},
+		{State: ZoneStateEmpty, Capacity: 100, StartLBA: 384, WritePointer: 384},
+		{State: ZoneStateOffline, StartLBA: 512},
+	}
+
+	s := summarizeZones(zones)
+	assert.Equal(uint64(5), s.Zones)
+	assert.Equal(uint64(2), s.Active)
+	assert.Equal(uint64(1), s.Open)
+	assert.Equal(uint64(400), s.Capacity)
+	assert.Equal(uint64(160), s.Used)
+	assert.Equal(uint64(1), s.ByState[ZoneStateOffline])
+}
+
+func TestWipeCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("secret")
+	c := WipeCertificate{Device: "/dev/nvme0", Method: "crypto erase", SanitizeLog: make([]byte, 512)}
+
+	assert.False(c.Verify(key))
+	assert.NoError(c.Sign(key))
+	assert.Len(c.Signature, 32)
+	assert.True(c.Verify(key))
+	assert.False(c.Verify([]byte("other")))
+
+	// Tampering with any field invalidates the signature
+	c.SerialNumber = "S123"
+	assert.False(c.Verify(key))
+}
+
+func TestParseLevel0Discovery(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 2048)
+
+	features := []byte{
+		0x00, 0x01, 0x10, 0x0c, 0x11, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // TPer
+		0x00, 0x02, 0x10, 0x0c, 0x13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // Locking: enabled, supported, MBR enabled
+		0x02, 0x03, 0x20, 0x10, 0x10, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // Opal 2.0
+	}
+	copy(buf[48:], features)
+	binary.BigEndian.PutUint32(buf, uint32(48+len(features)-4))
+
+	o, err := parseLevel0Discovery(buf)
+	if assert.NoError(err) {
+		assert.True(o.TPer)
+		assert.True(o.LockingSupport)
+		assert.True(o.LockingEnabled)
+		assert.False(o.Locked)
+		assert.True(o.MBREnabled)
+		assert.True(o.MBRShadowing)
+		assert.True(o.Opal2())
+		assert.False(o.Pyrite())
+		assert.Equal(uint16(0x1000), o.BaseComID())
+		assert.Equal([]uint16{0x0001, 0x0002, 0x0203}, o.Features)
+	}
+
+	// Truncated feature descriptor
+	binary.BigEndian.PutUint32(buf, uint32(48+len(features)-4-2))
+	_, err = parseLevel0Discovery(buf)
+	assert.Error(err)
+}
+
+func TestDecodeMemoryRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	regs := make([]byte, registersLen)
+
+	binary.LittleEndian.PutUint64(regs[regCAP:], capCMBS|capPMRS)
+	binary.LittleEndian.PutUint32(regs[regCMBLOC:], 0x2|1<<12)             // BAR2, offset 1 unit
+	binary.LittleEndian.PutUint32(regs[regCMBSZ:], 0x1f|0x1<<8|4<<12)      // All uses, 64 KiB units, 4 units
+	binary.LittleEndian.PutUint32(regs[regPMRCAP:], 1<<3|1<<4|4<<5|10<<16) // BAR4, 5 s timeout
+	binary.LittleEndian.PutUint32(regs[regPMRCTL:], 1)
+	binary.LittleEndian.PutUint32(regs[regPMREBS:], 0x2|16<<8) // 16 MiB
+
+	m := decodeMemoryRegions(regs)
+
+	assert.Equal(CMBInfo{
+		Supported: true, BAR: 2, Offset: 64 << 10, Size: 256 << 10, SubmissionQueues: true,
+		CompletionQueues: true, PRPSGLLists: true, ReadData: true, WriteData: true,
+	}, m.CMB)
+
+	assert.True(m.PMR.Supported)
+	assert.True(m.PMR.Enabled)
+	assert.True(m.PMR.Ready)
+	assert.Equal(uint8(4), m.PMR.BAR)
+	assert.Equal(5*time.Second, m.PMR.Timeout)
+	assert.Equal(uint64(16<<20), m.PMR.ElasticityBuffer)
+
+	assert.False(decodeMemoryRegions(make([]byte, registersLen)).CMB.Supported)
+}
+
+func TestRPMBFrame(t *testing.T) {
+	assert := assert.New(t)
+
+	f := rpmbFrame{Target: 1, WriteCounter: 7, Address: 2, Sectors: 1, Type: rpmbWriteData}
+	f.Nonce[0] = 0xaa
+
+	buf := f.marshal(make([]byte, rpmbSectorLen))
+	assert.Len(buf, rpmbFrameLen+rpmbSectorLen)
+	assert.Equal(uint8(1), buf[rpmbMACStart])
+	assert.Equal(uint8(0xaa), buf[rpmbMACStart+1])
+	assert.Equal(uint16(rpmbWriteData), binary.LittleEndian.Uint16(buf[254:]))
+
+	key := make([]byte, 32)
+	mac := rpmbMAC(key, buf)
+	assert.Len(mac, 32)
+
+	// The MAC does not cover the stuff bytes or the MAC field itself
+	copy(buf[rpmbMACOffset:], mac)
+	buf[0] = 0xff
+	assert.Equal(mac, rpmbMAC(key, buf))
+
+	assert.EqualError(RPMBAuthFailure|rpmbCounterExpired,
+		"RPMB: authentication failure, write counter expired")
+}
+
+func TestDecodeReservationStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	binary.LittleEndian.PutUint32(buf[0:], 3)    // GEN
+	buf[4] = byte(ResvWriteExclusiveRegistrants) // RTYPE
+	binary.LittleEndian.PutUint16(buf[5:], 2)    // REGCTL
+	buf[9] = 1                                   // PTPLS
+
+	// Extended registrant entries start at offset 64
+	binary.LittleEndian.PutUint16(buf[64:], 1)
+	binary.LittleEndian.PutUint64(buf[72:], 0x1111)
+	buf[80] = 0xab
+
+	binary.LittleEndian.PutUint16(buf[128:], 2)
+	buf[130] = 1
+	binary.LittleEndian.PutUint64(buf[136:], 0x2222)
+
+	s := decodeReservationStatus(buf, true)
+	assert.Equal(uint32(3), s.Generation)
+	assert.Equal(ResvWriteExclusiveRegistrants, s.Type)
+	assert.True(s.PersistThroughPowerLoss)
+
+	if assert.Len(s.Registrants, 2) {
+		assert.Equal(uint64(0x1111), s.Registrants[0].Key)
+		assert.Equal(uint8(0xab), s.Registrants[0].HostID[0])
+		assert.Equal(uint16(2), s.Holder().ControllerID)
+		assert.Equal(uint64(0x2222), s.Holder().Key)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	assert := assert.New(t)
+
+	healthy := &SMARTLog{
+		AvailSpare: 100, SpareThresh: 10, MediaErrors: big.NewInt(0), NumErrLogEntries: big.NewInt(5),
+	}
+
+	assert.Equal(ActionNone, Recommend(RecommendationInput{Current: healthy}).Action)
+
+	// Media errors increasing, and error log growing by 20 per day
+	cur := *healthy
+	cur.MediaErrors = big.NewInt(3)
+	cur.NumErrLogEntries = big.NewInt(25)
+
+	r := Recommend(RecommendationInput{Previous: healthy, Current: &cur, Interval: 24 * time.Hour})
+	assert.Equal(ActionMigrate, r.Action)
+	if assert.Len(r.Findings, 2) {
+		assert.Equal("media-errors", r.Findings[0].Rule)
+		assert.Equal("error-log-rate", r.Findings[1].Rule)
+		assert.Equal(ActionMonitor, r.Findings[1].Action)
+	}
+
+	// Most recent self-test failed
+	st := &SelfTestLog{Results: []SelfTestResult{{Code: SelfTestShort, Status: 0x7}}}
+	r = Recommend(RecommendationInput{Current: healthy, SelfTest: st})
+	assert.Equal(ActionReplace, r.Action)
+
+	b, err := ActionMigrate.MarshalText()
+	assert.NoError(err)
+	assert.Equal("migrate", string(b))
+}
+
+func TestDecodeLockdownLog(t *testing.T) {
+	buf := make([]byte, 512)
+	buf[0] = 0x12 // Prohibited, Set Features FIDs
+	buf[3] = 2
+	buf[4], buf[5], buf[6] = 0x17, 0x84, 0xff
+
+	l := decodeLockdownLog(buf)
+
+	assert.Equal(t, LockdownFeatureID, l.Scope)
+	assert.Equal(t, LockdownProhibited, l.Content)
+	assert.Equal(t, []uint8{0x17, 0x84}, l.IDs)
+}
+
+func TestBundle(t *testing.T) {
+	assert := assert.New(t)
+
+	smart := make([]byte, 512)
+	smart[1], smart[2] = 0x2c, 0x01 // 300 K
+
+	c := &Collection{
+		Profile:    "minimal",
+		Controller: NVMeController{VendorID: 0x144d, SerialNumber: "S1234"},
+		LogPages:   map[uint8][]byte{NVME_LOG_SMART: smart},
+		Features:   map[uint8]uint32{NVME_FEAT_NUM_QUEUES: 0x003f003f},
+		Errors:     map[string]error{"log page 0x01": NVMeStatus(NVME_SC_INVALID_LOG_PAGE)},
+	}
+
+	var buf bytes.Buffer
+
+	if !assert.NoError(c.SaveBundle(&buf)) {
+		return
+	}
+
+	loaded, err := LoadBundle(&buf)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal(c.Controller, loaded.Controller)
+	assert.Equal(c.LogPages, loaded.LogPages)
+	assert.Equal(c.Features, loaded.Features)
+	assert.Equal(uint16(300), loaded.SMART.Temperature)
+	assert.EqualError(loaded.Errors["log page 0x01"], c.Errors["log page 0x01"].Error())
+
+	report, err := json.Marshal(loaded)
+	if assert.NoError(err) {
+		assert.Contains(string(report), `"profile":"minimal"`)
+		assert.Contains(string(report), `"smart":{`)
+	}
+
+	_, err = LoadBundle(strings.NewReader(`{"version": 99}`))
+	assert.Error(err)
+}
+
+func TestDecodeCapacityConfigs(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[0] = 1
+
+	// Capacity configuration 3 in domain 1, with one endurance group
+	copy(buf[16:], []byte{3, 0, 1, 0, 1, 0})
+
+	// Endurance group 1 with 1 GiB capacity, NVM sets 1 and 2, one channel with one media unit
+	eg := buf[48:]
+	eg[0] = 1
+	eg[19] = 0x40 // TEGCAP = 0x40000000
+	copy(eg[80:], []byte{2, 0, 1, 0, 2, 0, 1, 0, 7, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0x10, 0})
+
+	l, err := decodeCapacityConfigs(buf)
+	if assert.NoError(err) && assert.Len(l, 1) {
+		assert.Equal(uint16(3), l[0].ID)
+		assert.Equal(uint16(1), l[0].DomainID)
+
+		if assert.Len(l[0].EnduranceGroups, 1) {
+			g := l[0].EnduranceGroups[0]
+			assert.Equal(uint16(1), g.ID)
+			assert.Equal(int64(1<<30), g.TotalCapacity.Int64())
+			assert.Equal([]uint16{1, 2}, g.NVMSets)
+			assert.Equal([]ChannelConfig{{ID: 7, MediaUnits: []MediaUnitConfig{{9, 0x10}}}},
+				g.Channels)
+		}
+	}
+
+	// Descriptor count exceeding the buffer
+	_, err = decodeCapacityConfigs(append([]byte{1}, make([]byte, 20)...))
+	assert.Error(err)
+}
+
+func TestDecodeMediaUnitStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	// Two media units in a domain with 4 channels and capacity configuration 3 selected
+	buf := []byte{2, 0, 4, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	// Media unit 1 in endurance group 1, NVM set 1, attached to channels 0 and 2
+	buf = append(buf, 1, 0, 1, 0, 1, 0, 1, 0, 64, 0, 90, 5, 2, 16, 0, 0, 0, 0, 2, 0)
+
+	// Media unit 2, not attached to any channel
+	buf = append(buf, 2, 0, 1, 0, 1, 0, 2, 0, 64, 0, 100, 0, 0, 0)
+
+	l, err := decodeMediaUnitStatus(buf)
+	if assert.NoError(err) {
+		assert.Equal(uint16(4), l.Channels)
+		assert.Equal(uint16(3), l.SelectedConfig)
+		assert.Equal([]MediaUnitStatus{
+			{ID: 1, DomainID: 1, EnduranceGroupID: 1, NVMSetID: 1, CapacityAdjustment: 64,
+				AvailSpare: 90, PercentUsed: 5, Channels: []uint16{0, 2}},
+			{ID: 2, DomainID: 1, EnduranceGroupID: 1, NVMSetID: 2, CapacityAdjustment: 64,
+				AvailSpare: 100},
+		}, l.MediaUnits)
+	}
+
+	_, err = decodeMediaUnitStatus(buf[:40])
+	assert.Error(err)
+}
+
+func TestDecodeLBAStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[0], buf[4] = 1, 2
+	copy(buf[8:], []byte{0x00, 0x10, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0})
+
+	s := decodeLBAStatus(buf)
+	assert.Equal(uint8(2), s.Condition)
+	assert.Equal([]LBARange{{Start: 0x1000, Count: 8}}, s.Ranges)
+
+	// Log page with one namespace element holding two ranges
+	buf = make([]byte, 64)
+	buf[0], buf[4], buf[8], buf[14] = 64, 1, 24, 3
+	copy(buf[16:], []byte{1, 0, 0, 0, 2, 0, 0, 0, 0x11})
+	copy(buf[32:], []byte{0x20, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0})
+	copy(buf[48:], []byte{0x80, 0, 0, 0, 0, 0, 0, 0, 20, 0, 0, 0})
+
+	l, err := decodeLBAStatusLog(buf)
+	if assert.NoError(err) {
+		assert.Equal(uint16(3), l.Generation)
+		assert.Equal(uint32(24), l.EstimatedUnrecoverable)
+		assert.Equal([]LBAStatusNamespace{{NSID: 1, RecommendedAction: LBAStatusTracked,
+			Ranges: []LBARange{{0x20, 4}, {0x80, 20}}}}, l.Namespaces)
+	}
+
+	_, err = decodeLBAStatusLog(buf[:40])
+	assert.Error(err)
+}
+
+func TestDecodeSubsystemHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	// Drive functional, port 0 link active, available spare warning, 40 C, 3% used
+	h := decodeSubsystemHealth([]byte{0x28, 0x3e, 40, 3, 0x10, 0, 0, 0})
+	assert.True(h.DriveFunctional)
+	assert.False(h.ResetNotRequired)
+	assert.True(h.Port0LinkActive)
+	assert.Equal(uint8(CritWarnSpare), h.CritWarning)
+	assert.Equal(uint16(313), h.Temperature)
+	assert.Equal(uint8(3), h.PercentUsed)
+	assert.Equal(uint16(0x10), h.ControllerChanges)
+
+	// Below 0 C, and no temperature data
+	assert.Equal(uint16(263), decodeSubsystemHealth([]byte{0, 0x3f, 0xf6, 0, 0, 0, 0, 0}).Temperature)
+	assert.Zero(decodeSubsystemHealth([]byte{0, 0x3f, 0x80, 0, 0, 0, 0, 0}).Temperature)
+}
+
+func TestCommandSetVector(t *testing.T) {
+	v := CommandSetVector(1<<NVME_CSI_NVM | 1<<NVME_CSI_ZNS)
+
+	assert.True(t, v.Supports(NVME_CSI_ZNS))
+	assert.False(t, v.Supports(NVME_CSI_KV))
+	assert.Equal(t, "NVM|ZNS", v.String())
+	assert.Equal(t, "KV|CSI 0x5", CommandSetVector(0x22).String())
+	assert.Equal(t, "none", CommandSetVector(0).String())
+}
+
+func TestDecodeErrorLog(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 128)
+	copy(buf, []byte{
+		5, 0, 0, 0, 0, 0, 0, 0, // Error count
+		1, 0, 0x2a, 0, // SQID, CID
+		0x03, 0x05, // Status field: Unrecovered Read Error, phase tag set
+		0xff, 0xff, // Parameter error location not applicable
+		0x00, 0x10, 0, 0, 0, 0, 0, 0, // LBA
+		1, 0, 0, 0, // NSID
+	})
+
+	l := decodeErrorLog(buf)
+	if !assert.Len(l, 2) {
+		return
+	}
+
+	e := l[0]
+	assert.Equal(uint64(5), e.ErrorCount)
+	assert.Equal(uint16(0x2a), e.CID)
+	assert.Equal(uint16(0x281), e.Status.Code())
+	assert.False(e.ParamValid)
+	assert.Equal(uint64(0x1000), e.LBA)
+	assert.Equal(ErrorLog{e}, l.NonEmpty())
+}
+
+func TestDecodeCommandEffects(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	binary.LittleEndian.PutUint32(buf[4*int(NVME_ADMIN_NS_MGMT):], 0x2000d)   // CSUPP|NCC|NIC, exclusive
+	binary.LittleEndian.PutUint32(buf[1024+4*int(NVME_CMD_WRITE):], 0x3)      // CSUPP|LBCC
+	binary.LittleEndian.PutUint32(buf[1024+4*int(NVME_CMD_VERIFY):], 0x80000) // USS, but not supported
+
+	l := decodeCommandEffects(buf)
+
+	e, ok := l.AdminCommand(NVME_ADMIN_NS_MGMT)
+	if assert.True(ok) {
+		assert.True(e.NamespaceInventoryChange)
+		assert.False(e.UserDataChange)
+		assert.Equal(SubmissionController, e.Submission)
+	}
+
+	e, ok = l.IOCommand(NVME_CMD_WRITE)
+	assert.True(ok)
+	assert.True(e.UserDataChange)
+
+	_, ok = l.IOCommand(NVME_CMD_VERIFY)
+	assert.False(ok)
+	assert.Len(l.Admin, 1)
+}
+
+func TestDecodeChangedNamespaces(t *testing.T) {
+	buf := make([]byte, 4096)
+	binary.LittleEndian.PutUint32(buf, 1)
+	binary.LittleEndian.PutUint32(buf[4:], 7)
+
+	assert.Equal(t, &ChangedNamespaceList{NSIDs: []uint32{1, 7}}, decodeChangedNamespaces(buf))
+
+	binary.LittleEndian.PutUint32(buf, 0xffffffff)
+	assert.True(t, decodeChangedNamespaces(buf).Overflow)
+}
+
+func TestDecodeANALog(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 16+32+8+32)
+	buf[0] = 5 // Change count
+	buf[8] = 2 // Number of groups
+
+	// Group 1 is optimized, with namespaces 1 and 3
+	buf[16], buf[20], buf[32] = 1, 2, byte(ANAOptimized)
+	buf[48], buf[52] = 1, 3
+
+	// Group 2 is inaccessible, with no namespaces
+	buf[56], buf[72] = 2, byte(ANAInaccessible)
+
+	l, err := decodeANALog(buf)
+	if assert.NoError(err) {
+		assert.Equal(uint64(5), l.ChangeCount)
+		assert.Equal([]ANAGroup{
+			{GroupID: 1, State: ANAOptimized, NSIDs: []uint32{1, 3}},
+			{GroupID: 2, State: ANAInaccessible},
+		}, l.Groups)
+
+		state, ok := l.NamespaceState(3)
+		assert.True(ok)
+		assert.Equal(ANAOptimized, state)
+
+		_, ok = l.NamespaceState(2)
+		assert.False(ok)
+	}
+
+	_, err = decodeANALog(buf[:60])
+	assert.Error(err)
+}
+
+func TestDecodeIDList(t *testing.T) {
+	buf := []byte{3, 0, 0, 0, 0, 0, 0, 0, 1, 0, 4, 0, 0, 1}
+
+	assert.Equal(t, []uint16{1, 4, 256}, decodeIDList(buf))
+
+	// Truncated list
+	assert.Equal(t, []uint16{1, 4}, decodeIDList(buf[:12]))
+}
+
+func TestDecodeSanitizeStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	// Overwrite in progress after 2 passes, with only an overwrite time estimate
+	sl := nvmeSanitizeLog{Sprog: 0x4000, Sstat: 2<<3 | sstatInProgress, Scdw10: sanactOverwrite,
+		Eto: 3600, Etbe: 0xffffffff, Etce: 0xffffffff}
+
+	s := sl.decode()
+	assert.Equal(SanitizeInProgress, s.State)
+	assert.Equal(25.0, s.PercentComplete())
+	assert.Equal(uint8(2), s.OverwritePasses)
+	assert.Equal(uint8(sanactOverwrite), s.Action)
+	assert.False(s.GlobalDataErased)
+	assert.Equal(time.Hour, s.EstOverwrite)
+	assert.Zero(s.EstBlockErase)
+
+	sl = nvmeSanitizeLog{Sprog: 0xffff, Sstat: sstatGlobalDataErased | sstatCompleted}
+
+	s = sl.decode()
+	assert.Equal("completed", s.State.String())
+	assert.Equal(100.0, s.PercentComplete())
+	assert.True(s.GlobalDataErased)
+}
+
+func TestDecodeFDPConfigs(t *testing.T) {
+	assert := assert.New(t)
+
+	// One configuration with 2 reclaim unit handles and 4 bytes of vendor specific data
+	buf := make([]byte, 16+76)
+	buf[4] = byte(len(buf))
+
+	desc := buf[16:]
+	desc[0], desc[2], desc[3] = 76, fdpaValid, 4
+	desc[4], desc[8], desc[10] = 1, 2, 7
+	binary.LittleEndian.PutUint64(desc[16:], 1<<30)
+	desc[64], desc[68] = byte(RUHInitiallyIsolated), byte(RUHPersistentlyIsolated)
+	copy(desc[72:], "vend")
+
+	l, err := decodeFDPConfigs(buf)
+	if assert.NoError(err) && assert.Len(l, 1) {
+		assert.True(l[0].Valid)
+		assert.Equal(uint32(1), l[0].ReclaimGroups)
+		assert.Equal(uint16(8), l[0].MaxPlacementIDs)
+		assert.Equal(uint64(1<<30), l[0].ReclaimUnitSize)
+		assert.Equal([]ReclaimUnitHandleType{RUHInitiallyIsolated, RUHPersistentlyIsolated},
+			l[0].Handles)
+		assert.Equal([]byte("vend"), l[0].VendorSpecific)
+	}
+
+	// Descriptor too short for its reclaim unit handles
+	desc[0] = 64
+	_, err = decodeFDPConfigs(buf)
+	assert.Error(err)
+}
+
+func TestDecodeFDPEvents(t *testing.T) {
+	buf := make([]byte, 3*64)
+	buf[0] = 2
+
+	// Invalid placement identifier event with valid PID and NSID
+	buf[64], buf[65], buf[66], buf[76] = byte(FDPEventInvalidPlacementID), 0x3, 9, 1
+
+	// Media reallocated event with only a valid location
+	buf[128], buf[129], buf[160], buf[162] = byte(FDPEventMediaReallocated), 0x4, 2, 3
+
+	assert.Equal(t, []FDPEvent{
+		{Type: FDPEventInvalidPlacementID, NSID: 1, PlacementID: 9},
+		{Type: FDPEventMediaReallocated, ReclaimGroup: 2, Handle: 3},
+	}, decodeFDPEvents(buf))
+}
+
+func TestDecodeOCPSMARTLog(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 512)
+	buf[1] = 0x10                                   // 4 KiB physical media units written
+	copy(buf[32:], []byte{1, 2, 0, 0, 0, 1, 98, 0}) // Bad user blocks
+	buf[80], buf[97] = 3, 1
+	copy(buf[81:], []byte{0, 0, 0, 0, 0, 0, 1}) // Refresh count
+	buf[112] = 7                                // Incomplete shutdowns
+	buf[494] = 4                                // Log page version
+
+	_, err := decodeOCPSMARTLog(buf)
+	assert.ErrorIs(err, ErrNotSupported)
+
+	copy(buf[496:], ocpSMARTGUID[:])
+
+	l, err := decodeOCPSMARTLog(buf)
+	if assert.NoError(err) {
+		assert.Equal(int64(4096), l.PhysMediaUnitsWritten.Int64())
+		assert.Equal(uint64(0x010000000201), l.BadUserBlocks)
+		assert.Equal(uint16(98), l.BadUserBlocksNorm)
+		assert.Equal(uint8(3), l.SystemDataPercentUsed)
+		assert.Equal(uint64(1)<<48, l.RefreshCount)
+		assert.Equal(uint8(1), l.ThermalThrottleStatus)
+		assert.Equal(uint32(7), l.IncompleteShutdowns)
+		assert.Equal(uint16(4), l.LogPageVersion)
+	}
+}
+
+func TestDecodeLatencyMonitorLog(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 512)
+	buf[4], buf[6] = 0x14, 5
+	copy(buf[496:], ocpLatencyMonitorGUID[:])
+
+	// Bucket 1: 3 writes, with a maximum write latency of 42 ms
+	binary.LittleEndian.PutUint32(buf[32+4*(4+1):], 3)
+	binary.LittleEndian.PutUint64(buf[96+8*(3+1):], 1.6e12)
+	binary.LittleEndian.PutUint16(buf[192+2*(3+1):], 42)
+
+	l, err := decodeLatencyMonitorLog(buf)
+	if assert.NoError(err) {
+		assert.Equal(uint16(0x14), l.BucketTimerThreshold)
+		assert.Equal([4]uint8{5, 0, 0, 0}, l.Thresholds)
+		assert.Equal([4]uint32{0, 3, 0, 0}, l.Active.Buckets[1])
+		assert.Equal([3]uint16{0, 42, 0}, l.Active.Latency[1])
+		assert.Equal(int64(1.6e12), l.Active.Timestamps[1][1].UnixMilli())
+		assert.True(l.Active.Timestamps[0][0].IsZero())
+	}
+}
+
+func TestDecodeOCPUnsupportedRequirements(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[0] = 2
+	copy(buf[16:], "PLP-1")
+	copy(buf[32:], "SEC-10          ")
+
+	_, err := decodeOCPUnsupportedRequirements(buf)
+	assert.ErrorIs(err, ErrNotSupported)
+
+	copy(buf[4080:], ocpUnsupportedGUID[:])
+
+	reqs, err := decodeOCPUnsupportedRequirements(buf)
+	assert.NoError(err)
+	assert.Equal([]string{"PLP-1", "SEC-10"}, reqs)
+}
+
+func TestDecodeIntelSMARTLog(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 512)
+	copy(buf[0:], []byte{intelAttrProgramFail, 0, 0, 100, 0, 3, 0, 0, 0, 0, 0, 0})
+	copy(buf[12:], []byte{intelAttrWearLeveling, 0, 0, 97, 0, 1, 0, 9, 0, 5, 0, 0})
+	copy(buf[24:], []byte{intelAttrThermalThrottle, 0, 0, 100, 0, 2, 7, 0, 0, 0, 0, 0})
+
+	l := decodeIntelSMARTLog(buf)
+	assert.Equal(IntelSMARTValue{100, 3}, l.ProgramFailCount)
+	assert.Equal(uint8(97), l.WearLeveling)
+	assert.Equal([]uint16{1, 9, 5}, []uint16{l.WearLevelingMin, l.WearLevelingMax, l.WearLevelingAvg})
+	assert.Equal(uint8(2), l.ThermalThrottlePercent)
+	assert.Equal(uint32(7), l.ThermalThrottleCount)
+	assert.Zero(l.EraseFailCount)
+}
+
+func TestDecodeWDCSMARTLog(t *testing.T) {
+	assert := assert.New(t)
+
+	// First 0x80 bytes of a log page laid out as nvme-cli's packed wdc_ssd_ca_perf_stats, with a
+	// distinct value in each field, so that any misaligned field is detected
+	dump := strings.Join([]string{
+		"00100000000000000000000000000000", // 0x00: NAND bytes written
+		"00200000000000000000000000000000", // 0x10: NAND bytes read
+		"03000000000000000400000000000000", // 0x20: bad blocks, uncorrectable reads
+		"05000000000000000600000007000000", // 0x30: soft ECC errors, E2E detected, E2E corrected
+		"0a341200006705000008000000000000", // 0x40: data used, erase max, erase min, refresh
+		"0009000000000000000b000000000000", // 0x50: program fail, user erase fail
+		"000c00000000000000010d0e00000000", // 0x60: system erase fail, throttle, PCIe errors
+		"0000000f0000002a0000000000000000", // 0x70: incomplete shutdowns, free blocks
+	}, "")
+
+	buf := make([]byte, 512)
+	n, err := hex.Decode(buf, []byte(dump))
+	assert.NoError(err)
+	assert.Equal(0x80, n)
+
+	l := decodeWDCSMARTLog(buf)
+	assert.Equal(int64(0x1000), l.NANDBytesWritten.Int64())
+	assert.Equal(int64(0x2000), l.NANDBytesRead.Int64())
+	assert.Equal(uint64(3), l.BadBlocks)
+	assert.Equal(uint64(4), l.UncorrectableReadErrors)
+	assert.Equal(uint64(5), l.SoftECCErrors)
+	assert.Equal(uint32(6), l.E2EDetectedErrors)
+	assert.Equal(uint32(7), l.E2ECorrectedErrors)
+	assert.Equal(uint8(10), l.SystemDataPercentUsed)
+	assert.Equal(uint32(0x1234), l.MaxEraseCount)
+	assert.Equal(uint32(0x567), l.MinEraseCount)
+	assert.Equal(uint64(8), l.RefreshCount)
+	assert.Equal(uint64(9), l.ProgramFailCount)
+	assert.Equal(uint64(11), l.UserEraseFailCount)
+	assert.Equal(uint64(12), l.SystemEraseFailCount)
+	assert.Equal(uint8(1), l.ThermalThrottleStatus)
+	assert.Equal(uint8(13), l.ThermalThrottleCount)
+	assert.Equal(uint64(14), l.PCIeCorrectableErrors)
+	assert.Equal(uint32(15), l.IncompleteShutdowns)
+	assert.Equal(uint8(42), l.PercentFreeBlocks)
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &nvmeIdentController{VendorID: 0x144d, Mdts: 5,
+		IEEE: [3]byte{0x38, 0x25, 0x00}})
+	buf.Write(make([]byte, 4096-buf.Len()))
+
+	c, err := ParseIdentifyController(buf.Bytes())
+	assert.NoError(err)
+	assert.Equal(uint16(0x144d), c.VendorID)
+	assert.Equal(uint(128<<10), c.MaxDataXferSize)
+	assert.Equal(uint32(0x002538), c.OUI)
+
+	_, err = ParseIdentifyController(buf.Bytes()[:512])
+	assert.Error(err)
+
+	ns := nvmeIdentNamespace{Nsze: 100, Nuse: 50, Flbas: 1, Nsattr: 1}
+	ns.Lbaf[1].Ds = 12
+
+	buf.Reset()
+	binary.Write(buf, binary.LittleEndian, &ns)
+	buf.Write(make([]byte, 4096-buf.Len()))
+
+	n, err := ParseIdentifyNamespace(buf.Bytes(), 1)
+	assert.NoError(err)
+	assert.Equal(NVMeNamespace{NSID: 1, Size: 100, Utilization: 50, LBASize: 4096,
+		WriteProtected: true}, n)
+
+	// FLBAS bits 6:5 extend the format index if more than 16 LBA formats are supported
+	ns = nvmeIdentNamespace{Nlbaf: 19, Flbas: 0x22}
+	ns.Lbaf[2].Ds = 9
+	ns.Lbaf[18].Ds = 12
+
+	idx, lbaf := ns.currentLBAF()
+	assert.Equal(18, idx)
+	assert.Equal(uint8(12), lbaf.Ds)
+
+	ns.Nlbaf = 15
+
+	idx, _ = ns.currentLBAF()
+	assert.Equal(2, idx)
+
+	smart := make([]byte, 512)
+	smart[3] = 95
+
+	sl, err := ParseSMARTLog(smart)
+	assert.NoError(err)
+	assert.Equal(uint8(95), sl.AvailSpare)
+
+	_, err = ParseErrorLog(make([]byte, 100))
+	assert.Error(err)
+
+	el, err := ParseErrorLog(make([]byte, 128))
+	assert.NoError(err)
+	assert.Len(el, 2)
+
+	tel := make([]byte, 512)
+	_, err = ParseTelemetryHeader(tel)
+	assert.Error(err)
+
+	tel[0] = NVME_LOG_TELEMETRY_HOST
+	h, err := ParseTelemetryHeader(tel)
+	assert.NoError(err)
+	assert.Equal(NVME_LOG_TELEMETRY_HOST, h.LogID)
+}
+
+func TestDecodeTelemetryStrings(t *testing.T) {
+	assert := assert.New(t)
+
+	// Header, followed by one statistic, one event, one VU event and a 32-byte ASCII table
+	buf := make([]byte, 512)
+	copy(buf[16:], ocpTelemetryStringsGUID[:])
+	binary.LittleEndian.PutUint64(buf[32:], uint64(len(buf)/4))
+	for i, v := range []uint64{108, 4, 112, 4, 116, 4, 120, 8} {
+		binary.LittleEndian.PutUint64(buf[64+8*i:], v)
+	}
+	copy(buf[128:], "Admin FIFO")
+
+	copy(buf[432:], []byte{0x34, 0x12, 0, 4, 0})    // Statistic 0x1234, "Temps"
+	copy(buf[448:], []byte{0x02, 0x01, 0x00, 4, 2}) // Event 0x02/0x0001, "PERST"
+	copy(buf[464:], []byte{0x80, 0x07, 0x00, 1, 4}) // VU event 0x80/0x0007, "VU"
+	copy(buf[480:], "Temps\x00\x00\x00PERST\x00\x00\x00VU")
+
+	s, err := decodeTelemetryStrings(buf)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal("Admin FIFO", s.FIFONames[0])
+	assert.Equal("Temps", s.StatisticName(0x1234))
+	assert.Equal("statistic 0x0001", s.StatisticName(1))
+	assert.Equal("PERST", s.EventName(0x02, 0x0001))
+	assert.Equal("VU", s.EventName(0x80, 0x0007))
+
+	// ASCII string beyond the table
+	buf[436] = 99
+	_, err = decodeTelemetryStrings(buf)
+	assert.Error(err)
+}
+
+func TestDecodeNamespaceDescriptors(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	copy(buf[0:], []byte{nidtEUI64, 8, 0, 0, 0x00, 0x25, 0x38, 0x51, 0x01, 0x02, 0x03, 0x04})
+	copy(buf[12:], []byte{nidtUUID, 16, 0, 0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef})
+	copy(buf[32:], []byte{nidtCSI, 1, 0, 0, NVME_CSI_ZNS})
+
+	n, err := decodeNamespaceDescriptors(buf)
+	if assert.NoError(err) {
+		assert.Equal(&NamespaceDescriptors{
+			EUI64:  "0025385101020304",
+			UUID:   "12345678-9abc-def0-0123-456789abcdef",
+			CSI:    NVME_CSI_ZNS,
+			HasCSI: true,
+		}, n)
+	}
+
+	buf[1] = 9
+	_, err = decodeNamespaceDescriptors(buf)
+	assert.Error(err)
+}
+
+func TestDecodeNVMSetList(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[0] = 2
+	copy(buf[128:], []byte{1, 0, 1, 0, 0, 0, 0, 0, 100, 0, 0, 0, 0, 0x40})
+	buf[128+17] = 0x10
+	copy(buf[256:], []byte{2, 0, 1, 0})
+
+	l := decodeNVMSetList(buf)
+	if assert.Len(l, 2) {
+		assert.Equal(uint16(1), l[0].ID)
+		assert.Equal(uint16(1), l[0].EnduranceGroupID)
+		assert.Equal(uint32(100), l[0].RandomReadTypical)
+		assert.Equal(uint32(0x4000), l[0].OptimalWriteSize)
+		assert.Equal(int64(0x1000), l[0].TotalCapacity.Int64())
+		assert.Equal(uint16(2), l[1].ID)
+	}
+}
+
+func TestDecodeControllerList(t *testing.T) {
+	buf, err := buildControllerList([]uint16{7, 1, 3})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint16{1, 3, 7}, decodeControllerList(buf))
+	}
+}
+
+func TestDecodeUUIDList(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[32] = byte(UUIDAssocController)
+	copy(buf[48:], []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67,
+		0x89, 0xab, 0xcd, 0xef})
+	buf[64] = byte(UUIDAssocSubsystem)
+	buf[80] = 0xff
+
+	l := decodeUUIDList(buf)
+	if assert.Len(l, 2) {
+		assert.Equal(UUIDEntry{1, UUIDAssocController, "12345678-9abc-def0-0123-456789abcdef"}, l[0])
+		assert.Equal(uint8(2), l[1].Index)
+	}
+
+	idx, ok := l.Index("12345678-9ABC-DEF0-0123-456789ABCDEF")
+	assert.True(ok)
+	assert.Equal(uint8(1), idx)
+}
+
+func TestDecodeZNSNamespace(t *testing.T) {
+	zns := nvmeZNSIdentNamespace{Zoc: 1, Ozcs: 2, Mar: 13, Mor: 0xffffffff, Numzrwa: 3, Zrwasz: 64,
+		Zrwafg: 8, Zrwacap: 1}
+	zns.Lbafe[1] = nvmeZNSLBAFE{Zsze: 0x80000, Zdes: 2}
+
+	assert.Equal(t, &ZNSNamespace{
+		ZoneSize:             0x80000,
+		ZoneDescExtSize:      128,
+		MaxActiveZones:       14,
+		VariableCapacity:     true,
+		ZRWASupported:        true,
+		ZRWAResources:        4,
+		ZRWASize:             64,
+		ZRWAFlushGranularity: 8,
+		ZRWAExplicitFlush:    true,
+	}, zns.decode(1))
+}
+
+func TestDecodeKVNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	binary.LittleEndian.PutUint64(buf[0:], 1<<40)
+	buf[25] = 1 // Two KV formats
+	binary.LittleEndian.PutUint16(buf[72:], 16)
+	binary.LittleEndian.PutUint32(buf[76:], 1<<20)
+	binary.LittleEndian.PutUint16(buf[88:], 255)
+	binary.LittleEndian.PutUint32(buf[92:], 2<<20)
+	binary.LittleEndian.PutUint32(buf[96:], 1000)
+
+	n := decodeKVNamespace(buf)
+	assert.Equal(uint64(1<<40), n.Size)
+	assert.Equal([]KVFormat{{16, 1 << 20, 0}, {255, 2 << 20, 1000}}, n.Formats)
+}
+
+func TestDecodeDomainList(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[0] = 1
+	buf[128] = 5
+	buf[128+17] = 0x20
+	buf[128+33] = 0x10
+
+	l := decodeDomainList(buf)
+	if assert.Len(l, 1) {
+		assert.Equal(uint16(5), l[0].ID)
+		assert.Equal(int64(0x2000), l[0].TotalCapacity.Int64())
+		assert.Equal(int64(0x1000), l[0].UnallocatedCapacity.Int64())
+		assert.Zero(l[0].MaxEnduranceGroupSize.Sign())
+	}
+}
+
+func TestControllerCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	raw := nvmeIdentController{
+		Oacs:    oacsNsMgmt | oacsSelfTest | oacsFirmware,
+		Oncs:    oncsDSM | oncsWriteZeroes | oncsVerify,
+		Lpa:     lpaTelemetry,
+		Frmw:    frmwActivateNoReset | 4<<frmwSlotsShift,
+		Sanicap: sanicapCryptoErase,
+		Ctratt:  ctrattEnduranceGroups,
+	}
+	c := raw.decode()
+
+	assert.True(c.SupportsNamespaceManagement())
+	assert.True(c.SupportsSelfTest())
+	assert.True(c.SupportsFirmwareUpdate())
+	assert.False(c.SupportsFormat())
+	assert.False(c.SupportsSecurity())
+	assert.True(c.SupportsDSM())
+	assert.True(c.SupportsWriteZeroes())
+	assert.True(c.SupportsVerify())
+	assert.False(c.SupportsCopy())
+	assert.True(c.SupportsTelemetry())
+	assert.False(c.SupportsPersistentEventLog())
+	assert.True(c.SupportsFirmwareActivateNoReset())
+	assert.Equal(4, c.FirmwareSlots())
+	assert.True(c.SupportsSanitize())
+	assert.True(c.SupportsCryptoErase())
+	assert.False(c.SupportsBlockErase())
+	assert.True(c.SupportsEnduranceGroups())
+	assert.False(c.SupportsFDP())
+}
+
+func TestMaxDataXferSize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(4096, pageSizeFromCAP(0))
+	assert.Equal(16384, pageSizeFromCAP(2<<capMPSMINShift|capCMBS))
+	assert.Equal(uint(0), mdtsBytes(0, 4096))
+	assert.Equal(uint(256<<10), mdtsBytes(4, 16384))
+
+	d := NewNVMeDevice("/dev/nvme0")
+	assert.Error(d.SetMinPageSize(2048))
+	assert.Error(d.SetMinPageSize(12288))
+	assert.NoError(d.SetMinPageSize(16384))
+	assert.Equal(16384, d.minPageSize())
+}
+
+func TestControllerIdentity(t *testing.T) {
+	assert := assert.New(t)
+
+	raw := nvmeIdentController{Cntlid: 3, Cmic: cmicMultiPort | cmicANA, Cntrltype: 2}
+	copy(raw.Subnqn[:], "nqn.2014-08.org.nvmexpress.discovery")
+
+	c := raw.decode()
+	assert.Equal("nqn.2014-08.org.nvmexpress.discovery", c.SubsystemNQN)
+	assert.Equal(uint16(3), c.ControllerID)
+	assert.True(c.IsDiscovery())
+	assert.True(c.MultiPort())
+	assert.False(c.MultiController())
+	assert.True(c.SupportsANA())
+	assert.Equal("discovery", c.ControllerType.String())
+	assert.Equal("unknown (0x7)", ControllerType(7).String())
+}
+
+func TestControllerFGUIDAndOUI(t *testing.T) {
+	assert := assert.New(t)
+
+	raw := nvmeIdentController{IEEE: [3]byte{0x38, 0x25, 0x00}}
+	c := raw.decode()
+	assert.Equal("Samsung Electronics", c.OUIVendor())
+	assert.Empty(c.FGUID)
+	assert.Empty(OUIVendor(0x123456))
+
+	raw.Fguid = [16]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 15: 0xff}
+	c = raw.decode()
+	assert.Equal("01234567-89ab-cdef-0000-0000000000ff", c.FGUID)
+
+	var sb strings.Builder
+	c.Print(&sb)
+	assert.Contains(sb.String(), "IEEE OUI vendor    : Samsung Electronics\n")
+	assert.Contains(sb.String(), "FRU GUID           : 01234567-89ab-cdef-0000-0000000000ff\n")
+}
+
+func TestPowerStates(t *testing.T) {
+	assert := assert.New(t)
+
+	raw := nvmeIdentController{Npss: 3}
+	raw.Psd[0] = nvmeIdentPowerState{MaxPower: 800, EntryLat: 5, ExitLat: 10, IdlePower: 350,
+		IdleScale: 1 << psPowerScaleShift, ActivePower: 600, ActiveWorkScale: 2<<psPowerScaleShift | 2}
+	raw.Psd[1] = nvmeIdentPowerState{MaxPower: 35, Flags: psMaxPowerScale | psNonOperational,
+		ExitLat: 2000, ReadTput: 1, ReadLat: 1, WriteTput: 1, WriteLat: 1}
+	raw.Psd[2] = nvmeIdentPowerState{MaxPower: 100, Flags: psMaxPowerScale}
+	raw.Psd[3] = nvmeIdentPowerState{IdleScale: 2 << psPowerScaleShift}
+
+	c := raw.decode()
+	if assert.Len(c.PowerStates, 4) {
+		ps := c.PowerStates[0]
+		assert.InDelta(8.0, ps.MaxPower, 1e-9)
+		assert.False(ps.NonOperational)
+		assert.Equal(5*time.Microsecond, ps.EntryLatency)
+		assert.InDelta(0.035, ps.IdlePower, 1e-9)
+		assert.InDelta(6.0, ps.ActivePower, 1e-9)
+		assert.Equal(uint8(2), ps.ActivePowerWorkload)
+
+		ps = c.PowerStates[1]
+		assert.InDelta(0.0035, ps.MaxPower, 1e-9)
+		assert.True(ps.NonOperational)
+		assert.Equal(2*time.Millisecond, ps.ExitLatency)
+		assert.Zero(ps.IdlePower)
+	}
+
+	var sb strings.Builder
+	c.PrintPowerStates(&sb)
+	assert.Equal("ps    0 : mp:8.00W operational enlat:5 exlat:10 rrt:0 rrl:0\n"+
+		"          rwt:0 rwl:0 idle_power:0.0350W active_power:6.00W\n"+
+		"          active_power_workload:2\n"+
+		"ps    1 : mp:0.0035W non-operational enlat:0 exlat:2000 rrt:1 rrl:1\n"+
+		"          rwt:1 rwl:1 idle_power:- active_power:-\n"+
+		"          active_power_workload:-\n"+
+		"ps    2 : mp:0.0100W operational enlat:0 exlat:0 rrt:0 rrl:0\n"+
+		"          rwt:0 rwl:0 idle_power:- active_power:-\n"+
+		"          active_power_workload:-\n"+
+		"ps    3 : mp:0.00W operational enlat:0 exlat:0 rrt:0 rrl:0\n"+
+		"          rwt:0 rwl:0 idle_power:0.00W active_power:-\n"+
+		"          active_power_workload:-\n", sb.String())
+}
+
+func TestFormatWWID(t *testing.T) {
+	assert := assert.New(t)
+
+	idCtrlr := nvmeIdentController{VendorID: 0x1b36}
+	copy(idCtrlr.SerialNumber[:], "1234                ")
+	copy(idCtrlr.ModelNumber[:], "QEMU NVMe Ctrl                          ")
+
+	var idNs nvmeIdentNamespace
+	assert.Equal("nvme.1b36-31323334-51454d55204e564d65204374726c-00000001",
+		formatWWID(&idCtrlr, &idNs, "", 1))
+
+	idNs.EUI64 = [8]byte{0x00, 0x25, 0x38, 0x5a, 0x91, 0xb0, 0x12, 0x34}
+	assert.Equal("eui.0025385a91b01234", formatWWID(&idCtrlr, &idNs, "", 1))
+
+	idNs.Nguid = [16]byte{0x01, 15: 0x02}
+	assert.Equal("eui.01000000000000000000000000000002", formatWWID(&idCtrlr, &idNs, "", 1))
+	assert.Equal("eui.01000000000000000000000000000002",
+		formatWWID(&idCtrlr, &idNs, "00000000-0000-0000-0000-000000000000", 1))
+
+	assert.Equal("uuid.6f1b2c3d-0000-4000-8000-0123456789ab",
+		formatWWID(&idCtrlr, &idNs, "6f1b2c3d-0000-4000-8000-0123456789ab", 1))
+}
+
+func TestDecodeRegisters(t *testing.T) {
+	assert := assert.New(t)
+
+	regs := make([]byte, registersLen)
+
+	// MQES 1023, CQR, TO 30 s, DSTRD 0, NSSRS, CSS NVM + I/O command sets, MPSMIN 4 KiB, MPSMAX 64 KiB
+	binary.LittleEndian.PutUint64(regs[regCAP:], 1023|1<<16|60<<24|1<<36|0x41<<37|4<<52)
+	binary.LittleEndian.PutUint32(regs[regVS:], 0x00010400)
+	binary.LittleEndian.PutUint32(regs[regCC:], 1|6<<16|4<<20)
+	binary.LittleEndian.PutUint32(regs[regCSTS:], 1)
+
+	r := decodeRegisters(regs)
+	assert.Equal(uint32(1024), r.MaxQueueEntries)
+	assert.True(r.ContiguousQueues)
+	assert.Equal(30*time.Second, r.Timeout)
+	assert.Equal(uint32(4), r.DoorbellStride)
+	assert.True(r.SubsystemReset)
+	assert.Equal(uint8(0x41), r.CommandSets)
+	assert.Equal(4096, r.MinPageSize)
+	assert.Equal(65536, r.MaxPageSize)
+	assert.Equal("1.4.0", r.VersionString())
+	assert.True(r.Enabled)
+	assert.Equal(4096, r.PageSize)
+	assert.Equal(64, r.IOSQEntSize)
+	assert.Equal(16, r.IOCQEntSize)
+	assert.True(r.Ready)
+	assert.False(r.FatalStatus)
+}
+
+func TestNamespaceGranularity(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := make([]byte, 4096)
+	buf[4] = 1 // Two descriptors
+	binary.LittleEndian.PutUint64(buf[32:], 1<<30)
+	binary.LittleEndian.PutUint64(buf[40:], 1<<20)
+	binary.LittleEndian.PutUint64(buf[48:], 8<<30)
+
+	l := decodeNamespaceGranularityList(buf)
+	assert.False(l.PerLBAFormat)
+	assert.Equal([]NamespaceGranularity{{Size: 1 << 30, Capacity: 1 << 20}, {Size: 8 << 30}},
+		l.Descriptors)
+
+	g, ok := l.ForLBAFormat(1)
+	assert.True(ok)
+	assert.Equal(uint64(1<<30), g.Size)
+
+	// 1 GiB size granularity and 1 MiB capacity granularity, in 4 KiB blocks
+	spec := NamespaceSpec{Size: 300000}
+	l.round(&spec, 0, 4096)
+	assert.Equal(uint64(2*262144), spec.Size)
+	assert.Equal(uint64(300032), spec.Capacity)
+
+	l.PerLBAFormat = true
+	_, ok = l.ForLBAFormat(2)
+	assert.False(ok)
+}
+
+func TestIdentifyShortBuffer(t *testing.T) {
+	assert.Error(t, NewNVMeDevice("/dev/null").Identify(NVME_IDENTIFY_CNS_CTRL, NVME_CSI_NVM, 0, 0,
+		make([]byte, 512)))
+}
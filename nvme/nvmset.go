@@ -0,0 +1,105 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ctrattNVMSets is the NVM Sets support bit of the CTRATT field.
+const ctrattNVMSets = 1 << 2
+
+// NVMSet describes an NVM Set, as reported in the NVM Set List.
+type NVMSet struct {
+	ID                  uint16
+	EnduranceGroupID    uint16
+	RandomReadTypical   uint32   // Typical time to complete a 4 KiB random read, in microseconds
+	OptimalWriteSize    uint32   // Bytes
+	TotalCapacity       *big.Int // Bytes
+	UnallocatedCapacity *big.Int // Bytes
+}
+
+// NVMSetList is the decoded NVM Set List.
+type NVMSetList []NVMSet
+
+// Print outputs the NVM Set List in a pretty-print style.
+func (l NVMSetList) Print(w io.Writer) {
+	for _, s := range l {
+		fmt.Fprintf(w, msg(MsgNVMSet), s.ID, s.EnduranceGroupID, formatBigBytes(s.TotalCapacity),
+			formatBigBytes(s.UnallocatedCapacity), s.OptimalWriteSize, s.RandomReadTypical)
+	}
+}
+
+// GetNVMSets returns the NVM Sets of the NVM subsystem. The controller must support NVM Sets.
+func (d *NVMeDevice) GetNVMSets() (NVMSetList, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Ctratt&ctrattNVMSets == 0 {
+		return nil, fmt.Errorf("NVM sets: %w", ErrNotSupported)
+	}
+
+	var sets NVMSetList
+
+	// Each list contains up to 31 NVM Sets, with identifiers greater than or equal to that
+	// specified
+	for start := uint16(0); ; {
+		buf := make([]byte, 4096)
+
+		args := identifyArgs{cns: NVME_IDENTIFY_CNS_NVMSET_LIST, cnssi: start}
+		if err := d.identifyWith(args, buf); err != nil {
+			return nil, err
+		}
+
+		page := decodeNVMSetList(buf)
+		sets = append(sets, page...)
+
+		if len(page) < 31 || page[len(page)-1].ID == 0xffff {
+			return sets, nil
+		}
+
+		start = page[len(page)-1].ID + 1
+	}
+}
+
+func decodeNVMSetList(buf []byte) NVMSetList {
+	n := int(buf[0])
+	if n > 31 {
+		n = 31
+	}
+
+	l := make(NVMSetList, n)
+
+	for i := range l {
+		var raw nvmeNVMSetAttr
+
+		raw.unmarshal(buf[128*(i+1):])
+
+		l[i] = NVMSet{
+			ID:                  raw.NVMSetID,
+			EnduranceGroupID:    raw.EnduranceGroupID,
+			RandomReadTypical:   raw.RandomReadTypical,
+			OptimalWriteSize:    raw.OptimalWriteSize,
+			TotalCapacity:       le128ToBigInt(raw.TotalCapacity),
+			UnallocatedCapacity: le128ToBigInt(raw.UnallocCapacity),
+		}
+	}
+
+	return l
+}
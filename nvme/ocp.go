@@ -0,0 +1,556 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Log page identifiers defined by the OCP Datacenter NVMe SSD Specification. Since they are in
+// the vendor specific range, each log page contains a GUID which identifies it as the OCP log
+// page.
+const (
+	NVME_LOG_OCP_SMART           uint8 = 0xc0
+	NVME_LOG_OCP_LATENCY_MONITOR uint8 = 0xc3
+	NVME_LOG_OCP_DEVICE_CAPS     uint8 = 0xc4
+	NVME_LOG_OCP_UNSUPPORTED     uint8 = 0xc5
+	NVME_LOG_OCP_TELEMETRY_STR   uint8 = 0xc9
+)
+
+// Feature identifiers defined by the OCP Datacenter NVMe SSD Specification.
+const (
+	NVME_FEAT_OCP_LATENCY_MONITOR uint8 = 0xc5
+)
+
+// OCP log page GUIDs, in the byte order in which they appear in the log page.
+var (
+	ocpSMARTGUID = [16]byte{0xc5, 0xaf, 0x10, 0x28, 0xea, 0xbf, 0xf2, 0xa4, 0x9c, 0x4f, 0x6f, 0x7c,
+		0xc9, 0x14, 0xd5, 0xaf} // AFD514C9-7C6F-4F9C-A4F2-BFEA2810AFC5
+	ocpLatencyMonitorGUID = [16]byte{0x92, 0x7a, 0xc0, 0x8c, 0xd0, 0x84, 0x6c, 0x9c, 0x70, 0x43,
+		0xe6, 0xd4, 0x58, 0x5e, 0xd4, 0x85} // 85D45E58-D4E6-4370-9C6C-84D08CC07A92
+	ocpDeviceCapsGUID = [16]byte{0x97, 0x42, 0x05, 0x0d, 0xd1, 0xe1, 0xc9, 0x98, 0x5d, 0x49,
+		0x58, 0x4b, 0x91, 0x3c, 0x05, 0xb7} // B7053C91-4B58-495D-98C9-E1D10D054297
+	ocpUnsupportedGUID = [16]byte{0x2f, 0x72, 0x9c, 0x0e, 0x99, 0x23, 0x2c, 0xbb, 0x63, 0x48,
+		0x32, 0xd0, 0xb7, 0x98, 0xbb, 0xc7} // C7BB98B7-D032-4863-BB2C-23990E9C722F
+	ocpTelemetryStringsGUID = [16]byte{0x44, 0xaa, 0x57, 0x00, 0x94, 0x95, 0xa4, 0x9e, 0x8b, 0x40,
+		0x8f, 0x1a, 0x69, 0x83, 0x3a, 0xb1} // B13A8369-1A8F-408B-9EA4-95940057AA44
+)
+
+// checkOCPGUID returns ErrNotSupported if the GUID of a vendor specific log page does not match
+// that of the OCP log page, i.e. if the vendor uses the log identifier for a different log page.
+func checkOCPGUID(logID uint8, guid, want [16]byte) error {
+	if !bytes.Equal(guid[:], want[:]) {
+		return fmt.Errorf("log page %#02x is not the OCP log page: %w", logID, ErrNotSupported)
+	}
+
+	return nil
+}
+
+// OCPSMARTLog is the decoded OCP SMART / Health Information Extended log page (0xC0).
+type OCPSMARTLog struct {
+	PhysMediaUnitsWritten   *big.Int // Bytes
+	PhysMediaUnitsRead      *big.Int // Bytes
+	BadUserBlocks           uint64
+	BadUserBlocksNorm       uint16 // Percentage of spare user blocks remaining
+	BadSystemBlocks         uint64
+	BadSystemBlocksNorm     uint16 // Percentage of spare system blocks remaining
+	XORRecoveryCount        uint64
+	UncorrectableReadErrors uint64
+	SoftECCErrors           uint64
+	E2EDetectedErrors       uint32
+	E2ECorrectedErrors      uint32
+	SystemDataPercentUsed   uint8
+	RefreshCount            uint64
+	MaxEraseCount           uint32 // Maximum user data block erase count
+	MinEraseCount           uint32 // Minimum user data block erase count
+	ThermalThrottleEvents   uint8
+	ThermalThrottleStatus   uint8 // 0 unthrottled, 1 first level, 2 second level, 3 critical
+	PCIeCorrectableErrors   uint64
+	IncompleteShutdowns     uint32
+	PercentFreeBlocks       uint8
+	CapacitorHealth         uint16 // Percent
+	UnalignedIO             uint64
+	SecurityVersion         uint64
+	TotalNUSE               uint64 // Namespace utilization, in logical blocks
+	PLPStartCount           *big.Int
+	EnduranceEstimate       *big.Int // Bytes
+	PCIeLinkRetraining      uint64
+	PowerStateChanges       uint64
+	LogPageVersion          uint16
+}
+
+// Print outputs the OCP SMART log in a pretty-print style.
+func (l *OCPSMARTLog) Print(w io.Writer) {
+	fmt.Fprint(w, msg(MsgOCPSMARTHeader))
+	fmt.Fprintf(w, msg(MsgOCPSMARTMedia), formatBigBytes(l.PhysMediaUnitsWritten),
+		formatBigBytes(l.PhysMediaUnitsRead))
+	fmt.Fprintf(w, msg(MsgOCPSMARTBadBlocks), l.BadUserBlocks, l.BadUserBlocksNorm,
+		l.BadSystemBlocks, l.BadSystemBlocksNorm)
+	fmt.Fprintf(w, msg(MsgOCPSMARTErrors), l.XORRecoveryCount, l.UncorrectableReadErrors,
+		l.SoftECCErrors, l.E2EDetectedErrors, l.E2ECorrectedErrors, l.PCIeCorrectableErrors)
+	fmt.Fprintf(w, msg(MsgOCPSMARTWear), l.SystemDataPercentUsed, l.MinEraseCount,
+		l.MaxEraseCount, l.PercentFreeBlocks, l.CapacitorHealth)
+	fmt.Fprintf(w, msg(MsgOCPSMARTEvents), l.IncompleteShutdowns, l.ThermalThrottleEvents,
+		l.ThermalThrottleStatus, l.PLPStartCount, l.PCIeLinkRetraining)
+}
+
+// GetOCPSMARTLog reads the OCP SMART / Health Information Extended log page, which is
+// implemented by SSDs conforming to the OCP Datacenter NVMe SSD Specification.
+func (d *NVMeDevice) GetOCPSMARTLog() (*OCPSMARTLog, error) {
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_OCP_SMART, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeOCPSMARTLog(buf)
+}
+
+func decodeOCPSMARTLog(buf []byte) (*OCPSMARTLog, error) {
+	var raw nvmeOCPSMARTLog
+
+	raw.unmarshal(buf)
+
+	if err := checkOCPGUID(NVME_LOG_OCP_SMART, raw.LogPageGUID, ocpSMARTGUID); err != nil {
+		return nil, err
+	}
+
+	return raw.decode(), nil
+}
+
+// decode converts the raw OCP SMART log page into its decoded form.
+func (raw *nvmeOCPSMARTLog) decode() *OCPSMARTLog {
+	return &OCPSMARTLog{
+		PhysMediaUnitsWritten:   le128ToBigInt(raw.PhysUnitsWritten),
+		PhysMediaUnitsRead:      le128ToBigInt(raw.PhysUnitsRead),
+		BadUserBlocks:           leUint(raw.BadUserBlocks[:]),
+		BadUserBlocksNorm:       raw.BadUserBlocksNorm,
+		BadSystemBlocks:         leUint(raw.BadSysBlocks[:]),
+		BadSystemBlocksNorm:     raw.BadSysBlocksNorm,
+		XORRecoveryCount:        raw.XORRecovery,
+		UncorrectableReadErrors: raw.UncorrReadErrors,
+		SoftECCErrors:           raw.SoftECCErrors,
+		E2EDetectedErrors:       raw.E2EDetected,
+		E2ECorrectedErrors:      raw.E2ECorrected,
+		SystemDataPercentUsed:   raw.SysDataUsed,
+		RefreshCount:            leUint(raw.RefreshCounts[:]),
+		MaxEraseCount:           raw.MaxEraseCount,
+		MinEraseCount:           raw.MinEraseCount,
+		ThermalThrottleEvents:   raw.ThrottleCount,
+		ThermalThrottleStatus:   raw.ThrottleStatus,
+		PCIeCorrectableErrors:   raw.PCIeCorrErrors,
+		IncompleteShutdowns:     raw.IncompleteShutdn,
+		PercentFreeBlocks:       raw.FreeBlocks,
+		CapacitorHealth:         raw.CapacitorHealth,
+		UnalignedIO:             raw.UnalignedIO,
+		SecurityVersion:         raw.SecurityVersion,
+		TotalNUSE:               raw.TotalNUSE,
+		PLPStartCount:           le128ToBigInt(raw.PLPStartCount),
+		EnduranceEstimate:       le128ToBigInt(raw.EnduranceEst),
+		PCIeLinkRetraining:      raw.LinkRetraining,
+		PowerStateChanges:       raw.PowerStateChanges,
+		LogPageVersion:          raw.LogPageVersion,
+	}
+}
+
+// LatencyStats are the bucket counters and latency stamps of a latency monitor measurement period.
+// Each row is indexed by bucket (0 to 3), and each column by command type: read, write and
+// deallocate.
+type LatencyStats struct {
+	Buckets    [4][4]uint32    // Number of commands exceeding each bucket's threshold
+	Timestamps [4][3]time.Time // Time of the maximum latency recorded in each bucket
+	Latency    [4][3]uint16    // Maximum latency recorded in each bucket, in ms
+	StampUnits uint16          // Bit n set if the corresponding timestamp is in units of 1 ms
+}
+
+// LatencyMonitorLog is the decoded OCP Latency Monitor log page (0xC3).
+type LatencyMonitorLog struct {
+	FeatureStatus        uint8
+	BucketTimer          uint16   // Elapsed time of the active measurement period, in units of 5 minutes
+	BucketTimerThreshold uint16   // Length of a measurement period, in units of 5 minutes
+	Thresholds           [4]uint8 // Active bucket thresholds A to D
+	LatencyConfig        uint16
+	MinWindow            uint8
+	Active               LatencyStats // Current measurement period
+	Static               LatencyStats // Previous measurement period
+	DebugTriggerEnable   uint16
+	DebugLatency         uint16 // Measured latency which triggered the debug log, in ms
+	DebugTimestamp       time.Time
+	DebugPointer         uint16
+	DebugTriggerSource   uint16
+	LogPageVersion       uint16
+}
+
+// Print outputs the latency monitor log in a pretty-print style.
+func (l *LatencyMonitorLog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgLatencyMonitorHeader), l.FeatureStatus, l.BucketTimer,
+		l.BucketTimerThreshold, l.Thresholds)
+
+	for _, s := range []struct {
+		name  string
+		stats *LatencyStats
+	}{{"active", &l.Active}, {"static", &l.Static}} {
+		for i := range s.stats.Buckets {
+			fmt.Fprintf(w, msg(MsgLatencyMonitorBucket), s.name, i, s.stats.Buckets[i][0],
+				s.stats.Buckets[i][1], s.stats.Buckets[i][2], s.stats.Latency[i])
+		}
+	}
+
+	if l.DebugLatency != 0 {
+		fmt.Fprintf(w, msg(MsgLatencyMonitorDebug), l.DebugLatency,
+			l.DebugTimestamp.Format(time.RFC3339), l.DebugTriggerSource)
+	}
+}
+
+// GetLatencyMonitorLog reads the OCP Latency Monitor log page, which tracks the commands
+// exceeding configurable latency thresholds.
+func (d *NVMeDevice) GetLatencyMonitorLog() (*LatencyMonitorLog, error) {
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_OCP_LATENCY_MONITOR, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeLatencyMonitorLog(buf)
+}
+
+func decodeLatencyMonitorLog(buf []byte) (*LatencyMonitorLog, error) {
+	var raw nvmeOCPLatencyMonitorLog
+
+	raw.unmarshal(buf)
+
+	if err := checkOCPGUID(NVME_LOG_OCP_LATENCY_MONITOR, raw.LogPageGUID, ocpLatencyMonitorGUID); err != nil {
+		return nil, err
+	}
+
+	l := &LatencyMonitorLog{
+		FeatureStatus:        raw.FeatureStatus,
+		BucketTimer:          raw.BucketTimer,
+		BucketTimerThreshold: raw.BucketTimerThresh,
+		Thresholds:           raw.Thresholds,
+		LatencyConfig:        raw.LatencyConfig,
+		MinWindow:            raw.MinWindow,
+		Active: decodeLatencyStats(raw.ActiveBuckets, raw.ActiveStamps, raw.ActiveLatency,
+			raw.ActiveStampUnits),
+		Static: decodeLatencyStats(raw.StaticBuckets, raw.StaticStamps, raw.StaticLatency,
+			raw.StaticStampUnits),
+		DebugTriggerEnable: raw.DebugTriggerEn,
+		DebugLatency:       raw.DebugLatency,
+		DebugTimestamp:     decodeTimestamp(raw.DebugStamp),
+		DebugPointer:       raw.DebugPointer,
+		DebugTriggerSource: raw.DebugTriggerSrc,
+		LogPageVersion:     raw.LogPageVersion,
+	}
+
+	return l, nil
+}
+
+func decodeLatencyStats(buckets [16]uint32, stamps [12]uint64, latency [12]uint16, units uint16) LatencyStats {
+	s := LatencyStats{StampUnits: units}
+
+	for i := 0; i < 4; i++ {
+		copy(s.Buckets[i][:], buckets[4*i:])
+		copy(s.Latency[i][:], latency[3*i:])
+
+		for j := 0; j < 3; j++ {
+			s.Timestamps[i][j] = decodeTimestamp(stamps[3*i+j])
+		}
+	}
+
+	return s
+}
+
+// LatencyMonitorConfig is the OCP Latency Monitor feature (FID 0xC5).
+type LatencyMonitorConfig struct {
+	Enable               bool
+	BucketTimerThreshold uint16   // Length of a measurement period, in units of 5 minutes
+	Thresholds           [4]uint8 // Bucket thresholds A to D
+	LatencyConfig        uint16
+	MinWindow            uint8
+	DebugTriggerEnable   uint16 // Bucket and command type combinations which trigger a debug log
+	DiscardDebugLog      bool   // Discard the current debug log, re-arming the trigger
+}
+
+// SetLatencyMonitor configures the OCP latency monitor.
+func (d *NVMeDevice) SetLatencyMonitor(c LatencyMonitorConfig) error {
+	buf := make([]byte, 4096)
+
+	binary.LittleEndian.PutUint16(buf[0:], c.BucketTimerThreshold)
+	copy(buf[2:6], c.Thresholds[:])
+	binary.LittleEndian.PutUint16(buf[6:], c.LatencyConfig)
+	buf[8] = c.MinWindow
+	binary.LittleEndian.PutUint16(buf[9:], c.DebugTriggerEnable)
+	buf[11] = boolToUint8(c.DiscardDebugLog)
+	buf[12] = boolToUint8(c.Enable)
+
+	_, err := d.setFeature(NVME_FEAT_OCP_LATENCY_MONITOR, 0, false, 0, 0, buf)
+	return err
+}
+
+// OCPDeviceCapabilities is the decoded OCP Device Capabilities log page (0xC4). The command
+// support fields are bitmaps of the supported options, as defined by the OCP specification.
+type OCPDeviceCapabilities struct {
+	PCIePorts      uint16
+	OOBManagement  uint16 // Out-of-band management interfaces, e.g. bit 0 for NVMe-MI over SMBus
+	WriteZeroes    uint16
+	Sanitize       uint16
+	DatasetMgmt    uint16
+	WriteUncorr    uint16
+	FusedOperation uint16
+	MinPowerState  uint16    // Minimum valid DSSD power state
+	PowerStates    [127]byte // DSSD power state descriptors
+	LogPageVersion uint16
+}
+
+// Print outputs the device capabilities in a pretty-print style.
+func (c *OCPDeviceCapabilities) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgOCPDeviceCaps), c.PCIePorts, c.OOBManagement, c.WriteZeroes, c.Sanitize,
+		c.DatasetMgmt, c.WriteUncorr, c.FusedOperation, c.MinPowerState)
+}
+
+// GetOCPDeviceCapabilities reads the OCP Device Capabilities log page.
+func (d *NVMeDevice) GetOCPDeviceCapabilities() (*OCPDeviceCapabilities, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.getLogPage(NVME_LOG_OCP_DEVICE_CAPS, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeOCPDeviceCaps
+
+	raw.unmarshal(buf)
+
+	if err := checkOCPGUID(NVME_LOG_OCP_DEVICE_CAPS, raw.LogPageGUID, ocpDeviceCapsGUID); err != nil {
+		return nil, err
+	}
+
+	return &OCPDeviceCapabilities{
+		PCIePorts:      raw.PCIePorts,
+		OOBManagement:  raw.OOBMgmt,
+		WriteZeroes:    raw.WriteZeroes,
+		Sanitize:       raw.Sanitize,
+		DatasetMgmt:    raw.DSM,
+		WriteUncorr:    raw.WriteUncorr,
+		FusedOperation: raw.Fused,
+		MinPowerState:  raw.MinPowerState,
+		PowerStates:    raw.PowerStates,
+		LogPageVersion: raw.LogPageVersion,
+	}, nil
+}
+
+// GetOCPUnsupportedRequirements reads the OCP Unsupported Requirements log page (0xC5), returning
+// the identifiers of the OCP specification requirements which the device does not meet, e.g.
+// "PLP-1". An empty list indicates full compliance.
+func (d *NVMeDevice) GetOCPUnsupportedRequirements() ([]string, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.getLogPage(NVME_LOG_OCP_UNSUPPORTED, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeOCPUnsupportedRequirements(buf)
+}
+
+func decodeOCPUnsupportedRequirements(buf []byte) ([]string, error) {
+	var raw nvmeOCPUnsupportedReqs
+
+	raw.unmarshal(buf)
+
+	if err := checkOCPGUID(NVME_LOG_OCP_UNSUPPORTED, raw.LogPageGUID, ocpUnsupportedGUID); err != nil {
+		return nil, err
+	}
+
+	if int(raw.Count) > len(raw.Reqs)/16 {
+		return nil, fmt.Errorf("invalid number of unsupported requirements %d", raw.Count)
+	}
+
+	reqs := make([]string, raw.Count)
+	for i := range reqs {
+		reqs[i] = string(bytes.TrimRight(raw.Reqs[16*i:16*(i+1)], " \x00"))
+	}
+
+	return reqs, nil
+}
+
+// TelemetryEventID identifies a debug event of OCP telemetry data.
+type TelemetryEventID struct {
+	Class uint8  // Debug event class
+	ID    uint16 // Event identifier, unique within the class
+}
+
+// TelemetryStrings is the decoded OCP Telemetry String log page (0xC9), which translates the
+// identifiers found in the data areas of telemetry log pages to human-readable names.
+type TelemetryStrings struct {
+	LogPageVersion uint8
+	FIFONames      [16]string                  // Names of the event FIFOs 1 to 16
+	Statistics     map[uint16]string           // Vendor specific statistic identifiers
+	Events         map[TelemetryEventID]string // Debug events
+	VUEvents       map[TelemetryEventID]string // Vendor unique debug events
+}
+
+// StatisticName returns the name of a vendor specific statistic, or its identifier if unnamed.
+func (s *TelemetryStrings) StatisticName(id uint16) string {
+	if name, ok := s.Statistics[id]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("statistic %#04x", id)
+}
+
+// EventName returns the name of a debug event, or its class and identifier if unnamed. Vendor
+// unique events are looked up if not found among the standard events.
+func (s *TelemetryStrings) EventName(class uint8, id uint16) string {
+	e := TelemetryEventID{class, id}
+
+	if name, ok := s.Events[e]; ok {
+		return name
+	}
+	if name, ok := s.VUEvents[e]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("event %#02x/%#04x", class, id)
+}
+
+// GetTelemetryStrings reads the OCP Telemetry String log page.
+func (d *NVMeDevice) GetTelemetryStrings() (*TelemetryStrings, error) {
+	hdr := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_OCP_TELEMETRY_STR, 0xffffffff, false, hdr); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeOCPTelemetryStrings
+
+	raw.unmarshal(hdr)
+
+	if err := checkOCPGUID(NVME_LOG_OCP_TELEMETRY_STR, raw.LogPageGUID, ocpTelemetryStringsGUID); err != nil {
+		return nil, err
+	}
+
+	if raw.Sls*4 < uint64(len(hdr)) || raw.Sls*4 > 16<<20 {
+		return nil, fmt.Errorf("invalid telemetry string log size %d dwords", raw.Sls)
+	}
+
+	args := logPageArgs{nsid: 0xffffffff, uuidIndex: d.vendorUUIDIndex(NVME_LOG_OCP_TELEMETRY_STR)}
+
+	buf, err := d.readLog(NVME_LOG_OCP_TELEMETRY_STR, args, int(raw.Sls*4))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTelemetryStrings(buf)
+}
+
+func decodeTelemetryStrings(buf []byte) (*TelemetryStrings, error) {
+	var raw nvmeOCPTelemetryStrings
+
+	raw.unmarshal(buf)
+
+	if err := checkOCPGUID(NVME_LOG_OCP_TELEMETRY_STR, raw.LogPageGUID, ocpTelemetryStringsGUID); err != nil {
+		return nil, err
+	}
+
+	// table returns a table of the log page, located by its start and size in dwords
+	table := func(start, size uint64) ([]byte, error) {
+		if start*4+size*4 > uint64(len(buf)) {
+			return nil, fmt.Errorf("telemetry string table at dword %d exceeds log page", start)
+		}
+
+		return buf[start*4 : start*4+size*4], nil
+	}
+
+	ascii, err := table(raw.Ascts, raw.Asctsz)
+	if err != nil {
+		return nil, err
+	}
+
+	// str returns a string of the ASCII table, located by its offset in dwords and its zero-based
+	// length in bytes
+	str := func(e []byte) (string, error) {
+		off, n := binary.LittleEndian.Uint64(e[4:])*4, uint64(e[3])+1
+		if off+n > uint64(len(ascii)) {
+			return "", fmt.Errorf("telemetry string at offset %d exceeds ASCII table", off)
+		}
+
+		return string(bytes.TrimRight(ascii[off:off+n], " \x00")), nil
+	}
+
+	s := &TelemetryStrings{
+		LogPageVersion: raw.LogPageVersion,
+		Statistics:     make(map[uint16]string),
+		Events:         make(map[TelemetryEventID]string),
+		VUEvents:       make(map[TelemetryEventID]string),
+	}
+
+	for i := range s.FIFONames {
+		s.FIFONames[i] = string(bytes.TrimRight(raw.FIFONames[16*i:16*(i+1)], " \x00"))
+	}
+
+	stats, err := table(raw.Sits, raw.Sitsz)
+	if err != nil {
+		return nil, err
+	}
+
+	for off := 0; off+16 <= len(stats); off += 16 {
+		name, err := str(stats[off : off+16])
+		if err != nil {
+			return nil, err
+		}
+
+		s.Statistics[binary.LittleEndian.Uint16(stats[off:])] = name
+	}
+
+	for _, t := range []struct {
+		start, size uint64
+		m           map[TelemetryEventID]string
+	}{
+		{raw.Ests, raw.Estsz, s.Events},
+		{raw.Vuests, raw.Vuestsz, s.VUEvents},
+	} {
+		events, err := table(t.start, t.size)
+		if err != nil {
+			return nil, err
+		}
+
+		for off := 0; off+16 <= len(events); off += 16 {
+			name, err := str(events[off : off+16])
+			if err != nil {
+				return nil, err
+			}
+
+			t.m[TelemetryEventID{events[off], binary.LittleEndian.Uint16(events[off+1:])}] = name
+		}
+	}
+
+	return s, nil
+}
+
+// leUint decodes a little-endian unsigned integer of up to 8 bytes, e.g. the 48-bit counters of
+// vendor log pages.
+func leUint(b []byte) uint64 {
+	var v uint64
+
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+
+	return v
+}
@@ -0,0 +1,214 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Level 0 Discovery feature codes, cf. TCG Storage Architecture Core Specification 2.01, section
+// 3.3.6, and the respective SSC specifications.
+const (
+	opalFeatTPer        = 0x0001
+	opalFeatLocking     = 0x0002
+	opalFeatGeometry    = 0x0003
+	opalFeatEnterprise  = 0x0100
+	opalFeatOpalV1      = 0x0200
+	opalFeatSingleUser  = 0x0201
+	opalFeatDataStore   = 0x0202
+	opalFeatOpalV2      = 0x0203
+	opalFeatOpalite     = 0x0301
+	opalFeatPyriteV1    = 0x0302
+	opalFeatPyriteV2    = 0x0303
+	opalFeatRuby        = 0x0304
+	opalFeatBlockSID    = 0x0402
+	opalFeatNSLocking   = 0x0403
+	opalFeatDataRemoval = 0x0404
+)
+
+const (
+	opalLevel0ComID       = 0x0001 // ComID for Level 0 Discovery
+	opalLevel0HeaderLen   = 48     // Length of the Level 0 Discovery header
+	opalLevel0DiscoverLen = 2048   // Allocation length for the Level 0 Discovery response
+)
+
+// Locking feature flags.
+const (
+	opalLockingSupported = 1 << 0
+	opalLockingEnabled   = 1 << 1
+	opalLocked           = 1 << 2
+	opalMediaEncryption  = 1 << 3
+	opalMBREnabled       = 1 << 4
+	opalMBRDone          = 1 << 5
+	opalMBRNotSupported  = 1 << 6
+)
+
+// OpalSSC describes a Security Subsystem Class reported by Level 0 Discovery.
+type OpalSSC struct {
+	Code      uint16
+	Name      string
+	BaseComID uint16
+	NumComIDs uint16
+}
+
+// OpalDiscovery is the decoded TCG Level 0 Discovery response, i.e. the information reported by
+// `sedutil-cli --query`.
+type OpalDiscovery struct {
+	TPer            bool // TPer feature present
+	LockingFeature  bool // Locking feature present
+	LockingSupport  bool
+	LockingEnabled  bool
+	Locked          bool
+	MediaEncryption bool
+	MBRShadowing    bool // MBR shadowing supported
+	MBREnabled      bool
+	MBRDone         bool
+	SSCs            []OpalSSC
+	Features        []uint16 // Codes of all reported features
+}
+
+// opalSSCNames maps the feature codes of Security Subsystem Classes to their names.
+var opalSSCNames = map[uint16]string{
+	opalFeatEnterprise: "Enterprise",
+	opalFeatOpalV1:     "Opal 1.0",
+	opalFeatOpalV2:     "Opal 2.0",
+	opalFeatOpalite:    "Opalite",
+	opalFeatPyriteV1:   "Pyrite 1.0",
+	opalFeatPyriteV2:   "Pyrite 2.0",
+	opalFeatRuby:       "Ruby",
+}
+
+// hasSSC returns true if any of the specified SSCs was reported.
+func (o *OpalDiscovery) hasSSC(codes ...uint16) bool {
+	for _, ssc := range o.SSCs {
+		for _, c := range codes {
+			if ssc.Code == c {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Opal2 returns true if the drive supports the Opal 2.0 SSC.
+func (o *OpalDiscovery) Opal2() bool {
+	return o.hasSSC(opalFeatOpalV2)
+}
+
+// Pyrite returns true if the drive supports any version of the Pyrite SSC.
+func (o *OpalDiscovery) Pyrite() bool {
+	return o.hasSSC(opalFeatPyriteV1, opalFeatPyriteV2)
+}
+
+// Ruby returns true if the drive supports the Ruby SSC.
+func (o *OpalDiscovery) Ruby() bool {
+	return o.hasSSC(opalFeatRuby)
+}
+
+// BaseComID returns the base ComID of the first reported SSC, which is used to open sessions, or
+// zero if no SSC was reported.
+func (o *OpalDiscovery) BaseComID() uint16 {
+	if len(o.SSCs) == 0 {
+		return 0
+	}
+
+	return o.SSCs[0].BaseComID
+}
+
+// Print outputs the discovery response in a pretty-print style.
+func (o *OpalDiscovery) Print(w io.Writer) {
+	for _, ssc := range o.SSCs {
+		fmt.Fprintf(w, msg(MsgOpalSSC), ssc.Name, ssc.BaseComID, ssc.NumComIDs)
+	}
+
+	fmt.Fprintf(w, msg(MsgOpalLockingSupported), o.LockingSupport)
+	fmt.Fprintf(w, msg(MsgOpalLockingEnabled), o.LockingEnabled)
+	fmt.Fprintf(w, msg(MsgOpalLocked), o.Locked)
+	fmt.Fprintf(w, msg(MsgOpalMediaEncryption), o.MediaEncryption)
+	fmt.Fprintf(w, msg(MsgOpalMBRShadowing), o.MBRShadowing)
+	fmt.Fprintf(w, msg(MsgOpalMBREnabled), o.MBREnabled)
+	fmt.Fprintf(w, msg(MsgOpalMBRDone), o.MBRDone)
+}
+
+// OpalDiscovery performs TCG Level 0 Discovery, reporting the security subsystem classes
+// supported by the drive and its locking state.
+func (d *NVMeDevice) OpalDiscovery() (*OpalDiscovery, error) {
+	buf := make([]byte, opalLevel0DiscoverLen)
+
+	if err := d.SecurityReceive(0, SecurityProtocolTCG1, opalLevel0ComID, 0, buf); err != nil {
+		return nil, err
+	}
+
+	return parseLevel0Discovery(buf)
+}
+
+// parseLevel0Discovery decodes a Level 0 Discovery response. Unlike NVMe data structures, TCG
+// data structures are big-endian.
+func parseLevel0Discovery(buf []byte) (*OpalDiscovery, error) {
+	if len(buf) < opalLevel0HeaderLen {
+		return nil, fmt.Errorf("level 0 discovery response too short")
+	}
+
+	// The length of parameter data excludes the length field itself
+	end := int(binary.BigEndian.Uint32(buf[0:4])) + 4
+	if end < opalLevel0HeaderLen {
+		return nil, fmt.Errorf("invalid level 0 discovery length %d", end-4)
+	} else if end > len(buf) {
+		end = len(buf)
+	}
+
+	o := &OpalDiscovery{}
+
+	for off := opalLevel0HeaderLen; off+4 <= end; {
+		code := binary.BigEndian.Uint16(buf[off:])
+		data := buf[off+4:]
+
+		n := int(buf[off+3])
+		if off+4+n > end {
+			return nil, fmt.Errorf("feature %#04x exceeds level 0 discovery response", code)
+		}
+		data = data[:n]
+
+		o.Features = append(o.Features, code)
+
+		switch {
+		case code == opalFeatTPer:
+			o.TPer = true
+		case code == opalFeatLocking && n > 0:
+			o.LockingFeature = true
+			o.LockingSupport = data[0]&opalLockingSupported != 0
+			o.LockingEnabled = data[0]&opalLockingEnabled != 0
+			o.Locked = data[0]&opalLocked != 0
+			o.MediaEncryption = data[0]&opalMediaEncryption != 0
+			o.MBREnabled = data[0]&opalMBREnabled != 0
+			o.MBRDone = data[0]&opalMBRDone != 0
+			o.MBRShadowing = data[0]&opalMBRNotSupported == 0
+		case opalSSCNames[code] != "" && n >= 4:
+			o.SSCs = append(o.SSCs, OpalSSC{
+				Code:      code,
+				Name:      opalSSCNames[code],
+				BaseComID: binary.BigEndian.Uint16(data[0:]),
+				NumComIDs: binary.BigEndian.Uint16(data[2:]),
+			})
+		}
+
+		off += 4 + n
+	}
+
+	return o, nil
+}
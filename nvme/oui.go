@@ -0,0 +1,37 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+// ouiVendors maps the IEEE OUI identifiers commonly reported by NVMe controllers to the names of
+// their vendors. It is not exhaustive.
+var ouiVendors = map[uint32]string{
+	0x00080d: "Toshiba",
+	0x000c50: "Seagate Technology",
+	0x000cca: "HGST",
+	0x0014ee: "Western Digital",
+	0x001b44: "SanDisk",
+	0x002538: "Samsung Electronics",
+	0x00a075: "Micron Technology",
+	0x5cd2e4: "Intel Corporation",
+	0x6479a7: "Phison Electronics",
+	0x8ce38e: "Kioxia",
+	0xace42e: "SK hynix",
+}
+
+// OUIVendor returns the name of the vendor to which an IEEE OUI identifier is assigned, or an
+// empty string if it is not known.
+func OUIVendor(oui uint32) string {
+	return ouiVendors[oui]
+}
@@ -0,0 +1,143 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// The Parse functions decode raw data structures previously saved from a device, e.g. with
+// DumpIdentify, DumpLogPage or CaptureHostTelemetry, or with nvme-cli's --raw-binary option, so
+// that they can be analyzed without access to the device.
+
+// NVMeNamespace encapsulates the attributes of an NVMe namespace.
+type NVMeNamespace struct {
+	NSID           uint32
+	Size           uint64 // In logical blocks
+	Capacity       uint64 // In logical blocks
+	Utilization    uint64 // In logical blocks
+	LBASize        uint   // Logical block size of the current LBA format, in bytes
+	WriteProtected bool
+}
+
+// Print outputs the attributes of an NVMe namespace in a pretty-print style.
+func (n *NVMeNamespace) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgNsSize), n.NSID, n.Size)
+	fmt.Fprintf(w, msg(MsgNsUtilisation), n.NSID, n.Utilization)
+	fmt.Fprintf(w, msg(MsgNsWriteProtected), n.NSID, n.WriteProtected)
+}
+
+// checkParseLen returns an error if a raw data structure is shorter than its defined size.
+func checkParseLen(name string, buf []byte, n int) error {
+	if len(buf) < n {
+		return fmt.Errorf("%s truncated to %d bytes, expected %d", name, len(buf), n)
+	}
+
+	return nil
+}
+
+// ParseIdentifyController decodes a raw 4096-byte Identify Controller data structure.
+func ParseIdentifyController(buf []byte) (NVMeController, error) {
+	if err := checkParseLen("identify controller data", buf, 4096); err != nil {
+		return NVMeController{}, err
+	}
+
+	var idCtrlr nvmeIdentController
+
+	idCtrlr.unmarshal(buf)
+
+	return idCtrlr.decode(), nil
+}
+
+// ParseIdentifyNamespace decodes a raw 4096-byte Identify Namespace data structure of the
+// specified namespace. The NSID is not part of the data structure, and is only used for reporting.
+func ParseIdentifyNamespace(buf []byte, nsid uint32) (NVMeNamespace, error) {
+	if err := checkParseLen("identify namespace data", buf, 4096); err != nil {
+		return NVMeNamespace{}, err
+	}
+
+	var ns nvmeIdentNamespace
+
+	ns.unmarshal(buf)
+
+	return ns.decode(nsid), nil
+}
+
+// decode converts the raw identify namespace data into an NVMeNamespace.
+func (ns *nvmeIdentNamespace) decode(nsid uint32) NVMeNamespace {
+	_, lbaf := ns.currentLBAF()
+
+	return NVMeNamespace{
+		NSID:           nsid,
+		Size:           ns.Nsze,
+		Capacity:       ns.Ncap,
+		Utilization:    ns.Nuse,
+		LBASize:        1 << lbaf.Ds,
+		WriteProtected: ns.Nsattr&1 != 0,
+	}
+}
+
+// currentLBAF returns the index and format of the namespace's current LBA format. The index is
+// formed by bits 3:0 of FLBAS, extended by bits 6:5 if more than 16 LBA formats are supported.
+func (ns *nvmeIdentNamespace) currentLBAF() (int, nvmeLBAF) {
+	idx := int(ns.Flbas & 0xf)
+
+	if ns.Nlbaf >= 16 { // 0's based
+		idx |= int(ns.Flbas>>5&0x3) << 4
+	}
+
+	return idx, ns.Lbaf[idx]
+}
+
+// ParseSMARTLog decodes a raw 512-byte SMART / Health Information log page.
+func ParseSMARTLog(buf []byte) (*SMARTLog, error) {
+	if err := checkParseLen("SMART log page", buf, 512); err != nil {
+		return nil, err
+	}
+
+	var sl nvmeSMARTLog
+
+	sl.unmarshal(buf)
+
+	return sl.decode(), nil
+}
+
+// ParseErrorLog decodes a raw Error Information log page, which may contain any number of 64-byte
+// entries.
+func ParseErrorLog(buf []byte) (ErrorLog, error) {
+	if len(buf) == 0 || len(buf)%64 != 0 {
+		return nil, fmt.Errorf("error log page length %d is not a multiple of 64 bytes", len(buf))
+	}
+
+	return decodeErrorLog(buf), nil
+}
+
+// ParseTelemetryHeader decodes the header of a raw telemetry log page. Since telemetry data may be
+// saved up to any data area, the presence of the data areas is not checked; the length of the
+// data up to a given area is returned by TelemetryHeader.Size.
+func ParseTelemetryHeader(buf []byte) (*TelemetryHeader, error) {
+	if err := checkParseLen("telemetry log page", buf, telemetryBlockSize); err != nil {
+		return nil, err
+	}
+
+	h := decodeTelemetryHeader(buf)
+
+	if h.LogID != NVME_LOG_TELEMETRY_HOST && h.LogID != NVME_LOG_TELEMETRY_CTRL {
+		return nil, fmt.Errorf("invalid telemetry log identifier %#02x", h.LogID)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// PassthruCommand is an arbitrary command for SubmitAdmin or SubmitIO, with the fields of
+// nvme-cli's admin-passthru and io-passthru. The direction of the data transfer of a standard
+// command is determined by bits 1:0 of the opcode; vendor specific commands may use them freely.
+type PassthruCommand struct {
+	Opcode   uint8
+	Flags    uint8
+	NSID     uint32
+	CDW2     uint32
+	CDW3     uint32
+	CDW10    uint32
+	CDW11    uint32
+	CDW12    uint32
+	CDW13    uint32
+	CDW14    uint32
+	CDW15    uint32
+	Data     []byte        // Data buffer, nil if the command transfers no data
+	Metadata []byte        // Metadata buffer, nil if the command transfers no metadata
+	Timeout  time.Duration // Zero for the kernel's default timeout
+}
+
+// Lowest vendor specific admin and I/O command opcodes.
+const (
+	adminVendorOpcodes = 0xc0
+	ioVendorOpcodes    = 0x80
+)
+
+// encode checks the command and returns the equivalent kernel passthru command. Opcodes from
+// vendorOpcodes upwards are vendor specific.
+func (c *PassthruCommand) encode(vendorOpcodes uint8) (nvmePassthruCommand, error) {
+	cmd := nvmePassthruCommand{
+		opcode: c.Opcode,
+		flags:  c.Flags,
+		nsid:   c.NSID,
+		cdw2:   c.CDW2,
+		cdw3:   c.CDW3,
+		cdw10:  c.CDW10,
+		cdw11:  c.CDW11,
+		cdw12:  c.CDW12,
+		cdw13:  c.CDW13,
+		cdw14:  c.CDW14,
+		cdw15:  c.CDW15,
+	}
+
+	// Standard opcodes with bits 1:0 cleared transfer no data, and the controller would ignore the
+	// buffers
+	if c.Opcode < vendorOpcodes && c.Opcode&0x3 == 0 && (len(c.Data) > 0 || len(c.Metadata) > 0) {
+		return cmd, fmt.Errorf("opcode %#02x does not transfer data", c.Opcode)
+	}
+
+	if uint64(len(c.Data)) > math.MaxUint32 || uint64(len(c.Metadata)) > math.MaxUint32 {
+		return cmd, fmt.Errorf("buffer too large")
+	}
+
+	if c.Timeout < 0 || c.Timeout.Milliseconds() > math.MaxUint32 {
+		return cmd, fmt.Errorf("invalid timeout %s", c.Timeout)
+	}
+
+	if len(c.Data) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&c.Data[0])))
+		cmd.data_len = uint32(len(c.Data))
+	}
+
+	if len(c.Metadata) > 0 {
+		cmd.metadata = uint64(uintptr(unsafe.Pointer(&c.Metadata[0])))
+		cmd.metadata_len = uint32(len(c.Metadata))
+	}
+
+	cmd.timeout_ms = uint32(c.Timeout.Milliseconds())
+
+	return cmd, nil
+}
+
+// SubmitAdmin submits an arbitrary admin command, e.g. a vendor specific command, returning
+// completion dword 0. If the controller completes the command with a non-zero status, it is
+// returned as an NVMeStatus error, together with completion dword 0.
+//
+// No checks are made that the command is safe to issue; vendor specific commands in particular
+// may modify or destroy data.
+func (d *NVMeDevice) SubmitAdmin(c *PassthruCommand) (uint32, error) {
+	cmd, err := c.encode(adminVendorOpcodes)
+	if err != nil {
+		return 0, err
+	}
+
+	err = d.adminCmd(&cmd, c.Data)
+
+	// The buffers are referenced only by address in the command
+	runtime.KeepAlive(c)
+
+	return cmd.result, err
+}
+
+// SubmitIO submits an arbitrary I/O command, e.g. a read, write, flush or dataset management
+// command, returning completion dword 0. The device should be a namespace block device or
+// namespace generic character device, and NSID should be that of the namespace. If the controller
+// completes the command with a non-zero status, it is returned as an NVMeStatus error, together
+// with completion dword 0.
+//
+// No checks are made that the command is safe to issue; write and dataset management commands in
+// particular may modify or destroy data.
+func (d *NVMeDevice) SubmitIO(c *PassthruCommand) (uint32, error) {
+	cmd, err := c.encode(ioVendorOpcodes)
+	if err != nil {
+		return 0, err
+	}
+
+	err = d.ioCmd(&cmd, c.Data)
+
+	// The buffers are referenced only by address in the command
+	runtime.KeepAlive(c)
+
+	return cmd.result, err
+}
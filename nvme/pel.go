@@ -0,0 +1,384 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// lpaPersistentEvent is the Persistent Event log support bit of the LPA field.
+const lpaPersistentEvent = 1 << 4
+
+// Persistent Event log Log Specific Field (LSP) actions.
+const (
+	pelReadLog      = 0x0 // Read Log Data
+	pelEstablishCtx = 0x1 // Establish Context and Read Log Data
+	pelReleaseCtx   = 0x2 // Release Context
+)
+
+const (
+	pelHeaderLength   = 512 // Minimum length of the log header
+	pelEventHdrLength = 24  // Minimum length of an event header
+)
+
+// Persistent event types.
+const (
+	PELSMARTSnapshot      uint8 = 0x01
+	PELFirmwareCommit     uint8 = 0x02
+	PELTimestampChange    uint8 = 0x03
+	PELPowerOnReset       uint8 = 0x04
+	PELHardwareError      uint8 = 0x05
+	PELChangeNamespace    uint8 = 0x06
+	PELFormatStart        uint8 = 0x07
+	PELFormatCompletion   uint8 = 0x08
+	PELSanitizeStart      uint8 = 0x09
+	PELSanitizeCompletion uint8 = 0x0a
+	PELSetFeature         uint8 = 0x0b
+	PELTelemetryCreated   uint8 = 0x0c
+	PELThermalExcursion   uint8 = 0x0d
+	PELVendorSpecific     uint8 = 0xde
+	PELTCGDefined         uint8 = 0xdf
+)
+
+var pelEventNames = map[uint8]string{
+	PELSMARTSnapshot:      "SMART / health log snapshot",
+	PELFirmwareCommit:     "firmware commit",
+	PELTimestampChange:    "timestamp change",
+	PELPowerOnReset:       "power-on or reset",
+	PELHardwareError:      "NVM subsystem hardware error",
+	PELChangeNamespace:    "change namespace",
+	PELFormatStart:        "format NVM start",
+	PELFormatCompletion:   "format NVM completion",
+	PELSanitizeStart:      "sanitize start",
+	PELSanitizeCompletion: "sanitize completion",
+	PELSetFeature:         "set feature",
+	PELTelemetryCreated:   "telemetry log create",
+	PELThermalExcursion:   "thermal excursion",
+	PELVendorSpecific:     "vendor specific",
+	PELTCGDefined:         "TCG defined",
+}
+
+// PersistentEvent is an event from the Persistent Event log page.
+type PersistentEvent struct {
+	Type         uint8
+	Revision     uint8
+	ControllerID uint16
+	Timestamp    time.Time
+	VendorInfo   []byte // Vendor specific information, if any
+	Data         []byte // Raw event data, excluding the vendor specific information
+	// Decoded event data for the common event types, i.e. one of *SMARTLog,
+	// *PELFirmwareCommitEvent, *PELTimestampChangeEvent, *PELPowerOnResetEvent,
+	// *PELSanitizeStartEvent, *PELSanitizeCompletionEvent or *PELThermalExcursionEvent. It is nil
+	// for other event types.
+	Detail interface{}
+}
+
+// TypeName returns a human-readable name of the event type.
+func (e *PersistentEvent) TypeName() string {
+	if name, ok := pelEventNames[e.Type]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%#02x)", e.Type)
+}
+
+// PELFirmwareCommitEvent is the data of a firmware commit event.
+type PELFirmwareCommitEvent struct {
+	OldRevision string
+	NewRevision string
+	Action      FirmwareCommitAction
+	Slot        uint8
+}
+
+// PELTimestampChangeEvent is the data of a timestamp change event.
+type PELTimestampChangeEvent struct {
+	Previous         time.Time // Timestamp prior to the change
+	SinceResetMillis uint64    // Milliseconds since the last controller reset
+}
+
+// PELPowerOnResetEvent is the data of a power-on or reset event, with the state of each
+// controller in the NVM subsystem.
+type PELPowerOnResetEvent struct {
+	FirmwareRevision string
+	Controllers      []PELControllerReset
+}
+
+// PELControllerReset describes the power-on or reset of a controller.
+type PELControllerReset struct {
+	ControllerID       uint16
+	FirmwareActivation bool   // A firmware activation occurred as a result of the reset
+	OperationActive    bool   // A sanitize or format operation was in progress
+	PowerCycle         uint32 // Controller power cycle count
+	PowerOnMillis      uint64 // Milliseconds since the controller was powered on
+	Timestamp          time.Time
+}
+
+// PELSanitizeStartEvent is the data of a sanitize start event.
+type PELSanitizeStartEvent struct {
+	Capabilities uint32 // SANICAP field of the identify controller data structure
+	CDW10        uint32 // Command dword 10 of the Sanitize command
+	CDW11        uint32 // Command dword 11 of the Sanitize command
+}
+
+// PELSanitizeCompletionEvent is the data of a sanitize completion event.
+type PELSanitizeCompletionEvent struct {
+	Progress uint16 // Sanitize Progress (SPROG)
+	Status   uint16 // Sanitize Status (SSTAT)
+	Info     uint16 // Completion Information
+}
+
+// PELThermalExcursionEvent is the data of a thermal excursion event.
+type PELThermalExcursionEvent struct {
+	OverTemperature uint8 // Degrees above the threshold
+	Threshold       uint8 // Threshold that was exceeded
+}
+
+// PersistentEventLog is the decoded Persistent Event log page (0x0D).
+type PersistentEventLog struct {
+	Revision        uint8
+	Timestamp       time.Time
+	PowerCycleCount uint64
+	VendorID        uint16
+	SerialNumber    string
+	ModelNumber     string
+	SubsystemNQN    string
+	Generation      uint16 // Incremented when the log page is cleared
+	SupportedEvents [32]byte
+	Events          []PersistentEvent
+}
+
+// Print outputs the persistent event log in a pretty-print style.
+func (l *PersistentEventLog) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgPELHeader), l.Generation, l.ModelNumber, l.SerialNumber, len(l.Events))
+
+	for _, e := range l.Events {
+		fmt.Fprintf(w, msg(MsgPELEvent), e.Timestamp.Format(time.RFC3339), e.ControllerID,
+			e.TypeName(), len(e.Data))
+
+		if e.Detail != nil {
+			if sl, ok := e.Detail.(*SMARTLog); ok {
+				sl.Print(w)
+			} else {
+				fmt.Fprintf(w, "    %+v\n", e.Detail)
+			}
+		}
+	}
+}
+
+// GetPersistentEventLog reads the Persistent Event log page. A reporting context is established
+// to obtain a consistent snapshot of the log, which is read in chunks and released afterwards.
+func (d *NVMeDevice) GetPersistentEventLog() (*PersistentEventLog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Lpa&lpaPersistentEvent == 0 {
+		return nil, fmt.Errorf("persistent event log: %w", ErrNotSupported)
+	}
+
+	args := logPageArgs{nsid: 0xffffffff, lsp: pelEstablishCtx}
+	hdr := make([]byte, pelHeaderLength)
+
+	if err := d.getLog(NVME_LOG_PERSISTENT_EVENT, args, hdr); err != nil {
+		return nil, err
+	}
+
+	// Release the context even if reading the events fails, so that the controller may resume
+	// updating the log
+	defer func() {
+		args := logPageArgs{nsid: 0xffffffff, lsp: pelReleaseCtx}
+		d.getLog(NVME_LOG_PERSISTENT_EVENT, args, make([]byte, pelHeaderLength))
+	}()
+
+	var h nvmePersistentEventHeader
+
+	h.unmarshal(hdr)
+
+	if h.Tll < pelHeaderLength || (idCtrlr.Pels != 0 && h.Tll > uint64(idCtrlr.Pels)*64*1024) {
+		return nil, fmt.Errorf("invalid persistent event log length %d", h.Tll)
+	}
+
+	args.lsp = pelReadLog
+
+	buf, err := d.readLog(NVME_LOG_PERSISTENT_EVENT, args, int(h.Tll))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePersistentEventLog(buf)
+}
+
+func decodePersistentEventLog(buf []byte) (*PersistentEventLog, error) {
+	if len(buf) < pelHeaderLength {
+		return nil, fmt.Errorf("persistent event log truncated")
+	}
+
+	var h nvmePersistentEventHeader
+
+	h.unmarshal(buf)
+
+	l := &PersistentEventLog{
+		Revision:        h.Rv,
+		Timestamp:       decodeTimestamp(h.Ts),
+		PowerCycleCount: h.Pcc,
+		VendorID:        h.Vid,
+		SerialNumber:    string(bytes.TrimSpace(h.Sn[:])),
+		ModelNumber:     string(bytes.TrimSpace(h.Mn[:])),
+		SubsystemNQN:    string(bytes.TrimRight(h.Subnqn[:], "\x00")),
+		Generation:      h.Gen,
+		SupportedEvents: h.Seb,
+	}
+
+	off := pelHeaderLength
+	if int(h.Lhl)+17 > off {
+		off = int(h.Lhl) + 17 // Header length excludes the first 17 bytes
+	}
+
+	for i := 0; i < int(h.Tnev); i++ {
+		if off+pelEventHdrLength > len(buf) {
+			return nil, fmt.Errorf("persistent event log truncated at event %d", i)
+		}
+
+		var eh nvmePersistentEventEntry
+
+		eh.unmarshal(buf[off:])
+
+		// Event header length excludes the first 3 bytes
+		start := off + int(eh.Ehl) + 3
+		end := start + int(eh.El)
+
+		if end > len(buf) || eh.Vsil > eh.El {
+			return nil, fmt.Errorf("persistent event log truncated in event %d", i)
+		}
+
+		e := PersistentEvent{
+			Type:         eh.Etype,
+			Revision:     eh.Etrev,
+			ControllerID: eh.Cntlid,
+			Timestamp:    decodeTimestamp(eh.Ets),
+			Data:         buf[start+int(eh.Vsil) : end],
+		}
+
+		if eh.Vsil > 0 {
+			e.VendorInfo = buf[start : start+int(eh.Vsil)]
+		}
+
+		e.Detail = decodePersistentEventData(e.Type, e.Data)
+
+		l.Events = append(l.Events, e)
+		off = end
+	}
+
+	return l, nil
+}
+
+// decodePersistentEventData decodes the data of the common persistent event types, returning nil
+// for other types, or if the data is too short.
+func decodePersistentEventData(etype uint8, data []byte) interface{} {
+	switch etype {
+	case PELSMARTSnapshot:
+		if len(data) >= 512 {
+			var sl nvmeSMARTLog
+
+			sl.unmarshal(data)
+			return sl.decode()
+		}
+	case PELFirmwareCommit:
+		if len(data) >= 18 {
+			var ev nvmePELFirmwareCommit
+
+			ev.unmarshal(data)
+			return &PELFirmwareCommitEvent{
+				OldRevision: string(bytes.TrimSpace(ev.OldFrs[:])),
+				NewRevision: string(bytes.TrimSpace(ev.NewFrs[:])),
+				Action:      FirmwareCommitAction(ev.Fca),
+				Slot:        ev.Fs,
+			}
+		}
+	case PELTimestampChange:
+		if len(data) >= 16 {
+			var ev nvmePELTimestampChange
+
+			ev.unmarshal(data)
+			return &PELTimestampChangeEvent{
+				Previous:         decodeTimestamp(ev.PrevTs),
+				SinceResetMillis: ev.Mssr,
+			}
+		}
+	case PELPowerOnReset:
+		if len(data) >= 8 {
+			ev := &PELPowerOnResetEvent{FirmwareRevision: string(bytes.TrimSpace(data[0:8]))}
+
+			for off := 8; off+36 <= len(data); off += 36 {
+				var desc nvmePELControllerReset
+
+				desc.unmarshal(data[off:])
+				ev.Controllers = append(ev.Controllers, PELControllerReset{
+					ControllerID:       desc.Cntlid,
+					FirmwareActivation: desc.Fa&1 != 0,
+					OperationActive:    desc.Oi&1 != 0,
+					PowerCycle:         desc.Ctrlpc,
+					PowerOnMillis:      desc.Pwrontime,
+					Timestamp:          decodeTimestamp(desc.Ctrltimestamp),
+				})
+			}
+
+			return ev
+		}
+	case PELSanitizeStart:
+		if len(data) >= 12 {
+			var ev nvmePELSanitizeStart
+
+			ev.unmarshal(data)
+			return &PELSanitizeStartEvent{
+				Capabilities: ev.Sanicap,
+				CDW10:        ev.Cdw10,
+				CDW11:        ev.Cdw11,
+			}
+		}
+	case PELSanitizeCompletion:
+		if len(data) >= 6 {
+			var ev nvmePELSanitizeCompletion
+
+			ev.unmarshal(data)
+			return &PELSanitizeCompletionEvent{
+				Progress: ev.Sprog,
+				Status:   ev.Sstat,
+				Info:     ev.Cmpinfo,
+			}
+		}
+	case PELThermalExcursion:
+		if len(data) >= 2 {
+			return &PELThermalExcursionEvent{OverTemperature: data[0], Threshold: data[1]}
+		}
+	}
+
+	return nil
+}
+
+// decodeTimestamp converts an NVMe timestamp, i.e. milliseconds since the Unix epoch in bits
+// 47:0, to a time.Time. A zero timestamp is returned as the zero time.
+func decodeTimestamp(ts uint64) time.Time {
+	ms := int64(ts & (1<<48 - 1))
+	if ms == 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms).UTC()
+}
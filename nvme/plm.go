@@ -0,0 +1,214 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ctrattPLM is the Predictable Latency Mode support bit of the CTRATT field.
+const ctrattPLM = 1 << 5
+
+// PLMWindow is a Predictable Latency Mode window of an NVM Set.
+type PLMWindow uint8
+
+const (
+	PLMWindowDeterministic    PLMWindow = 1 // Deterministic Window (DTWIN)
+	PLMWindowNonDeterministic PLMWindow = 2 // Non-Deterministic Window (NDWIN)
+)
+
+func (w PLMWindow) String() string {
+	switch w {
+	case 0:
+		return "not used"
+	case PLMWindowDeterministic:
+		return "DTWIN"
+	case PLMWindowNonDeterministic:
+		return "NDWIN"
+	}
+
+	return fmt.Sprintf("unknown (%d)", uint8(w))
+}
+
+// Predictable latency event bits, as reported in PredictableLatency.Events and enabled with
+// PLMConfig.EnableEvents.
+const (
+	PLMEventDTWINReadsWarning  = 1 << 0  // DTWIN Reads Warning
+	PLMEventDTWINWritesWarning = 1 << 1  // DTWIN Writes Warning
+	PLMEventDTWINTimeWarning   = 1 << 2  // DTWIN Time Warning
+	PLMEventTypicalExceeded    = 1 << 14 // Autonomous transition from DTWIN to NDWIN due to typical or maximum value exceeded
+	PLMEventExcursion          = 1 << 15 // Autonomous transition from DTWIN to NDWIN due to deterministic excursion
+)
+
+// PredictableLatency is the decoded Predictable Latency Per NVM Set log page (0x0A).
+type PredictableLatency struct {
+	NVMSetID uint16
+	Window   PLMWindow // Current window, or 0 if predictable latency mode is not enabled
+	Events   uint16    // Events that have occurred, see PLMEvent*
+
+	DTWINReadsTypical  uint64 // Typical number of 4 KiB random reads in a DTWIN
+	DTWINWritesTypical uint64 // Typical number of writes in a DTWIN, in units of optimal write size
+	DTWINTimeMax       uint64 // Maximum time in a DTWIN, in milliseconds
+	NDWINTimeMinHigh   uint64 // Minimum time in an NDWIN under high host load, in milliseconds
+	NDWINTimeMinLow    uint64 // Minimum time in an NDWIN under low host load, in milliseconds
+
+	// Estimates of the reads, writes and time remaining in the current DTWIN
+	DTWINReadsEstimate  uint64
+	DTWINWritesEstimate uint64
+	DTWINTimeEstimate   uint64
+}
+
+// Print outputs the predictable latency log in a pretty-print style.
+func (l *PredictableLatency) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgPLMHeader), l.NVMSetID, l.Window, l.Events)
+	fmt.Fprintf(w, msg(MsgPLMTypical), l.DTWINReadsTypical, l.DTWINWritesTypical, l.DTWINTimeMax,
+		l.NDWINTimeMinHigh, l.NDWINTimeMinLow)
+	fmt.Fprintf(w, msg(MsgPLMEstimate), l.DTWINReadsEstimate, l.DTWINWritesEstimate,
+		l.DTWINTimeEstimate)
+}
+
+// checkPLM returns ErrNotSupported if the controller does not support predictable latency mode.
+func (d *NVMeDevice) checkPLM() error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Ctratt&ctrattPLM == 0 {
+		return fmt.Errorf("predictable latency mode: %w", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// GetPredictableLatency reads the Predictable Latency Per NVM Set log page of the specified NVM
+// Set.
+func (d *NVMeDevice) GetPredictableLatency(setID uint16) (*PredictableLatency, error) {
+	if err := d.checkPLM(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 512)
+
+	if err := d.getLog(NVME_LOG_PRED_LAT, logPageArgs{nsid: 0xffffffff, lsi: setID}, buf); err != nil {
+		return nil, err
+	}
+
+	return decodePredictableLatency(setID, buf), nil
+}
+
+func decodePredictableLatency(setID uint16, buf []byte) *PredictableLatency {
+	var raw nvmePredLatencyLog
+
+	raw.unmarshal(buf)
+
+	return &PredictableLatency{
+		NVMSetID:            setID,
+		Window:              PLMWindow(raw.Status & 0x7),
+		Events:              raw.EventType,
+		DTWINReadsTypical:   raw.DtwinRt,
+		DTWINWritesTypical:  raw.DtwinWt,
+		DTWINTimeMax:        raw.DtwinTmax,
+		NDWINTimeMinHigh:    raw.NdwinTminHi,
+		NDWINTimeMinLow:     raw.NdwinTminLo,
+		DTWINReadsEstimate:  raw.DtwinRe,
+		DTWINWritesEstimate: raw.DtwinWe,
+		DTWINTimeEstimate:   raw.DtwinTe,
+	}
+}
+
+// GetPredictableLatencyEvents reads the Predictable Latency Event Aggregate log page (0x0B),
+// returning the identifiers of the NVM Sets which have predictable latency events to report.
+// Reading the log page clears it, and releases the associated asynchronous event.
+func (d *NVMeDevice) GetPredictableLatencyEvents() ([]uint16, error) {
+	if err := d.checkPLM(); err != nil {
+		return nil, err
+	}
+
+	return d.getAggregateLog(NVME_LOG_PRED_LAT_AGG)
+}
+
+// PLMConfig is the Predictable Latency Mode Config feature (FID 0x13) of an NVM Set.
+type PLMConfig struct {
+	Enabled              bool   // Predictable Latency Enable (LPE)
+	EnableEvents         uint16 // Events which generate an asynchronous event, see PLMEvent*
+	DTWINReadsThreshold  uint64 // Reads remaining in a DTWIN at which a warning is raised
+	DTWINWritesThreshold uint64 // Writes remaining in a DTWIN at which a warning is raised
+	DTWINTimeThreshold   uint64 // Time remaining in a DTWIN at which a warning is raised
+}
+
+// GetPLMConfig returns the current predictable latency mode configuration of an NVM Set.
+func (d *NVMeDevice) GetPLMConfig(setID uint16) (PLMConfig, error) {
+	buf := make([]byte, 512)
+
+	val, err := d.getFeature(NVME_FEAT_PLM_CONFIG, 0, NVME_FEAT_SEL_CURRENT, uint32(setID), buf)
+	if err != nil {
+		return PLMConfig{}, err
+	}
+
+	var raw nvmePLMConfig
+
+	raw.unmarshal(buf)
+
+	return PLMConfig{
+		Enabled:              val&1 != 0,
+		EnableEvents:         raw.EnableEvent,
+		DTWINReadsThreshold:  raw.DtwinRt,
+		DTWINWritesThreshold: raw.DtwinWt,
+		DTWINTimeThreshold:   raw.DtwinTt,
+	}, nil
+}
+
+// SetPLMConfig sets the predictable latency mode configuration of an NVM Set. If save is true,
+// the setting persists across power cycles and resets.
+func (d *NVMeDevice) SetPLMConfig(setID uint16, c PLMConfig, save bool) error {
+	if err := d.checkPLM(); err != nil {
+		return err
+	}
+
+	raw := nvmePLMConfig{
+		EnableEvent: c.EnableEvents,
+		DtwinRt:     c.DTWINReadsThreshold,
+		DtwinWt:     c.DTWINWritesThreshold,
+		DtwinTt:     c.DTWINTimeThreshold,
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &raw)
+
+	_, err := d.setFeature(NVME_FEAT_PLM_CONFIG, 0, save, uint32(setID), uint32(boolToUint8(c.Enabled)),
+		buf.Bytes())
+	return err
+}
+
+// GetPLMWindow returns the current predictable latency mode window of an NVM Set.
+func (d *NVMeDevice) GetPLMWindow(setID uint16) (PLMWindow, error) {
+	val, err := d.getFeature(NVME_FEAT_PLM_WINDOW, 0, NVME_FEAT_SEL_CURRENT, uint32(setID), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return PLMWindow(val & 0x7), nil
+}
+
+// SetPLMWindow requests an NVM Set to transition to the specified predictable latency mode
+// window. If save is true, the setting persists across power cycles and resets.
+func (d *NVMeDevice) SetPLMWindow(setID uint16, window PLMWindow, save bool) error {
+	_, err := d.setFeature(NVME_FEAT_PLM_WINDOW, 0, save, uint32(setID), uint32(window&0x7), nil)
+	return err
+}
@@ -0,0 +1,208 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	sysfsBlockDir = "/sys/class/block"
+
+	nvmeCtrlRe = regexp.MustCompile(`^nvme\d+$`)
+)
+
+// MemberHealth is the health of a single member of a pool.
+type MemberHealth struct {
+	Device string
+	Health
+	SMART *SMARTLog // nil if the SMART log could not be read
+}
+
+// PoolHealth is the rolled-up health of a set of devices, e.g., the members of an md RAID array or
+// a ZFS pool. The pool's status is that of its weakest member.
+type PoolHealth struct {
+	Status    HealthStatus
+	Weakest   string   // Device name of the weakest member
+	Rationale []string // Reasons for the weakest member's status
+	Members   []MemberHealth
+}
+
+// Print outputs the pool health in a pretty-print style.
+func (p *PoolHealth) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgPoolStatus), p.Status)
+	fmt.Fprintf(w, msg(MsgPoolWeakest), p.Weakest)
+
+	for _, m := range p.Members {
+		fmt.Fprintf(w, msg(MsgPoolMember), m.Device, m.Status)
+
+		for _, r := range m.Reasons {
+			fmt.Fprintf(w, msg(MsgPoolReason), r)
+		}
+	}
+}
+
+// RollupHealth reads the SMART log of each (already opened) device, and rolls up their health
+// into a pool health status.
+func RollupHealth(devices []*NVMeDevice) PoolHealth {
+	members := make([]MemberHealth, 0, len(devices))
+
+	for _, d := range devices {
+		m := MemberHealth{Device: d.Name}
+
+		if sl, err := d.GetSMARTLog(); err != nil {
+			m.add(HealthUnknown, fmt.Sprintf(msg(MsgHealthNoSMART), err))
+		} else {
+			m.SMART = sl
+			m.Health = sl.Health()
+		}
+
+		members = append(members, m)
+	}
+
+	return rollup(members)
+}
+
+func rollup(members []MemberHealth) PoolHealth {
+	p := PoolHealth{Members: members}
+
+	weakest := -1
+
+	for i := range members {
+		if weakest < 0 || weaker(&members[i], &members[weakest]) {
+			weakest = i
+		}
+	}
+
+	if weakest >= 0 {
+		p.Status = members[weakest].Status
+		p.Weakest = members[weakest].Device
+		p.Rationale = members[weakest].Reasons
+	}
+
+	return p
+}
+
+// weaker returns true if member a is in worse health than member b. Members of equal status are
+// ranked by their remaining spare capacity margin, then by percentage used.
+func weaker(a, b *MemberHealth) bool {
+	if a.Status != b.Status {
+		return a.Status > b.Status
+	}
+
+	if a.SMART == nil || b.SMART == nil {
+		return a.SMART == nil && b.SMART != nil
+	}
+
+	marginA := int(a.SMART.AvailSpare) - int(a.SMART.SpareThresh)
+	marginB := int(b.SMART.AvailSpare) - int(b.SMART.SpareThresh)
+
+	if marginA != marginB {
+		return marginA < marginB
+	}
+
+	return a.SMART.PercentUsed > b.SMART.PercentUsed
+}
+
+// PoolMembers returns the NVMe controller device paths (e.g., /dev/nvme0) underlying a stacked
+// block device such as an md RAID array or a device-mapper target, by recursively walking its
+// slaves in sysfs. Non-NVMe members are ignored. Pools which do not register as a stacked block
+// device (e.g., ZFS) should resolve each vdev with ControllerForBlockDevice instead.
+func PoolMembers(blockDev string) ([]string, error) {
+	var ctrlrs []string
+
+	seen := make(map[string]bool)
+
+	var walk func(name string) error
+	walk = func(name string) error {
+		slaves, err := os.ReadDir(filepath.Join(sysfsBlockDir, name, "slaves"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if len(slaves) == 0 {
+			ctrlr, err := ControllerForBlockDevice(name)
+			if err == nil && !seen[ctrlr] {
+				seen[ctrlr] = true
+				ctrlrs = append(ctrlrs, ctrlr)
+			}
+			return nil
+		}
+
+		for _, s := range slaves {
+			if err := walk(s.Name()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(filepath.Base(blockDev)); err != nil {
+		return nil, err
+	}
+
+	return ctrlrs, nil
+}
+
+// ControllerForBlockDevice returns the NVMe controller device path (e.g., /dev/nvme0) for an NVMe
+// namespace block device or partition thereof (e.g., /dev/nvme0n1p1). For namespaces which are
+// reachable via multiple controllers (native NVMe multipath), the first controller is returned.
+func ControllerForBlockDevice(blockDev string) (string, error) {
+	name := filepath.Base(blockDev)
+
+	path, err := filepath.EvalSymlinks(filepath.Join(sysfsBlockDir, name))
+	if err != nil {
+		return "", err
+	}
+
+	// Partitions are subdirectories of their parent block device
+	if _, err := os.Stat(filepath.Join(path, "partition")); err == nil {
+		path = filepath.Dir(path)
+	}
+
+	dev, err := filepath.EvalSymlinks(filepath.Join(path, "device"))
+	if err != nil {
+		return "", fmt.Errorf("%s is not an NVMe namespace: %w", name, err)
+	}
+
+	ctrlr := filepath.Base(dev)
+
+	if strings.HasPrefix(ctrlr, "nvme-subsys") {
+		entries, err := os.ReadDir(dev)
+		if err != nil {
+			return "", err
+		}
+
+		ctrlr = ""
+		for _, e := range entries {
+			if nvmeCtrlRe.MatchString(e.Name()) {
+				ctrlr = e.Name()
+				break
+			}
+		}
+	}
+
+	if !nvmeCtrlRe.MatchString(ctrlr) {
+		return "", fmt.Errorf("%s is not an NVMe namespace", name)
+	}
+
+	return "/dev/" + ctrlr, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Power state descriptor flags and scale fields, cf. NVM Express Base Specification 2.0c,
+// figure 276.
+const (
+	psMaxPowerScale   = 1 << 0 // Max Power is in units of 0.0001 W, rather than 0.01 W
+	psNonOperational  = 1 << 1
+	psPowerScaleShift = 6
+	psPowerScaleMask  = 0x3
+	psWorkloadMask    = 0x7
+)
+
+// PowerState is a decoded power state descriptor. The power units retain the scales reported by
+// the descriptor, so that powers can be formatted with the precision they were reported in.
+type PowerState struct {
+	MaxPower       float64 // Watts
+	MaxPowerUnit   float64 // Watts, 0.01 or 0.0001
+	NonOperational bool
+	EntryLatency   time.Duration
+	ExitLatency    time.Duration
+
+	// Relative performance of the power state, where lower values indicate higher performance
+	RelReadThroughput  uint8
+	RelReadLatency     uint8
+	RelWriteThroughput uint8
+	RelWriteLatency    uint8
+
+	IdlePower           float64 // Watts
+	IdlePowerUnit       float64 // Watts, 0.01 or 0.0001, or zero if idle power is not reported
+	ActivePower         float64 // Watts
+	ActivePowerUnit     float64 // Watts, 0.01 or 0.0001, or zero if active power is not reported
+	ActivePowerWorkload uint8   // Workload of the active power measurement, if reported
+}
+
+// powerScale returns the size in watts of the units of the idle and active power fields, or zero
+// if the field is not reported.
+func powerScale(scale uint8) float64 {
+	switch (scale >> psPowerScaleShift) & psPowerScaleMask {
+	case 1:
+		return 0.0001
+	case 2:
+		return 0.01
+	}
+
+	return 0
+}
+
+// decode converts a raw power state descriptor to a PowerState.
+func (ps *nvmeIdentPowerState) decode() PowerState {
+	maxPowerUnit := 0.01
+	if ps.Flags&psMaxPowerScale != 0 {
+		maxPowerUnit = 0.0001
+	}
+
+	s := PowerState{
+		MaxPower:           float64(ps.MaxPower) * maxPowerUnit,
+		MaxPowerUnit:       maxPowerUnit,
+		NonOperational:     ps.Flags&psNonOperational != 0,
+		EntryLatency:       time.Duration(ps.EntryLat) * time.Microsecond,
+		ExitLatency:        time.Duration(ps.ExitLat) * time.Microsecond,
+		RelReadThroughput:  ps.ReadTput & 0x1f,
+		RelReadLatency:     ps.ReadLat & 0x1f,
+		RelWriteThroughput: ps.WriteTput & 0x1f,
+		RelWriteLatency:    ps.WriteLat & 0x1f,
+		IdlePowerUnit:      powerScale(ps.IdleScale),
+		ActivePowerUnit:    powerScale(ps.ActiveWorkScale),
+	}
+
+	s.IdlePower = float64(ps.IdlePower) * s.IdlePowerUnit
+	s.ActivePower = float64(ps.ActivePower) * s.ActivePowerUnit
+
+	if s.ActivePowerUnit != 0 {
+		s.ActivePowerWorkload = ps.ActiveWorkScale & psWorkloadMask
+	}
+
+	return s
+}
+
+// formatPower formats a power in watts with the precision of the unit it was reported in, or "-"
+// if it is not reported, i.e. the unit is zero.
+func formatPower(watts, unit float64) string {
+	switch unit {
+	case 0:
+		return "-"
+	case 0.0001:
+		return fmt.Sprintf("%.4fW", watts)
+	}
+
+	return fmt.Sprintf("%.2fW", watts)
+}
+
+// PrintPowerStates outputs the controller's power state descriptors, in the same format as the
+// power state listing of `nvme id-ctrl`.
+func (c *NVMeController) PrintPowerStates(w io.Writer) {
+	for i, ps := range c.PowerStates {
+		op := "operational"
+		if ps.NonOperational {
+			op = "non-operational"
+		}
+
+		workload := "-"
+		if ps.ActivePowerUnit != 0 {
+			workload = fmt.Sprintf("%d", ps.ActivePowerWorkload)
+		}
+
+		fmt.Fprintf(w, msg(MsgPowerState), i, formatPower(ps.MaxPower, ps.MaxPowerUnit), op,
+			ps.EntryLatency.Microseconds(), ps.ExitLatency.Microseconds(), ps.RelReadThroughput,
+			ps.RelReadLatency)
+		fmt.Fprintf(w, msg(MsgPowerStatePerf), ps.RelWriteThroughput, ps.RelWriteLatency,
+			formatPower(ps.IdlePower, ps.IdlePowerUnit),
+			formatPower(ps.ActivePower, ps.ActivePowerUnit))
+		fmt.Fprintf(w, msg(MsgPowerStateWorkload), workload)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// logPageLen is the length in which each log page is collected.
+var logPageLen = map[uint8]int{
+	NVME_LOG_ERROR:            4096,
+	NVME_LOG_SMART:            512,
+	NVME_LOG_FW_SLOT:          512,
+	NVME_LOG_CHANGED_NS:       4096,
+	NVME_LOG_CMD_EFFECTS:      4096,
+	NVME_LOG_DEVICE_SELF_TEST: 564,
+	NVME_LOG_FID_EFFECTS:      1024,
+	NVME_LOG_SANITIZE:         512,
+}
+
+// CollectionProfile defines which identify structures, log pages and features are gathered by
+// Collect, trading completeness against collection time.
+type CollectionProfile struct {
+	Name      string
+	Namespace bool    // Identify the device's namespace (see NamespaceID), or namespace 1
+	LogPages  []uint8 // Log pages, which must be listed in logPageLen
+	Features  []uint8 // Features whose value is returned in completion dword 0
+}
+
+var (
+	// ProfileMinimal gathers only what is needed for health monitoring.
+	ProfileMinimal = CollectionProfile{
+		Name:     "minimal",
+		LogPages: []uint8{NVME_LOG_SMART},
+	}
+
+	// ProfileStandard additionally gathers the namespace, error, firmware and self-test logs, and
+	// commonly tuned features.
+	ProfileStandard = CollectionProfile{
+		Name:      "standard",
+		Namespace: true,
+		LogPages: []uint8{NVME_LOG_SMART, NVME_LOG_ERROR, NVME_LOG_FW_SLOT,
+			NVME_LOG_DEVICE_SELF_TEST},
+		Features: []uint8{NVME_FEAT_ARBITRATION, NVME_FEAT_POWER_MGMT, NVME_FEAT_TEMP_THRESH,
+			NVME_FEAT_VOLATILE_WC, NVME_FEAT_NUM_QUEUES},
+	}
+
+	// ProfileDeep gathers every log page and feature known to this package, for diagnostics.
+	ProfileDeep = CollectionProfile{
+		Name:      "deep",
+		Namespace: true,
+		LogPages:  probeLogPages,
+		Features: []uint8{NVME_FEAT_ARBITRATION, NVME_FEAT_POWER_MGMT, NVME_FEAT_TEMP_THRESH,
+			NVME_FEAT_ERR_RECOVERY, NVME_FEAT_VOLATILE_WC, NVME_FEAT_NUM_QUEUES,
+			NVME_FEAT_IRQ_COALESCE, NVME_FEAT_WRITE_ATOMIC, NVME_FEAT_ASYNC_EVENT,
+			NVME_FEAT_SANITIZE_CONFIG},
+	}
+)
+
+// profiles lists the predefined collection profiles.
+var profiles = []CollectionProfile{ProfileMinimal, ProfileStandard, ProfileDeep}
+
+// LookupProfile returns the predefined collection profile of the specified name.
+func LookupProfile(name string) (CollectionProfile, error) {
+	var names []string
+
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+
+	return CollectionProfile{}, fmt.Errorf("unknown collection profile %q (valid: %s)", name,
+		strings.Join(names, ", "))
+}
+
+// Collection holds the data gathered according to a collection profile. Items which could not be
+// gathered are recorded in Errors, keyed by a description of the item.
+type Collection struct {
+	Profile    string
+	Controller NVMeController
+	NSID       uint32 // Identified namespace, 0 if not collected
+	NSSize     uint64 // Namespace size, in logical blocks
+	NSUse      uint64 // Namespace utilization, in logical blocks
+	SMART      *SMARTLog
+	LogPages   map[uint8][]byte // Raw log pages
+	Features   map[uint8]uint32 // Current feature values
+	Errors     map[string]error
+}
+
+// Collect gathers the identify data, log pages and features specified by the profile. Failure to
+// gather an individual item is not fatal; only a failure to identify the controller is returned as
+// an error. Log pages are read with Retain Asynchronous Event set, so that collection does not
+// clear events which other software may be waiting on.
+func (d *NVMeDevice) Collect(p CollectionProfile) (*Collection, error) {
+	c := &Collection{
+		Profile:  p.Name,
+		LogPages: make(map[uint8][]byte),
+		Features: make(map[uint8]uint32),
+		Errors:   make(map[string]error),
+	}
+
+	var err error
+
+	if c.Controller, err = d.IdentifyController(io.Discard); err != nil {
+		return nil, err
+	}
+
+	if p.Namespace {
+		nsid, err := d.NamespaceID()
+		if err != nil {
+			nsid = 1
+		}
+
+		if ns, err := d.identifyNamespace(nsid); err != nil {
+			c.Errors[fmt.Sprintf("namespace %d", nsid)] = err
+		} else {
+			c.NSID, c.NSSize, c.NSUse = nsid, ns.Nsze, ns.Nuse
+		}
+	}
+
+	for _, id := range p.LogPages {
+		n, ok := logPageLen[id]
+		if !ok {
+			c.Errors[fmt.Sprintf("log page %#02x", id)] = fmt.Errorf("unknown log page length")
+			continue
+		}
+
+		buf := make([]byte, n)
+
+		if err := d.getLogPage(id, 0xffffffff, true, buf); err != nil {
+			c.Errors[fmt.Sprintf("log page %#02x", id)] = err
+			continue
+		}
+
+		c.LogPages[id] = buf
+
+		if id == NVME_LOG_SMART {
+			var sl nvmeSMARTLog
+
+			sl.unmarshal(buf)
+			c.SMART = sl.decode()
+		}
+	}
+
+	for _, fid := range p.Features {
+		val, err := d.getFeature(fid, 0, NVME_FEAT_SEL_CURRENT, 0, nil)
+		if err != nil {
+			c.Errors[fmt.Sprintf("feature %#02x", fid)] = err
+			continue
+		}
+
+		c.Features[fid] = val
+	}
+
+	return c, nil
+}
+
+// Print outputs the collection in a pretty-print style.
+func (c *Collection) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgCollectProfile), c.Profile)
+	c.Controller.Print(w)
+
+	if c.NSID != 0 {
+		fmt.Fprintf(w, msg(MsgNsSize), c.NSID, c.NSSize)
+		fmt.Fprintf(w, msg(MsgNsUtilisation), c.NSID, c.NSUse)
+	}
+
+	if c.SMART != nil {
+		c.SMART.Print(w)
+	}
+
+	for _, id := range sortedKeys(c.LogPages) {
+		fmt.Fprintf(w, msg(MsgCollectLogPage), id, len(c.LogPages[id]))
+	}
+
+	for _, fid := range sortedKeys(c.Features) {
+		fmt.Fprintf(w, msg(MsgCollectFeature), fid, c.Features[fid])
+	}
+
+	items := make([]string, 0, len(c.Errors))
+	for item := range c.Errors {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+
+	for _, item := range items {
+		fmt.Fprintf(w, msg(MsgCollectError), item, c.Errors[item])
+	}
+}
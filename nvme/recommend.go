@@ -0,0 +1,196 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Action is a recommended course of action for a device, ordered from least to most severe.
+type Action int
+
+const (
+	ActionNone    Action = iota
+	ActionMonitor        // Watch the device more closely
+	ActionMigrate        // Pre-emptively migrate data and workloads off the device
+	ActionReplace        // Replace the device
+)
+
+// String returns a stable identifier, suitable for automation.
+func (a Action) String() string {
+	switch a {
+	case ActionNone:
+		return "none"
+	case ActionMonitor:
+		return "monitor"
+	case ActionMigrate:
+		return "migrate"
+	case ActionReplace:
+		return "replace"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(a))
+}
+
+// MarshalText encodes the action as its stable identifier.
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Recommendation rule thresholds.
+const (
+	recErrLogRateMonitor = 10.0 // Error log entries per day
+	recErrLogRateMigrate = 100.0
+	recWearMonitor       = 90 // Percentage used
+	recSpareMargin       = 10 // Percentage points above the spare threshold
+)
+
+// Finding is the outcome of a single recommendation rule.
+type Finding struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	Detail string `json:"detail"`
+}
+
+// Recommendation is the most severe action of all findings, together with the findings.
+type Recommendation struct {
+	Action   Action    `json:"action"`
+	Findings []Finding `json:"findings"`
+}
+
+func (r *Recommendation) add(rule string, action Action, detail string) {
+	if action > r.Action {
+		r.Action = action
+	}
+
+	r.Findings = append(r.Findings, Finding{Rule: rule, Action: action, Detail: detail})
+}
+
+// Print outputs the recommendation in a pretty-print style.
+func (r *Recommendation) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgRecAction), r.Action)
+
+	for _, f := range r.Findings {
+		fmt.Fprintf(w, msg(MsgRecFinding), f.Action, f.Rule, f.Detail)
+	}
+}
+
+// RecommendationInput is the device state from which a recommendation is derived. Current is
+// required; Previous and SelfTest are optional, and rules depending on them are skipped if absent.
+type RecommendationInput struct {
+	Previous *SMARTLog     // Earlier SMART sample, for growth rates
+	Current  *SMARTLog     // Latest SMART sample
+	Interval time.Duration // Time between the Previous and Current samples
+	SelfTest *SelfTestLog
+}
+
+// Recommend derives an action from the device state, applying the following rules:
+//
+//	critical-warning  Spare below threshold, reliability degraded, read-only or volatile memory
+//	                  backup failed: replace. Temperature excursion: monitor.
+//	self-test         Most recent self-test failed: replace. An earlier self-test failed: monitor.
+//	media-errors      Media errors increased between samples: migrate. Otherwise, any media
+//	                  errors: monitor.
+//	error-log-rate    Error log entries growing by more than 100 per day: migrate; by more than
+//	                  10 per day: monitor.
+//	wear              Percentage used at least 100: migrate; at least 90: monitor.
+//	spare-margin      Available spare within 10 percentage points of the threshold: monitor.
+func Recommend(in RecommendationInput) Recommendation {
+	var r Recommendation
+
+	cur := in.Current
+	if cur == nil {
+		return r
+	}
+
+	const critical = CritWarnSpare | CritWarnReliability | CritWarnReadOnly |
+		CritWarnVolatileBackup | CritWarnPMRReadOnly
+
+	if cur.CritWarning&critical != 0 {
+		r.add("critical-warning", ActionReplace,
+			fmt.Sprintf("critical warning %#02x", cur.CritWarning&critical))
+	}
+	if cur.CritWarning&CritWarnTemperature != 0 {
+		r.add("critical-warning", ActionMonitor, "temperature excursion")
+	}
+
+	if in.SelfTest != nil {
+		for i, res := range in.SelfTest.Results {
+			if !res.Status.Failed() {
+				continue
+			}
+
+			if i == 0 {
+				r.add("self-test", ActionReplace, fmt.Sprintf("most recent %s self-test %s",
+					res.Code, res.Status))
+			} else {
+				r.add("self-test", ActionMonitor, fmt.Sprintf("earlier %s self-test %s",
+					res.Code, res.Status))
+			}
+			break
+		}
+	}
+
+	if in.Previous != nil && growth(in.Previous.MediaErrors, cur.MediaErrors) > 0 {
+		r.add("media-errors", ActionMigrate, fmt.Sprintf("media errors increased by %d to %s",
+			growth(in.Previous.MediaErrors, cur.MediaErrors), cur.MediaErrors))
+	} else if cur.MediaErrors != nil && cur.MediaErrors.Sign() > 0 {
+		r.add("media-errors", ActionMonitor, fmt.Sprintf("%s media errors", cur.MediaErrors))
+	}
+
+	if in.Previous != nil && in.Interval > 0 {
+		rate := float64(growth(in.Previous.NumErrLogEntries, cur.NumErrLogEntries)) /
+			(in.Interval.Hours() / 24)
+
+		if rate > recErrLogRateMigrate {
+			r.add("error-log-rate", ActionMigrate, fmt.Sprintf("%.1f error log entries per day", rate))
+		} else if rate > recErrLogRateMonitor {
+			r.add("error-log-rate", ActionMonitor, fmt.Sprintf("%.1f error log entries per day", rate))
+		}
+	}
+
+	if cur.PercentUsed >= 100 {
+		r.add("wear", ActionMigrate, fmt.Sprintf("%d%% of rated endurance used", cur.PercentUsed))
+	} else if cur.PercentUsed >= recWearMonitor {
+		r.add("wear", ActionMonitor, fmt.Sprintf("%d%% of rated endurance used", cur.PercentUsed))
+	}
+
+	if cur.CritWarning&CritWarnSpare == 0 && int(cur.AvailSpare) < int(cur.SpareThresh)+recSpareMargin {
+		r.add("spare-margin", ActionMonitor, fmt.Sprintf("available spare %d%%, threshold %d%%",
+			cur.AvailSpare, cur.SpareThresh))
+	}
+
+	return r
+}
+
+// growth returns the increase of a counter between two samples, or zero if it did not increase
+// or either sample is missing. Increases beyond the range of an int64 are clamped.
+func growth(prev, cur *big.Int) int64 {
+	if prev == nil || cur == nil {
+		return 0
+	}
+
+	d := new(big.Int).Sub(cur, prev)
+	if d.Sign() <= 0 {
+		return 0
+	} else if !d.IsInt64() {
+		return 1<<63 - 1
+	}
+
+	return d.Int64()
+}
@@ -0,0 +1,284 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Controller register offsets, cf. NVM Express Base Specification 2.0c, figure 33.
+const (
+	regCAP     = 0x00  // Controller Capabilities
+	regVS      = 0x08  // Version
+	regCC      = 0x14  // Controller Configuration
+	regCSTS    = 0x1c  // Controller Status
+	regCMBLOC  = 0x38  // Controller Memory Buffer Location
+	regCMBSZ   = 0x3c  // Controller Memory Buffer Size
+	regPMRCAP  = 0xe00 // Persistent Memory Capabilities
+	regPMRCTL  = 0xe04 // Persistent Memory Region Control
+	regPMRSTS  = 0xe08 // Persistent Memory Region Status
+	regPMREBS  = 0xe0c // Persistent Memory Region Elasticity Buffer Size
+	regPMRSWTP = 0xe10 // Persistent Memory Region Sustained Write Throughput
+)
+
+// CAP.MPSMIN, the minimum memory page size as a power of two of 4 KiB.
+const (
+	capMPSMINShift = 48
+	capMPSMINMask  = 0xf
+)
+
+// ControllerRegisters describes the controller's capabilities, version, configuration and status,
+// decoded from the CAP, VS, CC and CSTS registers, cf. NVM Express Base Specification 2.0c,
+// figures 36-43.
+type ControllerRegisters struct {
+	CAP  uint64 // Controller Capabilities
+	VS   uint32 // Version
+	CC   uint32 // Controller Configuration
+	CSTS uint32 // Controller Status
+
+	MaxQueueEntries  uint32        // Maximum individual queue size
+	ContiguousQueues bool          // Contiguous queues required
+	Arbitration      uint8         // Arbitration mechanisms supported, in addition to round robin
+	Timeout          time.Duration // Worst-case time to wait for CSTS.RDY to change
+	DoorbellStride   uint32        // In bytes
+	SubsystemReset   bool          // NVM Subsystem Reset supported
+	CommandSets      uint8         // Command sets supported (CAP.CSS)
+	BootPartitions   bool          // Boot partitions supported
+	MinPageSize      int           // In bytes
+	MaxPageSize      int           // In bytes
+
+	Enabled     bool
+	PageSize    int   // Configured memory page size, in bytes
+	IOSQEntSize int   // I/O submission queue entry size, in bytes
+	IOCQEntSize int   // I/O completion queue entry size, in bytes
+	Shutdown    uint8 // Shutdown notification
+
+	Ready                  bool
+	FatalStatus            bool
+	ShutdownStatus         uint8
+	SubsystemResetOccurred bool
+	ProcessingPaused       bool
+}
+
+// VersionString returns the NVMe version supported by the controller, e.g. "1.4.0".
+func (r *ControllerRegisters) VersionString() string {
+	return Version(r.VS).String()
+}
+
+// Print outputs the controller registers in a pretty-print style.
+func (r *ControllerRegisters) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgRegCAP), r.CAP)
+	fmt.Fprintf(w, msg(MsgRegQueues), r.MaxQueueEntries, r.ContiguousQueues, r.DoorbellStride)
+	fmt.Fprintf(w, msg(MsgRegTimeout), r.Timeout)
+	fmt.Fprintf(w, msg(MsgRegCommandSets), r.CommandSets, r.Arbitration, r.SubsystemReset,
+		r.BootPartitions)
+	fmt.Fprintf(w, msg(MsgRegPageSizes), r.MinPageSize, r.MaxPageSize)
+	fmt.Fprintf(w, msg(MsgRegVersion), r.VS, r.VersionString())
+	fmt.Fprintf(w, msg(MsgRegCC), r.CC)
+	fmt.Fprintf(w, msg(MsgRegConfig), r.Enabled, r.PageSize, r.IOSQEntSize, r.IOCQEntSize,
+		r.Shutdown)
+	fmt.Fprintf(w, msg(MsgRegCSTS), r.CSTS)
+	fmt.Fprintf(w, msg(MsgRegStatus), r.Ready, r.FatalStatus, r.ShutdownStatus,
+		r.SubsystemResetOccurred, r.ProcessingPaused)
+}
+
+// GetRegisters reads and decodes the controller's CAP, VS, CC and CSTS registers, equivalent to
+// `nvme show-regs`. The registers are read via sysfs, which requires root privileges, and is only
+// possible for PCIe controllers.
+func (d *NVMeDevice) GetRegisters() (*ControllerRegisters, error) {
+	regs, err := d.readRegisters()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRegisters(regs), nil
+}
+
+// decodeRegisters decodes the CAP, VS, CC and CSTS registers.
+func decodeRegisters(regs []byte) *ControllerRegisters {
+	caps := reg64(regs, regCAP)
+	cc := reg32(regs, regCC)
+	csts := reg32(regs, regCSTS)
+
+	return &ControllerRegisters{
+		CAP:  caps,
+		VS:   reg32(regs, regVS),
+		CC:   cc,
+		CSTS: csts,
+
+		// MQES is zero-based, TO is in 500 ms units
+		MaxQueueEntries:  uint32(caps&0xffff) + 1,
+		ContiguousQueues: caps&(1<<16) != 0,
+		Arbitration:      uint8(caps>>17) & 0x3,
+		Timeout:          time.Duration((caps>>24)&0xff) * 500 * time.Millisecond,
+		DoorbellStride:   4 << ((caps >> 32) & 0xf),
+		SubsystemReset:   caps&(1<<36) != 0,
+		CommandSets:      uint8(caps >> 37),
+		BootPartitions:   caps&(1<<45) != 0,
+		MinPageSize:      pageSizeFromCAP(caps),
+		MaxPageSize:      defaultPageSize << ((caps >> 52) & 0xf),
+
+		Enabled:     cc&1 != 0,
+		PageSize:    defaultPageSize << ((cc >> 7) & 0xf),
+		IOSQEntSize: 1 << ((cc >> 16) & 0xf),
+		IOCQEntSize: 1 << ((cc >> 20) & 0xf),
+		Shutdown:    uint8(cc>>14) & 0x3,
+
+		Ready:                  csts&1 != 0,
+		FatalStatus:            csts&(1<<1) != 0,
+		ShutdownStatus:         uint8(csts>>2) & 0x3,
+		SubsystemResetOccurred: csts&(1<<4) != 0,
+		ProcessingPaused:       csts&(1<<5) != 0,
+	}
+}
+
+// defaultPageSize is the memory page size assumed if the CAP register cannot be read.
+const defaultPageSize = 4096
+
+// registersLen is the length of the mapped register block, which includes the PMR registers.
+const registersLen = 0x1000
+
+// controllerName returns the name of the controller (e.g. nvme0) of the device, which may be a
+// controller or namespace device.
+func (d *NVMeDevice) controllerName() (string, error) {
+	name := filepath.Base(d.Name)
+	if nvmeCtrlRe.MatchString(name) {
+		return name, nil
+	}
+
+	ctrlr, err := ControllerForBlockDevice(d.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Base(ctrlr), nil
+}
+
+// barPath returns the sysfs path of the specified PCI BAR of the controller.
+func (d *NVMeDevice) barPath(bar uint8) (string, error) {
+	ctrlr, err := d.controllerName()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(sysfsNVMeDir, ctrlr, "device", fmt.Sprintf("resource%d", bar)), nil
+}
+
+// barSize returns the size of the specified PCI BAR of the controller.
+func (d *NVMeDevice) barSize(bar uint8) (uint64, error) {
+	path, err := d.barPath(bar)
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(fi.Size()), nil
+}
+
+// readRegisters returns a copy of the controller's register block, read from a read-only mapping
+// of BAR0. This requires root privileges, and is only possible for PCIe controllers.
+func (d *NVMeDevice) readRegisters() ([]byte, error) {
+	path, err := d.barPath(0)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mem, err := unix.Mmap(int(f.Fd()), 0, registersLen, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", path, err)
+	}
+	defer unix.Munmap(mem)
+
+	// Registers must be read with aligned 32-bit accesses, rather than copied bytewise
+	regs := make([]byte, registersLen)
+
+	for off := 0; off < registersLen; off += 4 {
+		*(*uint32)(unsafe.Pointer(&regs[off])) = *(*uint32)(unsafe.Pointer(&mem[off]))
+	}
+
+	return regs, nil
+}
+
+// reg32 returns the value of a 32-bit register.
+func reg32(regs []byte, off int) uint32 {
+	return binary.LittleEndian.Uint32(regs[off:])
+}
+
+// reg64 returns the value of a 64-bit register.
+func reg64(regs []byte, off int) uint64 {
+	return binary.LittleEndian.Uint64(regs[off:])
+}
+
+// SetMinPageSize overrides the controller's minimum memory page size (CAP.MPSMIN), in bytes, which
+// is the unit of the Maximum Data Transfer Size. Otherwise, it is read from the CAP register if
+// possible, or assumed to be 4 KiB. A size of zero reverts to the default behaviour.
+func (d *NVMeDevice) SetMinPageSize(size int) error {
+	if size != 0 && (size < defaultPageSize || size > defaultPageSize<<capMPSMINMask ||
+		size&(size-1) != 0) {
+		return fmt.Errorf("invalid memory page size %d", size)
+	}
+
+	d.pageSize = size
+
+	return nil
+}
+
+// minPageSize returns the controller's minimum memory page size in bytes. Unless overridden with
+// SetMinPageSize, it is read from the CAP register, which requires root privileges and is only
+// possible for PCIe controllers. If the register cannot be read, 4 KiB is assumed.
+func (d *NVMeDevice) minPageSize() int {
+	if d.pageSize == 0 {
+		d.pageSize = defaultPageSize
+
+		if regs, err := d.readRegisters(); err == nil {
+			d.pageSize = pageSizeFromCAP(reg64(regs, regCAP))
+		}
+	}
+
+	return d.pageSize
+}
+
+// pageSizeFromCAP returns the minimum memory page size in bytes, as specified by CAP.MPSMIN.
+func pageSizeFromCAP(caps uint64) int {
+	return defaultPageSize << ((caps >> capMPSMINShift) & capMPSMINMask)
+}
+
+// mdtsBytes converts a Maximum Data Transfer Size, in units of the minimum memory page size, to
+// bytes. A value of zero indicates no limit.
+func mdtsBytes(mdts uint8, pageSize int) uint {
+	if mdts == 0 {
+		return 0
+	}
+
+	return uint(pageSize) << mdts
+}
@@ -0,0 +1,341 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// ReservationType is the type of a reservation, cf. NVM Express Base Specification 2.0c, figure
+// 556.
+type ReservationType uint8
+
+const (
+	ResvNone                          ReservationType = 0x0
+	ResvWriteExclusive                ReservationType = 0x1
+	ResvExclusiveAccess               ReservationType = 0x2
+	ResvWriteExclusiveRegistrants     ReservationType = 0x3
+	ResvExclusiveAccessRegistrants    ReservationType = 0x4
+	ResvWriteExclusiveAllRegistrants  ReservationType = 0x5
+	ResvExclusiveAccessAllRegistrants ReservationType = 0x6
+)
+
+func (t ReservationType) String() string {
+	switch t {
+	case ResvNone:
+		return "none"
+	case ResvWriteExclusive:
+		return "write exclusive"
+	case ResvExclusiveAccess:
+		return "exclusive access"
+	case ResvWriteExclusiveRegistrants:
+		return "write exclusive - registrants only"
+	case ResvExclusiveAccessRegistrants:
+		return "exclusive access - registrants only"
+	case ResvWriteExclusiveAllRegistrants:
+		return "write exclusive - all registrants"
+	case ResvExclusiveAccessAllRegistrants:
+		return "exclusive access - all registrants"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(t))
+}
+
+// Reservation Register (RREGA), Acquire (RACQA) and Release (RRELA) actions.
+const (
+	resvRegister   = 0x0
+	resvUnregister = 0x1
+	resvReplace    = 0x2
+
+	resvAcquire         = 0x0
+	resvPreempt         = 0x1
+	resvPreemptAndAbort = 0x2
+
+	resvRelease = 0x0
+	resvClear   = 0x1
+)
+
+// resvIgnoreKey is the Ignore Existing Key (IEKEY) bit of the reservation commands.
+const resvIgnoreKey = 1 << 3
+
+// Registrant is a controller registered with a namespace's reservation.
+type Registrant struct {
+	ControllerID     uint16 // 0xffff if the controller is not known, e.g. on another subsystem
+	HoldsReservation bool
+	HostID           [16]byte // 64-bit host identifiers occupy the first 8 bytes
+	Key              uint64
+}
+
+// ReservationStatus is the decoded Reservation Status data structure.
+type ReservationStatus struct {
+	Generation              uint32
+	Type                    ReservationType
+	PersistThroughPowerLoss bool
+	Registrants             []Registrant
+}
+
+// Holder returns the registrant which holds the reservation, or nil if no registrant (or, for
+// all registrants reservation types, every registrant) holds it.
+func (s *ReservationStatus) Holder() *Registrant {
+	for i := range s.Registrants {
+		if s.Registrants[i].HoldsReservation {
+			return &s.Registrants[i]
+		}
+	}
+
+	return nil
+}
+
+// Print outputs the reservation status in a pretty-print style.
+func (s *ReservationStatus) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgResvGeneration), s.Generation)
+	fmt.Fprintf(w, msg(MsgResvType), s.Type)
+	fmt.Fprintf(w, msg(MsgResvPTPL), s.PersistThroughPowerLoss)
+
+	for _, r := range s.Registrants {
+		fmt.Fprintf(w, msg(MsgResvRegistrant), r.ControllerID, r.HostID, r.Key, r.HoldsReservation)
+	}
+}
+
+// ReservationReport returns the reservation status of the specified namespace. The extended data
+// structure, with 128-bit host identifiers as used by NVMe over Fabrics, is requested first, and
+// the 64-bit host identifier data structure if that fails.
+func (d *NVMeDevice) ReservationReport(nsid uint32) (*ReservationStatus, error) {
+	buf := make([]byte, 4096)
+
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_CMD_RESV_REPORT,
+		nsid:     nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    uint32(len(buf)/4) - 1, // Number of Dwords (0's based)
+		cdw11:    1,                      // Extended Data Structure
+	}
+
+	if err := d.ioCmd(&cmd, buf); err != nil {
+		if _, ok := err.(NVMeStatus); !ok {
+			return nil, err
+		}
+
+		cmd.cdw11 = 0
+		if err := d.ioCmd(&cmd, buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return decodeReservationStatus(buf, cmd.cdw11 == 1), nil
+}
+
+// decodeReservationStatus decodes a Reservation Status data structure, or the extended variant
+// thereof.
+func decodeReservationStatus(buf []byte, extended bool) *ReservationStatus {
+	s := &ReservationStatus{
+		Generation:              binary.LittleEndian.Uint32(buf[0:]),
+		Type:                    ReservationType(buf[4]),
+		PersistThroughPowerLoss: buf[9]&1 != 0,
+	}
+
+	hdrLen, entryLen := 24, 24
+	if extended {
+		hdrLen, entryLen = 64, 64
+	}
+
+	n := int(binary.LittleEndian.Uint16(buf[5:]))
+	if limit := (len(buf) - hdrLen) / entryLen; n > limit {
+		n = limit
+	}
+
+	for i := 0; i < n; i++ {
+		e := buf[hdrLen+i*entryLen:]
+
+		r := Registrant{
+			ControllerID:     binary.LittleEndian.Uint16(e[0:]),
+			HoldsReservation: e[2]&1 != 0,
+		}
+
+		if extended {
+			r.Key = binary.LittleEndian.Uint64(e[8:])
+			copy(r.HostID[:], e[16:32])
+		} else {
+			copy(r.HostID[:], e[8:16])
+			r.Key = binary.LittleEndian.Uint64(e[16:])
+		}
+
+		s.Registrants = append(s.Registrants, r)
+	}
+
+	return s
+}
+
+// RegisterReservationKey registers the specified reservation key for the controller.
+func (d *NVMeDevice) RegisterReservationKey(nsid uint32, key uint64) error {
+	return d.reservationCmd(NVME_CMD_RESV_REGISTER, nsid, resvRegister, 0, key)
+}
+
+// UnregisterReservationKey unregisters the controller's reservation key.
+func (d *NVMeDevice) UnregisterReservationKey(nsid uint32, key uint64) error {
+	return d.reservationCmd(NVME_CMD_RESV_REGISTER, nsid, resvUnregister, key, 0)
+}
+
+// ReplaceReservationKey replaces the controller's reservation key. If ignoreKey is true, the
+// current key is not checked.
+func (d *NVMeDevice) ReplaceReservationKey(nsid uint32, key, newKey uint64, ignoreKey bool) error {
+	cdw10 := uint32(resvReplace)
+	if ignoreKey {
+		cdw10 |= resvIgnoreKey
+	}
+
+	return d.reservationCmd(NVME_CMD_RESV_REGISTER, nsid, cdw10, key, newKey)
+}
+
+// AcquireReservation acquires a reservation of the specified type, using the controller's
+// registered key.
+func (d *NVMeDevice) AcquireReservation(nsid uint32, key uint64, rtype ReservationType) error {
+	return d.reservationCmd(NVME_CMD_RESV_ACQUIRE, nsid, resvAcquire|uint32(rtype)<<8, key, 0)
+}
+
+// PreemptReservation preempts the reservation or registrations held with preemptKey, e.g. to fence
+// a failed cluster node. If abort is true, commands from the preempted controllers are aborted.
+func (d *NVMeDevice) PreemptReservation(nsid uint32, key, preemptKey uint64, rtype ReservationType,
+	abort bool) error {
+
+	action := uint32(resvPreempt)
+	if abort {
+		action = resvPreemptAndAbort
+	}
+
+	return d.reservationCmd(NVME_CMD_RESV_ACQUIRE, nsid, action|uint32(rtype)<<8, key, preemptKey)
+}
+
+// ReleaseReservation releases the reservation of the specified type held by the controller.
+func (d *NVMeDevice) ReleaseReservation(nsid uint32, key uint64, rtype ReservationType) error {
+	return d.reservationCmd(NVME_CMD_RESV_RELEASE, nsid, resvRelease|uint32(rtype)<<8, key)
+}
+
+// ClearReservation releases any reservation and unregisters all registrants of the namespace.
+func (d *NVMeDevice) ClearReservation(nsid uint32, key uint64) error {
+	return d.reservationCmd(NVME_CMD_RESV_RELEASE, nsid, resvClear, key)
+}
+
+// reservationCmd issues a reservation command, whose data structure consists of the specified
+// keys, i.e. two keys for Register and Acquire, and one key for Release.
+func (d *NVMeDevice) reservationCmd(opcode uint8, nsid uint32, cdw10 uint32, keys ...uint64) error {
+	buf := make([]byte, 8*len(keys))
+
+	for i, k := range keys {
+		binary.LittleEndian.PutUint64(buf[i*8:], k)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode:   opcode,
+		nsid:     nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    cdw10,
+	}
+
+	return d.ioCmd(&cmd, buf)
+}
+
+// ReservationNotificationType is the type of a reservation notification.
+type ReservationNotificationType uint8
+
+const (
+	ResvNotifyEmpty                 ReservationNotificationType = 0x0 // No notification pending
+	ResvNotifyRegistrationPreempted ReservationNotificationType = 0x1
+	ResvNotifyReservationReleased   ReservationNotificationType = 0x2
+	ResvNotifyReservationPreempted  ReservationNotificationType = 0x3
+)
+
+func (t ReservationNotificationType) String() string {
+	switch t {
+	case ResvNotifyEmpty:
+		return "empty"
+	case ResvNotifyRegistrationPreempted:
+		return "registration preempted"
+	case ResvNotifyReservationReleased:
+		return "reservation released"
+	case ResvNotifyReservationPreempted:
+		return "reservation preempted"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(t))
+}
+
+// ReservationNotification is a notification from the Reservation Notification log page (0x80).
+type ReservationNotification struct {
+	// Log Page Count, incremented for each notification. A gap between consecutive notifications
+	// indicates that notifications were lost.
+	Count     uint64
+	Type      ReservationNotificationType
+	Available uint8 // Number of further notifications pending, saturating at 255
+	NSID      uint32
+}
+
+// Print outputs the reservation notification in a pretty-print style.
+func (n *ReservationNotification) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgResvNotification), n.Count, n.NSID, n.Type)
+}
+
+// GetReservationNotification reads the Reservation Notification log page, which returns and
+// removes the oldest pending notification. If no notification is pending, its type is
+// ResvNotifyEmpty.
+func (d *NVMeDevice) GetReservationNotification() (*ReservationNotification, error) {
+	buf := make([]byte, 64)
+
+	if err := d.getLog(NVME_LOG_RESV_NOTIFY, logPageArgs{nsid: 0xffffffff}, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeResvNotificationLog
+
+	raw.unmarshal(buf)
+
+	return &ReservationNotification{
+		Count:     raw.Lpc,
+		Type:      ReservationNotificationType(raw.Rnlpt),
+		Available: raw.Nalp,
+		NSID:      raw.Nsid,
+	}, nil
+}
+
+// GetReservationNotifications drains the pending reservation notifications, e.g. upon a
+// reservation log page available asynchronous event.
+func (d *NVMeDevice) GetReservationNotifications() ([]ReservationNotification, error) {
+	var notifications []ReservationNotification
+
+	// Bound the number of reads, in case notifications arrive faster than they are read
+	for i := 0; i < 256; i++ {
+		n, err := d.GetReservationNotification()
+		if err != nil {
+			return notifications, err
+		}
+
+		if n.Type == ResvNotifyEmpty {
+			break
+		}
+
+		notifications = append(notifications, *n)
+
+		if n.Available == 0 {
+			break
+		}
+	}
+
+	return notifications, nil
+}
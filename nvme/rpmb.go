@@ -0,0 +1,281 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// RPMB request and response message types, cf. NVM Express Base Specification 2.0c, figure 496.
+const (
+	rpmbProgramKey   = 0x0001
+	rpmbReadCounter  = 0x0002
+	rpmbWriteData    = 0x0003
+	rpmbReadData     = 0x0004
+	rpmbReadResult   = 0x0005
+	rpmbResponseFlag = 0x0100
+)
+
+const (
+	rpmbFrameLen  = 256 // Length of the RPMB data frame, excluding data
+	rpmbSectorLen = 512
+	rpmbMACOffset = 191 // Offset of the MAC / authentication key
+	rpmbMACStart  = 223 // Offset from which the MAC is calculated, i.e. the target field
+)
+
+// RPMBResult is the operation result of an RPMB request.
+type RPMBResult uint16
+
+const (
+	RPMBOK               RPMBResult = 0x00
+	RPMBGeneralFailure   RPMBResult = 0x01
+	RPMBAuthFailure      RPMBResult = 0x02
+	RPMBCounterFailure   RPMBResult = 0x03
+	RPMBAddressFailure   RPMBResult = 0x04
+	RPMBWriteFailure     RPMBResult = 0x05
+	RPMBReadFailure      RPMBResult = 0x06
+	RPMBKeyNotProgrammed RPMBResult = 0x07
+
+	// rpmbCounterExpired is set in addition to the result once the write counter has expired.
+	rpmbCounterExpired RPMBResult = 0x80
+)
+
+func (r RPMBResult) Error() string {
+	var desc string
+
+	switch r &^ rpmbCounterExpired {
+	case RPMBOK:
+		desc = "operation successful"
+	case RPMBGeneralFailure:
+		desc = "general failure"
+	case RPMBAuthFailure:
+		desc = "authentication failure"
+	case RPMBCounterFailure:
+		desc = "counter failure"
+	case RPMBAddressFailure:
+		desc = "address failure"
+	case RPMBWriteFailure:
+		desc = "write failure"
+	case RPMBReadFailure:
+		desc = "read failure"
+	case RPMBKeyNotProgrammed:
+		desc = "authentication key not yet programmed"
+	default:
+		desc = "reserved"
+	}
+
+	if r&rpmbCounterExpired != 0 {
+		desc += ", write counter expired"
+	}
+
+	return "RPMB: " + desc
+}
+
+// RPMBInfo describes the RPMB targets of a controller, decoded from the RPMBS identify field.
+type RPMBInfo struct {
+	Targets    uint8  // Number of RPMB targets, 0 if RPMB is not supported
+	TotalSize  uint32 // Size of each target, in bytes
+	AccessSize uint32 // Maximum size of a single read or write, in bytes
+}
+
+// RPMBInfo returns the controller's RPMB support. RPMB targets are authenticated with
+// HMAC-SHA256, which is the only authentication method defined by the specification.
+func (d *NVMeDevice) RPMBInfo() (RPMBInfo, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return RPMBInfo{}, err
+	}
+
+	rpmbs := idCtrlr.Rpmbs
+
+	return RPMBInfo{
+		Targets:    uint8(rpmbs & 0x7),
+		TotalSize:  (rpmbs>>16&0xff + 1) * 128 << 10,
+		AccessSize: (rpmbs>>24&0xff + 1) * rpmbSectorLen,
+	}, nil
+}
+
+// RPMBProgramKey programs the authentication key of an RPMB target. The key can only be
+// programmed once.
+func (d *NVMeDevice) RPMBProgramKey(target uint8, key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("RPMB key must be 32 bytes")
+	}
+
+	req := rpmbFrame{Target: target, Type: rpmbProgramKey}
+	copy(req.Mac[:], key)
+
+	if err := d.rpmbSend(target, req.marshal(nil)); err != nil {
+		return err
+	}
+
+	_, _, err := d.rpmbResult(target, rpmbProgramKey)
+	return err
+}
+
+// RPMBWriteCounter returns the write counter of an RPMB target. If key is non-nil, the response
+// is authenticated with it.
+func (d *NVMeDevice) RPMBWriteCounter(target uint8, key []byte) (uint32, error) {
+	req := rpmbFrame{Target: target, Type: rpmbReadCounter}
+
+	if _, err := rand.Read(req.Nonce[:]); err != nil {
+		return 0, err
+	}
+
+	if err := d.rpmbSend(target, req.marshal(nil)); err != nil {
+		return 0, err
+	}
+
+	resp, _, err := d.rpmbRecv(target, rpmbReadCounter, 0, &req, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.WriteCounter, nil
+}
+
+// RPMBRead performs an authenticated read of the specified number of 512-byte sectors of an RPMB
+// target, starting at the specified sector address.
+func (d *NVMeDevice) RPMBRead(target uint8, key []byte, addr, sectors uint32) ([]byte, error) {
+	if key == nil {
+		return nil, fmt.Errorf("RPMB key required")
+	}
+
+	req := rpmbFrame{Target: target, Address: addr, Sectors: sectors, Type: rpmbReadData}
+
+	if _, err := rand.Read(req.Nonce[:]); err != nil {
+		return nil, err
+	}
+
+	if err := d.rpmbSend(target, req.marshal(nil)); err != nil {
+		return nil, err
+	}
+
+	_, data, err := d.rpmbRecv(target, rpmbReadData, int(sectors), &req, key)
+	return data, err
+}
+
+// RPMBWrite performs an authenticated write of data, which must be a multiple of 512 bytes, to an
+// RPMB target, starting at the specified sector address.
+func (d *NVMeDevice) RPMBWrite(target uint8, key []byte, addr uint32, data []byte) error {
+	if len(data) == 0 || len(data)%rpmbSectorLen != 0 {
+		return fmt.Errorf("RPMB data must be a non-zero multiple of %d bytes", rpmbSectorLen)
+	}
+
+	counter, err := d.RPMBWriteCounter(target, key)
+	if err != nil {
+		return err
+	}
+
+	req := rpmbFrame{
+		Target:       target,
+		WriteCounter: counter,
+		Address:      addr,
+		Sectors:      uint32(len(data) / rpmbSectorLen),
+		Type:         rpmbWriteData,
+	}
+
+	frame := req.marshal(data)
+	copy(frame[rpmbMACOffset:], rpmbMAC(key, frame))
+
+	if err := d.rpmbSend(target, frame); err != nil {
+		return err
+	}
+
+	_, _, err = d.rpmbResult(target, rpmbWriteData)
+	return err
+}
+
+// rpmbResult issues a Result Read Request following a key programming or write request, and
+// returns the response to it.
+func (d *NVMeDevice) rpmbResult(target uint8, reqType uint16) (*rpmbFrame, []byte, error) {
+	req := rpmbFrame{Target: target, Type: rpmbReadResult}
+
+	if err := d.rpmbSend(target, req.marshal(nil)); err != nil {
+		return nil, nil, err
+	}
+
+	return d.rpmbRecv(target, reqType, 0, nil, nil)
+}
+
+func (d *NVMeDevice) rpmbSend(target uint8, frame []byte) error {
+	return d.SecuritySend(0, SecurityProtocolRPMB, 0, target, frame)
+}
+
+// rpmbRecv receives the response to a request of the specified type, carrying the specified
+// number of data sectors. If req is non-nil, the response nonce must match that of the request.
+// If key is non-nil, the response MAC is verified.
+func (d *NVMeDevice) rpmbRecv(target uint8, reqType uint16, sectors int, req *rpmbFrame,
+	key []byte) (*rpmbFrame, []byte, error) {
+
+	buf := make([]byte, rpmbFrameLen+sectors*rpmbSectorLen)
+
+	if err := d.SecurityReceive(0, SecurityProtocolRPMB, 0, target, buf); err != nil {
+		return nil, nil, err
+	}
+
+	var resp rpmbFrame
+
+	binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &resp)
+
+	if resp.Type != reqType|rpmbResponseFlag {
+		return nil, nil, fmt.Errorf("RPMB: unexpected response type %#04x", resp.Type)
+	} else if r := RPMBResult(resp.Result); r != RPMBOK {
+		return nil, nil, r
+	} else if req != nil && resp.Nonce != req.Nonce {
+		return nil, nil, fmt.Errorf("RPMB: response nonce mismatch")
+	} else if key != nil && !hmac.Equal(resp.Mac[:], rpmbMAC(key, buf)) {
+		return nil, nil, fmt.Errorf("RPMB: response MAC mismatch")
+	}
+
+	return &resp, buf[rpmbFrameLen:], nil
+}
+
+// rpmbMAC calculates the HMAC-SHA256 of an RPMB data frame, which covers the frame from the target
+// field to the end of the data.
+func rpmbMAC(key, frame []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(frame[rpmbMACStart:])
+
+	return mac.Sum(nil)
+}
+
+// rpmbFrame is the low-level struct of an RPMB Data Frame, excluding the data.
+type rpmbFrame struct {
+	Stuff        [191]byte // Stuff Bytes
+	Mac          [32]byte  // Authentication Key or Message Authentication Code
+	Target       uint8     // RPMB Target
+	Nonce        [16]byte  // Nonce
+	WriteCounter uint32    // Write Counter
+	Address      uint32    // Address
+	Sectors      uint32    // Sector Count
+	Result       uint16    // Result
+	Type         uint16    // Request / Response Message Type
+} // 256 bytes
+
+// marshal returns the encoded frame, followed by data.
+func (f *rpmbFrame) marshal(data []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, rpmbFrameLen+len(data)))
+
+	binary.Write(buf, binary.LittleEndian, f)
+	buf.Write(data)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,218 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Sanitize Capabilities (SANICAP) bits, cf. NVM Express Base Specification 2.0c, figure 275.
+const (
+	sanicapCryptoErase = 1 << 0
+	sanicapBlockErase  = 1 << 1
+	sanicapOverwrite   = 1 << 2
+)
+
+// SanitizeConfig is the value of the Sanitize Config feature (FID 0x17).
+type SanitizeConfig struct {
+	// No-Deallocate Response Mode. If true, a sanitize command requesting No-Deallocate After
+	// Sanitize is performed and completed with a warning status, instead of being aborted, when
+	// the controller inhibits that option.
+	NoDeallocResponseMode bool
+}
+
+// GetSanitizeConfig returns the current value of the Sanitize Config feature.
+func (d *NVMeDevice) GetSanitizeConfig() (SanitizeConfig, error) {
+	if err := d.checkSanitize(); err != nil {
+		return SanitizeConfig{}, err
+	}
+
+	val, err := d.getFeature(NVME_FEAT_SANITIZE_CONFIG, 0, NVME_FEAT_SEL_CURRENT, 0, nil)
+	if err != nil {
+		return SanitizeConfig{}, err
+	}
+
+	return SanitizeConfig{NoDeallocResponseMode: val&1 != 0}, nil
+}
+
+// SetSanitizeConfig sets the Sanitize Config feature. If save is true, the setting persists
+// across power cycles and resets.
+func (d *NVMeDevice) SetSanitizeConfig(cfg SanitizeConfig, save bool) error {
+	if err := d.checkSanitize(); err != nil {
+		return err
+	}
+
+	var cdw11 uint32
+
+	if cfg.NoDeallocResponseMode {
+		cdw11 |= 1
+	}
+
+	_, err := d.setFeature(NVME_FEAT_SANITIZE_CONFIG, 0, save, cdw11, 0, nil)
+	return err
+}
+
+// checkSanitize consults the controller's SANICAP field to determine whether any sanitize
+// operation is supported.
+func (d *NVMeDevice) checkSanitize() error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Sanicap&(sanicapCryptoErase|sanicapBlockErase|sanicapOverwrite) == 0 {
+		return fmt.Errorf("sanitize: %w", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// Sanitize Action (SANACT) values, cf. NVM Express Base Specification 2.0c, figure 372.
+const (
+	sanactExitFailure = 0x1
+	sanactBlockErase  = 0x2
+	sanactOverwrite   = 0x3
+	sanactCryptoErase = 0x4
+)
+
+// Status of the most recent sanitize operation (SSTAT bits 2:0), cf. NVM Express Base
+// Specification 2.0c, figure 287.
+const (
+	sstatNever              = 0x0
+	sstatCompleted          = 0x1
+	sstatInProgress         = 0x2
+	sstatFailed             = 0x3
+	sstatCompletedNoDealloc = 0x4
+)
+
+// sstatGlobalDataErased is the Global Data Erased bit of the SSTAT field.
+const sstatGlobalDataErased = 1 << 8
+
+// readSanitizeLog reads the Sanitize Status log page, returning both the raw log page and its
+// low-level decoding.
+func (d *NVMeDevice) readSanitizeLog() ([]byte, *nvmeSanitizeLog, error) {
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_SANITIZE, 0xffffffff, false, buf); err != nil {
+		return nil, nil, err
+	}
+
+	var sl nvmeSanitizeLog
+
+	sl.unmarshal(buf)
+
+	return buf, &sl, nil
+}
+
+// SanitizeState is the status of the most recent sanitize operation.
+type SanitizeState uint8
+
+const (
+	SanitizeNever              SanitizeState = sstatNever
+	SanitizeCompleted          SanitizeState = sstatCompleted
+	SanitizeInProgress         SanitizeState = sstatInProgress
+	SanitizeFailed             SanitizeState = sstatFailed
+	SanitizeCompletedNoDealloc SanitizeState = sstatCompletedNoDealloc
+)
+
+func (s SanitizeState) String() string {
+	switch s {
+	case SanitizeNever:
+		return "never sanitized"
+	case SanitizeCompleted:
+		return "completed"
+	case SanitizeInProgress:
+		return "in progress"
+	case SanitizeFailed:
+		return "failed"
+	case SanitizeCompletedNoDealloc:
+		return "completed without deallocation"
+	}
+
+	return fmt.Sprintf("unknown (%d)", uint8(s))
+}
+
+// SanitizeStatus is the decoded Sanitize Status log page (0x81).
+type SanitizeStatus struct {
+	State SanitizeState
+	// Sanitize Progress (SPROG), the fraction of the operation completed in units of 1/65536.
+	// It is 65535 if no sanitize operation is in progress.
+	Progress         uint16
+	OverwritePasses  uint8 // Completed passes of an overwrite operation
+	GlobalDataErased bool  // No user data has been written since manufacture or the last sanitize
+	Action           uint8 // Sanitize Action (SANACT) of the most recent sanitize command
+	CDW10            uint32
+	// Estimated times for each sanitize operation, zero if not reported
+	EstOverwrite   time.Duration
+	EstBlockErase  time.Duration
+	EstCryptoErase time.Duration
+}
+
+// PercentComplete returns the progress of the sanitize operation as a percentage.
+func (s *SanitizeStatus) PercentComplete() float64 {
+	if s.State != SanitizeInProgress {
+		return 100
+	}
+
+	return float64(s.Progress) * 100 / 65536
+}
+
+// Print outputs the sanitize status in a pretty-print style.
+func (s *SanitizeStatus) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgSanitizeStatus), s.State, s.PercentComplete(), s.GlobalDataErased,
+		s.OverwritePasses)
+	fmt.Fprintf(w, msg(MsgSanitizeEstimates), s.EstOverwrite, s.EstBlockErase, s.EstCryptoErase)
+}
+
+// GetSanitizeStatus reads the Sanitize Status log page, which reports the progress of a sanitize
+// operation in progress, or the outcome of the most recent one.
+func (d *NVMeDevice) GetSanitizeStatus() (*SanitizeStatus, error) {
+	if err := d.checkSanitize(); err != nil {
+		return nil, err
+	}
+
+	_, sl, err := d.readSanitizeLog()
+	if err != nil {
+		return nil, err
+	}
+
+	return sl.decode(), nil
+}
+
+func (sl *nvmeSanitizeLog) decode() *SanitizeStatus {
+	return &SanitizeStatus{
+		State:            SanitizeState(sl.Sstat & 0x7),
+		Progress:         sl.Sprog,
+		OverwritePasses:  uint8(sl.Sstat>>3) & 0x1f,
+		GlobalDataErased: sl.Sstat&sstatGlobalDataErased != 0,
+		Action:           uint8(sl.Scdw10 & 0x7),
+		CDW10:            sl.Scdw10,
+		EstOverwrite:     sanitizeEstimate(sl.Eto),
+		EstBlockErase:    sanitizeEstimate(sl.Etbe),
+		EstCryptoErase:   sanitizeEstimate(sl.Etce),
+	}
+}
+
+// sanitizeEstimate converts an estimated sanitize time in seconds to a duration. A value of
+// 0xffffffff indicates that no estimate is reported.
+func sanitizeEstimate(secs uint32) time.Duration {
+	if secs == 0xffffffff {
+		return 0
+	}
+
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,288 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// oncsVerify is the Verify command support bit of the ONCS field.
+const oncsVerify = 1 << 7
+
+// sctMediaError is the Media and Data Integrity Errors status code type.
+const sctMediaError = 0x2
+
+// LBARange is a range of logical blocks.
+type LBARange struct {
+	Start uint64
+	Count uint64
+}
+
+// ScrubOptions controls the behaviour of Scrub.
+type ScrubOptions struct {
+	Start     uint64 // First LBA to scrub, e.g. the Next LBA of an interrupted scrub
+	RateLimit uint64 // Maximum scrub rate in bytes per second, 0 for unlimited
+
+	// BadRanges are the unreadable ranges found by an interrupted scrub, which are carried over
+	// into the report when resuming it.
+	BadRanges []LBARange
+
+	// Checkpoint is called after each chunk with the report so far, whose Next LBA and BadRanges
+	// may be persisted in order to resume an interrupted scrub.
+	Checkpoint func(r *ScrubReport)
+}
+
+// ScrubReport is the result of a (possibly interrupted) scrub.
+type ScrubReport struct {
+	NSID      uint32
+	Verify    bool       // Scrubbed with Verify commands, rather than reads
+	Blocks    uint64     // Namespace size, in logical blocks
+	Scrubbed  uint64     // Logical blocks scrubbed by this run
+	Next      uint64     // Next LBA to be scrubbed, equal to Blocks once complete
+	BadRanges []LBARange // Unreadable ranges
+
+	// Untracked are the parts of the unreadable ranges which the controller does not report as
+	// potentially unrecoverable in response to Get LBA Status. Only set if LBAStatusChecked.
+	Untracked        []LBARange
+	LBAStatusChecked bool
+}
+
+// Print outputs the scrub report in a pretty-print style.
+func (r *ScrubReport) Print(w io.Writer) {
+	method := "read"
+	if r.Verify {
+		method = "verify"
+	}
+
+	fmt.Fprintf(w, msg(MsgScrubProgress), r.NSID, r.Next, r.Blocks, method)
+
+	for _, br := range r.BadRanges {
+		fmt.Fprintf(w, msg(MsgScrubBadRange), br.Start, br.Start+br.Count-1, br.Count)
+	}
+
+	for _, ur := range r.Untracked {
+		fmt.Fprintf(w, msg(MsgScrubUntracked), ur.Start, ur.Start+ur.Count-1, ur.Count)
+	}
+}
+
+// Scrub walks a namespace checking that all logical blocks are readable, using Verify commands if
+// the controller supports them and reads otherwise, in chunks limited by the controller's maximum
+// data transfer size. Unreadable chunks are retried block by block to determine the unreadable
+// ranges, which are cross-checked against Get LBA Status once the scrub completes, if the
+// controller supports it. The scrub stops when ctx is cancelled, in which case the report and
+// ctx.Err() are returned, and the scrub can later be resumed from the report's Next LBA.
+func (d *NVMeDevice) Scrub(ctx context.Context, nsid uint32, opts ScrubOptions) (*ScrubReport, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := d.identifyNamespace(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	_, lbaf := ns.currentLBAF()
+	if lbaf.Ds < 9 {
+		return nil, fmt.Errorf("namespace %d: invalid LBA data size", nsid)
+	}
+
+	lbaSize := 1 << lbaf.Ds
+
+	chunkLen, err := d.maxXferLen(defaultXferLen)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ScrubReport{
+		NSID:      nsid,
+		Verify:    idCtrlr.Oncs&oncsVerify != 0,
+		Blocks:    ns.Nsze,
+		Next:      opts.Start,
+		BadRanges: append([]LBARange(nil), opts.BadRanges...),
+	}
+
+	// Scrub at least one block at a time, even if the LBA size exceeds the maximum transfer size
+	chunkBlocks := uint64(chunkLen / lbaSize)
+	if chunkBlocks == 0 {
+		chunkBlocks = 1
+	}
+
+	buf := make([]byte, chunkBlocks*uint64(lbaSize))
+	started := time.Now()
+
+	for r.Next < r.Blocks {
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+
+		nlb := chunkBlocks
+		if r.Next+nlb > r.Blocks {
+			nlb = r.Blocks - r.Next
+		}
+
+		if err := d.scrubRange(nsid, r.Next, nlb, buf[:nlb*uint64(lbaSize)], r.Verify); err != nil {
+			if !isMediaError(err) {
+				return r, fmt.Errorf("scrub LBA %d: %w", r.Next, err)
+			}
+
+			// Retry block by block to pinpoint the unreadable blocks
+			for lba := r.Next; lba < r.Next+nlb; lba++ {
+				err := d.scrubRange(nsid, lba, 1, buf[:lbaSize], r.Verify)
+				if err == nil {
+					continue
+				} else if !isMediaError(err) {
+					return r, fmt.Errorf("scrub LBA %d: %w", lba, err)
+				}
+
+				r.addBadBlock(lba)
+			}
+		}
+
+		r.Next += nlb
+		r.Scrubbed += nlb
+
+		if opts.Checkpoint != nil {
+			opts.Checkpoint(r)
+		}
+
+		if opts.RateLimit > 0 {
+			due := time.Duration(float64(r.Scrubbed*uint64(lbaSize)) / float64(opts.RateLimit) *
+				float64(time.Second))
+
+			if wait := due - time.Since(started); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return r, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+	}
+
+	if len(r.BadRanges) > 0 && idCtrlr.Oacs&oacsGetLBAStatus != 0 {
+		if err := d.checkLBAStatus(r); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// checkLBAStatus cross-checks the unreadable ranges of a scrub report against the potentially
+// unrecoverable ranges tracked by the controller, recording those which are not tracked.
+func (d *NVMeDevice) checkLBAStatus(r *ScrubReport) error {
+	for _, br := range r.BadRanges {
+		var tracked []LBARange
+
+		for slba, end := br.Start, br.Start+br.Count; slba < end; {
+			count := end - slba
+			if count > 0xffff {
+				count = 0xffff
+			}
+
+			s, err := d.GetLBAStatus(r.NSID, slba, uint16(count), LBAStatusTracked)
+			if err != nil {
+				return fmt.Errorf("get LBA status of LBA %d: %w", slba, err)
+			}
+
+			tracked = append(tracked, s.Ranges...)
+			slba += count
+		}
+
+		r.Untracked = append(r.Untracked, subtractRanges(br, tracked)...)
+	}
+
+	r.LBAStatusChecked = true
+
+	return nil
+}
+
+// subtractRanges returns the parts of r not covered by any of the ranges in sub.
+func subtractRanges(r LBARange, sub []LBARange) []LBARange {
+	sort.Slice(sub, func(i, j int) bool { return sub[i].Start < sub[j].Start })
+
+	var rem []LBARange
+
+	next, end := r.Start, r.Start+r.Count
+
+	for _, s := range sub {
+		if next >= end {
+			break
+		}
+
+		if s.Start > next {
+			stop := s.Start
+			if stop > end {
+				stop = end
+			}
+
+			rem = append(rem, LBARange{Start: next, Count: stop - next})
+		}
+
+		if s.Start+s.Count > next {
+			next = s.Start + s.Count
+		}
+	}
+
+	if next < end {
+		rem = append(rem, LBARange{Start: next, Count: end - next})
+	}
+
+	return rem
+}
+
+// addBadBlock records an unreadable block, extending the last bad range if contiguous.
+func (r *ScrubReport) addBadBlock(lba uint64) {
+	if n := len(r.BadRanges); n > 0 {
+		last := &r.BadRanges[n-1]
+		if last.Start+last.Count == lba {
+			last.Count++
+			return
+		}
+	}
+
+	r.BadRanges = append(r.BadRanges, LBARange{Start: lba, Count: 1})
+}
+
+// scrubRange verifies or reads the specified range of logical blocks. Verify transfers no data,
+// so buf is only used for reads.
+func (d *NVMeDevice) scrubRange(nsid uint32, slba, nlb uint64, buf []byte, verify bool) error {
+	if !verify {
+		return d.rwBlocks(NVME_CMD_READ, nsid, slba, buf, len(buf)/int(nlb))
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_CMD_VERIFY,
+		nsid:   nsid,
+		cdw10:  uint32(slba),
+		cdw11:  uint32(slba >> 32),
+		cdw12:  uint32(nlb - 1), // Number of Logical Blocks (0's based)
+	}
+
+	return d.ioCmd(&cmd, nil)
+}
+
+// isMediaError returns true if err is a media and data integrity error status.
+func isMediaError(err error) bool {
+	var status NVMeStatus
+
+	return errors.As(err, &status) && status.SCT() == sctMediaError
+}
@@ -0,0 +1,115 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// oacsSecurity is the Security Send and Security Receive support bit of the OACS field.
+const oacsSecurity = 1 << 0
+
+// Security protocols, cf. SPC-5 table 276 (SECURITY PROTOCOL field).
+const (
+	SecurityProtocolInfo = 0x00 // Security protocol information
+	SecurityProtocolTCG1 = 0x01 // TCG (Opal, Pyrite, etc.) Level 0 discovery and sessions
+	SecurityProtocolTCG2 = 0x02 // TCG ComID management
+	SecurityProtocolRPMB = 0xea // NVMe Replay Protected Memory Block
+)
+
+// SecuritySend transfers data to the controller for the specified security protocol (SECP) and
+// SP specific value (SPSP), e.g. a TCG ComID. nssf is the NVMe Security Specific Field, which is
+// only used by some protocols (e.g. the RPMB target).
+func (d *NVMeDevice) SecuritySend(nsid uint32, secp uint8, spsp uint16, nssf uint8, data []byte) error {
+	if err := d.checkSecurity(); err != nil {
+		return err
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_SECURITY_SEND,
+		nsid:   nsid,
+		cdw10:  securityCdw10(secp, spsp, nssf),
+		cdw11:  uint32(len(data)), // Transfer Length
+	}
+
+	if len(data) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&data[0])))
+		cmd.data_len = uint32(len(data))
+	}
+
+	return d.adminCmd(&cmd, data)
+}
+
+// SecurityReceive transfers data from the controller for the specified security protocol (SECP)
+// and SP specific value (SPSP) into buf, which determines the allocation length.
+func (d *NVMeDevice) SecurityReceive(nsid uint32, secp uint8, spsp uint16, nssf uint8, buf []byte) error {
+	if len(buf) == 0 {
+		return fmt.Errorf("invalid buffer size")
+	}
+
+	if err := d.checkSecurity(); err != nil {
+		return err
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode:   NVME_ADMIN_SECURITY_RECV,
+		nsid:     nsid,
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		data_len: uint32(len(buf)),
+		cdw10:    securityCdw10(secp, spsp, nssf),
+		cdw11:    uint32(len(buf)), // Allocation Length
+	}
+
+	return d.adminCmd(&cmd, buf)
+}
+
+// SecurityProtocols returns the security protocols supported by the controller, as reported by
+// the supported security protocol list of security protocol 0x00.
+func (d *NVMeDevice) SecurityProtocols() ([]uint8, error) {
+	buf := make([]byte, 512)
+
+	if err := d.SecurityReceive(0, SecurityProtocolInfo, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	// Bytes 6-7 hold the (big-endian) length of the list that follows
+	n := int(buf[6])<<8 | int(buf[7])
+	if n > len(buf)-8 {
+		n = len(buf) - 8
+	}
+
+	return append([]uint8(nil), buf[8:8+n]...), nil
+}
+
+// securityCdw10 encodes command dword 10 of the Security Send and Security Receive commands.
+func securityCdw10(secp uint8, spsp uint16, nssf uint8) uint32 {
+	return uint32(secp)<<24 | uint32(spsp)<<8 | uint32(nssf)
+}
+
+// checkSecurity returns an error if the controller does not support the Security Send and
+// Security Receive commands.
+func (d *NVMeDevice) checkSecurity() error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Oacs&oacsSecurity == 0 {
+		return fmt.Errorf("security send / receive: %w", ErrNotSupported)
+	}
+
+	return nil
+}
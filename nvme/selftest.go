@@ -0,0 +1,213 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// oacsSelfTest is the Device Self-test support bit of the OACS field.
+const oacsSelfTest = 1 << 4
+
+// SelfTestCode is the Self-test Code (STC) of a Device Self-test command.
+type SelfTestCode uint8
+
+const (
+	SelfTestShort    SelfTestCode = 0x1
+	SelfTestExtended SelfTestCode = 0x2
+	SelfTestVendor   SelfTestCode = 0xe
+	selfTestAbort    SelfTestCode = 0xf
+)
+
+func (c SelfTestCode) String() string {
+	switch c {
+	case 0:
+		return "none"
+	case SelfTestShort:
+		return "short"
+	case SelfTestExtended:
+		return "extended"
+	case SelfTestVendor:
+		return "vendor"
+	}
+
+	return fmt.Sprintf("%#x", uint8(c))
+}
+
+// SelfTestStatus is the result of a completed (or aborted) device self-test.
+type SelfTestStatus uint8
+
+func (s SelfTestStatus) String() string {
+	switch s {
+	case 0x0:
+		return "completed without error"
+	case 0x1:
+		return "aborted by a Device Self-test command"
+	case 0x2:
+		return "aborted by a controller level reset"
+	case 0x3:
+		return "aborted due to a removal of a namespace"
+	case 0x4:
+		return "aborted due to a Format NVM command"
+	case 0x5:
+		return "fatal or unknown test error"
+	case 0x6:
+		return "completed with failed segment (unknown)"
+	case 0x7:
+		return "completed with one or more failed segments"
+	case 0x8:
+		return "aborted for unknown reason"
+	case 0x9:
+		return "aborted due to a sanitize operation"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(s))
+}
+
+// Failed returns true if the self-test found a fault.
+func (s SelfTestStatus) Failed() bool {
+	return s >= 0x5 && s <= 0x7
+}
+
+// SelfTestResult is a single entry of the self-test log.
+type SelfTestResult struct {
+	Code         SelfTestCode
+	Status       SelfTestStatus
+	Segment      uint8 // First failed segment, if any
+	PowerOnHours uint64
+	NSID         uint32 // Namespace of the failing LBA, 0 if not valid
+	FailingLBA   uint64
+	FailingLBAOK bool   // FailingLBA is valid
+	NVMeStatus   uint16 // Status (SCT << 8 | SC) of the failing command, 0 if not valid
+}
+
+// SelfTestLog is the decoded Device Self-test log page (0x06).
+type SelfTestLog struct {
+	CurrentOperation  SelfTestCode // Zero if no self-test is in progress
+	CurrentCompletion uint8        // Percent complete of the current self-test
+	Results           []SelfTestResult
+}
+
+// Print outputs the self-test log in a pretty-print style.
+func (l *SelfTestLog) Print(w io.Writer) {
+	if l.CurrentOperation == 0 {
+		fmt.Fprint(w, msg(MsgSelfTestIdle))
+	} else {
+		fmt.Fprintf(w, msg(MsgSelfTestCurrent), l.CurrentOperation, l.CurrentCompletion)
+	}
+
+	if len(l.Results) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, msg(MsgSelfTestHeader))
+
+	for i, r := range l.Results {
+		fmt.Fprintf(w, msg(MsgSelfTestResult), i, r.Code, r.Status, r.Segment, r.PowerOnHours)
+
+		if r.FailingLBAOK {
+			fmt.Fprintf(w, msg(MsgSelfTestFailingLBA), r.FailingLBA, r.NSID, r.NVMeStatus)
+		}
+	}
+}
+
+// StartSelfTest starts a device self-test of the specified type. An nsid of 0 tests only the
+// controller, and 0xffffffff tests the controller and all namespaces.
+func (d *NVMeDevice) StartSelfTest(nsid uint32, code SelfTestCode) error {
+	switch code {
+	case SelfTestShort, SelfTestExtended, SelfTestVendor:
+	default:
+		return fmt.Errorf("invalid self-test code %#x", uint8(code))
+	}
+
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	if idCtrlr.Oacs&oacsSelfTest == 0 {
+		return fmt.Errorf("device self-test: %w", ErrNotSupported)
+	}
+
+	return d.selfTest(nsid, code)
+}
+
+// AbortSelfTest aborts a device self-test in progress.
+func (d *NVMeDevice) AbortSelfTest() error {
+	return d.selfTest(0xffffffff, selfTestAbort)
+}
+
+func (d *NVMeDevice) selfTest(nsid uint32, code SelfTestCode) error {
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_SELF_TEST,
+		nsid:   nsid,
+		cdw10:  uint32(code),
+	}
+
+	return d.adminCmd(&cmd, nil)
+}
+
+// GetSelfTestLog reads and decodes the Device Self-test log page. Only valid result entries are
+// returned, newest first.
+func (d *NVMeDevice) GetSelfTestLog() (*SelfTestLog, error) {
+	buf := make([]byte, 564)
+
+	if err := d.getLogPage(NVME_LOG_DEVICE_SELF_TEST, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeSelfTestLog
+
+	raw.unmarshal(buf)
+
+	l := &SelfTestLog{
+		CurrentOperation:  SelfTestCode(raw.CurrentOperation & 0xf),
+		CurrentCompletion: raw.CurrentCompletion & 0x7f,
+	}
+
+	for _, r := range raw.Results {
+		// A status of 0xf indicates an unused entry
+		if r.Status&0xf == 0xf {
+			continue
+		}
+
+		res := SelfTestResult{
+			Code:         SelfTestCode(r.Status >> 4),
+			Status:       SelfTestStatus(r.Status & 0xf),
+			Segment:      r.Segment,
+			PowerOnHours: binary.LittleEndian.Uint64(r.PowerOnHours[:]),
+		}
+
+		if r.ValidInfo&(1<<0) != 0 {
+			res.NSID = r.NSID
+		}
+		if r.ValidInfo&(1<<1) != 0 {
+			res.FailingLBA = binary.LittleEndian.Uint64(r.FailingLBA[:])
+			res.FailingLBAOK = true
+		}
+		if r.ValidInfo&(1<<2) != 0 {
+			res.NVMeStatus = uint16(r.StatusCodeType&0x7) << 8
+		}
+		if r.ValidInfo&(1<<3) != 0 {
+			res.NVMeStatus |= uint16(r.StatusCode)
+		}
+
+		l.Results = append(l.Results, res)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Critical Warning bits of the SMART / Health Information log page, cf. NVM Express Base
+// Specification 2.0c, figure 207.
+const (
+	CritWarnSpare          = 1 << 0 // Available spare capacity below threshold
+	CritWarnTemperature    = 1 << 1 // Temperature above / below threshold
+	CritWarnReliability    = 1 << 2 // NVM subsystem reliability degraded
+	CritWarnReadOnly       = 1 << 3 // Media placed in read-only mode
+	CritWarnVolatileBackup = 1 << 4 // Volatile memory backup device failed
+	CritWarnPMRReadOnly    = 1 << 5 // Persistent memory region placed in read-only mode
+)
+
+// SMARTLog is the decoded SMART / Health Information log page (0x02).
+type SMARTLog struct {
+	CritWarning      uint8     `json:"crit_warning"`
+	Temperature      uint16    `json:"temperature_kelvin"` // Composite temperature, in Kelvin
+	AvailSpare       uint8     `json:"avail_spare"`        // Percent
+	SpareThresh      uint8     `json:"spare_thresh"`       // Percent
+	PercentUsed      uint8     `json:"percent_used"`
+	DataUnitsRead    *big.Int  `json:"data_units_read"`    // In units of 1000 512-byte blocks
+	DataUnitsWritten *big.Int  `json:"data_units_written"` // In units of 1000 512-byte blocks
+	HostReads        *big.Int  `json:"host_reads"`
+	HostWrites       *big.Int  `json:"host_writes"`
+	CtrlBusyTime     *big.Int  `json:"ctrl_busy_time"` // Minutes
+	PowerCycles      *big.Int  `json:"power_cycles"`
+	PowerOnHours     *big.Int  `json:"power_on_hours"`
+	UnsafeShutdowns  *big.Int  `json:"unsafe_shutdowns"`
+	MediaErrors      *big.Int  `json:"media_errors"`
+	NumErrLogEntries *big.Int  `json:"num_err_log_entries"`
+	WarningTempTime  uint32    `json:"warning_temp_time"`  // Minutes
+	CritCompTime     uint32    `json:"crit_comp_time"`     // Minutes
+	TempSensor       [8]uint16 `json:"temp_sensor_kelvin"` // Kelvin, zero if not implemented
+}
+
+// MarshalJSON encodes the SMART log with its temperatures both in Kelvin and in the unit selected
+// by SetTemperatureUnit. Temperature sensors which are not implemented are encoded as null.
+func (sl *SMARTLog) MarshalJSON() ([]byte, error) {
+	// smartLog has the fields of SMARTLog, but not its MarshalJSON method
+	type smartLog SMARTLog
+
+	u := currentTemperatureUnit()
+
+	v := struct {
+		*smartLog
+		Unit        TemperatureUnit `json:"temperature_unit"`
+		Temperature float64         `json:"temperature"`
+		TempSensor  [8]*float64     `json:"temp_sensor"`
+	}{
+		smartLog:    (*smartLog)(sl),
+		Unit:        u,
+		Temperature: u.FromKelvin(sl.Temperature),
+	}
+
+	for i, k := range sl.TempSensor {
+		if k != 0 {
+			t := u.FromKelvin(k)
+			v.TempSensor[i] = &t
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+// lpaSMARTPerNS is the per-namespace SMART / Health Information log page support bit of the LPA
+// field.
+const lpaSMARTPerNS = 1 << 0
+
+// GetSMARTLog reads and decodes the controller's SMART / Health Information log page.
+func (d *NVMeDevice) GetSMARTLog() (*SMARTLog, error) {
+	return d.getSMARTLog(0xffffffff)
+}
+
+// GetNamespaceSMARTLog reads and decodes the SMART / Health Information log page of a single
+// namespace, e.g. to attribute wear to the tenants of a shared controller. The controller must
+// support per-namespace SMART information (LPA bit 0); otherwise only the controller-wide log page
+// returned by GetSMARTLog is available. Controller-wide fields, such as the available spare, are
+// reported identically for each namespace.
+func (d *NVMeDevice) GetNamespaceSMARTLog(nsid uint32) (*SMARTLog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Lpa&lpaSMARTPerNS == 0 {
+		return nil, fmt.Errorf("per-namespace SMART log: %w", ErrNotSupported)
+	}
+
+	return d.getSMARTLog(nsid)
+}
+
+func (d *NVMeDevice) getSMARTLog(nsid uint32) (*SMARTLog, error) {
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_SMART, nsid, false, buf); err != nil {
+		return nil, err
+	}
+
+	var sl nvmeSMARTLog
+
+	sl.unmarshal(buf)
+
+	return sl.decode(), nil
+}
+
+func (sl *nvmeSMARTLog) decode() *SMARTLog {
+	return &SMARTLog{
+		CritWarning:      sl.CritWarning,
+		Temperature:      uint16(sl.Temperature[0]) | uint16(sl.Temperature[1])<<8,
+		AvailSpare:       sl.AvailSpare,
+		SpareThresh:      sl.SpareThresh,
+		PercentUsed:      sl.PercentUsed,
+		DataUnitsRead:    le128ToBigInt(sl.DataUnitsRead),
+		DataUnitsWritten: le128ToBigInt(sl.DataUnitsWritten),
+		HostReads:        le128ToBigInt(sl.HostReads),
+		HostWrites:       le128ToBigInt(sl.HostWrites),
+		CtrlBusyTime:     le128ToBigInt(sl.CtrlBusyTime),
+		PowerCycles:      le128ToBigInt(sl.PowerCycles),
+		PowerOnHours:     le128ToBigInt(sl.PowerOnHours),
+		UnsafeShutdowns:  le128ToBigInt(sl.UnsafeShutdowns),
+		MediaErrors:      le128ToBigInt(sl.MediaErrors),
+		NumErrLogEntries: le128ToBigInt(sl.NumErrLogEntries),
+		WarningTempTime:  sl.WarningTempTime,
+		CritCompTime:     sl.CritCompTime,
+		TempSensor:       sl.TempSensor,
+	}
+}
+
+// Print outputs the SMART log in a pretty-print style.
+func (sl *SMARTLog) Print(w io.Writer) {
+	unit := big.NewInt(512 * 1000)
+
+	fmt.Fprint(w, msg(MsgSMARTHeader))
+	fmt.Fprintf(w, msg(MsgSMARTCritWarning), sl.CritWarning)
+	fmt.Fprintf(w, msg(MsgSMARTTemperature), FormatTemperature(sl.Temperature))
+	fmt.Fprintf(w, msg(MsgSMARTAvailSpare), sl.AvailSpare)
+	fmt.Fprintf(w, msg(MsgSMARTSpareThresh), sl.SpareThresh)
+	fmt.Fprintf(w, msg(MsgSMARTPercentUsed), sl.PercentUsed)
+	fmt.Fprintf(w, msg(MsgSMARTDataUnitsRead),
+		sl.DataUnitsRead, formatBigBytes(new(big.Int).Mul(sl.DataUnitsRead, unit)))
+	fmt.Fprintf(w, msg(MsgSMARTDataUnitsWritten),
+		sl.DataUnitsWritten, formatBigBytes(new(big.Int).Mul(sl.DataUnitsWritten, unit)))
+	fmt.Fprintf(w, msg(MsgSMARTHostReads), sl.HostReads)
+	fmt.Fprintf(w, msg(MsgSMARTHostWrites), sl.HostWrites)
+	fmt.Fprintf(w, msg(MsgSMARTCtrlBusyTime), sl.CtrlBusyTime)
+	fmt.Fprintf(w, msg(MsgSMARTPowerCycles), sl.PowerCycles)
+	fmt.Fprintf(w, msg(MsgSMARTPowerOnHours), sl.PowerOnHours)
+	fmt.Fprintf(w, msg(MsgSMARTUnsafeShutdowns), sl.UnsafeShutdowns)
+	fmt.Fprintf(w, msg(MsgSMARTMediaErrors), sl.MediaErrors)
+	fmt.Fprintf(w, msg(MsgSMARTNumErrLogEntries), sl.NumErrLogEntries)
+
+	for i, t := range sl.TempSensor {
+		if t != 0 {
+			fmt.Fprintf(w, msg(MsgSMARTTempSensor), i+1, FormatTemperature(t))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"sync"
+)
+
+// CommandStats counts the commands issued in one opcode class.
+type CommandStats struct {
+	Commands uint64 // Commands submitted
+	Errors   uint64 // Commands which failed, either in the kernel or with a non-zero status
+	Bytes    uint64 // Data transferred by successful commands
+}
+
+func (s *CommandStats) add(dataLen uint32, err error) {
+	s.Commands++
+
+	if err != nil {
+		s.Errors++
+	} else {
+		s.Bytes += uint64(dataLen)
+	}
+}
+
+// HandleStats counts the commands issued through a device handle, by opcode class. I/O commands
+// are classified by the data transfer direction encoded in the opcode.
+type HandleStats struct {
+	Admin   CommandStats
+	IORead  CommandStats // Controller to host transfers, e.g. Read
+	IOWrite CommandStats // Host to controller transfers, e.g. Write
+	IOOther CommandStats // Commands without data transfer, e.g. Flush and Verify
+}
+
+// handleStats guards the HandleStats of a device handle.
+type handleStats struct {
+	mu    sync.Mutex
+	stats HandleStats
+}
+
+// record accounts for a submitted command.
+func (h *handleStats) record(req uintptr, cmd *nvmePassthruCommand, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if req == NVME_IOCTL_ADMIN_CMD {
+		h.stats.Admin.add(cmd.data_len, err)
+		return
+	}
+
+	// Opcode bits 1:0 indicate the data transfer direction
+	switch cmd.opcode & 0x3 {
+	case 0x1:
+		h.stats.IOWrite.add(cmd.data_len, err)
+	case 0x2:
+		h.stats.IORead.add(cmd.data_len, err)
+	default:
+		h.stats.IOOther.add(cmd.data_len, err)
+	}
+}
+
+// Stats returns the commands issued through the device handle since it was created or the
+// statistics were last reset, so that applications can attribute their own passthrough load.
+func (d *NVMeDevice) Stats() HandleStats {
+	d.stats.mu.Lock()
+	defer d.stats.mu.Unlock()
+
+	return d.stats.stats
+}
+
+// ResetStats resets the statistics of the device handle.
+func (d *NVMeDevice) ResetStats() {
+	d.stats.mu.Lock()
+	defer d.stats.mu.Unlock()
+
+	d.stats.stats = HandleStats{}
+}
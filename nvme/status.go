@@ -0,0 +1,176 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"errors"
+	"fmt"
+)
+
+// cf. NVM Express Base Specification 2.0c, section 4.6.1.2: Status Code Type (SCT) and Status
+// Code (SC) values. The constants combine SCT and SC, i.e. (SCT << 8) | SC.
+const (
+	// Generic Command Status
+	NVME_SC_SUCCESS              uint16 = 0x000
+	NVME_SC_INVALID_OPCODE       uint16 = 0x001
+	NVME_SC_INVALID_FIELD        uint16 = 0x002
+	NVME_SC_CMDID_CONFLICT       uint16 = 0x003
+	NVME_SC_DATA_XFER_ERROR      uint16 = 0x004
+	NVME_SC_POWER_LOSS           uint16 = 0x005
+	NVME_SC_INTERNAL             uint16 = 0x006
+	NVME_SC_ABORT_REQ            uint16 = 0x007
+	NVME_SC_INVALID_NS           uint16 = 0x00b
+	NVME_SC_RESERVATION_CONFLICT uint16 = 0x018
+	NVME_SC_NS_WRITE_PROTECTED   uint16 = 0x020
+
+	// Command Specific Status
+	NVME_SC_ABORT_LIMIT            uint16 = 0x103
+	NVME_SC_INVALID_FW_SLOT        uint16 = 0x106
+	NVME_SC_INVALID_FW_IMAGE       uint16 = 0x107
+	NVME_SC_INVALID_LOG_PAGE       uint16 = 0x109
+	NVME_SC_FW_NEEDS_CONV_RESET    uint16 = 0x10b
+	NVME_SC_FEATURE_NOT_SAVEABLE   uint16 = 0x10d
+	NVME_SC_FEATURE_NOT_CHANGEABLE uint16 = 0x10e
+	NVME_SC_FEATURE_NOT_PER_NS     uint16 = 0x10f
+	NVME_SC_FW_NEEDS_SUBSYS_RESET  uint16 = 0x110
+	NVME_SC_FW_NEEDS_RESET         uint16 = 0x111
+	NVME_SC_FW_NEEDS_MAX_TIME      uint16 = 0x112
+	NVME_SC_FW_ACTIVATE_PROHIBITED uint16 = 0x113
+	NVME_SC_OVERLAPPING_RANGE      uint16 = 0x114
+)
+
+// Bits above the status code type in the status value reported by the kernel.
+const (
+	nvmeStatusMore = 0x2000 // More information available in the Error Information log
+	nvmeStatusDNR  = 0x4000 // Do Not Retry
+)
+
+// ErrNotSupported is returned (wrapped) when a command is known to be unsupported by the
+// controller, either because it previously failed with an "unsupported" status, or because the
+// controller's identify data indicates as much.
+var ErrNotSupported = errors.New("not supported by controller")
+
+// NVMeStatus is the status field of a completion queue entry, as returned by the kernel when the
+// controller completes a passthrough command with a non-zero status.
+type NVMeStatus uint16
+
+// Code returns the combined status code type and status code, for comparison against the
+// NVME_SC_* constants.
+func (s NVMeStatus) Code() uint16 {
+	return uint16(s) & 0x7ff
+}
+
+// SCT returns the status code type.
+func (s NVMeStatus) SCT() uint8 {
+	return uint8((s >> 8) & 0x7)
+}
+
+// SC returns the status code.
+func (s NVMeStatus) SC() uint8 {
+	return uint8(s)
+}
+
+// DNR returns true if the controller indicated that the command should not be retried.
+func (s NVMeStatus) DNR() bool {
+	return s&nvmeStatusDNR != 0
+}
+
+// ResetRequired returns true if the status indicates that a firmware commit succeeded, but that
+// some form of reset is required to activate the new firmware image.
+func (s NVMeStatus) ResetRequired() bool {
+	switch s.Code() {
+	case NVME_SC_FW_NEEDS_CONV_RESET, NVME_SC_FW_NEEDS_SUBSYS_RESET, NVME_SC_FW_NEEDS_RESET,
+		NVME_SC_FW_NEEDS_MAX_TIME:
+		return true
+	}
+
+	return false
+}
+
+func (s NVMeStatus) Error() string {
+	var desc string
+
+	switch s.Code() {
+	case NVME_SC_INVALID_OPCODE:
+		desc = "invalid command opcode"
+	case NVME_SC_INVALID_FIELD:
+		desc = "invalid field in command"
+	case NVME_SC_CMDID_CONFLICT:
+		desc = "command ID conflict"
+	case NVME_SC_DATA_XFER_ERROR:
+		desc = "data transfer error"
+	case NVME_SC_POWER_LOSS:
+		desc = "commands aborted due to power loss notification"
+	case NVME_SC_INTERNAL:
+		desc = "internal error"
+	case NVME_SC_ABORT_REQ:
+		desc = "command abort requested"
+	case NVME_SC_INVALID_NS:
+		desc = "invalid namespace or format"
+	case NVME_SC_RESERVATION_CONFLICT:
+		desc = "reservation conflict"
+	case NVME_SC_NS_WRITE_PROTECTED:
+		desc = "namespace is write protected"
+	case NVME_SC_ABORT_LIMIT:
+		desc = "abort command limit exceeded"
+	case NVME_SC_INVALID_FW_SLOT:
+		desc = "invalid firmware slot"
+	case NVME_SC_INVALID_FW_IMAGE:
+		desc = "invalid firmware image"
+	case NVME_SC_INVALID_LOG_PAGE:
+		desc = "invalid log page"
+	case NVME_SC_FW_NEEDS_CONV_RESET:
+		desc = "firmware activation requires conventional reset"
+	case NVME_SC_FEATURE_NOT_SAVEABLE:
+		desc = "feature identifier not saveable"
+	case NVME_SC_FEATURE_NOT_CHANGEABLE:
+		desc = "feature not changeable"
+	case NVME_SC_FEATURE_NOT_PER_NS:
+		desc = "feature not namespace specific"
+	case NVME_SC_FW_NEEDS_SUBSYS_RESET:
+		desc = "firmware activation requires NVM subsystem reset"
+	case NVME_SC_FW_NEEDS_RESET:
+		desc = "firmware activation requires controller level reset"
+	case NVME_SC_FW_NEEDS_MAX_TIME:
+		desc = "firmware activation requires maximum time violation"
+	case NVME_SC_FW_ACTIVATE_PROHIBITED:
+		desc = "firmware activation prohibited"
+	case NVME_SC_OVERLAPPING_RANGE:
+		desc = "overlapping range"
+	default:
+		desc = "unknown status"
+	}
+
+	return fmt.Sprintf("NVMe status %#03x (sct=%d, sc=%#02x): %s", s.Code(), s.SCT(), s.SC(), desc)
+}
+
+// isUnsupportedStatus returns true if err is an NVMe status which indicates that the controller
+// does not implement the requested command or log page. Invalid Field in Command is not considered
+// to indicate a lack of support, since it may equally be caused by a rejected argument (e.g. an
+// NSID, LSP or offset), while other arguments would succeed.
+func isUnsupportedStatus(err error) bool {
+	var s NVMeStatus
+
+	if !errors.As(err, &s) {
+		return false
+	}
+
+	switch s.Code() {
+	case NVME_SC_INVALID_OPCODE, NVME_SC_INVALID_LOG_PAGE:
+		return true
+	}
+
+	return false
+}
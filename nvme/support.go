@@ -0,0 +1,299 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// probeLogPages lists the optional log pages which are tried during a capability probe.
+var probeLogPages = []uint8{
+	NVME_LOG_ERROR,
+	NVME_LOG_SMART,
+	NVME_LOG_FW_SLOT,
+	NVME_LOG_CHANGED_NS,
+	NVME_LOG_CMD_EFFECTS,
+	NVME_LOG_DEVICE_SELF_TEST,
+	NVME_LOG_FID_EFFECTS,
+	NVME_LOG_SANITIZE,
+}
+
+// SupportMatrix records which optional log pages and features a controller has been found to
+// support. Results are learned either by an explicit probe pass (see NVMeDevice.Probe), or
+// opportunistically as commands complete, and are used to skip requests which are known to fail.
+type SupportMatrix struct {
+	mu       sync.Mutex
+	logPages map[supportKey]bool
+	features map[supportKey]bool
+}
+
+// supportKey identifies a log page or feature. Vendor specific log pages and features may differ
+// between UUID indexes, so their support is recorded separately for each index.
+type supportKey struct {
+	id        uint8
+	uuidIndex uint8
+}
+
+func newSupportMatrix() *SupportMatrix {
+	return &SupportMatrix{logPages: make(map[supportKey]bool), features: make(map[supportKey]bool)}
+}
+
+// LogPage reports whether the specified log page is supported, when requested without a UUID
+// index. If the log page has not yet been requested from the controller, known is false.
+func (m *SupportMatrix) LogPage(logID uint8) (supported, known bool) {
+	return m.LogPageUUID(logID, 0)
+}
+
+// LogPageUUID reports whether the specified log page is supported, when requested with the
+// specified UUID index. If the log page has not yet been requested from the controller with that
+// index, known is false.
+func (m *SupportMatrix) LogPageUUID(logID, uuidIndex uint8) (supported, known bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	supported, known = m.logPages[supportKey{logID, uuidIndex}]
+	return
+}
+
+// Feature reports whether the specified feature is supported, when requested without a UUID
+// index. If the feature has not yet been requested from the controller, known is false.
+func (m *SupportMatrix) Feature(fid uint8) (supported, known bool) {
+	return m.FeatureUUID(fid, 0)
+}
+
+// FeatureUUID reports whether the specified feature is supported, when requested with the
+// specified UUID index. If the feature has not yet been requested from the controller with that
+// index, known is false.
+func (m *SupportMatrix) FeatureUUID(fid, uuidIndex uint8) (supported, known bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	supported, known = m.features[supportKey{fid, uuidIndex}]
+	return
+}
+
+// Reset discards all cached results, e.g. after a firmware update.
+func (m *SupportMatrix) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.logPages = make(map[supportKey]bool)
+	m.features = make(map[supportKey]bool)
+}
+
+// Print outputs the cached results in a pretty-print style.
+func (m *SupportMatrix) Print(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range sortedSupportKeys(m.logPages) {
+		if k.uuidIndex != 0 {
+			fmt.Fprintf(w, msg(MsgSupportLogPageUUID), k.id, k.uuidIndex,
+				supportedStr(m.logPages[k]))
+		} else {
+			fmt.Fprintf(w, msg(MsgSupportLogPage), k.id, supportedStr(m.logPages[k]))
+		}
+	}
+
+	for _, k := range sortedSupportKeys(m.features) {
+		if k.uuidIndex != 0 {
+			fmt.Fprintf(w, msg(MsgSupportFeatureUUID), k.id, k.uuidIndex,
+				supportedStr(m.features[k]))
+		} else {
+			fmt.Fprintf(w, msg(MsgSupportFeature), k.id, supportedStr(m.features[k]))
+		}
+	}
+}
+
+// recordLogPage caches the outcome of a Get Log Page command issued with the specified UUID
+// index. Errors which do not indicate a lack of support (e.g., insufficient privileges) are not
+// cached.
+func (m *SupportMatrix) recordLogPage(logID, uuidIndex uint8, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record(m.logPages, supportKey{logID, uuidIndex}, err)
+}
+
+// recordFeature caches the outcome of a Get / Set Features command issued with the specified UUID
+// index.
+func (m *SupportMatrix) recordFeature(fid, uuidIndex uint8, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record(m.features, supportKey{fid, uuidIndex}, err)
+}
+
+// setLogPage records the support status of a log page without a UUID index, as advertised by the
+// controller.
+func (m *SupportMatrix) setLogPage(logID uint8, supported bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.logPages[supportKey{id: logID}] = supported
+}
+
+// setFeature records the support status of a feature without a UUID index, as advertised by the
+// controller.
+func (m *SupportMatrix) setFeature(fid uint8, supported bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.features[supportKey{id: fid}] = supported
+}
+
+func record(set map[supportKey]bool, k supportKey, err error) {
+	if err == nil {
+		set[k] = true
+	} else if isUnsupportedStatus(err) {
+		set[k] = false
+	}
+}
+
+func sortedSupportKeys(set map[supportKey]bool) []supportKey {
+	keys := make([]supportKey, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].id != keys[j].id {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].uuidIndex < keys[j].uuidIndex
+	})
+
+	return keys
+}
+
+func sortedKeys[V any](set map[uint8]V) []uint8 {
+	keys := make([]uint8, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
+
+func supportedStr(b bool) string {
+	if b {
+		return msg(MsgSupported)
+	}
+	return msg(MsgNotSupported)
+}
+
+// Support returns the device's cached capability matrix.
+func (d *NVMeDevice) Support() *SupportMatrix {
+	return d.support
+}
+
+// Probe performs a capability probe pass. If the controller supports the Supported Log Pages log,
+// the supported log pages are recorded from it. Otherwise, the minimum amount of data is requested
+// from each optional log page in order to record whether the controller supports it. Asynchronous
+// events are retained, so the probe does not interfere with event-driven monitoring. If the
+// controller supports the Feature Identifiers Supported and Effects log, the supported features are
+// also recorded. Previously cached results are discarded.
+func (d *NVMeDevice) Probe() *SupportMatrix {
+	buf := make([]byte, 4)
+
+	d.support.Reset()
+
+	if _, err := d.GetSupportedLogPages(); err != nil {
+		for _, id := range probeLogPages {
+			d.getLogPage(id, 0xffffffff, true, buf)
+		}
+	}
+
+	if effects, err := d.readFeatureEffects(); err == nil {
+		for fid, e := range effects {
+			d.support.setFeature(uint8(fid), e&fidEffectSupported != 0)
+		}
+	}
+
+	return d.support
+}
+
+// Supported Log Pages log entry bits.
+const (
+	logSupported   = 1 << 0 // LID Supported (LSUPP)
+	logIndexOffset = 1 << 1 // Index Offset Supported (IOS)
+)
+
+// SupportedLogPages is the decoded Supported Log Pages log page (0x00), with an entry for each log
+// identifier.
+type SupportedLogPages [256]uint32
+
+// Supported reports whether the controller supports the specified log page.
+func (l *SupportedLogPages) Supported(logID uint8) bool {
+	return l[logID]&logSupported != 0
+}
+
+// IndexOffset reports whether the log page may be read with an index offset, i.e. with the
+// offset in units of log page entries rather than bytes.
+func (l *SupportedLogPages) IndexOffset(logID uint8) bool {
+	return l[logID]&logIndexOffset != 0
+}
+
+// SpecificParameter returns the LID Specific Parameter of the log page, which for some log pages
+// indicates the supported Log Specific Field (LSP) values.
+func (l *SupportedLogPages) SpecificParameter(logID uint8) uint16 {
+	return uint16(l[logID] >> 16)
+}
+
+// Print outputs the supported log pages in a pretty-print style.
+func (l *SupportedLogPages) Print(w io.Writer) {
+	for id := 0; id < len(l); id++ {
+		if l.Supported(uint8(id)) {
+			fmt.Fprintf(w, msg(MsgSupportedLogPage), id, l.IndexOffset(uint8(id)),
+				l.SpecificParameter(uint8(id)))
+		}
+	}
+}
+
+// GetSupportedLogPages reads the Supported Log Pages log page, and records the supported log pages
+// in the device's capability matrix, so that requests for unsupported log pages fail with
+// ErrNotSupported without being sent to the controller.
+func (d *NVMeDevice) GetSupportedLogPages() (*SupportedLogPages, error) {
+	buf := make([]byte, 1024)
+
+	if err := d.getLogPage(NVME_LOG_SUPPORTED_PAGES, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	l := new(SupportedLogPages)
+
+	for i := range l {
+		l[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+
+	// A controller which reports that it does not support this log page is not to be trusted
+	if !l.Supported(NVME_LOG_SUPPORTED_PAGES) {
+		return nil, fmt.Errorf("invalid supported log pages log")
+	}
+
+	// Vendor specific log pages may be supported only with a non-zero UUID index, so the log,
+	// which is read without one, is not authoritative for them
+	for id := 0; id < vendorSpecificID; id++ {
+		d.support.setLogPage(uint8(id), l.Supported(uint8(id)))
+	}
+
+	return l, nil
+}
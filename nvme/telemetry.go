@@ -0,0 +1,202 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// Log Page Attributes (LPA) bits relating to telemetry.
+const (
+	lpaTelemetry      = 1 << 3 // Telemetry Host-Initiated and Controller-Initiated log pages
+	lpaTelemetryArea4 = 1 << 6 // Telemetry Data Area 4
+)
+
+// telemetryBlockSize is the unit of the telemetry data area last block fields.
+const telemetryBlockSize = 512
+
+// TelemetryHeader is the decoded header (first block) of a telemetry log page.
+type TelemetryHeader struct {
+	LogID uint8
+	OUI   uint32 // IEEE OUI of the vendor defining the data areas
+	// Last block of each data area, 1 to 4. The data areas are contiguous, and block 0 is the
+	// header. A data area is empty if its last block equals that of the previous data area.
+	LastBlock            [4]uint32
+	HostGeneration       uint8 // Host-initiated data generation number
+	ControllerAvailable  bool  // Controller-initiated data is available
+	ControllerGeneration uint8 // Controller-initiated data generation number
+	ReasonID             [128]byte
+}
+
+// Size returns the length in bytes of the log page, including the header, up to the end of the
+// specified data area (1 to 4).
+func (h *TelemetryHeader) Size(area int) int64 {
+	if area < 1 || area > 4 {
+		return telemetryBlockSize
+	}
+
+	// Data area 4 is optional, and its last block field is zero if unsupported or disabled
+	last := h.LastBlock[area-1]
+	for i := area - 1; i > 0 && last < h.LastBlock[i-1]; i-- {
+		last = h.LastBlock[i-1]
+	}
+
+	return int64(last+1) * telemetryBlockSize
+}
+
+// generation returns the data generation number applicable to the specified telemetry log page.
+func (h *TelemetryHeader) generation(logID uint8) uint8 {
+	if logID == NVME_LOG_TELEMETRY_CTRL {
+		return h.ControllerGeneration
+	}
+
+	return h.HostGeneration
+}
+
+// Print outputs the telemetry header in a pretty-print style.
+func (h *TelemetryHeader) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgTelemetryHeader), h.LogID, h.OUI, h.HostGeneration,
+		h.ControllerAvailable, h.ControllerGeneration)
+
+	for i := 1; i <= 4; i++ {
+		fmt.Fprintf(w, msg(MsgTelemetryArea), i, h.LastBlock[i-1], h.Size(i))
+	}
+}
+
+func decodeTelemetryHeader(buf []byte) *TelemetryHeader {
+	var raw nvmeTelemetryHeader
+
+	raw.unmarshal(buf)
+
+	return &TelemetryHeader{
+		LogID: raw.Lid,
+		// Convert IEEE OUI ID from big-endian
+		OUI:                  uint32(raw.Ieee[0]) | uint32(raw.Ieee[1])<<8 | uint32(raw.Ieee[2])<<16,
+		LastBlock:            [4]uint32{uint32(raw.Da1lb), uint32(raw.Da2lb), uint32(raw.Da3lb), raw.Da4lb},
+		HostGeneration:       raw.Hostdgn,
+		ControllerAvailable:  raw.Ctrlavail&1 != 0,
+		ControllerGeneration: raw.Ctrldgn,
+		ReasonID:             raw.Rsnident,
+	}
+}
+
+// CaptureHostTelemetry requests the controller to capture new host-initiated telemetry data, and
+// writes the log page, from its header up to the end of the specified data area (1 to 4), to w.
+// The result is in the format expected by vendor analysis tools. Data area 4 is only populated if
+// the controller supports it, and it was enabled with SetHostBehavior.
+func (d *NVMeDevice) CaptureHostTelemetry(w io.Writer, area int) (*TelemetryHeader, error) {
+	return d.readTelemetry(NVME_LOG_TELEMETRY_HOST, true, false, area, w)
+}
+
+// GetControllerTelemetryStatus reads the header of the controller-initiated telemetry log page,
+// without clearing the Telemetry Log Changed asynchronous event. New controller-initiated data is
+// indicated by ControllerAvailable, and a ControllerGeneration differing from a previous read.
+func (d *NVMeDevice) GetControllerTelemetryStatus() (*TelemetryHeader, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Lpa&lpaTelemetry == 0 {
+		return nil, fmt.Errorf("telemetry log: %w", ErrNotSupported)
+	}
+
+	buf := make([]byte, telemetryBlockSize)
+
+	if err := d.getLog(NVME_LOG_TELEMETRY_CTRL, logPageArgs{rae: true}, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeTelemetryHeader(buf), nil
+}
+
+// SaveControllerTelemetry writes the controller-initiated telemetry log page, from its header up
+// to the end of the specified data area (1 to 4), to w. The log page is read with the Retain
+// Asynchronous Event bit set, so that reading it does not clear the controller's notification
+// state. The result is in the format expected by vendor analysis tools.
+func (d *NVMeDevice) SaveControllerTelemetry(w io.Writer, area int) (*TelemetryHeader, error) {
+	return d.readTelemetry(NVME_LOG_TELEMETRY_CTRL, false, true, area, w)
+}
+
+// readTelemetry reads a telemetry log page up to the end of the specified data area, and writes it
+// to w once its generation number has been verified to be unchanged by the read. If create is true,
+// new host-initiated data is captured first. The log page is read with the Retain Asynchronous
+// Event bit set to rae.
+func (d *NVMeDevice) readTelemetry(logID uint8, create, rae bool, area int, w io.Writer) (*TelemetryHeader, error) {
+	if area < 1 || area > 4 {
+		return nil, fmt.Errorf("invalid telemetry data area %d", area)
+	}
+
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Lpa&lpaTelemetry == 0 || (area == 4 && idCtrlr.Lpa&lpaTelemetryArea4 == 0) {
+		return nil, fmt.Errorf("telemetry data area %d: %w", area, ErrNotSupported)
+	}
+
+	buf := make([]byte, telemetryBlockSize)
+
+	args := logPageArgs{rae: rae}
+	if create {
+		args.lsp = 1 // Create Telemetry Host-Initiated Data
+	}
+
+	if err := d.getLog(logID, args, buf); err != nil {
+		return nil, err
+	}
+
+	hdr := decodeTelemetryHeader(buf)
+
+	// The data is buffered, so that nothing is written if it turns out to be inconsistent
+	data := make([]byte, hdr.Size(area))
+	copy(data, buf)
+
+	args.lsp = 0
+
+	for off := telemetryBlockSize; off < len(data); off += 0x4000 {
+		chunk := data[off:]
+		if len(chunk) > 0x4000 {
+			chunk = chunk[:0x4000]
+		}
+
+		args.offset = uint64(off)
+
+		if err := d.getLog(logID, args, chunk); err != nil {
+			return nil, fmt.Errorf("telemetry offset %d: %w", off, err)
+		}
+	}
+
+	// The data may have been replaced while it was being read, in which case the generation
+	// number in the header will have changed.
+	args.offset = 0
+
+	if err := d.getLog(logID, args, buf); err != nil {
+		return nil, err
+	}
+
+	if gen := decodeTelemetryHeader(buf).generation(logID); gen != hdr.generation(logID) {
+		return nil, fmt.Errorf("telemetry data generation changed from %d to %d during read",
+			hdr.generation(logID), gen)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	return hdr, nil
+}
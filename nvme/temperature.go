@@ -0,0 +1,102 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// TemperatureUnit is the unit in which temperatures are presented. NVMe controllers report
+// temperatures in Kelvin, which remain available in the raw fields of decoded structures.
+type TemperatureUnit int32
+
+const (
+	Celsius TemperatureUnit = iota
+	Fahrenheit
+	Kelvin
+)
+
+var temperatureUnit int32 // TemperatureUnit, accessed atomically
+
+// SetTemperatureUnit sets the unit used by the printers in this package. The default is Celsius.
+func SetTemperatureUnit(u TemperatureUnit) {
+	atomic.StoreInt32(&temperatureUnit, int32(u))
+}
+
+// ParseTemperatureUnit parses a unit name or symbol, e.g. "celsius", "F" or "kelvin".
+func ParseTemperatureUnit(s string) (TemperatureUnit, error) {
+	switch strings.ToLower(s) {
+	case "c", "celsius":
+		return Celsius, nil
+	case "f", "fahrenheit":
+		return Fahrenheit, nil
+	case "k", "kelvin":
+		return Kelvin, nil
+	}
+
+	return 0, fmt.Errorf("unknown temperature unit %q", s)
+}
+
+// FromKelvin converts a temperature in Kelvin, as reported by the controller, to the unit. As per
+// the NVMe specification, 0° Celsius is taken to be 273 Kelvin.
+func (u TemperatureUnit) FromKelvin(k uint16) float64 {
+	c := float64(k) - 273
+
+	switch u {
+	case Fahrenheit:
+		return c*9/5 + 32
+	case Kelvin:
+		return float64(k)
+	}
+
+	return c
+}
+
+// MarshalText encodes the unit by the name accepted by ParseTemperatureUnit.
+func (u TemperatureUnit) MarshalText() ([]byte, error) {
+	switch u {
+	case Fahrenheit:
+		return []byte("fahrenheit"), nil
+	case Kelvin:
+		return []byte("kelvin"), nil
+	}
+
+	return []byte("celsius"), nil
+}
+
+func (u TemperatureUnit) String() string {
+	switch u {
+	case Fahrenheit:
+		return "°F"
+	case Kelvin:
+		return "K"
+	}
+
+	return "°C"
+}
+
+// currentTemperatureUnit returns the unit selected by SetTemperatureUnit.
+func currentTemperatureUnit() TemperatureUnit {
+	return TemperatureUnit(atomic.LoadInt32(&temperatureUnit))
+}
+
+// FormatTemperature formats a temperature in Kelvin in the unit selected by SetTemperatureUnit.
+func FormatTemperature(k uint16) string {
+	u := currentTemperatureUnit()
+
+	return fmt.Sprintf("%.0f %s", u.FromKelvin(k), u)
+}
@@ -0,0 +1,70 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+)
+
+// ThermalStress holds the time a controller spent above its composite temperature thresholds
+// between two SMART log samples. Increases indicate ongoing thermal stress, which a snapshot of
+// the composite temperature can easily miss.
+type ThermalStress struct {
+	WarningTempTime uint32 // Minutes above the warning composite temperature threshold
+	CritCompTime    uint32 // Minutes above the critical composite temperature threshold
+}
+
+// ThermalEvent is generated when the time spent above a temperature threshold increases.
+type ThermalEvent struct {
+	Critical bool
+	Minutes  uint32
+}
+
+func (e ThermalEvent) String() string {
+	if e.Critical {
+		return fmt.Sprintf(msg(MsgThermalCritical), e.Minutes)
+	}
+
+	return fmt.Sprintf(msg(MsgThermalWarning), e.Minutes)
+}
+
+// DeriveThermalStress computes the thermal stress between two SMART log samples. An error is
+// returned if either counter decreased, which indicates that the samples were not taken from the
+// same device.
+func DeriveThermalStress(prev, cur *SMARTLog) (ThermalStress, error) {
+	if cur.WarningTempTime < prev.WarningTempTime || cur.CritCompTime < prev.CritCompTime {
+		return ThermalStress{}, fmt.Errorf("SMART counter decreased between samples")
+	}
+
+	return ThermalStress{
+		WarningTempTime: cur.WarningTempTime - prev.WarningTempTime,
+		CritCompTime:    cur.CritCompTime - prev.CritCompTime,
+	}, nil
+}
+
+// Events returns an event for each threshold whose time increased.
+func (t ThermalStress) Events() []ThermalEvent {
+	var events []ThermalEvent
+
+	if t.CritCompTime > 0 {
+		events = append(events, ThermalEvent{Critical: true, Minutes: t.CritCompTime})
+	}
+
+	if t.WarningTempTime > 0 {
+		events = append(events, ThermalEvent{Minutes: t.WarningTempTime})
+	}
+
+	return events
+}
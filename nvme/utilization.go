@@ -0,0 +1,101 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Utilization holds metrics derived from the difference between two SMART log samples. Since the
+// controller busy time is only reported in minutes, the busy ratio is only meaningful over
+// intervals of several minutes or more.
+type Utilization struct {
+	Interval time.Duration
+
+	// Fraction of the interval during which the controller had I/O commands outstanding
+	BusyRatio float64
+
+	ReadCmdsPerSec   float64
+	WriteCmdsPerSec  float64
+	ReadBytesPerSec  float64
+	WriteBytesPerSec float64
+
+	// Host commands completed per second of controller busy time, i.e. the average queue pressure
+	// while the controller was busy. Zero if no busy time elapsed.
+	CmdsPerBusySec float64
+}
+
+// Print outputs the utilization metrics in a pretty-print style.
+func (u *Utilization) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgUtilInterval), u.Interval)
+	fmt.Fprintf(w, msg(MsgUtilBusy), u.BusyRatio*100)
+	fmt.Fprintf(w, msg(MsgUtilIOPS), u.ReadCmdsPerSec, u.WriteCmdsPerSec)
+	fmt.Fprintf(w, msg(MsgUtilThroughput),
+		formatBigBytes(big.NewInt(int64(u.ReadBytesPerSec))),
+		formatBigBytes(big.NewInt(int64(u.WriteBytesPerSec))))
+	fmt.Fprintf(w, msg(MsgUtilCmdsPerBusy), u.CmdsPerBusySec)
+}
+
+// DeriveUtilization computes utilization metrics from two SMART log samples taken interval apart.
+// An error is returned if any counter decreased, which indicates that the counters were reset or
+// that the samples were not taken from the same device.
+func DeriveUtilization(prev, cur *SMARTLog, interval time.Duration) (Utilization, error) {
+	if interval <= 0 {
+		return Utilization{}, fmt.Errorf("invalid sample interval %s", interval)
+	}
+
+	deltas := make([]float64, 5)
+
+	for i, pair := range [][2]*big.Int{
+		{prev.CtrlBusyTime, cur.CtrlBusyTime},
+		{prev.HostReads, cur.HostReads},
+		{prev.HostWrites, cur.HostWrites},
+		{prev.DataUnitsRead, cur.DataUnitsRead},
+		{prev.DataUnitsWritten, cur.DataUnitsWritten},
+	} {
+		delta := new(big.Int).Sub(pair[1], pair[0])
+		if delta.Sign() < 0 {
+			return Utilization{}, fmt.Errorf("SMART counter decreased between samples")
+		}
+
+		deltas[i], _ = new(big.Float).SetInt(delta).Float64()
+	}
+
+	secs := interval.Seconds()
+	busySecs := deltas[0] * 60
+
+	u := Utilization{
+		Interval:         interval,
+		BusyRatio:        busySecs / secs,
+		ReadCmdsPerSec:   deltas[1] / secs,
+		WriteCmdsPerSec:  deltas[2] / secs,
+		ReadBytesPerSec:  deltas[3] * 512 * 1000 / secs,
+		WriteBytesPerSec: deltas[4] * 512 * 1000 / secs,
+	}
+
+	// Busy time is rounded to whole minutes, so clamp the ratio
+	if u.BusyRatio > 1 {
+		u.BusyRatio = 1
+	}
+
+	if busySecs > 0 {
+		u.CmdsPerBusySec = (deltas[1] + deltas[2]) / busySecs
+	}
+
+	return u, nil
+}
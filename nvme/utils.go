@@ -68,3 +68,15 @@ func le128ToBigInt(buf [16]byte) *big.Int {
 
 	return new(big.Int).SetBytes(rev)
 }
+
+func boolToUint8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// formatUUID formats a 16-byte UUID in its canonical form.
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
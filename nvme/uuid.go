@@ -0,0 +1,147 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// ctrattUUIDList is the UUID List support bit of the CTRATT field.
+	ctrattUUIDList = 1 << 9
+
+	// vendorSpecificID is the first vendor specific log page and feature identifier.
+	vendorSpecificID = 0xc0
+)
+
+// UUIDAssociation indicates the vendor with which a UUID of the UUID List is associated.
+type UUIDAssociation uint8
+
+const (
+	UUIDAssocNone       UUIDAssociation = 0 // No association reported
+	UUIDAssocController UUIDAssociation = 1 // Vendor of the controller's PCI Vendor ID
+	UUIDAssocSubsystem  UUIDAssociation = 2 // Vendor of the subsystem's PCI Subsystem Vendor ID
+)
+
+func (a UUIDAssociation) String() string {
+	switch a {
+	case UUIDAssocNone:
+		return "none"
+	case UUIDAssocController:
+		return "controller vendor"
+	case UUIDAssocSubsystem:
+		return "subsystem vendor"
+	}
+
+	return fmt.Sprintf("unknown (%d)", uint8(a))
+}
+
+// UUIDEntry is an entry of the UUID List.
+type UUIDEntry struct {
+	Index       uint8 // UUID index, for use with SetUUIDIndex
+	Association UUIDAssociation
+	UUID        string // UUID, in its canonical form
+}
+
+// UUIDList is the decoded UUID List, identifying the vendor specific log pages and features
+// implemented by the controller, e.g. where the firmware incorporates components from several
+// vendors which use the same identifiers.
+type UUIDList []UUIDEntry
+
+// Print outputs the UUID List in a pretty-print style.
+func (l UUIDList) Print(w io.Writer) {
+	for _, e := range l {
+		fmt.Fprintf(w, msg(MsgUUIDEntry), e.Index, e.UUID, e.Association)
+	}
+}
+
+// Index returns the UUID index of the specified UUID, which is matched case-insensitively.
+func (l UUIDList) Index(uuid string) (uint8, bool) {
+	for _, e := range l {
+		if strings.EqualFold(e.UUID, uuid) {
+			return e.Index, true
+		}
+	}
+
+	return 0, false
+}
+
+// GetUUIDList returns the UUID List of the controller.
+func (d *NVMeDevice) GetUUIDList() (UUIDList, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if idCtrlr.Ctratt&ctrattUUIDList == 0 {
+		return nil, fmt.Errorf("UUID list: %w", ErrNotSupported)
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_UUID_LIST, NVME_CSI_NVM, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeUUIDList(buf), nil
+}
+
+func decodeUUIDList(buf []byte) UUIDList {
+	var l UUIDList
+
+	// Up to 128 32-byte entries follow a 32-byte header. The list is terminated by a zero UUID.
+	for i := 0; i < 128 && 32*(i+2) <= len(buf); i++ {
+		e := buf[32*(i+1) : 32*(i+2)]
+
+		if bytes.Equal(e[16:], make([]byte, 16)) {
+			break
+		}
+
+		l = append(l, UUIDEntry{
+			Index:       uint8(i + 1),
+			Association: UUIDAssociation(e[0] & 0x3),
+			UUID:        formatUUID(e[16:]),
+		})
+	}
+
+	return l
+}
+
+// SetUUIDIndex selects the UUID, by its index in the UUID List, with which subsequent commands for
+// vendor specific log pages (0xC0 to 0xFF) and features (0xC0 to 0xFF) are issued. An index of
+// zero, the default, specifies no UUID. Standard log pages and features are not affected. The
+// support of vendor specific log pages and features is cached separately for each index.
+func (d *NVMeDevice) SetUUIDIndex(index uint8) error {
+	if index > 127 {
+		return fmt.Errorf("invalid UUID index %d", index)
+	}
+
+	d.uuidIndex = index
+
+	return nil
+}
+
+// vendorUUIDIndex returns the UUID index with which the specified log page or feature is to be
+// requested, i.e. the index selected with SetUUIDIndex for vendor specific identifiers, or zero.
+func (d *NVMeDevice) vendorUUIDIndex(id uint8) uint8 {
+	if id >= vendorSpecificID {
+		return d.uuidIndex
+	}
+
+	return 0
+}
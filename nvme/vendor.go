@@ -0,0 +1,214 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// VendorLog is a vendor specific log page contributed by a VendorPlugin.
+type VendorLog struct {
+	Name  string // Short name, unique within the plugin, e.g. "smart-add"
+	Usage string // One-line description
+
+	// Print reads the log page from the device and prints it in a pretty-print style.
+	Print func(d *NVMeDevice, w io.Writer) error
+}
+
+// VendorCommand is a vendor specific command line command contributed by a VendorPlugin, e.g. to
+// issue vendor specific admin commands or to decode several related log pages.
+type VendorCommand struct {
+	Name  string // Short name, unique within the plugin, e.g. "clear-pcie-errors"
+	Usage string // One-line description
+
+	// Run executes the command on the device, with the command line arguments following the
+	// command name, writing its output to w.
+	Run func(d *NVMeDevice, args []string, w io.Writer) error
+}
+
+// VendorPlugin contributes decoders of vendor specific log pages and vendor specific commands, in
+// the manner of nvme-cli's vendor plugins. A plugin applies to a controller whose PCI vendor ID or
+// IEEE OUI matches one of those listed.
+type VendorPlugin struct {
+	Name      string // Unique name, e.g. "intel"
+	VendorIDs []uint16
+	OUIs      []uint32
+	Logs      []VendorLog
+	Commands  []VendorCommand
+}
+
+// matches reports whether the plugin applies to a controller with the specified VID and OUI.
+func (p *VendorPlugin) matches(vid uint16, oui uint32) bool {
+	for _, v := range p.VendorIDs {
+		if v == vid {
+			return true
+		}
+	}
+
+	for _, o := range p.OUIs {
+		if o == oui {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Log returns the plugin's log page of the specified name.
+func (p *VendorPlugin) Log(name string) (VendorLog, bool) {
+	for _, l := range p.Logs {
+		if l.Name == name {
+			return l, true
+		}
+	}
+
+	return VendorLog{}, false
+}
+
+// Command returns the plugin's command of the specified name.
+func (p *VendorPlugin) Command(name string) (VendorCommand, bool) {
+	for _, c := range p.Commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+
+	return VendorCommand{}, false
+}
+
+var (
+	vendorMu      sync.RWMutex
+	vendorPlugins = make(map[string]VendorPlugin)
+)
+
+// RegisterVendorPlugin makes a vendor plugin available, typically from the init function of the
+// package implementing it. It panics if a plugin of the same name is already registered.
+func RegisterVendorPlugin(p VendorPlugin) {
+	vendorMu.Lock()
+	defer vendorMu.Unlock()
+
+	if _, dup := vendorPlugins[p.Name]; dup {
+		panic("nvme: RegisterVendorPlugin called twice for plugin " + p.Name)
+	}
+
+	vendorPlugins[p.Name] = p
+}
+
+// LookupVendorPlugins returns the registered plugins applicable to a controller with the specified
+// PCI vendor ID and IEEE OUI, sorted by name.
+func LookupVendorPlugins(vid uint16, oui uint32) []VendorPlugin {
+	vendorMu.RLock()
+	defer vendorMu.RUnlock()
+
+	var plugins []VendorPlugin
+
+	for _, p := range vendorPlugins {
+		if p.matches(vid, oui) {
+			plugins = append(plugins, p)
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+
+	return plugins
+}
+
+// VendorPlugins returns the registered plugins applicable to the device's controller.
+func (d *NVMeDevice) VendorPlugins() ([]VendorPlugin, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	oui := uint32(idCtrlr.IEEE[0]) | uint32(idCtrlr.IEEE[1])<<8 | uint32(idCtrlr.IEEE[2])<<16
+
+	return LookupVendorPlugins(idCtrlr.VendorID, oui), nil
+}
+
+// PrintVendorLog reads and prints the vendor specific log page of the specified name, from the
+// first applicable plugin which provides it.
+func (d *NVMeDevice) PrintVendorLog(w io.Writer, name string) error {
+	plugins, err := d.VendorPlugins()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plugins {
+		if l, ok := p.Log(name); ok {
+			return l.Print(d, w)
+		}
+	}
+
+	return fmt.Errorf("vendor log %q: %w", name, ErrNotSupported)
+}
+
+// RunVendorCommand runs the vendor specific command of the specified name, from the first
+// applicable plugin which provides it, with the specified command line arguments.
+func (d *NVMeDevice) RunVendorCommand(w io.Writer, name string, args []string) error {
+	plugins, err := d.VendorPlugins()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plugins {
+		if c, ok := p.Command(name); ok {
+			return c.Run(d, args, w)
+		}
+	}
+
+	return fmt.Errorf("vendor command %q: %w", name, ErrNotSupported)
+}
+
+// printer adapts a log page getter to VendorLog.Print.
+func printer[T interface{ Print(io.Writer) }](get func(*NVMeDevice) (T, error),
+) func(*NVMeDevice, io.Writer) error {
+	return func(d *NVMeDevice, w io.Writer) error {
+		l, err := get(d)
+		if err != nil {
+			return err
+		}
+
+		l.Print(w)
+		return nil
+	}
+}
+
+// Built-in vendor plugins.
+func init() {
+	RegisterVendorPlugin(VendorPlugin{
+		Name:      "intel",
+		VendorIDs: []uint16{vidIntel, vidSolidigm},
+		Logs: []VendorLog{
+			{"smart-add", "Additional SMART (0xCA)", printer((*NVMeDevice).GetIntelSMARTLog)},
+		},
+	})
+	RegisterVendorPlugin(VendorPlugin{
+		Name:      "micron",
+		VendorIDs: []uint16{vidMicron},
+		Logs: []VendorLog{
+			{"smart-ext", "Extended SMART (0xC0)", printer((*NVMeDevice).GetMicronSMARTLog)},
+		},
+	})
+	RegisterVendorPlugin(VendorPlugin{
+		Name:      "wdc",
+		VendorIDs: []uint16{vidWDC, vidSanDisk},
+		Logs: []VendorLog{
+			{"smart-add", "Extended SMART (0xCA)", printer((*NVMeDevice).GetWDCSMARTLog)},
+		},
+	})
+}
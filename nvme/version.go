@@ -0,0 +1,94 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import "fmt"
+
+// Version is an NVM Express specification version, encoded as in the VER field of the identify
+// controller data structure and the VS register.
+type Version uint32
+
+// Specification versions which introduced commands or data structures not otherwise indicated by
+// a capability bit. Controllers implementing NVMe 1.4 may also support the I/O command set
+// specific identify data structures of NVMe 2.0, which were ratified as technical proposals
+// against NVMe 1.4, so those are gated on NVMe 1.4.
+const (
+	Version12 Version = 0x00010200
+	Version13 Version = 0x00010300
+	Version14 Version = 0x00010400
+	Version20 Version = 0x00020000
+)
+
+// Major returns the major version number.
+func (v Version) Major() uint16 { return uint16(v >> 16) }
+
+// Minor returns the minor version number.
+func (v Version) Minor() uint8 { return uint8(v >> 8) }
+
+// Tertiary returns the tertiary version number.
+func (v Version) Tertiary() uint8 { return uint8(v) }
+
+func (v Version) String() string {
+	// The VER field is reserved prior to NVMe 1.2
+	if v == 0 {
+		return "pre-1.2"
+	}
+
+	return fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Tertiary())
+}
+
+// Version returns the NVMe version implemented by the controller. It is taken from the VER field
+// of the identify controller data structure, or if that is not reported (i.e. prior to NVMe 1.2),
+// the VS register. If neither is available, zero is returned. The version is cached along with
+// the identify controller data.
+func (d *NVMeDevice) Version() (Version, error) {
+	if d.versionKnown {
+		return d.version, nil
+	}
+
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return 0, err
+	}
+
+	d.version = Version(idCtrlr.Ver)
+
+	// Reading the registers requires root privileges, and is only possible for PCIe controllers
+	if d.version == 0 {
+		if regs, err := d.readRegisters(); err == nil {
+			d.version = Version(reg32(regs, regVS))
+		}
+	}
+
+	d.versionKnown = true
+
+	return d.version, nil
+}
+
+// checkVersion returns an error wrapping ErrNotSupported if the controller implements a version
+// of NVMe prior to min, i.e. the version which introduced what.
+func (d *NVMeDevice) checkVersion(what string, min Version) error {
+	v, err := d.Version()
+	if err != nil {
+		return err
+	}
+
+	if v < min {
+		return fmt.Errorf("%s requires NVMe %d.%d, controller implements %s: %w", what,
+			min.Major(), min.Minor(), v, ErrNotSupported)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+)
+
+// oacsVirtMgmt is the Virtualization Management support bit of the OACS field.
+const oacsVirtMgmt = 1 << 7
+
+// VirtResource is a flexible resource type of the Virtualization Enhancements.
+type VirtResource uint8
+
+const (
+	VirtQueueResource     VirtResource = 0x0 // VQ resources (submission and completion queues)
+	VirtInterruptResource VirtResource = 0x1 // VI resources (interrupt vectors)
+)
+
+// Virtualization Management actions (ACT), cf. NVM Express Base Specification 2.0c, figure 422.
+const (
+	virtPrimaryFlexAlloc = 0x1
+	virtSecondaryOffline = 0x7
+	virtSecondaryAssign  = 0x8
+	virtSecondaryOnline  = 0x9
+)
+
+// VirtResourceCaps describes the flexible and private resources of one resource type of a
+// primary controller.
+type VirtResourceCaps struct {
+	FlexibleTotal    uint32 // Flexible resources total
+	FlexibleAssigned uint32 // Flexible resources assigned to secondary controllers
+	PrimaryAllocated uint16 // Flexible resources allocated to the primary controller
+	PrivateTotal     uint16 // Private resources of the primary controller
+	MaxPerSecondary  uint16 // Maximum flexible resources per secondary controller
+	Granularity      uint16 // Preferred granularity of flexible resource assignment
+}
+
+// PrimaryCtrlCaps is the decoded Primary Controller Capabilities data structure.
+type PrimaryCtrlCaps struct {
+	ControllerID uint16
+	PortID       uint16
+	VQSupported  bool // VQ resources are flexible
+	VISupported  bool // VI resources are flexible
+	VQ           VirtResourceCaps
+	VI           VirtResourceCaps
+}
+
+// Print outputs the primary controller capabilities in a pretty-print style.
+func (c *PrimaryCtrlCaps) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgVirtPrimary), c.ControllerID, c.PortID)
+
+	for _, r := range []struct {
+		name      string
+		supported bool
+		caps      VirtResourceCaps
+	}{{"VQ", c.VQSupported, c.VQ}, {"VI", c.VISupported, c.VI}} {
+		if r.supported {
+			fmt.Fprintf(w, msg(MsgVirtResources), r.name, r.caps.FlexibleTotal,
+				r.caps.FlexibleAssigned, r.caps.PrimaryAllocated, r.caps.PrivateTotal,
+				r.caps.MaxPerSecondary, r.caps.Granularity)
+		}
+	}
+}
+
+// GetPrimaryCtrlCaps returns the virtualization capabilities of the (primary) controller.
+func (d *NVMeDevice) GetPrimaryCtrlCaps() (*PrimaryCtrlCaps, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_PRIMARY_CTRL_CAP, 0, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmePrimaryCtrlCaps
+
+	raw.unmarshal(buf)
+
+	return &PrimaryCtrlCaps{
+		ControllerID: raw.Cntlid,
+		PortID:       raw.Portid,
+		VQSupported:  raw.Crt&(1<<0) != 0,
+		VISupported:  raw.Crt&(1<<1) != 0,
+		VQ: VirtResourceCaps{raw.Vqfrt, raw.Vqrfa, raw.Vqrfap, raw.Vqprt, raw.Vqfrsm,
+			raw.Vqgran},
+		VI: VirtResourceCaps{raw.Vifrt, raw.Virfa, raw.Virfap, raw.Viprt, raw.Vifrsm,
+			raw.Vigran},
+	}, nil
+}
+
+// SecondaryController is an entry of the secondary controller list.
+type SecondaryController struct {
+	ControllerID        uint16
+	PrimaryControllerID uint16
+	Online              bool
+	VirtualFunction     uint16 // VF number, starting at 1
+	VQ                  uint16 // Flexible VQ resources assigned
+	VI                  uint16 // Flexible VI resources assigned
+}
+
+// SecondaryControllerList is a list of secondary controllers.
+type SecondaryControllerList []SecondaryController
+
+// Print outputs the secondary controller list in a pretty-print style.
+func (l SecondaryControllerList) Print(w io.Writer) {
+	for _, c := range l {
+		fmt.Fprintf(w, msg(MsgVirtSecondary), c.ControllerID, c.VirtualFunction, c.Online, c.VQ,
+			c.VI)
+	}
+}
+
+// GetSecondaryControllers returns the secondary controllers associated with the primary
+// controller, starting at the specified controller identifier. At most 127 entries are returned.
+func (d *NVMeDevice) GetSecondaryControllers(start uint16) (SecondaryControllerList, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_SECONDARY_CTRL, 0, 0, start, buf); err != nil {
+		return nil, err
+	}
+
+	var raw nvmeSecondaryCtrlList
+
+	raw.unmarshal(buf)
+
+	n := int(raw.Numid)
+	if n > len(raw.Entries) {
+		n = len(raw.Entries)
+	}
+
+	list := make(SecondaryControllerList, n)
+
+	for i, e := range raw.Entries[:n] {
+		list[i] = SecondaryController{
+			ControllerID:        e.Scid,
+			PrimaryControllerID: e.Pcid,
+			Online:              e.Scs&1 != 0,
+			VirtualFunction:     e.Vfn,
+			VQ:                  e.Nvq,
+			VI:                  e.Nvi,
+		}
+	}
+
+	return list, nil
+}
+
+// AllocatePrimaryResources sets the number of flexible resources of the specified type to
+// allocate to the primary controller, which takes effect after the next controller level reset.
+// The number of resources modified is returned.
+func (d *NVMeDevice) AllocatePrimaryResources(cntlid uint16, rt VirtResource, n uint16) (uint16, error) {
+	return d.virtMgmt(virtPrimaryFlexAlloc, rt, cntlid, n)
+}
+
+// AssignSecondaryResources assigns flexible resources of the specified type to an offline
+// secondary controller, returning the number of resources modified.
+func (d *NVMeDevice) AssignSecondaryResources(cntlid uint16, rt VirtResource, n uint16) (uint16, error) {
+	return d.virtMgmt(virtSecondaryAssign, rt, cntlid, n)
+}
+
+// SetSecondaryOnline transitions a secondary controller online or offline. A secondary controller
+// must be offline for resources to be assigned to it, and requires both VQ and VI resources to
+// be brought online.
+func (d *NVMeDevice) SetSecondaryOnline(cntlid uint16, online bool) error {
+	act := uint8(virtSecondaryOffline)
+	if online {
+		act = virtSecondaryOnline
+	}
+
+	_, err := d.virtMgmt(act, 0, cntlid, 0)
+	return err
+}
+
+// virtMgmt issues a Virtualization Management command.
+func (d *NVMeDevice) virtMgmt(act uint8, rt VirtResource, cntlid, nr uint16) (uint16, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return 0, err
+	}
+
+	if idCtrlr.Oacs&oacsVirtMgmt == 0 {
+		return 0, fmt.Errorf("virtualization management: %w", ErrNotSupported)
+	}
+
+	cmd := nvmePassthruCommand{
+		opcode: NVME_ADMIN_VIRT_MGMT,
+		cdw10:  uint32(act&0xf) | uint32(rt&0x7)<<8 | uint32(cntlid)<<16,
+		cdw11:  uint32(nr),
+	}
+
+	err = d.adminCmd(&cmd, nil)
+	return uint16(cmd.result), err
+}
@@ -0,0 +1,123 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+const (
+	vidWDC     = 0x1b96 // PCI vendor ID of Western Digital
+	vidSanDisk = 0x15b7 // PCI vendor ID of SanDisk, acquired by Western Digital
+
+	// NVME_LOG_WDC_SMART_ADD is the vendor specific WDC extended SMART log page, referred to as
+	// the "eCA" log by WDC tooling.
+	NVME_LOG_WDC_SMART_ADD uint8 = 0xca
+)
+
+// WDCSMARTLog is the decoded WDC / SanDisk extended SMART log page (0xCA).
+type WDCSMARTLog struct {
+	NANDBytesWritten        *big.Int
+	NANDBytesRead           *big.Int
+	BadBlocks               uint64
+	UncorrectableReadErrors uint64
+	SoftECCErrors           uint64
+	E2EDetectedErrors       uint32
+	E2ECorrectedErrors      uint32
+	SystemDataPercentUsed   uint8
+	MaxEraseCount           uint32 // Maximum user data block erase count
+	MinEraseCount           uint32 // Minimum user data block erase count
+	RefreshCount            uint64
+	ProgramFailCount        uint64
+	UserEraseFailCount      uint64
+	SystemEraseFailCount    uint64
+	ThermalThrottleStatus   uint8
+	ThermalThrottleCount    uint8
+	PCIeCorrectableErrors   uint64
+	IncompleteShutdowns     uint32
+	PercentFreeBlocks       uint8
+}
+
+// Print outputs the WDC extended SMART log in a pretty-print style.
+func (l *WDCSMARTLog) Print(w io.Writer) {
+	fmt.Fprint(w, msg(MsgWDCSMARTHeader))
+	fmt.Fprintf(w, msg(MsgWDCSMARTMedia), formatBigBytes(l.NANDBytesWritten),
+		formatBigBytes(l.NANDBytesRead), l.BadBlocks)
+	fmt.Fprintf(w, msg(MsgWDCSMARTErrors), l.UncorrectableReadErrors, l.SoftECCErrors,
+		l.E2EDetectedErrors, l.E2ECorrectedErrors, l.PCIeCorrectableErrors)
+	fmt.Fprintf(w, msg(MsgWDCSMARTFailures), l.ProgramFailCount, l.UserEraseFailCount,
+		l.SystemEraseFailCount)
+	fmt.Fprintf(w, msg(MsgWDCSMARTWear), l.SystemDataPercentUsed, l.MinEraseCount,
+		l.MaxEraseCount, l.RefreshCount, l.PercentFreeBlocks)
+	fmt.Fprintf(w, msg(MsgWDCSMARTEvents), l.IncompleteShutdowns, l.ThermalThrottleCount,
+		l.ThermalThrottleStatus)
+}
+
+// isWDC reports whether the PCI vendor ID is that of Western Digital or SanDisk.
+func isWDC(vid uint16) bool {
+	return vid == vidWDC || vid == vidSanDisk
+}
+
+// GetWDCSMARTLog reads the WDC / SanDisk extended SMART log page. Since the log identifier is
+// vendor specific, ErrNotSupported is returned for devices of other vendors.
+func (d *NVMeDevice) GetWDCSMARTLog() (*WDCSMARTLog, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isWDC(idCtrlr.VendorID) {
+		return nil, fmt.Errorf("WDC extended SMART log on vendor %#04x: %w", idCtrlr.VendorID,
+			ErrNotSupported)
+	}
+
+	buf := make([]byte, 512)
+
+	if err := d.getLogPage(NVME_LOG_WDC_SMART_ADD, 0xffffffff, false, buf); err != nil {
+		return nil, err
+	}
+
+	return decodeWDCSMARTLog(buf), nil
+}
+
+func decodeWDCSMARTLog(buf []byte) *WDCSMARTLog {
+	var raw nvmeWDCSMARTLog
+
+	raw.unmarshal(buf)
+
+	return &WDCSMARTLog{
+		NANDBytesWritten:        le128ToBigInt(raw.NANDWritten),
+		NANDBytesRead:           le128ToBigInt(raw.NANDRead),
+		BadBlocks:               raw.BadBlocks,
+		UncorrectableReadErrors: raw.UncorrReads,
+		SoftECCErrors:           raw.SoftECCErrors,
+		E2EDetectedErrors:       raw.E2EDetected,
+		E2ECorrectedErrors:      raw.E2ECorrected,
+		SystemDataPercentUsed:   raw.SysDataUsed,
+		MaxEraseCount:           raw.EraseMax,
+		MinEraseCount:           raw.EraseMin,
+		RefreshCount:            raw.RefreshCount,
+		ProgramFailCount:        raw.ProgramFail,
+		UserEraseFailCount:      raw.UserEraseFail,
+		SystemEraseFailCount:    raw.SysEraseFail,
+		ThermalThrottleStatus:   raw.ThrottleStatus,
+		ThermalThrottleCount:    raw.ThrottleCount,
+		PCIeCorrectableErrors:   raw.PCIeCorrErrors,
+		IncompleteShutdowns:     raw.IncompleteShutdn,
+		PercentFreeBlocks:       raw.FreeBlocks,
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WipeCertificate records evidence that a device was sanitized, for compliance workflows which
+// require proof of erasure. The certificate is signed with an HMAC-SHA256 over its JSON encoding
+// (excluding the signature itself), using a key supplied by the caller.
+type WipeCertificate struct {
+	Device           string    `json:"device"`
+	VendorID         uint16    `json:"vendor_id"`
+	ModelNumber      string    `json:"model_number"`
+	SerialNumber     string    `json:"serial_number"`
+	FirmwareVersion  string    `json:"firmware_version"`
+	Method           string    `json:"method"`
+	OverwritePasses  uint8     `json:"overwrite_passes,omitempty"`
+	GlobalDataErased bool      `json:"global_data_erased"`
+	Started          time.Time `json:"started"`
+	Issued           time.Time `json:"issued"`
+	SanitizeLog      []byte    `json:"sanitize_log"` // Raw Sanitize Status log page
+	Signature        []byte    `json:"signature,omitempty"`
+}
+
+// WipeCertificate issues an unsigned certificate for the most recent sanitize operation, which the
+// caller started at the specified time. An error is returned if the most recent sanitize
+// operation has not completed successfully.
+func (d *NVMeDevice) WipeCertificate(started time.Time) (*WipeCertificate, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, sl, err := d.readSanitizeLog()
+	if err != nil {
+		return nil, err
+	}
+
+	switch sl.Sstat & 0x7 {
+	case sstatCompleted, sstatCompletedNoDealloc:
+	case sstatNever:
+		return nil, fmt.Errorf("device has never been sanitized")
+	case sstatInProgress:
+		return nil, fmt.Errorf("sanitize in progress, %d%% complete", uint32(sl.Sprog)*100/65536)
+	default:
+		return nil, fmt.Errorf("most recent sanitize operation failed")
+	}
+
+	c := &WipeCertificate{
+		Device:           d.Name,
+		VendorID:         idCtrlr.VendorID,
+		ModelNumber:      string(bytes.TrimSpace(idCtrlr.ModelNumber[:])),
+		SerialNumber:     string(bytes.TrimSpace(idCtrlr.SerialNumber[:])),
+		FirmwareVersion:  string(bytes.TrimSpace(idCtrlr.Firmware[:])),
+		GlobalDataErased: sl.Sstat&sstatGlobalDataErased != 0,
+		Started:          started.UTC(),
+		Issued:           time.Now().UTC(),
+		SanitizeLog:      raw,
+	}
+
+	switch sl.Scdw10 & 0x7 {
+	case sanactBlockErase:
+		c.Method = "block erase"
+	case sanactOverwrite:
+		c.Method = "overwrite"
+		c.OverwritePasses = uint8(sl.Sstat>>3) & 0x1f
+	case sanactCryptoErase:
+		c.Method = "crypto erase"
+	default:
+		return nil, fmt.Errorf("unknown sanitize action %#x", sl.Scdw10&0x7)
+	}
+
+	return c, nil
+}
+
+// payload returns the signed content of the certificate.
+func (c *WipeCertificate) payload() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = nil
+
+	return json.Marshal(&unsigned)
+}
+
+// Sign signs the certificate with an HMAC-SHA256 using the specified key.
+func (c *WipeCertificate) Sign(key []byte) error {
+	p, err := c.payload()
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(p)
+	c.Signature = mac.Sum(nil)
+
+	return nil
+}
+
+// Verify returns true if the certificate carries a valid signature for the specified key.
+func (c *WipeCertificate) Verify(key []byte) bool {
+	p, err := c.payload()
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(p)
+
+	return hmac.Equal(mac.Sum(nil), c.Signature)
+}
+
+// Print outputs the certificate in a pretty-print style.
+func (c *WipeCertificate) Print(w io.Writer) {
+	fmt.Fprintf(w, msg(MsgWipeDevice), c.Device)
+	fmt.Fprintf(w, msg(MsgCtrlModelNumber), c.ModelNumber)
+	fmt.Fprintf(w, msg(MsgCtrlSerialNumber), c.SerialNumber)
+	fmt.Fprintf(w, msg(MsgCtrlFirmwareVersion), c.FirmwareVersion)
+	fmt.Fprintf(w, msg(MsgWipeMethod), c.Method)
+
+	if c.OverwritePasses > 0 {
+		fmt.Fprintf(w, msg(MsgWipePasses), c.OverwritePasses)
+	}
+
+	fmt.Fprintf(w, msg(MsgWipeGlobalErased), c.GlobalDataErased)
+	fmt.Fprintf(w, msg(MsgWipeStarted), c.Started.Format(time.RFC3339))
+	fmt.Fprintf(w, msg(MsgWipeIssued), c.Issued.Format(time.RFC3339))
+	fmt.Fprintf(w, msg(MsgWipeSignature), c.Signature)
+}
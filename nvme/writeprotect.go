@@ -0,0 +1,121 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"fmt"
+)
+
+// WriteProtectState is the value of the Namespace Write Protection Config feature (FID 0x84).
+type WriteProtectState uint8
+
+const (
+	WriteProtectNone            WriteProtectState = 0x0
+	WriteProtectOn              WriteProtectState = 0x1
+	WriteProtectUntilPowerCycle WriteProtectState = 0x2
+	WriteProtectPermanent       WriteProtectState = 0x3
+)
+
+func (s WriteProtectState) String() string {
+	switch s {
+	case WriteProtectNone:
+		return "no write protect"
+	case WriteProtectOn:
+		return "write protect"
+	case WriteProtectUntilPowerCycle:
+		return "write protect until power cycle"
+	case WriteProtectPermanent:
+		return "permanent write protect"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(s))
+}
+
+// GetWriteProtect returns the current write protection state of the specified namespace.
+func (d *NVMeDevice) GetWriteProtect(nsid uint32) (WriteProtectState, error) {
+	if err := d.checkWriteProtect(WriteProtectOn); err != nil {
+		return 0, err
+	}
+
+	val, err := d.getFeature(NVME_FEAT_NS_WRITE_PROTECT, nsid, NVME_FEAT_SEL_CURRENT, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return WriteProtectState(val & 0x7), nil
+}
+
+// SetWriteProtect sets the write protection state of the specified namespace. Permanent write
+// protection is irreversible and is refused here; use SetPermanentWriteProtect instead.
+func (d *NVMeDevice) SetWriteProtect(nsid uint32, state WriteProtectState) error {
+	if state == WriteProtectPermanent {
+		return fmt.Errorf("permanent write protection must be set via SetPermanentWriteProtect")
+	}
+
+	return d.setWriteProtect(nsid, state)
+}
+
+// SetPermanentWriteProtect permanently write protects the specified namespace. This cannot be
+// undone, not even by a format or sanitize operation, so the namespace ID must be passed twice as
+// an explicit confirmation.
+func (d *NVMeDevice) SetPermanentWriteProtect(nsid, confirmNsid uint32) error {
+	if nsid != confirmNsid {
+		return fmt.Errorf("namespace ID confirmation mismatch (%d != %d)", nsid, confirmNsid)
+	}
+
+	return d.setWriteProtect(nsid, WriteProtectPermanent)
+}
+
+func (d *NVMeDevice) setWriteProtect(nsid uint32, state WriteProtectState) error {
+	if nsid == 0 || nsid == 0xffffffff {
+		return fmt.Errorf("invalid namespace ID %#x", nsid)
+	}
+
+	if err := d.checkWriteProtect(state); err != nil {
+		return err
+	}
+
+	// The Namespace Write Protection Config feature is not saveable.
+	_, err := d.setFeature(NVME_FEAT_NS_WRITE_PROTECT, nsid, false, uint32(state), 0, nil)
+	return err
+}
+
+// checkWriteProtect consults the controller's NWPC field to determine whether the specified write
+// protection state is supported.
+func (d *NVMeDevice) checkWriteProtect(state WriteProtectState) error {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	var mask uint8
+
+	switch state {
+	case WriteProtectNone, WriteProtectOn:
+		mask = 1 << 0
+	case WriteProtectUntilPowerCycle:
+		mask = 1 << 1
+	case WriteProtectPermanent:
+		mask = 1 << 2
+	default:
+		return fmt.Errorf("invalid write protect state %#x", uint8(state))
+	}
+
+	if idCtrlr.Nwpc&mask == 0 {
+		return fmt.Errorf("%s: %w", state, ErrNotSupported)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// WWID returns the World Wide Identifier of a namespace, in the same form as the wwid attribute
+// which the Linux kernel exposes in sysfs, and from which the /dev/disk/by-id/nvme-* links are
+// derived. The namespace UUID is preferred, followed by the NGUID and the EUI-64. If the namespace
+// reports none of these, the identifier is composed of the controller's vendor ID, serial number
+// and model number, and the namespace ID.
+func (d *NVMeDevice) WWID(nsid uint32) (string, error) {
+	idCtrlr, err := d.controller()
+	if err != nil {
+		return "", err
+	}
+
+	idNs, err := d.identifyNamespace(nsid)
+	if err != nil {
+		return "", err
+	}
+
+	// The Namespace Identification Descriptor list is optional prior to NVMe 1.3
+	var uuid string
+	if desc, err := d.GetNamespaceDescriptors(nsid); err == nil {
+		uuid = desc.UUID
+	}
+
+	return formatWWID(idCtrlr, idNs, uuid, nsid), nil
+}
+
+// formatWWID formats a namespace WWID as per the kernel's wwid_show function.
+func formatWWID(idCtrlr *nvmeIdentController, idNs *nvmeIdentNamespace, uuid string,
+	nsid uint32) string {
+	switch {
+	case uuid != "" && uuid != formatUUID(make([]byte, 16)):
+		return "uuid." + uuid
+	case idNs.Nguid != [16]byte{}:
+		return fmt.Sprintf("eui.%x", idNs.Nguid)
+	case idNs.EUI64 != [8]byte{}:
+		return fmt.Sprintf("eui.%x", idNs.EUI64)
+	}
+
+	// The serial and model numbers are formatted as hex, without trailing spaces or NULs
+	serial := bytes.TrimRight(idCtrlr.SerialNumber[:], " \x00")
+	model := bytes.TrimRight(idCtrlr.ModelNumber[:], " \x00")
+
+	return fmt.Sprintf("nvme.%04x-%x-%x-%08x", idCtrlr.VendorID, serial, model, nsid)
+}
@@ -0,0 +1,426 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// zoneReportLen is the size of the buffer used for each Zone Management Receive command, i.e.
+// the report header plus 255 zone descriptors.
+const zoneReportLen = 0x4000
+
+// ZoneState is the state of a zone, cf. NVM Express Zoned Namespace Command Set Specification
+// 1.1b, figure 37.
+type ZoneState uint8
+
+const (
+	ZoneStateEmpty            ZoneState = 0x1
+	ZoneStateImplicitlyOpened ZoneState = 0x2
+	ZoneStateExplicitlyOpened ZoneState = 0x3
+	ZoneStateClosed           ZoneState = 0x4
+	ZoneStateReadOnly         ZoneState = 0xd
+	ZoneStateFull             ZoneState = 0xe
+	ZoneStateOffline          ZoneState = 0xf
+)
+
+// zoneStates lists the zone states in the order in which they are printed.
+var zoneStates = []ZoneState{ZoneStateEmpty, ZoneStateImplicitlyOpened, ZoneStateExplicitlyOpened,
+	ZoneStateClosed, ZoneStateFull, ZoneStateReadOnly, ZoneStateOffline}
+
+func (s ZoneState) String() string {
+	switch s {
+	case ZoneStateEmpty:
+		return "empty"
+	case ZoneStateImplicitlyOpened:
+		return "implicitly opened"
+	case ZoneStateExplicitlyOpened:
+		return "explicitly opened"
+	case ZoneStateClosed:
+		return "closed"
+	case ZoneStateReadOnly:
+		return "read only"
+	case ZoneStateFull:
+		return "full"
+	case ZoneStateOffline:
+		return "offline"
+	}
+
+	return fmt.Sprintf("reserved (%#x)", uint8(s))
+}
+
+// Zone is a decoded zone descriptor.
+type Zone struct {
+	Type         uint8 // 0x2 = sequential write required
+	State        ZoneState
+	Attributes   uint8
+	Capacity     uint64 // Zone capacity, in logical blocks
+	StartLBA     uint64
+	WritePointer uint64
+}
+
+// ReportZones returns the zone descriptors of all zones of the specified zoned namespace. The
+// device must permit I/O commands (see ioCmd).
+func (d *NVMeDevice) ReportZones(nsid uint32) ([]Zone, error) {
+	ns, err := d.identifyNamespace(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	zns, err := d.identifyZNSNamespace(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, _ := ns.currentLBAF()
+
+	zsze := zns.Lbafe[idx].Zsze
+	if zsze == 0 {
+		return nil, fmt.Errorf("namespace %d: zone size not reported", nsid)
+	}
+
+	buf := make([]byte, zoneReportLen)
+
+	var zones []Zone
+
+	for slba := uint64(0); slba < ns.Nsze; {
+		cmd := nvmePassthruCommand{
+			opcode:   NVME_CMD_ZONE_MGMT_RECV,
+			nsid:     nsid,
+			addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+			data_len: uint32(len(buf)),
+			cdw10:    uint32(slba),
+			cdw11:    uint32(slba >> 32),
+			cdw12:    uint32(len(buf)/4) - 1,
+			cdw13:    1 << 16, // Report zones, all zone states, partial report
+		}
+
+		if err := d.ioCmd(&cmd, buf); err != nil {
+			return nil, err
+		}
+
+		var report nvmeZoneReport
+
+		binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &report)
+
+		n := report.NrZones
+		if n == 0 {
+			break
+		} else if n > uint64(len(report.Zones)) {
+			n = uint64(len(report.Zones))
+		}
+
+		for _, zd := range report.Zones[:n] {
+			zones = append(zones, Zone{
+				Type:         zd.Zt & 0xf,
+				State:        ZoneState(zd.Zs >> 4),
+				Attributes:   zd.Za,
+				Capacity:     zd.Zcap,
+				StartLBA:     zd.Zslba,
+				WritePointer: zd.Wp,
+			})
+		}
+
+		slba = report.Zones[n-1].Zslba + zsze
+	}
+
+	return zones, nil
+}
+
+// identifyZNSNamespace issues an Identify command for the ZNS command set specific namespace data
+// structure and returns the raw identify data.
+func (d *NVMeDevice) identifyZNSNamespace(nsid uint32) (*nvmeZNSIdentNamespace, error) {
+	if err := d.checkVersion("zoned namespace identify", Version14); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_CSI_NS, NVME_CSI_ZNS, nsid, 0, buf); err != nil {
+		return nil, err
+	}
+
+	var zns nvmeZNSIdentNamespace
+
+	binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &zns)
+
+	return &zns, nil
+}
+
+// zoneLimit converts a 0's based resource limit, with all bits set indicating no limit, to a
+// count, or 0 if unlimited.
+func zoneLimit(v uint32) uint32 {
+	if v == 0xffffffff {
+		return 0
+	}
+
+	return v + 1
+}
+
+// ZNSNamespace holds the Zoned Namespace command set specific attributes of a namespace.
+type ZNSNamespace struct {
+	ZoneSize             uint64 // In logical blocks, for the current LBA format
+	ZoneDescExtSize      uint32 // Zone descriptor extension size, in bytes
+	MaxActiveZones       uint32 // 0 if unlimited
+	MaxOpenZones         uint32 // 0 if unlimited
+	VariableCapacity     bool   // Zone capacity may change upon a zone reset
+	ActiveExcursions     bool   // Controller may transition active zones to full
+	ReadAcrossBoundaries bool   // Reads may cross zone boundaries
+	ResetRecommended     uint32 // Reset recommended limit, in seconds
+	FinishRecommended    uint32 // Finish recommended limit, in seconds
+
+	// Zone Random Write Area (ZRWA) capabilities
+	ZRWASupported        bool
+	ZRWAResources        uint64 // Number of zones which may have a ZRWA allocated
+	ZRWASize             uint16 // In logical blocks
+	ZRWAFlushGranularity uint16 // In logical blocks
+	ZRWAExplicitFlush    bool   // Explicit ZRWA flush is supported
+}
+
+// Print outputs the ZNS namespace attributes in a pretty-print style.
+func (n *ZNSNamespace) Print(w io.Writer) {
+	limit := func(n uint32) string {
+		if n == 0 {
+			return msg(MsgZoneNoLimit)
+		}
+		return fmt.Sprint(n)
+	}
+
+	fmt.Fprintf(w, msg(MsgZNSZoneSize), n.ZoneSize, n.ZoneDescExtSize)
+	fmt.Fprintf(w, msg(MsgZNSLimits), limit(n.MaxActiveZones), limit(n.MaxOpenZones))
+	fmt.Fprintf(w, msg(MsgZNSCharacteristics), n.VariableCapacity, n.ActiveExcursions,
+		n.ReadAcrossBoundaries)
+
+	if n.ZRWASupported {
+		fmt.Fprintf(w, msg(MsgZNSZRWA), n.ZRWAResources, n.ZRWASize, n.ZRWAFlushGranularity,
+			n.ZRWAExplicitFlush)
+	}
+}
+
+// IdentifyZNSNamespace returns the Zoned Namespace command set specific attributes of the
+// specified namespace.
+func (d *NVMeDevice) IdentifyZNSNamespace(nsid uint32) (*ZNSNamespace, error) {
+	ns, err := d.identifyNamespace(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	zns, err := d.identifyZNSNamespace(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, _ := ns.currentLBAF()
+
+	return zns.decode(idx), nil
+}
+
+// decode converts the raw ZNS namespace identify data into a ZNSNamespace, with the zone size of
+// the specified LBA format.
+func (zns *nvmeZNSIdentNamespace) decode(lbaf int) *ZNSNamespace {
+	n := &ZNSNamespace{
+		ZoneSize:             zns.Lbafe[lbaf].Zsze,
+		ZoneDescExtSize:      uint32(zns.Lbafe[lbaf].Zdes) * 64,
+		MaxActiveZones:       zoneLimit(zns.Mar),
+		MaxOpenZones:         zoneLimit(zns.Mor),
+		VariableCapacity:     zns.Zoc&(1<<0) != 0,
+		ActiveExcursions:     zns.Zoc&(1<<1) != 0,
+		ReadAcrossBoundaries: zns.Ozcs&(1<<0) != 0,
+		ResetRecommended:     zns.Rrl,
+		FinishRecommended:    zns.Frl,
+		ZRWASupported:        zns.Ozcs&(1<<1) != 0,
+	}
+
+	if n.ZRWASupported {
+		n.ZRWAResources = uint64(zns.Numzrwa) + 1 // 0's based
+		n.ZRWASize = zns.Zrwasz
+		n.ZRWAFlushGranularity = zns.Zrwafg
+		n.ZRWAExplicitFlush = zns.Zrwacap&1 != 0
+	}
+
+	return n
+}
+
+// ZNSController holds the Zoned Namespace command set specific attributes of a controller.
+type ZNSController struct {
+	ZoneAppendSizeLimit uint32 // Maximum Zone Append data size, in bytes, or 0 if limited by MDTS
+}
+
+// IdentifyZNSController returns the Zoned Namespace command set specific attributes of the
+// controller.
+func (d *NVMeDevice) IdentifyZNSController() (*ZNSController, error) {
+	if err := d.checkVersion("zoned namespace identify", Version14); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+
+	if err := d.identify(NVME_IDENTIFY_CNS_CSI_CTRL, NVME_CSI_ZNS, 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	c := &ZNSController{}
+
+	// ZASL is a power of two, in units of the minimum memory page size
+	if zasl := buf[0]; zasl > 0 {
+		c.ZoneAppendSizeLimit = uint32(d.minPageSize()) << zasl
+	}
+
+	return c, nil
+}
+
+// ZoneSummary summarizes the zones of a zoned namespace.
+type ZoneSummary struct {
+	Zones     uint64
+	ByState   map[ZoneState]uint64
+	Active    uint64 // Zones in the opened or closed states
+	Open      uint64 // Zones in the opened states
+	MaxActive uint32 // Maximum active resources, 0 if unlimited
+	MaxOpen   uint32 // Maximum open resources, 0 if unlimited
+	Capacity  uint64 // Sum of writable zone capacities, in logical blocks
+	Used      uint64 // Logical blocks written to writable zones
+}
+
+// Print outputs the zone summary in a pretty-print style.
+func (s *ZoneSummary) Print(w io.Writer) {
+	limit := func(n uint32) string {
+		if n == 0 {
+			return msg(MsgZoneNoLimit)
+		}
+		return fmt.Sprint(n)
+	}
+
+	fmt.Fprintf(w, msg(MsgZoneCount), s.Zones)
+
+	for _, state := range zoneStates {
+		if n := s.ByState[state]; n > 0 {
+			fmt.Fprintf(w, msg(MsgZoneState), state, n)
+		}
+	}
+
+	fmt.Fprintf(w, msg(MsgZoneActive), s.Active, limit(s.MaxActive))
+	fmt.Fprintf(w, msg(MsgZoneOpen), s.Open, limit(s.MaxOpen))
+
+	var pct float64
+	if s.Capacity > 0 {
+		pct = float64(s.Used) / float64(s.Capacity) * 100
+	}
+
+	fmt.Fprintf(w, msg(MsgZoneUtilisation), s.Used, s.Capacity, pct)
+}
+
+// GetZoneSummary reports the zones of the specified zoned namespace and summarizes them.
+func (d *NVMeDevice) GetZoneSummary(nsid uint32) (*ZoneSummary, error) {
+	zns, err := d.identifyZNSNamespace(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := d.ReportZones(nsid)
+	if err != nil {
+		return nil, err
+	}
+
+	s := summarizeZones(zones)
+
+	s.MaxActive, s.MaxOpen = zoneLimit(zns.Mar), zoneLimit(zns.Mor)
+
+	return s, nil
+}
+
+func summarizeZones(zones []Zone) *ZoneSummary {
+	s := &ZoneSummary{Zones: uint64(len(zones)), ByState: make(map[ZoneState]uint64)}
+
+	for _, z := range zones {
+		s.ByState[z.State]++
+
+		switch z.State {
+		case ZoneStateImplicitlyOpened, ZoneStateExplicitlyOpened:
+			s.Open++
+			s.Active++
+		case ZoneStateClosed:
+			s.Active++
+		case ZoneStateReadOnly, ZoneStateOffline:
+			// Not writable, so not counted towards capacity
+			continue
+		}
+
+		s.Capacity += z.Capacity
+
+		if z.State == ZoneStateFull {
+			s.Used += z.Capacity
+		} else if z.WritePointer > z.StartLBA {
+			s.Used += z.WritePointer - z.StartLBA
+		}
+	}
+
+	return s
+}
+
+// nvmeZoneDescriptor is the low-level struct of a Zone Descriptor data structure.
+type nvmeZoneDescriptor struct {
+	Zt     uint8  // Zone Type
+	Zs     uint8  // Zone State
+	Za     uint8  // Zone Attributes
+	Zai    uint8  // Zone Attributes Information
+	Rsvd4  uint32 // ...
+	Zcap   uint64 // Zone Capacity
+	Zslba  uint64 // Zone Start Logical Block Address
+	Wp     uint64 // Write Pointer
+	Rsvd32 [32]byte
+} // 64 bytes
+
+// nvmeZoneReport is the low-level struct of a Report Zones data structure, sized to match
+// zoneReportLen.
+type nvmeZoneReport struct {
+	NrZones uint64 // Number of Zones
+	Rsvd8   [56]byte
+	Zones   [zoneReportLen/64 - 1]nvmeZoneDescriptor
+}
+
+// nvmeZNSLBAFE is the low-level struct of a ZNS LBA Format Extension data structure.
+type nvmeZNSLBAFE struct {
+	Zsze  uint64  // Zone Size
+	Zdes  uint8   // Zone Descriptor Extension Size
+	Rsvd9 [7]byte // ...
+} // 16 bytes
+
+// nvmeZNSIdentNamespace is the low-level struct of the I/O Command Set specific Identify
+// Namespace data structure for the Zoned Namespace command set.
+type nvmeZNSIdentNamespace struct {
+	Zoc     uint16           // Zone Operation Characteristics
+	Ozcs    uint16           // Optional Zoned Command Support
+	Mar     uint32           // Maximum Active Resources
+	Mor     uint32           // Maximum Open Resources
+	Rrl     uint32           // Reset Recommended Limit
+	Frl     uint32           // Finish Recommended Limit
+	Rrl1    uint32           // Reset Recommended Limit 1
+	Rrl2    uint32           // Reset Recommended Limit 2
+	Rrl3    uint32           // Reset Recommended Limit 3
+	Frl1    uint32           // Finish Recommended Limit 1
+	Frl2    uint32           // Finish Recommended Limit 2
+	Frl3    uint32           // Finish Recommended Limit 3
+	Numzrwa uint32           // Number of ZRWA Resources
+	Zrwafg  uint16           // ZRWA Flush Granularity
+	Zrwasz  uint16           // ZRWA Size
+	Zrwacap uint8            // ZRWA Capability
+	Rsvd53  [2763]byte       // ...
+	Lbafe   [64]nvmeZNSLBAFE // LBA Format Extensions
+	Vs      [256]byte        // Vendor Specific
+} // 4096 bytes
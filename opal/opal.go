@@ -0,0 +1,163 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package opal implements the TCG Storage core session and method protocol over the Security Send
+// and Security Receive commands, for managing self-encrypting drives which implement the Opal
+// family of Security Subsystem Classes.
+package opal
+
+import (
+	"fmt"
+)
+
+// Transport is the interface to a drive's Security Send and Security Receive commands, which is
+// satisfied by *nvme.NVMeDevice.
+type Transport interface {
+	SecuritySend(nsid uint32, secp uint8, spsp uint16, nssf uint8, data []byte) error
+	SecurityReceive(nsid uint32, secp uint8, spsp uint16, nssf uint8, buf []byte) error
+}
+
+// securityProtocol is the security protocol used for TCG sessions.
+const securityProtocol = 0x01
+
+// UID is the 8-byte unique identifier of a TCG object or method.
+type UID [8]byte
+
+// uid returns the UID with the specified (big-endian) value.
+func uid(v uint64) UID {
+	var u UID
+
+	for i := range u {
+		u[i] = byte(v >> (56 - 8*i))
+	}
+
+	return u
+}
+
+// Well-known UIDs, cf. TCG Storage Security Subsystem Class: Opal 2.01, section 6.
+var (
+	UIDSessionManager = uid(0x00000000000000ff)
+	UIDThisSP         = uid(0x0000000000000001)
+	UIDAdminSP        = uid(0x0000020500000001)
+	UIDLockingSP      = uid(0x0000020500000002)
+
+	UIDAnybody = uid(0x0000000900000001)
+	UIDSID     = uid(0x0000000900000006)
+	UIDPSID    = uid(0x000000090001ff01)
+	UIDAdmin1  = uid(0x0000000900010001)
+
+	UIDCPinSID    = uid(0x0000000b00000001)
+	UIDCPinMSID   = uid(0x0000000b00008402)
+	UIDCPinAdmin1 = uid(0x0000000b00010001)
+
+	UIDLockingGlobalRange = uid(0x0000080200000001)
+)
+
+// Method UIDs.
+var (
+	methodStartSession = uid(0x000000000000ff02)
+	methodSyncSession  = uid(0x000000000000ff03)
+	methodGet          = uid(0x0000000600000016)
+	methodSet          = uid(0x0000000600000017)
+	methodRevert       = uid(0x0000000600000202)
+	methodActivate     = uid(0x0000000600000203)
+)
+
+// Column numbers.
+const (
+	colPIN = 3 // C_PIN table
+
+	colRangeStart       = 3 // Locking table
+	colRangeLength      = 4
+	colReadLockEnabled  = 5
+	colWriteLockEnabled = 6
+	colReadLocked       = 7
+	colWriteLocked      = 8
+)
+
+// LockingRangeUID returns the UID of the specified locking range, where range 0 is the global
+// range.
+func LockingRangeUID(n uint) UID {
+	if n == 0 {
+		return UIDLockingGlobalRange
+	}
+
+	return uid(0x0000080200030000 + uint64(n))
+}
+
+// MethodStatus is the status code of a method invocation, cf. TCG Storage Architecture Core
+// Specification 2.01, section 5.1.5.
+type MethodStatus uint8
+
+const (
+	StatusSuccess             MethodStatus = 0x00
+	StatusNotAuthorized       MethodStatus = 0x01
+	StatusSPBusy              MethodStatus = 0x03
+	StatusSPFailed            MethodStatus = 0x04
+	StatusSPDisabled          MethodStatus = 0x05
+	StatusSPFrozen            MethodStatus = 0x06
+	StatusNoSessionsAvailable MethodStatus = 0x07
+	StatusUniquenessConflict  MethodStatus = 0x08
+	StatusInsufficientSpace   MethodStatus = 0x09
+	StatusInsufficientRows    MethodStatus = 0x0a
+	StatusInvalidParameter    MethodStatus = 0x0c
+	StatusTPerMalfunction     MethodStatus = 0x0f
+	StatusTransactionFailure  MethodStatus = 0x10
+	StatusResponseOverflow    MethodStatus = 0x11
+	StatusAuthorityLockedOut  MethodStatus = 0x12
+	StatusFail                MethodStatus = 0x3f
+)
+
+func (s MethodStatus) Error() string {
+	var desc string
+
+	switch s {
+	case StatusSuccess:
+		desc = "success"
+	case StatusNotAuthorized:
+		desc = "not authorized"
+	case StatusSPBusy:
+		desc = "SP busy"
+	case StatusSPFailed:
+		desc = "SP failed"
+	case StatusSPDisabled:
+		desc = "SP disabled"
+	case StatusSPFrozen:
+		desc = "SP frozen"
+	case StatusNoSessionsAvailable:
+		desc = "no sessions available"
+	case StatusUniquenessConflict:
+		desc = "uniqueness conflict"
+	case StatusInsufficientSpace:
+		desc = "insufficient space"
+	case StatusInsufficientRows:
+		desc = "insufficient rows"
+	case StatusInvalidParameter:
+		desc = "invalid parameter"
+	case StatusTPerMalfunction:
+		desc = "TPer malfunction"
+	case StatusTransactionFailure:
+		desc = "transaction failure"
+	case StatusResponseOverflow:
+		desc = "response overflow"
+	case StatusAuthorityLockedOut:
+		desc = "authority locked out"
+	case StatusFail:
+		desc = "fail"
+	default:
+		desc = "reserved"
+	}
+
+	return fmt.Sprintf("TCG method status %#02x: %s", uint8(s), desc)
+}
@@ -0,0 +1,144 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens(t *testing.T) {
+	assert := assert.New(t)
+
+	e := new(encoder).
+		ctrl(tokStartList).
+		uint(5).uint(0x1234).
+		bytes([]byte("abc")).bytes(make([]byte, 0x20)).
+		named(3, true).
+		ctrl(tokEndList)
+
+	assert.Equal([]byte{0xf0, 0x05, 0x82, 0x12, 0x34, 0xa3, 'a', 'b', 'c', 0xd0, 0x20}, e.buf[:11])
+
+	toks, err := decodeTokens(append(e.buf, tokEmpty))
+	if assert.NoError(err) {
+		assert.Len(toks, 10)
+		assert.Equal(uint64(0x1234), toks[2].num)
+		assert.Equal([]byte("abc"), toks[3].data)
+		assert.Len(toks[4].data, 0x20)
+		assert.Equal(map[uint64]token{3: {num: 1}}, namedValues(toks))
+	}
+
+	_, err = decodeTokens([]byte{0xa3, 'a'})
+	assert.Error(err)
+}
+
+// fakeTPer is a minimal TPer which supports sessions, and Get and Set of C_PIN PINs.
+type fakeTPer struct {
+	pins     map[UID][]byte
+	response []byte
+	calls    []UID // Methods invoked
+}
+
+func (f *fakeTPer) SecuritySend(nsid uint32, secp uint8, spsp uint16, nssf uint8, data []byte) error {
+	sub := data[comPacketHeaderLen+packetHeaderLen:]
+	n := binary.BigEndian.Uint32(sub[8:])
+
+	toks, err := decodeTokens(sub[subPacketHeaderLen : subPacketHeaderLen+n])
+	if err != nil {
+		return err
+	}
+
+	status := StatusSuccess
+	resp := new(encoder)
+
+	if toks[0].ctrl == tokEndOfSession {
+		f.response = []byte{tokEndOfSession}
+		return nil
+	}
+
+	var object, method UID
+	copy(object[:], toks[1].data)
+	copy(method[:], toks[2].data)
+	f.calls = append(f.calls, method)
+
+	switch method {
+	case methodStartSession:
+		// Authenticate the HostSigningAuthority with the HostChallenge, if any
+		named := namedValues(toks)
+		if auth, ok := named[3]; ok {
+			var a UID
+			copy(a[:], auth.data)
+			if !bytes.Equal(f.pins[a], named[0].data) {
+				status = StatusNotAuthorized
+			}
+		}
+
+		resp.ctrl(tokCall).uid(UIDSessionManager).uid(methodSyncSession).
+			ctrl(tokStartList).uint(hostSessionID).uint(0x1001).ctrl(tokEndList)
+	case methodGet:
+		resp.ctrl(tokStartList).ctrl(tokStartList).
+			named(colPIN, f.pins[UIDAdmin1]).
+			ctrl(tokEndList).ctrl(tokEndList)
+	case methodSet:
+		f.pins[UIDAdmin1] = namedValues(toks)[colPIN].data
+		resp.ctrl(tokStartList).ctrl(tokEndList)
+	}
+
+	resp.ctrl(tokEndOfData).ctrl(tokStartList).uint(uint64(status)).uint(0).uint(0).ctrl(tokEndList)
+	f.response = resp.buf
+
+	return nil
+}
+
+func (f *fakeTPer) SecurityReceive(nsid uint32, secp uint8, spsp uint16, nssf uint8, buf []byte) error {
+	binary.BigEndian.PutUint32(buf[16:], uint32(packetHeaderLen+subPacketHeaderLen+len(f.response)))
+
+	sub := buf[comPacketHeaderLen+packetHeaderLen:]
+	binary.BigEndian.PutUint32(sub[8:], uint32(len(f.response)))
+	copy(sub[subPacketHeaderLen:], f.response)
+
+	return nil
+}
+
+func TestSession(t *testing.T) {
+	assert := assert.New(t)
+
+	// For simplicity, the fake TPer stores all PINs under the Admin1 UID
+	f := &fakeTPer{pins: map[UID][]byte{UIDAdmin1: []byte("old")}}
+	d := NewDrive(f, 0x1000)
+
+	s, err := d.StartSession(UIDLockingSP, UIDAdmin1, []byte("old"))
+	if assert.NoError(err) {
+		assert.Equal(uint32(0x1001), s.tsn)
+		assert.Equal(uint32(hostSessionID), s.hsn)
+		assert.NoError(s.Close())
+	}
+
+	assert.NoError(d.SetAdmin1PIN([]byte("old"), []byte("new")))
+	assert.Equal([]byte("new"), f.pins[UIDAdmin1])
+
+	err = d.SetAdmin1PIN([]byte("old"), []byte("other"))
+	assert.Equal(StatusNotAuthorized, err)
+
+	f.calls = nil
+	msid, err := d.MSID()
+	if assert.NoError(err) {
+		assert.Equal([]byte("new"), msid)
+		assert.Equal([]UID{methodStartSession, methodGet}, f.calls)
+	}
+}
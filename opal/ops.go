@@ -0,0 +1,134 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opal
+
+import (
+	"fmt"
+)
+
+// withSession runs fn in a session with the specified security provider and authority, closing
+// the session afterwards.
+func (d *Drive) withSession(sp, authority UID, pin []byte, fn func(s *Session) error) error {
+	s, err := d.StartSession(sp, authority, pin)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(s); err != nil {
+		s.Close()
+		return err
+	}
+
+	return s.Close()
+}
+
+// MSID returns the manufactured SID PIN, which is the initial SID PIN of a drive that has not yet
+// been taken ownership of.
+func (d *Drive) MSID() ([]byte, error) {
+	var msid []byte
+
+	err := d.withSession(UIDAdminSP, UID{}, nil, func(s *Session) error {
+		cols, err := s.Get(UIDCPinMSID, colPIN, colPIN)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if msid, ok = cols[colPIN]; !ok {
+			return fmt.Errorf("MSID PIN not returned")
+		}
+
+		return nil
+	})
+
+	return msid, err
+}
+
+// TakeOwnership sets the SID PIN of a drive whose SID PIN is still the MSID.
+func (d *Drive) TakeOwnership(sidPin []byte) error {
+	msid, err := d.MSID()
+	if err != nil {
+		return err
+	}
+
+	return d.withSession(UIDAdminSP, UIDSID, msid, func(s *Session) error {
+		return s.set(UIDCPinSID, func(e *encoder) { e.named(colPIN, sidPin) })
+	})
+}
+
+// ActivateLockingSP activates the Locking SP. Upon activation, the Admin1 PIN of the Locking SP
+// is set to the SID PIN.
+func (d *Drive) ActivateLockingSP(sidPin []byte) error {
+	return d.withSession(UIDAdminSP, UIDSID, sidPin, func(s *Session) error {
+		_, err := s.call(UIDLockingSP, methodActivate, nil)
+		return err
+	})
+}
+
+// SetAdmin1PIN changes the Admin1 PIN of the Locking SP.
+func (d *Drive) SetAdmin1PIN(oldPin, newPin []byte) error {
+	return d.withSession(UIDLockingSP, UIDAdmin1, oldPin, func(s *Session) error {
+		return s.set(UIDCPinAdmin1, func(e *encoder) { e.named(colPIN, newPin) })
+	})
+}
+
+// ConfigureLockingRange sets the extent of the specified locking range, in logical blocks, and
+// enables read and write locking for it. The extent of the global range (0) cannot be changed.
+func (d *Drive) ConfigureLockingRange(admin1Pin []byte, rng uint, start, length uint64) error {
+	return d.withSession(UIDLockingSP, UIDAdmin1, admin1Pin, func(s *Session) error {
+		return s.set(LockingRangeUID(rng), func(e *encoder) {
+			if rng != 0 {
+				e.named(colRangeStart, start).named(colRangeLength, length)
+			}
+			e.named(colReadLockEnabled, true).named(colWriteLockEnabled, true)
+		})
+	})
+}
+
+// SetLockingRange locks or unlocks the specified locking range for both reading and writing.
+func (d *Drive) SetLockingRange(admin1Pin []byte, rng uint, locked bool) error {
+	return d.withSession(UIDLockingSP, UIDAdmin1, admin1Pin, func(s *Session) error {
+		return s.set(LockingRangeUID(rng), func(e *encoder) {
+			e.named(colReadLocked, locked).named(colWriteLocked, locked)
+		})
+	})
+}
+
+// RevertTPer reverts the TPer to its factory state, authenticating as SID. This irrecoverably
+// erases all data in locking ranges.
+func (d *Drive) RevertTPer(sidPin []byte) error {
+	return d.revert(UIDSID, sidPin)
+}
+
+// RevertTPerPSID reverts the TPer to its factory state, authenticating with the PSID printed on
+// the drive's label. This irrecoverably erases all data in locking ranges.
+func (d *Drive) RevertTPerPSID(psid []byte) error {
+	return d.revert(UIDPSID, psid)
+}
+
+func (d *Drive) revert(authority UID, pin []byte) error {
+	s, err := d.StartSession(UIDAdminSP, authority, pin)
+	if err != nil {
+		return err
+	}
+
+	// The TPer closes the session upon successful completion of Revert
+	if _, err := s.call(UIDAdminSP, methodRevert, nil); err != nil && err != ErrSessionClosed {
+		s.Close()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Header lengths of the ComPacket, Packet and Data SubPacket, cf. TCG Storage Architecture Core
+// Specification 2.01, section 3.2.3.
+const (
+	comPacketHeaderLen = 20
+	packetHeaderLen    = 24
+	subPacketHeaderLen = 12
+)
+
+// hostSessionID is the host session number used for all sessions.
+const hostSessionID = 0x69
+
+var (
+	// recvBufLen is the allocation length for Security Receive, which is the minimum
+	// MaxComPacketSize a TPer must support.
+	recvBufLen = 2048
+
+	// pollInterval and pollTimeout control how long to wait for the TPer to prepare a response.
+	pollInterval = 10 * time.Millisecond
+	pollTimeout  = 30 * time.Second
+)
+
+// ErrSessionClosed is returned when the TPer closes a session, e.g. following a Revert.
+var ErrSessionClosed = errors.New("session closed by TPer")
+
+// Drive is a TCG storage device, addressed by the base ComID reported by Level 0 Discovery (see
+// (*nvme.NVMeDevice).OpalDiscovery).
+type Drive struct {
+	t     Transport
+	comID uint16
+}
+
+// NewDrive returns a Drive which communicates via the specified transport and ComID.
+func NewDrive(t Transport, comID uint16) *Drive {
+	return &Drive{t: t, comID: comID}
+}
+
+// Session is an open session with a security provider.
+type Session struct {
+	d   *Drive
+	tsn uint32 // TPer session number
+	hsn uint32 // Host session number
+}
+
+// StartSession opens a read/write session with the specified security provider. If authority is
+// non-zero, the session is authenticated as that authority with the specified PIN; otherwise the
+// session is opened as the Anybody authority.
+func (d *Drive) StartSession(sp, authority UID, pin []byte) (*Session, error) {
+	sm := &Session{d: d}
+
+	e := new(encoder).
+		ctrl(tokCall).uid(UIDSessionManager).uid(methodStartSession).
+		ctrl(tokStartList).uint(hostSessionID).uid(sp).bool(true)
+
+	if authority != (UID{}) {
+		e.named(0, pin).named(3, authority) // HostChallenge, HostSigningAuthority
+	}
+
+	e.ctrl(tokEndList).ctrl(tokEndOfData).
+		ctrl(tokStartList).uint(0).uint(0).uint(0).ctrl(tokEndList)
+
+	toks, err := sm.exchange(e.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expect SyncSession: Call, SMUID, SyncSession UID, [HostSessionID, SPSessionID, ...]
+	if len(toks) < 6 || toks[0].ctrl != tokCall || toks[3].ctrl != tokStartList ||
+		string(toks[2].data) != string(methodSyncSession[:]) {
+		return nil, fmt.Errorf("unexpected response to StartSession")
+	}
+
+	return &Session{d: d, hsn: uint32(toks[4].num), tsn: uint32(toks[5].num)}, nil
+}
+
+// Close ends the session.
+func (s *Session) Close() error {
+	if err := s.send([]byte{tokEndOfSession}); err != nil {
+		return err
+	}
+
+	_, err := s.recv()
+	return err
+}
+
+// call invokes a method on the specified object with the (encoded) arguments, returning the
+// tokens of the method's result list.
+func (s *Session) call(object, method UID, args []byte) ([]token, error) {
+	e := new(encoder).ctrl(tokCall).uid(object).uid(method).ctrl(tokStartList)
+	e.buf = append(e.buf, args...)
+	e.ctrl(tokEndList).ctrl(tokEndOfData).
+		ctrl(tokStartList).uint(0).uint(0).uint(0).ctrl(tokEndList)
+
+	return s.exchange(e.buf)
+}
+
+// exchange sends a method invocation and returns the tokens of the response, preceding the
+// EndOfData token. A non-zero method status is returned as a MethodStatus error.
+func (s *Session) exchange(payload []byte) ([]token, error) {
+	if err := s.send(payload); err != nil {
+		return nil, err
+	}
+
+	toks, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(toks) > 0 && toks[0].ctrl == tokEndOfSession {
+		return nil, ErrSessionClosed
+	}
+
+	for i, t := range toks {
+		if t.ctrl != tokEndOfData {
+			continue
+		}
+
+		// Method status list: StartList, status, 0, 0, EndList
+		if i+2 >= len(toks) || toks[i+1].ctrl != tokStartList {
+			return nil, fmt.Errorf("missing method status list")
+		}
+
+		if status := MethodStatus(toks[i+2].num); status != StatusSuccess {
+			return nil, status
+		}
+
+		return toks[:i], nil
+	}
+
+	return nil, fmt.Errorf("missing end of data token")
+}
+
+// send wraps a data stream in a ComPacket and sends it to the TPer.
+func (s *Session) send(payload []byte) error {
+	// Data SubPacket payloads are padded to a multiple of 4 bytes
+	subLen := subPacketHeaderLen + (len(payload)+3)&^3
+	pktLen := packetHeaderLen + subLen
+	comLen := comPacketHeaderLen + pktLen
+
+	// Pad the transfer to a multiple of 512 bytes, as some TPers require
+	buf := make([]byte, (comLen+511)&^511)
+
+	binary.BigEndian.PutUint16(buf[4:], s.d.comID)
+	binary.BigEndian.PutUint32(buf[16:], uint32(pktLen))
+
+	pkt := buf[comPacketHeaderLen:]
+	binary.BigEndian.PutUint32(pkt[0:], s.tsn)
+	binary.BigEndian.PutUint32(pkt[4:], s.hsn)
+	binary.BigEndian.PutUint32(pkt[20:], uint32(subLen))
+
+	sub := pkt[packetHeaderLen:]
+	binary.BigEndian.PutUint32(sub[8:], uint32(len(payload)))
+	copy(sub[subPacketHeaderLen:], payload)
+
+	return s.d.t.SecuritySend(0, securityProtocol, s.d.comID, 0, buf)
+}
+
+// recv polls the TPer for a response ComPacket, and decodes the data stream of its first Data
+// SubPacket.
+func (s *Session) recv() ([]token, error) {
+	buf := make([]byte, recvBufLen)
+
+	for deadline := time.Now().Add(pollTimeout); ; {
+		if err := s.d.t.SecurityReceive(0, securityProtocol, s.d.comID, 0, buf); err != nil {
+			return nil, err
+		}
+
+		outstanding := binary.BigEndian.Uint32(buf[8:])
+		length := binary.BigEndian.Uint32(buf[16:])
+
+		if length > 0 {
+			break
+		} else if outstanding == 0 {
+			return nil, fmt.Errorf("empty response from TPer")
+		} else if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for TPer response")
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	if binary.BigEndian.Uint32(buf[16:]) > uint32(len(buf)-comPacketHeaderLen) {
+		return nil, fmt.Errorf("response exceeds receive buffer")
+	}
+
+	sub := buf[comPacketHeaderLen+packetHeaderLen:]
+	n := binary.BigEndian.Uint32(sub[8:])
+
+	if n > uint32(len(sub)-subPacketHeaderLen) {
+		return nil, fmt.Errorf("invalid subpacket length %d", n)
+	}
+
+	return decodeTokens(sub[subPacketHeaderLen : subPacketHeaderLen+n])
+}
+
+// Get returns the values of the specified range of columns of a table row.
+func (s *Session) Get(object UID, startCol, endCol uint64) (map[uint64][]byte, error) {
+	args := new(encoder).ctrl(tokStartList).
+		named(3, startCol).named(4, endCol). // startColumn, endColumn
+		ctrl(tokEndList)
+
+	toks, err := s.call(object, methodGet, args.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make(map[uint64][]byte)
+
+	for col, t := range namedValues(toks) {
+		if t.isBytes {
+			cols[col] = t.data
+		} else {
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], t.num)
+			cols[col] = b[:]
+		}
+	}
+
+	return cols, nil
+}
+
+// set invokes the Set method to set the specified column values of a table row.
+func (s *Session) set(object UID, values func(e *encoder)) error {
+	e := new(encoder).ctrl(tokStartName).uint(1).ctrl(tokStartList) // Values
+	values(e)
+	e.ctrl(tokEndList).ctrl(tokEndName)
+
+	_, err := s.call(object, methodSet, e.buf)
+	return err
+}
@@ -0,0 +1,206 @@
+// Copyright 2017-2022 Daniel Swarbrick. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opal
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Control tokens, cf. TCG Storage Architecture Core Specification 2.01, section 3.2.2.3.1.
+const (
+	tokStartList        = 0xf0
+	tokEndList          = 0xf1
+	tokStartName        = 0xf2
+	tokEndName          = 0xf3
+	tokCall             = 0xf8
+	tokEndOfData        = 0xf9
+	tokEndOfSession     = 0xfa
+	tokStartTransaction = 0xfb
+	tokEndTransaction   = 0xfc
+	tokEmpty            = 0xff
+)
+
+// token is a decoded token of a TCG data stream. Control tokens have a non-zero ctrl value, atoms
+// are either byte sequences or unsigned integers.
+type token struct {
+	ctrl    byte
+	isBytes bool
+	data    []byte
+	num     uint64
+}
+
+// encoder builds a TCG data stream.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) ctrl(t byte) *encoder {
+	e.buf = append(e.buf, t)
+	return e
+}
+
+// uint encodes an unsigned integer as a tiny atom if possible, otherwise a short atom.
+func (e *encoder) uint(v uint64) *encoder {
+	if v < 0x40 {
+		e.buf = append(e.buf, byte(v))
+		return e
+	}
+
+	var b [8]byte
+
+	binary.BigEndian.PutUint64(b[:], v)
+
+	n := 8
+	for n > 1 && b[8-n] == 0 {
+		n--
+	}
+
+	e.buf = append(e.buf, 0x80|byte(n))
+	e.buf = append(e.buf, b[8-n:]...)
+
+	return e
+}
+
+// bool encodes a boolean as an unsigned integer.
+func (e *encoder) bool(v bool) *encoder {
+	if v {
+		return e.uint(1)
+	}
+	return e.uint(0)
+}
+
+// bytes encodes a byte sequence as a short, medium or long atom, depending on its length.
+func (e *encoder) bytes(b []byte) *encoder {
+	switch n := len(b); {
+	case n < 0x10:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n < 0x800:
+		e.buf = append(e.buf, 0xd0|byte(n>>8), byte(n))
+	default:
+		e.buf = append(e.buf, 0xe2, byte(n>>16), byte(n>>8), byte(n))
+	}
+
+	e.buf = append(e.buf, b...)
+
+	return e
+}
+
+func (e *encoder) uid(u UID) *encoder {
+	return e.bytes(u[:])
+}
+
+// named encodes a name / value pair with an unsigned integer name. The value must be a uint64,
+// bool, []byte or UID.
+func (e *encoder) named(name uint64, value interface{}) *encoder {
+	e.ctrl(tokStartName).uint(name)
+
+	switch v := value.(type) {
+	case uint64:
+		e.uint(v)
+	case bool:
+		e.bool(v)
+	case []byte:
+		e.bytes(v)
+	case UID:
+		e.uid(v)
+	default:
+		panic(fmt.Sprintf("unsupported value type %T", value))
+	}
+
+	return e.ctrl(tokEndName)
+}
+
+// decodeTokens decodes a TCG data stream. Empty tokens, which may be used as padding, are
+// discarded.
+func decodeTokens(b []byte) ([]token, error) {
+	var toks []token
+
+	for i := 0; i < len(b); {
+		t := b[i]
+
+		var hdr, n int
+		var isBytes bool
+
+		switch {
+		case t < 0x80: // Tiny atom (signed tiny atoms are not used by this package)
+			toks = append(toks, token{num: uint64(t & 0x3f)})
+			i++
+			continue
+		case t < 0xc0: // Short atom
+			hdr, n, isBytes = 1, int(t&0x0f), t&0x20 != 0
+		case t < 0xe0: // Medium atom
+			if i+1 >= len(b) {
+				return nil, fmt.Errorf("truncated medium atom at offset %d", i)
+			}
+			hdr, n, isBytes = 2, int(t&0x07)<<8|int(b[i+1]), t&0x10 != 0
+		case t < 0xe4: // Long atom
+			if i+3 >= len(b) {
+				return nil, fmt.Errorf("truncated long atom at offset %d", i)
+			}
+			hdr, n, isBytes = 4, int(b[i+1])<<16|int(b[i+2])<<8|int(b[i+3]), t&0x02 != 0
+		case t >= tokStartList:
+			if t != tokEmpty {
+				toks = append(toks, token{ctrl: t})
+			}
+			i++
+			continue
+		default:
+			return nil, fmt.Errorf("reserved token %#02x at offset %d", t, i)
+		}
+
+		if i+hdr+n > len(b) {
+			return nil, fmt.Errorf("truncated atom at offset %d", i)
+		}
+
+		data := b[i+hdr : i+hdr+n]
+
+		if isBytes {
+			toks = append(toks, token{isBytes: true, data: data})
+		} else {
+			if n > 8 {
+				return nil, fmt.Errorf("integer atom too long at offset %d", i)
+			}
+
+			var v uint64
+			for _, c := range data {
+				v = v<<8 | uint64(c)
+			}
+
+			toks = append(toks, token{num: v})
+		}
+
+		i += hdr + n
+	}
+
+	return toks, nil
+}
+
+// namedValues returns the name / value pairs with unsigned integer names in a token stream, e.g.
+// the columns returned by the Get method. Values which are not atoms are ignored.
+func namedValues(toks []token) map[uint64]token {
+	m := make(map[uint64]token)
+
+	for i := 0; i+3 < len(toks); i++ {
+		if toks[i].ctrl == tokStartName && toks[i+1].ctrl == 0 && !toks[i+1].isBytes &&
+			toks[i+2].ctrl == 0 && toks[i+3].ctrl == tokEndName {
+
+			m[toks[i+1].num] = toks[i+2]
+			i += 3
+		}
+	}
+
+	return m
+}